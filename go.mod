@@ -6,11 +6,9 @@ toolchain go1.23.2
 
 require (
 	github.com/youmark/pkcs8 v0.0.0-20240726163527-a2c0da244d78
+	golang.org/x/sys v0.31.0
 	golang.org/x/term v0.30.0
 	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
-require (
-	golang.org/x/crypto v0.36.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
-)
+require golang.org/x/crypto v0.36.0 // indirect
@@ -0,0 +1,169 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"errors"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type accountResponse struct {
+	Status               string   `json:"status"`
+	Contact              []string `json:"contact,omitempty"`
+	TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed,omitempty"`
+	Orders               string   `json:"orders"`
+}
+
+func (s *Server) accountURL(acct *Account) string {
+	return s.url(accountPathPrefix + acct.ID)
+}
+
+func (s *Server) writeAccount(w http.ResponseWriter, status int, acct *Account) {
+	w.Header().Set("Location", s.accountURL(acct))
+	writeJSON(w, status, &accountResponse{
+		Status:               acct.Status,
+		Contact:              acct.Contact,
+		TermsOfServiceAgreed: acct.TermsOfServiceAgreed,
+		Orders:               s.accountURL(acct) + "/orders",
+	})
+}
+
+// checkContact 只支持mailto联系方式
+func checkContact(contact []string) *Problem {
+	for _, c := range contact {
+		email, ok := strings.CutPrefix(c, "mailto:")
+		if !ok {
+			return NewProblem(ErrorUnsupportedContact, http.StatusBadRequest, "only mailto contacts are supported: %s", c)
+		} else if !utils.IsValidEmail(email) {
+			return NewProblem(ErrorInvalidContact, http.StatusBadRequest, "not a valid email address: %s", email)
+		}
+	}
+	return nil
+}
+
+// newAccount RFC 8555 7.3，相同公钥的账户已存在时返回该账户
+func (s *Server) newAccount(w http.ResponseWriter, _ *http.Request, r *request) *Problem {
+	var payload struct {
+		Contact                []string `json:"contact"`
+		TermsOfServiceAgreed   bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting     bool     `json:"onlyReturnExisting"`
+		ExternalAccountBinding any      `json:"externalAccountBinding"`
+	}
+
+	prob := r.decode(&payload)
+	if prob != nil {
+		return prob
+	}
+
+	existing, err := s.store.findAccountByThumbprint(r.thumbprint)
+	if err != nil {
+		return serverInternal(err)
+	} else if existing != nil {
+		s.writeAccount(w, http.StatusOK, existing)
+		return nil
+	} else if payload.OnlyReturnExisting {
+		return NewProblem(ErrorAccountDoesNotExist, http.StatusBadRequest, "no account exists with the provided key")
+	}
+
+	prob = checkContact(payload.Contact)
+	if prob != nil {
+		return prob
+	}
+
+	id, err := newID()
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	acct := &Account{
+		ID:                   id,
+		Status:               StatusValid,
+		Contact:              payload.Contact,
+		TermsOfServiceAgreed: payload.TermsOfServiceAgreed,
+		Key:                  r.jwk,
+		Thumbprint:           r.thumbprint,
+		CreatedAt:            time.Now(),
+	}
+
+	err = s.store.saveAccount(acct)
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	s.writeAccount(w, http.StatusCreated, acct)
+	return nil
+}
+
+// updateAccount RFC 8555 7.3.2 和 7.3.6，载荷为空时返回账户信息
+func (s *Server) updateAccount(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	if req.PathValue("id") != r.account.ID {
+		return unauthorized("the account does not match the kid")
+	}
+
+	if r.isPostAsGet() {
+		s.writeAccount(w, http.StatusOK, r.account)
+		return nil
+	}
+
+	var payload struct {
+		Status  string    `json:"status"`
+		Contact *[]string `json:"contact"`
+	}
+
+	prob := r.decode(&payload)
+	if prob != nil {
+		return prob
+	}
+
+	if payload.Status != "" && payload.Status != StatusDeactivated {
+		return malformed("the account status can only be changed to %s", StatusDeactivated)
+	}
+
+	if payload.Contact != nil {
+		prob = checkContact(*payload.Contact)
+		if prob != nil {
+			return prob
+		}
+		r.account.Contact = *payload.Contact
+	}
+
+	if payload.Status == StatusDeactivated {
+		r.account.Status = StatusDeactivated
+	}
+
+	err := s.store.saveAccount(r.account)
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	s.writeAccount(w, http.StatusOK, r.account)
+	return nil
+}
+
+// accountOrders RFC 8555 7.1.2.1
+func (s *Server) accountOrders(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	if req.PathValue("id") != r.account.ID {
+		return unauthorized("the account does not match the kid")
+	}
+
+	orders := make([]string, 0, len(r.account.Orders))
+	for _, id := range r.account.Orders {
+		order, err := s.store.getOrder(id)
+		if errors.Is(err, errNotFound) {
+			continue
+		} else if err != nil {
+			return serverInternal(err)
+		}
+		orders = append(orders, s.orderURL(order))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"orders": orders,
+	})
+	return nil
+}
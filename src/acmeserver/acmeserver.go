@@ -0,0 +1,204 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package acmeserver 实现 RFC 8555 ACME 服务（包括 RFC 8737 tls-alpn-01 和 RFC 8738 IP标识），
+// 账户、订单和授权保存在 Store 中，证书由调用者提供的 IssueFunc 签发
+package acmeserver
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRequestSize = 64 * 1024
+
+const (
+	orderLifetime         = 7 * 24 * time.Hour
+	validAuthzLifetime    = 30 * 24 * time.Hour
+	nonceLifetime         = time.Hour
+	maxNonces             = 10000
+	contentTypeJOSE       = "application/jose+json"
+	contentTypeJSON       = "application/json"
+	contentTypePEMChain   = "application/pem-certificate-chain"
+	linkRelationIndex     = "index"
+	linkRelationUp        = "up"
+	directoryPath         = "/directory"
+	newNoncePath          = "/new-nonce"
+	newAccountPath        = "/new-account"
+	newOrderPath          = "/new-order"
+	accountPathPrefix     = "/acct/"
+	orderPathPrefix       = "/order/"
+	authorizationPrefix   = "/authz/"
+	challengePathPrefix   = "/chall/"
+	certificatePathPrefix = "/cert/"
+)
+
+// IssueFunc 根据订单的CSR签发证书，返回证书以及PEM格式的证书链（终端证书在前）
+// 调用时CSR的签名以及CSR中的名称与订单标识一致均已检查
+type IssueFunc func(orderID string, csr *x509.CertificateRequest, domains []string, ips []net.IP) (*x509.Certificate, []byte, error)
+
+// Config ACME服务的配置
+type Config struct {
+	BaseURL          string                                     // 客户端访问服务使用的地址，例如 https://acme.example.com/acme
+	Store            *Store                                     // 保存账户、订单和授权
+	Validator        *Validator                                 // 验证挑战
+	CheckIdentifiers func(domains []string, ips []net.IP) error // 创建订单时检查标识（例如CA的名称约束），为nil时不检查
+	Issue            IssueFunc
+}
+
+// Server ACME服务，实现 http.Handler
+type Server struct {
+	baseURL          string
+	store            *Store
+	validator        *Validator
+	checkIdentifiers func(domains []string, ips []net.IP) error
+	issue            IssueFunc
+	handler          http.Handler
+
+	nonceLock sync.Mutex
+	nonces    map[string]time.Time
+}
+
+func NewServer(config *Config) (*Server, error) {
+	base, err := url.Parse(config.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("not a valid base url: %s", config.BaseURL)
+	} else if config.Store == nil || config.Validator == nil || config.Issue == nil {
+		return nil, fmt.Errorf("the store, validator and issue function must be set")
+	}
+
+	s := &Server{
+		baseURL:          strings.TrimSuffix(config.BaseURL, "/"),
+		store:            config.Store,
+		validator:        config.Validator,
+		checkIdentifiers: config.CheckIdentifiers,
+		issue:            config.Issue,
+		nonces:           make(map[string]time.Time, 100),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+directoryPath, s.handleDirectory)
+	mux.HandleFunc("HEAD "+newNoncePath, s.handleNewNonce)
+	mux.HandleFunc("GET "+newNoncePath, s.handleNewNonce)
+	mux.HandleFunc("POST "+newAccountPath, s.handleNewAccount)
+	mux.HandleFunc("POST "+accountPathPrefix+"{id}", s.handleAccount)
+	mux.HandleFunc("POST "+accountPathPrefix+"{id}/orders", s.handleAccountOrders)
+	mux.HandleFunc("POST "+newOrderPath, s.handleNewOrder)
+	mux.HandleFunc("POST "+orderPathPrefix+"{id}", s.handleOrder)
+	mux.HandleFunc("POST "+orderPathPrefix+"{id}/finalize", s.handleFinalize)
+	mux.HandleFunc("POST "+authorizationPrefix+"{id}", s.handleAuthorization)
+	mux.HandleFunc("POST "+challengePathPrefix+"{id}/{type}", s.handleChallenge)
+	mux.HandleFunc("POST "+certificatePathPrefix+"{id}", s.handleCertificate)
+
+	s.handler = mux
+	if basePath := strings.TrimSuffix(base.Path, "/"); basePath != "" {
+		s.handler = http.StripPrefix(basePath, mux)
+	}
+
+	return s, nil
+}
+
+// DirectoryURL 客户端配置使用的目录地址
+func (s *Server) DirectoryURL() string {
+	return s.url(directoryPath)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.handler.ServeHTTP(w, req)
+}
+
+func (s *Server) url(p string) string {
+	return s.baseURL + p
+}
+
+// newNonce 生成新的 Replay-Nonce，nonce过多时清理过期的nonce，仍然过多时随机丢弃一半
+func (s *Server) newNonce() (string, error) {
+	b := make([]byte, 16)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+
+	s.nonceLock.Lock()
+	defer s.nonceLock.Unlock()
+
+	now := time.Now()
+	if len(s.nonces) >= maxNonces {
+		for n, expires := range s.nonces {
+			if now.After(expires) {
+				delete(s.nonces, n)
+			}
+		}
+
+		for n := range s.nonces {
+			if len(s.nonces) < maxNonces/2 {
+				break
+			}
+			delete(s.nonces, n) // 被丢弃的nonce会返回badNonce，客户端会自动重试
+		}
+	}
+
+	s.nonces[nonce] = now.Add(nonceLifetime)
+	return nonce, nil
+}
+
+// useNonce 检查并消耗nonce，每个nonce只能使用一次
+func (s *Server) useNonce(nonce string) bool {
+	s.nonceLock.Lock()
+	defer s.nonceLock.Unlock()
+
+	expires, ok := s.nonces[nonce]
+	if !ok {
+		return false
+	}
+
+	delete(s.nonces, nonce)
+	return time.Now().Before(expires)
+}
+
+// setCommonHeaders 每个响应都携带新的nonce和指向目录的链接
+func (s *Server) setCommonHeaders(w http.ResponseWriter) {
+	nonce, err := s.newNonce()
+	if err == nil {
+		w.Header().Set("Replay-Nonce", nonce)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	s.addLink(w, s.url(directoryPath), linkRelationIndex)
+}
+
+func (s *Server) addLink(w http.ResponseWriter, target string, relation string) {
+	w.Header().Add("Link", fmt.Sprintf("<%s>;rel=\"%s\"", target, relation))
+}
+
+func (s *Server) handleDirectory(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"newNonce":   s.url(newNoncePath),
+		"newAccount": s.url(newAccountPath),
+		"newOrder":   s.url(newOrderPath),
+		"meta": map[string]any{
+			"externalAccountRequired": false,
+		},
+	})
+}
+
+func (s *Server) handleNewNonce(w http.ResponseWriter, req *http.Request) {
+	s.setCommonHeaders(w)
+
+	if req.Method == http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ALPNProtocol tls-alpn-01 挑战使用的ALPN协议（RFC 8737）
+const ALPNProtocol = "acme-tls/1"
+
+// OIDACMEIdentifier id-pe-acmeIdentifier 扩展，值为密钥授权的SHA-256摘要
+var OIDACMEIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+
+const maxHTTPChallengeSize = 8 * 1024
+
+// Validator 验证挑战，端口和DNS服务器可以修改，以便使用本地的替代服务进行测试
+type Validator struct {
+	HTTPPort int           // http-01 连接的端口，默认80
+	TLSPort  int           // tls-alpn-01 连接的端口，默认443
+	Resolver *net.Resolver // 查询TXT记录和解析域名使用的DNS解析器，为nil时使用系统解析器
+	Timeout  time.Duration // 单次验证的超时时间
+}
+
+// NewValidator 创建挑战验证器，dnsServer为DNS服务器地址（host:port），为空时使用系统解析器
+func NewValidator(httpPort int, tlsPort int, dnsServer string, timeout time.Duration) *Validator {
+	v := &Validator{
+		HTTPPort: httpPort,
+		TLSPort:  tlsPort,
+		Timeout:  timeout,
+	}
+
+	if v.HTTPPort <= 0 {
+		v.HTTPPort = 80
+	}
+
+	if v.TLSPort <= 0 {
+		v.TLSPort = 443
+	}
+
+	if v.Timeout <= 0 {
+		v.Timeout = 30 * time.Second
+	}
+
+	if dnsServer != "" {
+		if _, _, err := net.SplitHostPort(dnsServer); err != nil {
+			dnsServer = net.JoinHostPort(dnsServer, "53")
+		}
+
+		v.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+
+	return v
+}
+
+// Validate 验证一个挑战，失败时返回的Problem会记录在挑战中
+func (v *Validator) Validate(challengeType string, identifier Identifier, token string, keyAuthorization string) *Problem {
+	ctx, cancel := context.WithTimeout(context.Background(), v.Timeout)
+	defer cancel()
+
+	switch challengeType {
+	case ChallengeHTTP01:
+		return v.validateHTTP01(ctx, identifier, token, keyAuthorization)
+	case ChallengeDNS01:
+		return v.validateDNS01(ctx, identifier, keyAuthorization)
+	case ChallengeTLSALPN01:
+		return v.validateTLSALPN01(ctx, identifier, keyAuthorization)
+	default:
+		return malformed("unsupported challenge type: %s", challengeType)
+	}
+}
+
+func (v *Validator) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:  v.Timeout,
+		Resolver: v.Resolver,
+	}
+}
+
+// validateHTTP01 RFC 8555 8.3
+func (v *Validator) validateHTTP01(ctx context.Context, identifier Identifier, token string, keyAuthorization string) *Problem {
+	host := identifier.Value
+	if v.HTTPPort != 80 {
+		host = net.JoinHostPort(host, strconv.Itoa(v.HTTPPort))
+	} else if identifier.Type == IdentifierIP && strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", host, token)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return malformed("%s", err.Error())
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext:       v.dialer().DialContext,
+			DisableKeepAlives: true,
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return NewProblem(ErrorConnection, http.StatusBadRequest, "fetch %s failed: %s", url, err.Error())
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "fetch %s returned status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPChallengeSize))
+	if err != nil {
+		return NewProblem(ErrorConnection, http.StatusBadRequest, "read %s failed: %s", url, err.Error())
+	}
+
+	if subtle.ConstantTimeCompare(bytes.TrimSpace(body), []byte(keyAuthorization)) != 1 {
+		return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the key authorization from %s is incorrect", url)
+	}
+
+	return nil
+}
+
+// validateDNS01 RFC 8555 8.4，通配符域名查询其父域名的 _acme-challenge 记录
+func (v *Validator) validateDNS01(ctx context.Context, identifier Identifier, keyAuthorization string) *Problem {
+	if identifier.Type != IdentifierDNS {
+		return malformed("dns-01 can only be used for dns identifiers")
+	}
+
+	name := "_acme-challenge." + strings.TrimPrefix(identifier.Value, "*.")
+
+	resolver := v.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return NewProblem(ErrorDNS, http.StatusBadRequest, "lookup TXT %s failed: %s", name, err.Error())
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+
+	for _, r := range records {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(r)), []byte(expected)) == 1 {
+			return nil
+		}
+	}
+
+	return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "no TXT record of %s matches the key authorization (found %d records)", name, len(records))
+}
+
+// validateTLSALPN01 RFC 8737，IP标识使用反向域名作为SNI（RFC 8738）
+func (v *Validator) validateTLSALPN01(ctx context.Context, identifier Identifier, keyAuthorization string) *Problem {
+	serverName := identifier.Value
+	var ip net.IP
+	if identifier.Type == IdentifierIP {
+		ip = net.ParseIP(identifier.Value)
+		serverName = reverseName(ip)
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: v.dialer(),
+		Config: &tls.Config{
+			ServerName:         serverName,
+			NextProtos:         []string{ALPNProtocol},
+			InsecureSkipVerify: true, // 验证服务器返回的自签名挑战证书，而不是证书链
+			MinVersion:         tls.VersionTLS12,
+		},
+	}
+
+	address := net.JoinHostPort(identifier.Value, strconv.Itoa(v.TLSPort))
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return NewProblem(ErrorTLS, http.StatusBadRequest, "TLS handshake with %s failed: %s", address, err.Error())
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if state.NegotiatedProtocol != ALPNProtocol {
+		return NewProblem(ErrorTLS, http.StatusBadRequest, "%s did not negotiate the %s protocol", address, ALPNProtocol)
+	} else if len(state.PeerCertificates) == 0 {
+		return NewProblem(ErrorTLS, http.StatusBadRequest, "%s did not send a certificate", address)
+	}
+
+	cert := state.PeerCertificates[0]
+
+	if identifier.Type == IdentifierIP {
+		if len(cert.IPAddresses) != 1 || len(cert.DNSNames) != 0 || !cert.IPAddresses[0].Equal(ip) {
+			return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the challenge certificate must contain only the IP address %s", identifier.Value)
+		}
+	} else if len(cert.DNSNames) != 1 || len(cert.IPAddresses) != 0 || !strings.EqualFold(cert.DNSNames[0], identifier.Value) {
+		return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the challenge certificate must contain only the DNS name %s", identifier.Value)
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(OIDACMEIdentifier) {
+			continue
+		}
+
+		if !ext.Critical {
+			return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the acmeIdentifier extension must be critical")
+		}
+
+		var value []byte
+		rest, err := asn1.Unmarshal(ext.Value, &value)
+		if err != nil || len(rest) != 0 {
+			return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "not a valid acmeIdentifier extension")
+		}
+
+		if subtle.ConstantTimeCompare(value, digest[:]) != 1 {
+			return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the acmeIdentifier extension does not match the key authorization")
+		}
+
+		return nil
+	}
+
+	return NewProblem(ErrorIncorrectResponse, http.StatusForbidden, "the challenge certificate does not have the acmeIdentifier extension")
+}
+
+// reverseName 返回IP地址的反向解析域名（in-addr.arpa 或 ip6.arpa）
+func reverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+
+	const hexDigits = "0123456789abcdef"
+	var sb strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[ip[i]&0x0f])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[ip[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa")
+	return sb.String()
+}
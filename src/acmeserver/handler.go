@@ -0,0 +1,177 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// request 通过验证的ACME请求，account在newAccount请求中为nil
+type request struct {
+	payload    []byte
+	account    *Account
+	jwk        []byte // newAccount请求中的规范化JWK
+	thumbprint string
+}
+
+// isPostAsGet POST-as-GET请求的载荷为空（RFC 8555 6.3）
+func (r *request) isPostAsGet() bool {
+	return len(r.payload) == 0
+}
+
+func (r *request) decode(v any) *Problem {
+	err := json.Unmarshal(r.payload, v)
+	if err != nil {
+		return malformed("not a valid payload: %s", err.Error())
+	}
+	return nil
+}
+
+// authenticate 检查请求的类型、nonce、url以及签名，useJWK为true时（newAccount）使用请求携带的公钥，否则使用kid指向的账户的公钥
+// 调用者需持有 Store 的锁
+func (s *Server) authenticate(req *http.Request, useJWK bool) (*request, *Problem) {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != contentTypeJOSE {
+		return nil, NewProblem(ErrorMalformed, http.StatusUnsupportedMediaType, "the content type must be %s", contentTypeJOSE)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxRequestSize+1))
+	if err != nil {
+		return nil, malformed("read request failed: %s", err.Error())
+	} else if len(body) > maxRequestSize {
+		return nil, NewProblem(ErrorMalformed, http.StatusRequestEntityTooLarge, "request too large")
+	}
+
+	obj, header, payload, prob := parseJWS(body)
+	if prob != nil {
+		return nil, prob
+	}
+
+	if !s.useNonce(header.Nonce) {
+		return nil, NewProblem(ErrorBadNonce, http.StatusBadRequest, "the nonce is invalid or has been used")
+	}
+
+	if header.URL != s.url(req.URL.Path) {
+		return nil, unauthorized("the url of the protected header does not match the request url")
+	}
+
+	res := &request{
+		payload: payload,
+	}
+
+	var pub crypto.PublicKey
+	if useJWK {
+		if len(header.JWK) == 0 {
+			return nil, malformed("the jwk of the protected header must be set")
+		}
+
+		pub, res.jwk, prob = parseJWK(header.JWK)
+		if prob != nil {
+			return nil, prob
+		}
+		res.thumbprint = jwkThumbprint(res.jwk)
+	} else {
+		if header.KID == "" {
+			return nil, malformed("the kid of the protected header must be set")
+		}
+
+		id, ok := strings.CutPrefix(header.KID, s.url(accountPathPrefix))
+		if !ok {
+			return nil, NewProblem(ErrorAccountDoesNotExist, http.StatusBadRequest, "unknown kid: %s", header.KID)
+		}
+
+		res.account, err = s.store.getAccount(id)
+		if errors.Is(err, errNotFound) {
+			return nil, NewProblem(ErrorAccountDoesNotExist, http.StatusBadRequest, "unknown kid: %s", header.KID)
+		} else if err != nil {
+			return nil, serverInternal(err)
+		}
+
+		if res.account.Status != StatusValid {
+			return nil, unauthorized("the account is %s", res.account.Status)
+		}
+
+		pub, _, prob = parseJWK(res.account.Key)
+		if prob != nil {
+			return nil, serverInternal(prob)
+		}
+		res.thumbprint = res.account.Thumbprint
+	}
+
+	prob = obj.verify(header.Alg, pub)
+	if prob != nil {
+		return nil, prob
+	}
+
+	return res, nil
+}
+
+// handle 处理ACME的POST请求，持有 Store 的锁调用fn
+func (s *Server) handle(w http.ResponseWriter, req *http.Request, useJWK bool, fn func(w http.ResponseWriter, req *http.Request, r *request) *Problem) {
+	s.setCommonHeaders(w)
+
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+
+	r, prob := s.authenticate(req, useJWK)
+	if prob == nil {
+		prob = fn(w, req, r)
+	}
+
+	if prob != nil {
+		writeProblem(w, prob)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+func (s *Server) handleNewAccount(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, true, s.newAccount)
+}
+
+func (s *Server) handleAccount(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.updateAccount)
+}
+
+func (s *Server) handleAccountOrders(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.accountOrders)
+}
+
+func (s *Server) handleNewOrder(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.newOrder)
+}
+
+func (s *Server) handleOrder(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.getOrder)
+}
+
+func (s *Server) handleFinalize(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.finalizeOrder)
+}
+
+func (s *Server) handleAuthorization(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.getAuthorization)
+}
+
+func (s *Server) handleChallenge(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.respondChallenge)
+}
+
+func (s *Server) handleCertificate(w http.ResponseWriter, req *http.Request) {
+	s.handle(w, req, false, s.getCertificate)
+}
@@ -0,0 +1,255 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+)
+
+// jwsObject RFC 8555 6.2 要求的 Flattened JSON 序列化格式
+type jwsObject struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+type jwsHeader struct {
+	Alg   string          `json:"alg"`
+	Nonce string          `json:"nonce"`
+	URL   string          `json:"url"`
+	KID   string          `json:"kid,omitempty"`
+	JWK   json.RawMessage `json:"jwk,omitempty"`
+}
+
+// jwk 账户公钥（RFC 7517），仅保留计算指纹（RFC 7638）需要的成员
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// parseJWS 解析JWS，返回保护头部和解码后的载荷（POST-as-GET请求的载荷为空）
+func parseJWS(data []byte) (*jwsObject, *jwsHeader, []byte, *Problem) {
+	var obj jwsObject
+	err := json.Unmarshal(data, &obj)
+	if err != nil {
+		return nil, nil, nil, malformed("not a valid flattened JWS: %s", err.Error())
+	}
+
+	headerData, err := base64.RawURLEncoding.DecodeString(obj.Protected)
+	if err != nil {
+		return nil, nil, nil, malformed("not a valid protected header: %s", err.Error())
+	}
+
+	var header jwsHeader
+	err = json.Unmarshal(headerData, &header)
+	if err != nil {
+		return nil, nil, nil, malformed("not a valid protected header: %s", err.Error())
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(obj.Payload)
+	if err != nil {
+		return nil, nil, nil, malformed("not a valid payload: %s", err.Error())
+	}
+
+	if header.URL == "" {
+		return nil, nil, nil, malformed("the url of the protected header must be set")
+	} else if header.Nonce == "" {
+		return nil, nil, nil, NewProblem(ErrorBadNonce, http.StatusBadRequest, "the nonce of the protected header must be set")
+	} else if (header.KID == "") == (len(header.JWK) == 0) {
+		return nil, nil, nil, malformed("exactly one of jwk and kid must be set")
+	}
+
+	return &obj, &header, payload, nil
+}
+
+// verify 使用账户公钥验证JWS签名
+func (obj *jwsObject) verify(alg string, pub crypto.PublicKey) *Problem {
+	sig, err := base64.RawURLEncoding.DecodeString(obj.Signature)
+	if err != nil {
+		return malformed("not a valid signature: %s", err.Error())
+	}
+
+	signingInput := []byte(obj.Protected + "." + obj.Payload)
+
+	switch alg {
+	case "RS256":
+		key, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		digest := sha256.Sum256(signingInput)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) != nil {
+			return malformed("JWS signature verification failed")
+		}
+		return nil
+	case "ES256", "ES384", "ES512":
+		key, ok := pub.(*ecdsa.PublicKey)
+		if !ok || key.Curve != curveForAlg(alg) {
+			break
+		}
+
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return malformed("JWS signature verification failed")
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, hashForAlg(alg, signingInput), r, s) {
+			return malformed("JWS signature verification failed")
+		}
+		return nil
+	case "EdDSA":
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			break
+		}
+		if !ed25519.Verify(key, signingInput, sig) {
+			return malformed("JWS signature verification failed")
+		}
+		return nil
+	default:
+		return NewProblem(ErrorBadSignatureAlgorithm, http.StatusBadRequest, "unsupported algorithm: %s (supported: RS256, ES256, ES384, ES512, EdDSA)", alg)
+	}
+
+	return NewProblem(ErrorBadSignatureAlgorithm, http.StatusBadRequest, "the algorithm %s does not match the account key", alg)
+}
+
+func curveForAlg(alg string) elliptic.Curve {
+	switch alg {
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	default:
+		return elliptic.P256()
+	}
+}
+
+func hashForAlg(alg string, data []byte) []byte {
+	switch alg {
+	case "ES384":
+		h := sha512.Sum384(data)
+		return h[:]
+	case "ES512":
+		h := sha512.Sum512(data)
+		return h[:]
+	default:
+		h := sha256.Sum256(data)
+		return h[:]
+	}
+}
+
+// parseJWK 解析JWK，返回公钥以及规范化的JWK（只包含RFC 7638规定的成员，可直接用于计算指纹）
+func parseJWK(data []byte) (crypto.PublicKey, []byte, *Problem) {
+	var key jwk
+	err := json.Unmarshal(data, &key)
+	if err != nil {
+		return nil, nil, malformed("not a valid jwk: %s", err.Error())
+	}
+
+	var pub crypto.PublicKey
+	var canonical any
+
+	switch key.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(key.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(key.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, nil, malformed("not a valid RSA jwk")
+		}
+
+		rsaKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if rsaKey.N.BitLen() < 2048 {
+			return nil, nil, malformed("the RSA account key must be at least 2048 bits")
+		}
+
+		pub = rsaKey
+		canonical = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{key.E, key.Kty, key.N}
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil, malformed("unsupported curve: %s", key.Crv)
+		}
+
+		x, err1 := base64.RawURLEncoding.DecodeString(key.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(key.Y)
+		if err1 != nil || err2 != nil {
+			return nil, nil, malformed("not a valid EC jwk")
+		}
+
+		ecKey := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return nil, nil, malformed("the EC jwk is not on the curve %s", key.Crv)
+		}
+
+		pub = ecKey
+		canonical = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{key.Crv, key.Kty, key.X, key.Y}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if key.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, malformed("not a valid Ed25519 jwk")
+		}
+
+		pub = ed25519.PublicKey(x)
+		canonical = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{key.Crv, key.Kty, key.X}
+	default:
+		return nil, nil, malformed("unsupported key type: %s", key.Kty)
+	}
+
+	res, err := json.Marshal(canonical)
+	if err != nil {
+		return nil, nil, serverInternal(err)
+	}
+
+	return pub, res, nil
+}
+
+// jwkThumbprint RFC 7638 JWK指纹，canonicalJWK 为 parseJWK 返回的规范化JWK
+func jwkThumbprint(canonicalJWK []byte) string {
+	h := sha256.Sum256(canonicalJWK)
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
@@ -0,0 +1,564 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type orderResponse struct {
+	Status         string       `json:"status"`
+	Expires        string       `json:"expires"`
+	Identifiers    []Identifier `json:"identifiers"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate,omitempty"`
+	Error          *Problem     `json:"error,omitempty"`
+}
+
+type authorizationResponse struct {
+	Status     string               `json:"status"`
+	Expires    string               `json:"expires"`
+	Identifier Identifier           `json:"identifier"`
+	Challenges []*challengeResponse `json:"challenges"`
+	Wildcard   bool                 `json:"wildcard,omitempty"`
+}
+
+type challengeResponse struct {
+	Type      string   `json:"type"`
+	URL       string   `json:"url"`
+	Status    string   `json:"status"`
+	Token     string   `json:"token"`
+	Validated string   `json:"validated,omitempty"`
+	Error     *Problem `json:"error,omitempty"`
+}
+
+func (s *Server) orderURL(order *Order) string {
+	return s.url(orderPathPrefix + order.ID)
+}
+
+func (s *Server) authorizationURL(authzID string) string {
+	return s.url(authorizationPrefix + authzID)
+}
+
+func (s *Server) challengeURL(authzID string, challengeType string) string {
+	return s.url(challengePathPrefix + authzID + "/" + challengeType)
+}
+
+func (s *Server) writeOrder(w http.ResponseWriter, status int, order *Order) {
+	res := &orderResponse{
+		Status:         order.Status,
+		Expires:        order.Expires.UTC().Format(time.RFC3339),
+		Identifiers:    order.Identifiers,
+		Authorizations: make([]string, 0, len(order.Authorizations)),
+		Finalize:       s.orderURL(order) + "/finalize",
+		Error:          order.Error,
+	}
+
+	for _, id := range order.Authorizations {
+		res.Authorizations = append(res.Authorizations, s.authorizationURL(id))
+	}
+
+	if order.Status == StatusValid {
+		res.Certificate = s.url(certificatePathPrefix + order.ID)
+	}
+
+	w.Header().Set("Location", s.orderURL(order))
+	writeJSON(w, status, res)
+}
+
+func (s *Server) newChallengeResponse(authzID string, ch *Challenge) *challengeResponse {
+	res := &challengeResponse{
+		Type:   ch.Type,
+		URL:    s.challengeURL(authzID, ch.Type),
+		Status: ch.Status,
+		Token:  ch.Token,
+		Error:  ch.Error,
+	}
+
+	if ch.Validated != nil {
+		res.Validated = ch.Validated.UTC().Format(time.RFC3339)
+	}
+
+	return res
+}
+
+// normalizeIdentifier 检查订单标识，域名统一为小写，IP统一为标准形式
+func normalizeIdentifier(id Identifier) (Identifier, *Problem) {
+	switch id.Type {
+	case IdentifierDNS:
+		domain := strings.TrimSuffix(strings.ToLower(id.Value), ".")
+		if !utils.IsValidDomain(strings.TrimPrefix(domain, "*.")) {
+			return Identifier{}, NewProblem(ErrorRejectedIdentifier, http.StatusBadRequest, "not a valid domain: %s", id.Value)
+		}
+		return Identifier{Type: IdentifierDNS, Value: domain}, nil
+	case IdentifierIP:
+		ip := net.ParseIP(id.Value)
+		if ip == nil {
+			return Identifier{}, NewProblem(ErrorRejectedIdentifier, http.StatusBadRequest, "not a valid IP address: %s", id.Value)
+		}
+		return Identifier{Type: IdentifierIP, Value: ip.String()}, nil
+	default:
+		return Identifier{}, NewProblem(ErrorUnsupportedIdentifier, http.StatusBadRequest, "unsupported identifier type: %s", id.Type)
+	}
+}
+
+// splitIdentifiers 将订单标识分为域名和IP
+func splitIdentifiers(identifiers []Identifier) ([]string, []net.IP) {
+	domains := make([]string, 0, len(identifiers))
+	ips := make([]net.IP, 0, len(identifiers))
+
+	for _, id := range identifiers {
+		if id.Type == IdentifierIP {
+			ips = append(ips, net.ParseIP(id.Value))
+		} else {
+			domains = append(domains, id.Value)
+		}
+	}
+
+	return domains, ips
+}
+
+// challengeTypes 通配符域名只能使用dns-01，IP只能使用http-01和tls-alpn-01
+func challengeTypes(id Identifier, wildcard bool) []string {
+	if wildcard {
+		return []string{ChallengeDNS01}
+	} else if id.Type == IdentifierIP {
+		return []string{ChallengeHTTP01, ChallengeTLSALPN01}
+	}
+	return []string{ChallengeHTTP01, ChallengeDNS01, ChallengeTLSALPN01}
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// newOrder RFC 8555 7.4，每个标识创建一个新的授权
+func (s *Server) newOrder(w http.ResponseWriter, _ *http.Request, r *request) *Problem {
+	var payload struct {
+		Identifiers []Identifier `json:"identifiers"`
+		NotBefore   string       `json:"notBefore"`
+		NotAfter    string       `json:"notAfter"`
+	}
+
+	prob := r.decode(&payload)
+	if prob != nil {
+		return prob
+	} else if len(payload.Identifiers) == 0 {
+		return malformed("the identifiers must be set")
+	} else if payload.NotBefore != "" || payload.NotAfter != "" {
+		return malformed("notBefore and notAfter are not supported, the validity is set by the server")
+	}
+
+	identifiers := make([]Identifier, 0, len(payload.Identifiers))
+	for _, id := range payload.Identifiers {
+		id, prob = normalizeIdentifier(id)
+		if prob != nil {
+			return prob
+		}
+
+		if !slices.Contains(identifiers, id) {
+			identifiers = append(identifiers, id)
+		}
+	}
+
+	if s.checkIdentifiers != nil {
+		domains, ips := splitIdentifiers(identifiers)
+		err := s.checkIdentifiers(domains, ips)
+		if err != nil {
+			return NewProblem(ErrorRejectedIdentifier, http.StatusBadRequest, "%s", err.Error())
+		}
+	}
+
+	orderID, err := newID()
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	now := time.Now()
+	order := &Order{
+		ID:             orderID,
+		AccountID:      r.account.ID,
+		Status:         StatusPending,
+		Expires:        now.Add(orderLifetime),
+		Identifiers:    identifiers,
+		Authorizations: make([]string, 0, len(identifiers)),
+		CreatedAt:      now,
+	}
+
+	for _, id := range identifiers {
+		authzID, err := newID()
+		if err != nil {
+			return serverInternal(err)
+		}
+
+		authz := &Authorization{
+			ID:         authzID,
+			AccountID:  r.account.ID,
+			OrderID:    orderID,
+			Status:     StatusPending,
+			Expires:    order.Expires,
+			Identifier: id,
+			Wildcard:   strings.HasPrefix(id.Value, "*."),
+		}
+
+		if authz.Wildcard {
+			authz.Identifier.Value = strings.TrimPrefix(id.Value, "*.") // RFC 8555 7.1.4
+		}
+
+		for _, t := range challengeTypes(id, authz.Wildcard) {
+			token, err := newToken()
+			if err != nil {
+				return serverInternal(err)
+			}
+
+			authz.Challenges = append(authz.Challenges, &Challenge{
+				Type:   t,
+				Status: StatusPending,
+				Token:  token,
+			})
+		}
+
+		err = s.store.saveAuthorization(authz)
+		if err != nil {
+			return serverInternal(err)
+		}
+
+		order.Authorizations = append(order.Authorizations, authzID)
+	}
+
+	err = s.store.saveOrder(order)
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	r.account.Orders = append(r.account.Orders, orderID)
+	err = s.store.saveAccount(r.account)
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	s.writeOrder(w, http.StatusCreated, order)
+	return nil
+}
+
+// loadOrder 读取属于该账户的订单，并更新订单状态
+func (s *Server) loadOrder(r *request, id string) (*Order, *Problem) {
+	order, err := s.store.getOrder(id)
+	if errors.Is(err, errNotFound) {
+		return nil, NewProblem(ErrorMalformed, http.StatusNotFound, "order not found")
+	} else if err != nil {
+		return nil, serverInternal(err)
+	} else if order.AccountID != r.account.ID {
+		return nil, unauthorized("the order does not belong to the account")
+	}
+
+	changed, err := s.store.refreshOrder(order)
+	if err != nil {
+		return nil, serverInternal(err)
+	} else if changed {
+		err = s.store.saveOrder(order)
+		if err != nil {
+			return nil, serverInternal(err)
+		}
+	}
+
+	return order, nil
+}
+
+func (s *Server) getOrder(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	order, prob := s.loadOrder(r, req.PathValue("id"))
+	if prob != nil {
+		return prob
+	}
+
+	s.writeOrder(w, http.StatusOK, order)
+	return nil
+}
+
+// finalizeOrder RFC 8555 7.4，CSR中的名称必须与订单的标识完全一致
+func (s *Server) finalizeOrder(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	order, prob := s.loadOrder(r, req.PathValue("id"))
+	if prob != nil {
+		return prob
+	} else if order.Status != StatusReady {
+		return NewProblem(ErrorOrderNotReady, http.StatusForbidden, "the order is %s", order.Status)
+	}
+
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+
+	prob = r.decode(&payload)
+	if prob != nil {
+		return prob
+	}
+
+	csrDER, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return NewProblem(ErrorBadCSR, http.StatusBadRequest, "not a valid base64url CSR")
+	}
+
+	csr, err := x509.ParseCertificateRequest(csrDER)
+	if err != nil {
+		return NewProblem(ErrorBadCSR, http.StatusBadRequest, "parse CSR failed: %s", err.Error())
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return NewProblem(ErrorBadCSR, http.StatusBadRequest, "CSR signature check failed: %s", err.Error())
+	}
+
+	prob = checkCSRIdentifiers(csr, order.Identifiers)
+	if prob != nil {
+		return prob
+	}
+
+	order.Status = StatusProcessing
+	err = s.store.saveOrder(order)
+	if err != nil {
+		return serverInternal(err)
+	}
+
+	domains, ips := splitIdentifiers(order.Identifiers)
+	cert, chainPEM, err := s.issue(order.ID, csr, domains, ips)
+	if err == nil {
+		err = s.store.saveCertificate(order.ID, chainPEM)
+	}
+
+	if err != nil {
+		order.Status = StatusInvalid
+		order.Error = NewProblem(ErrorBadCSR, http.StatusBadRequest, "issue certificate failed: %s", err.Error())
+	} else {
+		order.Status = StatusValid
+		order.CertificateSerial = cert.SerialNumber.Text(16)
+	}
+
+	err = s.store.saveOrder(order)
+	if err != nil {
+		return serverInternal(err)
+	} else if order.Status == StatusInvalid {
+		return order.Error
+	}
+
+	s.writeOrder(w, http.StatusOK, order)
+	return nil
+}
+
+// checkCSRIdentifiers 检查CSR中的DNS名称、IP地址以及CN是否与订单标识完全一致
+func checkCSRIdentifiers(csr *x509.CertificateRequest, identifiers []Identifier) *Problem {
+	if len(csr.EmailAddresses) != 0 || len(csr.URIs) != 0 {
+		return NewProblem(ErrorBadCSR, http.StatusBadRequest, "the CSR can only contain DNS names and IP addresses")
+	}
+
+	names := make([]Identifier, 0, len(csr.DNSNames)+len(csr.IPAddresses)+1)
+	for _, domain := range csr.DNSNames {
+		names = append(names, Identifier{Type: IdentifierDNS, Value: strings.ToLower(domain)})
+	}
+	for _, ip := range csr.IPAddresses {
+		names = append(names, Identifier{Type: IdentifierIP, Value: ip.String()})
+	}
+
+	if cn := csr.Subject.CommonName; cn != "" {
+		id := Identifier{Type: IdentifierDNS, Value: strings.ToLower(cn)}
+		if ip := net.ParseIP(cn); ip != nil {
+			id = Identifier{Type: IdentifierIP, Value: ip.String()}
+		}
+
+		if !slices.Contains(identifiers, id) {
+			return NewProblem(ErrorBadCSR, http.StatusBadRequest, "the common name %s is not an identifier of the order", cn)
+		}
+	}
+
+	for _, name := range names {
+		if !slices.Contains(identifiers, name) {
+			return NewProblem(ErrorBadCSR, http.StatusBadRequest, "%s is not an identifier of the order", name.Value)
+		}
+	}
+
+	for _, id := range identifiers {
+		if !slices.Contains(names, id) {
+			return NewProblem(ErrorBadCSR, http.StatusBadRequest, "the CSR does not contain the identifier %s", id.Value)
+		}
+	}
+
+	return nil
+}
+
+// loadAuthorization 读取属于该账户的授权，待验证的授权过期时更新其状态
+func (s *Server) loadAuthorization(r *request, id string) (*Authorization, *Problem) {
+	authz, err := s.store.getAuthorization(id)
+	if errors.Is(err, errNotFound) {
+		return nil, NewProblem(ErrorMalformed, http.StatusNotFound, "authorization not found")
+	} else if err != nil {
+		return nil, serverInternal(err)
+	} else if authz.AccountID != r.account.ID {
+		return nil, unauthorized("the authorization does not belong to the account")
+	}
+
+	if authz.Status == StatusPending && time.Now().After(authz.Expires) {
+		authz.Status = StatusExpired
+		err = s.store.saveAuthorization(authz)
+		if err != nil {
+			return nil, serverInternal(err)
+		}
+	}
+
+	return authz, nil
+}
+
+// getAuthorization RFC 8555 7.5，载荷为 {"status":"deactivated"} 时停用授权（7.5.2）
+func (s *Server) getAuthorization(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	authz, prob := s.loadAuthorization(r, req.PathValue("id"))
+	if prob != nil {
+		return prob
+	}
+
+	if !r.isPostAsGet() {
+		var payload struct {
+			Status string `json:"status"`
+		}
+
+		prob = r.decode(&payload)
+		if prob != nil {
+			return prob
+		} else if payload.Status != StatusDeactivated {
+			return malformed("the authorization status can only be changed to %s", StatusDeactivated)
+		} else if authz.Status != StatusPending && authz.Status != StatusValid {
+			return malformed("the authorization is %s", authz.Status)
+		}
+
+		authz.Status = StatusDeactivated
+		err := s.store.saveAuthorization(authz)
+		if err != nil {
+			return serverInternal(err)
+		}
+	}
+
+	res := &authorizationResponse{
+		Status:     authz.Status,
+		Expires:    authz.Expires.UTC().Format(time.RFC3339),
+		Identifier: authz.Identifier,
+		Challenges: make([]*challengeResponse, 0, len(authz.Challenges)),
+		Wildcard:   authz.Wildcard,
+	}
+
+	for _, ch := range authz.Challenges {
+		res.Challenges = append(res.Challenges, s.newChallengeResponse(authz.ID, ch))
+	}
+
+	writeJSON(w, http.StatusOK, res)
+	return nil
+}
+
+// respondChallenge RFC 8555 7.5.1，客户端通知服务器开始验证，验证在后台进行
+func (s *Server) respondChallenge(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	authz, prob := s.loadAuthorization(r, req.PathValue("id"))
+	if prob != nil {
+		return prob
+	}
+
+	ch := authz.challenge(req.PathValue("type"))
+	if ch == nil {
+		return NewProblem(ErrorMalformed, http.StatusNotFound, "challenge not found")
+	}
+
+	if !r.isPostAsGet() && ch.Status == StatusPending && authz.Status == StatusPending {
+		ch.Status = StatusProcessing
+		err := s.store.saveAuthorization(authz)
+		if err != nil {
+			return serverInternal(err)
+		}
+
+		keyAuthorization := fmt.Sprintf("%s.%s", ch.Token, r.thumbprint)
+		go s.validate(authz.ID, ch.Type, authz.Identifier, ch.Token, keyAuthorization)
+	}
+
+	s.addLink(w, s.authorizationURL(authz.ID), linkRelationUp)
+	writeJSON(w, http.StatusOK, s.newChallengeResponse(authz.ID, ch))
+	return nil
+}
+
+// validate 在后台验证挑战，并更新挑战、授权和订单的状态
+func (s *Server) validate(authzID string, challengeType string, identifier Identifier, token string, keyAuthorization string) {
+	prob := s.validator.Validate(challengeType, identifier, token, keyAuthorization)
+
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+
+	authz, err := s.store.getAuthorization(authzID)
+	if err != nil {
+		return
+	}
+
+	ch := authz.challenge(challengeType)
+	if ch == nil || ch.Status != StatusProcessing || authz.Status != StatusPending {
+		return // 授权在验证期间被停用
+	}
+
+	if prob == nil {
+		now := time.Now()
+		ch.Status = StatusValid
+		ch.Validated = &now
+		authz.Status = StatusValid
+		authz.Expires = now.Add(validAuthzLifetime)
+	} else {
+		ch.Status = StatusInvalid
+		ch.Error = prob
+		authz.Status = StatusInvalid
+	}
+
+	err = s.store.saveAuthorization(authz)
+	if err != nil {
+		return
+	}
+
+	order, err := s.store.getOrder(authz.OrderID)
+	if err != nil {
+		return
+	}
+
+	changed, err := s.store.refreshOrder(order)
+	if err == nil && changed {
+		_ = s.store.saveOrder(order)
+	}
+}
+
+// getCertificate RFC 8555 7.4.2
+func (s *Server) getCertificate(w http.ResponseWriter, req *http.Request, r *request) *Problem {
+	order, prob := s.loadOrder(r, req.PathValue("id"))
+	if prob != nil {
+		return prob
+	} else if order.Status != StatusValid {
+		return NewProblem(ErrorMalformed, http.StatusNotFound, "certificate not found")
+	}
+
+	chainPEM, err := s.store.getCertificate(order.ID)
+	if errors.Is(err, errNotFound) {
+		return NewProblem(ErrorMalformed, http.StatusNotFound, "certificate not found")
+	} else if err != nil {
+		return serverInternal(err)
+	}
+
+	w.Header().Set("Content-Type", contentTypePEMChain)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(chainPEM)
+	return nil
+}
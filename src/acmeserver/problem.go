@@ -0,0 +1,71 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const errorNamespace = "urn:ietf:params:acme:error:"
+
+// RFC 8555 6.7 定义的错误类型
+const (
+	ErrorAccountDoesNotExist     = "accountDoesNotExist"
+	ErrorBadCSR                  = "badCSR"
+	ErrorBadNonce                = "badNonce"
+	ErrorBadSignatureAlgorithm   = "badSignatureAlgorithm"
+	ErrorConnection              = "connection"
+	ErrorDNS                     = "dns"
+	ErrorIncorrectResponse       = "incorrectResponse"
+	ErrorInvalidContact          = "invalidContact"
+	ErrorMalformed               = "malformed"
+	ErrorOrderNotReady           = "orderNotReady"
+	ErrorRejectedIdentifier      = "rejectedIdentifier"
+	ErrorServerInternal          = "serverInternal"
+	ErrorTLS                     = "tls"
+	ErrorUnauthorized            = "unauthorized"
+	ErrorUnsupportedContact      = "unsupportedContact"
+	ErrorUnsupportedIdentifier   = "unsupportedIdentifier"
+	ErrorExternalAccountRequired = "externalAccountRequired"
+)
+
+// Problem RFC 7807 问题详情，用于返回错误以及记录挑战和订单失败的原因
+type Problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+func NewProblem(errorType string, status int, format string, args ...any) *Problem {
+	return &Problem{
+		Type:   errorNamespace + errorType,
+		Detail: fmt.Sprintf(format, args...),
+		Status: status,
+	}
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func malformed(format string, args ...any) *Problem {
+	return NewProblem(ErrorMalformed, http.StatusBadRequest, format, args...)
+}
+
+func unauthorized(format string, args ...any) *Problem {
+	return NewProblem(ErrorUnauthorized, http.StatusForbidden, format, args...)
+}
+
+func serverInternal(err error) *Problem {
+	return NewProblem(ErrorServerInternal, http.StatusInternalServerError, "%s", err.Error())
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
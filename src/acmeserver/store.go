@@ -0,0 +1,307 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acmeserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 账户、订单、授权和挑战的状态（RFC 8555 7.1.6）
+const (
+	StatusPending     = "pending"
+	StatusProcessing  = "processing"
+	StatusReady       = "ready"
+	StatusValid       = "valid"
+	StatusInvalid     = "invalid"
+	StatusDeactivated = "deactivated"
+	StatusExpired     = "expired"
+)
+
+const (
+	IdentifierDNS = "dns"
+	IdentifierIP  = "ip" // RFC 8738
+)
+
+const (
+	ChallengeHTTP01    = "http-01"
+	ChallengeDNS01     = "dns-01"
+	ChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+type Identifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Account ACME账户，保存在 accounts/<id>.json
+type Account struct {
+	metadata.Header
+
+	ID                   string          `json:"id"`
+	Status               string          `json:"status"`
+	Contact              []string        `json:"contact,omitempty"`
+	TermsOfServiceAgreed bool            `json:"terms_of_service_agreed"`
+	Key                  json.RawMessage `json:"key"`        // 规范化的JWK
+	Thumbprint           string          `json:"thumbprint"` // RFC 7638 JWK指纹
+	Orders               []string        `json:"orders,omitempty"`
+	CreatedAt            time.Time       `json:"created_at"`
+}
+
+// Order ACME订单，保存在 orders/<id>.json，签发的证书链保存在 certs/<id>.pem
+type Order struct {
+	metadata.Header
+
+	ID                string       `json:"id"`
+	AccountID         string       `json:"account_id"`
+	Status            string       `json:"status"`
+	Expires           time.Time    `json:"expires"`
+	Identifiers       []Identifier `json:"identifiers"`
+	Authorizations    []string     `json:"authorizations"`
+	Error             *Problem     `json:"error,omitempty"`
+	CertificateSerial string       `json:"certificate_serial,omitempty"` // 小写十六进制
+	CreatedAt         time.Time    `json:"created_at"`
+}
+
+// Authorization ACME授权及其挑战，保存在 authz/<id>.json
+type Authorization struct {
+	metadata.Header
+
+	ID         string       `json:"id"`
+	AccountID  string       `json:"account_id"`
+	OrderID    string       `json:"order_id"`
+	Status     string       `json:"status"`
+	Expires    time.Time    `json:"expires"`
+	Identifier Identifier   `json:"identifier"`
+	Wildcard   bool         `json:"wildcard,omitempty"`
+	Challenges []*Challenge `json:"challenges"`
+}
+
+type Challenge struct {
+	Type      string     `json:"type"`
+	Status    string     `json:"status"`
+	Token     string     `json:"token"`
+	Validated *time.Time `json:"validated,omitempty"`
+	Error     *Problem   `json:"error,omitempty"`
+}
+
+func (a *Authorization) challenge(challengeType string) *Challenge {
+	for _, ch := range a.Challenges {
+		if ch.Type == challengeType {
+			return ch
+		}
+	}
+	return nil
+}
+
+var errNotFound = errors.New("not found")
+
+// Store 将账户、订单和授权保存在目录中（通常为 home/acme/<ICA名称>），所有读写由同一把锁保护
+type Store struct {
+	lock sync.Mutex
+	dir  string
+}
+
+func NewStore(dir string) (*Store, error) {
+	for _, sub := range []string{"accounts", "orders", "authz", "certs"} {
+		err := os.MkdirAll(path.Join(dir, sub), 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+// newID 生成对象ID，同时作为URL的一部分
+func newID() (string, error) {
+	b := make([]byte, 16)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isValidID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+func (s *Store) filePath(kind string, id string) string {
+	switch kind {
+	case metadata.KindACMEAccount:
+		return path.Join(s.dir, "accounts", id+".json")
+	case metadata.KindACMEOrder:
+		return path.Join(s.dir, "orders", id+".json")
+	default:
+		return path.Join(s.dir, "authz", id+".json")
+	}
+}
+
+func (s *Store) read(kind string, id string, v any) error {
+	if !isValidID(id) {
+		return errNotFound
+	}
+
+	err := metadata.Read(s.filePath(kind, id), kind, v)
+	if errors.Is(err, fs.ErrNotExist) {
+		return errNotFound
+	}
+	return err
+}
+
+func (s *Store) getAccount(id string) (*Account, error) {
+	var acct Account
+	err := s.read(metadata.KindACMEAccount, id, &acct)
+	if err != nil {
+		return nil, err
+	}
+	return &acct, nil
+}
+
+func (s *Store) saveAccount(acct *Account) error {
+	acct.Header = metadata.NewHeader(metadata.KindACMEAccount)
+	return metadata.Write(s.filePath(metadata.KindACMEAccount, acct.ID), acct)
+}
+
+// findAccountByThumbprint 按账户公钥查找账户，不存在时返回nil
+func (s *Store) findAccountByThumbprint(thumbprint string) (*Account, error) {
+	entries, err := os.ReadDir(path.Join(s.dir, "accounts"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !isValidID(id) {
+			continue
+		}
+
+		acct, err := s.getAccount(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if acct.Thumbprint == thumbprint {
+			return acct, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Store) getOrder(id string) (*Order, error) {
+	var order Order
+	err := s.read(metadata.KindACMEOrder, id, &order)
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (s *Store) saveOrder(order *Order) error {
+	order.Header = metadata.NewHeader(metadata.KindACMEOrder)
+	return metadata.Write(s.filePath(metadata.KindACMEOrder, order.ID), order)
+}
+
+func (s *Store) getAuthorization(id string) (*Authorization, error) {
+	var authz Authorization
+	err := s.read(metadata.KindACMEAuthorization, id, &authz)
+	if err != nil {
+		return nil, err
+	}
+	return &authz, nil
+}
+
+func (s *Store) saveAuthorization(authz *Authorization) error {
+	authz.Header = metadata.NewHeader(metadata.KindACMEAuthorization)
+	return metadata.Write(s.filePath(metadata.KindACMEAuthorization, authz.ID), authz)
+}
+
+func (s *Store) certificatePath(orderID string) string {
+	return path.Join(s.dir, "certs", orderID+".pem")
+}
+
+func (s *Store) saveCertificate(orderID string, chainPEM []byte) error {
+	return os.WriteFile(s.certificatePath(orderID), chainPEM, 0600)
+}
+
+func (s *Store) getCertificate(orderID string) ([]byte, error) {
+	if !isValidID(orderID) {
+		return nil, errNotFound
+	}
+
+	data, err := os.ReadFile(s.certificatePath(orderID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("read certificate failed: %s", err.Error())
+	}
+
+	return data, nil
+}
+
+// refreshOrder 根据授权的状态更新订单状态，返回状态是否发生变化（调用者需持有锁）
+func (s *Store) refreshOrder(order *Order) (bool, error) {
+	if order.Status != StatusPending {
+		return false, nil
+	}
+
+	if time.Now().After(order.Expires) {
+		order.Status = StatusInvalid
+		return true, nil
+	}
+
+	ready := true
+	for _, id := range order.Authorizations {
+		authz, err := s.getAuthorization(id)
+		if err != nil {
+			return false, err
+		}
+
+		if authz.Status == StatusPending && time.Now().After(authz.Expires) {
+			authz.Status = StatusExpired
+			err = s.saveAuthorization(authz)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		switch authz.Status {
+		case StatusValid:
+			// pass
+		case StatusPending:
+			ready = false
+		default:
+			order.Status = StatusInvalid
+			order.Error = unauthorized("the authorization for %s is %s", authz.Identifier.Value, authz.Status)
+			return true, nil
+		}
+	}
+
+	if ready {
+		order.Status = StatusReady
+		return true, nil
+	}
+
+	return false, nil
+}
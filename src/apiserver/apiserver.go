@@ -0,0 +1,234 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package apiserver 实现MyCA的JSON REST API，客户端使用访问令牌（Bearer）或由MyCA签发的TLS客户端证书认证，
+// 具体的操作（读取CA、签发、吊销等）由调用者提供的 Backend 实现，接口描述见 openapi.json
+package apiserver
+
+import (
+	"crypto/x509"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const maxRequestSize = 1024 * 1024
+
+const (
+	apiPrefix   = "/api/v1"
+	bearerRealm = "MyCA API"
+
+	contentTypeJSON     = "application/json"
+	contentTypePEMChain = "application/pem-certificate-chain"
+	contentTypePEM      = "application/x-pem-file"
+	contentTypePKIXCRL  = "application/pkix-crl"
+)
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// Client 通过认证的客户端，Token 和 Certificate 有且只有一个不为空
+type Client struct {
+	Token       *Token            // 访问令牌
+	Certificate *x509.Certificate // TLS客户端证书
+}
+
+func (c *Client) String() string {
+	if c.Token != nil {
+		return fmt.Sprintf("token %s (%s)", c.Token.Name, c.Token.ID)
+	}
+	return fmt.Sprintf("certificate %s (serial number: %s)", c.Certificate.Subject.String(), c.Certificate.SerialNumber.Text(16))
+}
+
+// ReadOnly 客户端是否只能调用GET接口
+func (c *Client) ReadOnly() bool {
+	return c.Token != nil && c.Token.ReadOnly
+}
+
+// Subject 签发请求中的证书主题
+type Subject struct {
+	Country            []string `json:"country,omitempty"`
+	Province           []string `json:"province,omitempty"`
+	Locality           []string `json:"locality,omitempty"`
+	Organization       []string `json:"organization,omitempty"`
+	OrganizationalUnit []string `json:"organizational_unit,omitempty"`
+	StreetAddress      []string `json:"street_address,omitempty"`
+	PostalCode         []string `json:"postal_code,omitempty"`
+	CommonName         string   `json:"common_name,omitempty"`
+}
+
+// IssueRequest 签发请求，CSR 为空时由服务端生成私钥
+type IssueRequest struct {
+	IssuerPassword string   `json:"issuer_password,omitempty"` // 签发CA私钥的密码
+	Name           string   `json:"name,omitempty"`            // home/cert 中的目录名称，默认根据通用名称生成
+	CSR            string   `json:"csr,omitempty"`             // PEM格式的CSR
+	Subject        *Subject `json:"subject,omitempty"`         // 为空时使用CSR的主题
+	DNSNames       []string `json:"dns_names,omitempty"`       // 为空时使用CSR中的SAN
+	IPAddresses    []string `json:"ip_addresses,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	ExtKeyUsage    []string `json:"ext_key_usage,omitempty"` // 与命令行的 -ext-key-usage 相同，为空时使用CSR中的扩展密钥用途或默认值
+	Policies       []string `json:"policies,omitempty"`      // CA中定义的证书策略名称
+	Validity       string   `json:"validity,omitempty"`      // 例如 90d，默认 365d
+	CryptoType     string   `json:"crypto,omitempty"`        // 服务端生成私钥时使用：RSA、ECDSA或Ed25519
+	KeyLength      int      `json:"key_length,omitempty"`
+	KeyPassword    string   `json:"key_password,omitempty"` // 服务端生成的私钥的密码，响应中的私钥使用该密码加密
+}
+
+// IssueResult 签发结果
+type IssueResult struct {
+	Name         string `json:"name"` // home/cert 中的目录名称
+	Path         string `json:"path"` // 相对于home目录
+	SerialNumber string `json:"serial_number"`
+	Certificate  string `json:"certificate"`           // PEM
+	Chain        string `json:"chain"`                 // 包含证书的完整证书链（PEM）
+	PrivateKey   string `json:"private_key,omitempty"` // 仅服务端生成私钥时返回（PEM）
+}
+
+// RevokeRequest 吊销请求，与命令行的 cert revoke 相同，Name、SerialNumber 和 Fingerprint 至少设置一个
+type RevokeRequest struct {
+	CertType     string `json:"cert_type,omitempty"` // CERT（默认）或ICA
+	Name         string `json:"name,omitempty"`      // 证书的目录名称
+	SerialNumber string `json:"serial_number,omitempty"`
+	Fingerprint  string `json:"fingerprint,omitempty"`
+	IssuerType   string `json:"issuer_type,omitempty"` // RCA或ICA（默认）
+	Issuer       string `json:"issuer,omitempty"`      // 签发CA的目录名称，证书不在home中时必须设置
+	Reason       string `json:"reason,omitempty"`      // 默认 unspecified
+}
+
+// CRLRequest 生成CRL的请求
+type CRLRequest struct {
+	IssuerPassword string `json:"issuer_password,omitempty"`
+	NextUpdate     string `json:"next_update,omitempty"` // 默认 7d
+	Delta          bool   `json:"delta,omitempty"`
+}
+
+// IssuedQuery 查询签发记录的条件，与命令行的 issued list 相同
+type IssuedQuery struct {
+	IssuerType    string
+	Issuer        string // 为空时查询全部CA
+	Search        string
+	Status        string
+	Type          string
+	ExpiresWithin string
+}
+
+// Backend 实现API的操作，caType 为 RCA 或 ICA（已转换为大写），
+// 返回的错误为 *Error 时使用其状态码，否则GET请求返回500，其它请求返回400
+type Backend interface {
+	ListCAs(caType string) (any, error) // caType为空时返回全部RCA和ICA
+	GetCA(caType string, name string) (any, error)
+	GetChain(caType string, name string) ([]byte, error)           // 返回PEM格式的完整证书链
+	GetCRL(caType string, name string, delta bool) ([]byte, error) // 返回已发布的DER格式CRL
+	GenerateCRL(caType string, name string, req *CRLRequest, client *Client) (any, error)
+	Issue(caType string, name string, req *IssueRequest, client *Client) (*IssueResult, error)
+	ListCertificates() (any, error)
+	GetCertificate(name string) (any, error)
+	ListIssued(query *IssuedQuery) (any, error)
+	Revoke(req *RevokeRequest, client *Client) (any, error)
+}
+
+// Config API服务的配置
+type Config struct {
+	Tokens           *TokenStore                           // 访问令牌，为nil时不接受令牌认证
+	VerifyClientCert func(chain []*x509.Certificate) error // 验证TLS客户端证书，为nil时不接受客户端证书认证
+	Backend          Backend
+}
+
+// Server API服务，实现 http.Handler，使用客户端证书认证时TLS配置应请求但不验证客户端证书
+type Server struct {
+	tokens           *TokenStore
+	verifyClientCert func(chain []*x509.Certificate) error
+	backend          Backend
+	handler          http.Handler
+}
+
+func NewServer(config *Config) (*Server, error) {
+	if config.Backend == nil {
+		return nil, fmt.Errorf("the backend must be set")
+	} else if config.Tokens == nil && config.VerifyClientCert == nil {
+		return nil, fmt.Errorf("at least one of the tokens and client certificate verification must be set")
+	}
+
+	s := &Server{
+		tokens:           config.Tokens,
+		verifyClientCert: config.VerifyClientCert,
+		backend:          config.Backend,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.json", s.getOpenAPI)
+	mux.Handle("GET "+apiPrefix+"/cas", s.auth(s.listCAs))
+	mux.Handle("GET "+apiPrefix+"/cas/{type}/{name}", s.auth(s.getCA))
+	mux.Handle("GET "+apiPrefix+"/cas/{type}/{name}/chain", s.auth(s.getChain))
+	mux.Handle("GET "+apiPrefix+"/cas/{type}/{name}/crl", s.auth(s.getCRL))
+	mux.Handle("POST "+apiPrefix+"/cas/{type}/{name}/crl", s.auth(s.generateCRL))
+	mux.Handle("POST "+apiPrefix+"/cas/{type}/{name}/certificates", s.auth(s.issue))
+	mux.Handle("GET "+apiPrefix+"/certificates", s.auth(s.listCertificates))
+	mux.Handle("GET "+apiPrefix+"/certificates/{name}", s.auth(s.getCertificate))
+	mux.Handle("GET "+apiPrefix+"/issued", s.auth(s.listIssued))
+	mux.Handle("POST "+apiPrefix+"/revocations", s.auth(s.revoke))
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newError(http.StatusNotFound, "no such endpoint: %s %s", r.Method, r.URL.Path))
+	})
+	s.handler = mux
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, client *Client)
+
+// auth 认证客户端，只读令牌不能调用GET以外的接口
+func (s *Server) auth(h handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, e := s.authenticate(r)
+		if e != nil {
+			writeError(w, e)
+			return
+		}
+
+		if r.Method != http.MethodGet && client.ReadOnly() {
+			writeError(w, newError(http.StatusForbidden, "the token is read-only"))
+			return
+		}
+
+		h(w, r, client)
+	})
+}
+
+// authenticate 优先使用TLS客户端证书，其次使用 Authorization: Bearer <令牌>
+func (s *Server) authenticate(r *http.Request) (*Client, *Error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 && s.verifyClientCert != nil {
+		err := s.verifyClientCert(r.TLS.PeerCertificates)
+		if err != nil {
+			return nil, newError(http.StatusUnauthorized, "client certificate verification failed: %s", err.Error())
+		}
+		return &Client{Certificate: r.TLS.PeerCertificates[0]}, nil
+	}
+
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && s.tokens != nil {
+		token, err := s.tokens.findToken(strings.TrimSpace(value))
+		if err != nil {
+			return nil, newError(http.StatusUnauthorized, "%s", err.Error())
+		}
+		return &Client{Token: token}, nil
+	}
+
+	return nil, newError(http.StatusUnauthorized, "authentication required")
+}
+
+// caType 读取路径中的CA类型（rca或ica）
+func caType(r *http.Request) (string, *Error) {
+	t := strings.ToUpper(r.PathValue("type"))
+	if t != "RCA" && t != "ICA" {
+		return "", newError(http.StatusNotFound, "unknown CA type: %s", r.PathValue("type"))
+	}
+	return t, nil
+}
@@ -0,0 +1,281 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Error 返回给客户端的错误，Backend 可以返回该错误以指定状态码
+type Error struct {
+	Status int
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+func newError(status int, format string, args ...any) *Error {
+	return &Error{
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	}
+}
+
+// NotFound 返回404错误
+func NotFound(format string, args ...any) error {
+	return newError(http.StatusNotFound, format, args...)
+}
+
+// backendError 将 Backend 返回的错误转换为 *Error
+func backendError(r *http.Request, err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	} else if errors.Is(err, fs.ErrNotExist) {
+		return newError(http.StatusNotFound, "%s", err.Error())
+	} else if r.Method == http.MethodGet {
+		return newError(http.StatusInternalServerError, "%s", err.Error())
+	}
+	return newError(http.StatusBadRequest, "%s", err.Error())
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	if e.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", bearerRealm))
+	}
+
+	writeJSON(w, e.Status, map[string]string{"error": e.Detail})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		status = http.StatusInternalServerError
+		data = []byte(`{"error": "encode response failed"}`)
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+	_, _ = w.Write([]byte("\n"))
+}
+
+func writeData(w http.ResponseWriter, contentType string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// readJSON 读取请求体中的JSON，不接受未知的字段（避免拼写错误的字段被静默忽略）
+func readJSON(r *http.Request, v any) *Error {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return newError(http.StatusBadRequest, "read request failed: %s", err.Error())
+	} else if len(data) > maxRequestSize {
+		return newError(http.StatusRequestEntityTooLarge, "the request is too large")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(v)
+	if err != nil {
+		return newError(http.StatusBadRequest, "not a valid JSON request: %s", err.Error())
+	} else if decoder.More() {
+		return newError(http.StatusBadRequest, "trailing data after the JSON request")
+	}
+
+	return nil
+}
+
+func (s *Server) getOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	writeData(w, contentTypeJSON, openAPIDocument)
+}
+
+func (s *Server) listCAs(w http.ResponseWriter, r *http.Request, _ *Client) {
+	t := strings.ToUpper(r.URL.Query().Get("type"))
+	if t != "" && t != "RCA" && t != "ICA" {
+		writeError(w, newError(http.StatusBadRequest, "unknown CA type: %s", r.URL.Query().Get("type")))
+		return
+	}
+
+	res, err := s.backend.ListCAs(t)
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getCA(w http.ResponseWriter, r *http.Request, _ *Client) {
+	t, e := caType(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	res, err := s.backend.GetCA(t, r.PathValue("name"))
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getChain(w http.ResponseWriter, r *http.Request, _ *Client) {
+	t, e := caType(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	res, err := s.backend.GetChain(t, r.PathValue("name"))
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeData(w, contentTypePEMChain, res)
+}
+
+// getCRL 返回已发布的CRL，默认为DER格式，format=pem 时返回PEM格式
+func (s *Server) getCRL(w http.ResponseWriter, r *http.Request, _ *Client) {
+	t, e := caType(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "der" && format != "pem" {
+		writeError(w, newError(http.StatusBadRequest, "unknown format: %s", format))
+		return
+	}
+
+	res, err := s.backend.GetCRL(t, r.PathValue("name"), r.URL.Query().Get("delta") == "true")
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	if format == "pem" {
+		writeData(w, contentTypePEM, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: res}))
+		return
+	}
+
+	writeData(w, contentTypePKIXCRL, res)
+}
+
+func (s *Server) generateCRL(w http.ResponseWriter, r *http.Request, client *Client) {
+	t, e := caType(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	var req CRLRequest
+	if e := readJSON(r, &req); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	res, err := s.backend.GenerateCRL(t, r.PathValue("name"), &req, client)
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func (s *Server) issue(w http.ResponseWriter, r *http.Request, client *Client) {
+	t, e := caType(r)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	var req IssueRequest
+	if e := readJSON(r, &req); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	res, err := s.backend.Issue(t, r.PathValue("name"), &req, client)
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, res)
+}
+
+func (s *Server) listCertificates(w http.ResponseWriter, r *http.Request, _ *Client) {
+	res, err := s.backend.ListCertificates()
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) getCertificate(w http.ResponseWriter, r *http.Request, _ *Client) {
+	res, err := s.backend.GetCertificate(r.PathValue("name"))
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) listIssued(w http.ResponseWriter, r *http.Request, _ *Client) {
+	q := r.URL.Query()
+	res, err := s.backend.ListIssued(&IssuedQuery{
+		IssuerType:    strings.ToUpper(q.Get("issuer_type")),
+		Issuer:        q.Get("issuer"),
+		Search:        q.Get("search"),
+		Status:        q.Get("status"),
+		Type:          strings.ToUpper(q.Get("type")),
+		ExpiresWithin: q.Get("expires_within"),
+	})
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) revoke(w http.ResponseWriter, r *http.Request, client *Client) {
+	var req RevokeRequest
+	if e := readJSON(r, &req); e != nil {
+		writeError(w, e)
+		return
+	}
+
+	res, err := s.backend.Revoke(&req, client)
+	if err != nil {
+		writeError(w, backendError(r, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,221 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package apiserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 访问令牌的状态（根据字段计算，不保存在文件中）
+const (
+	TokenActive  = "active"
+	TokenExpired = "expired"
+	TokenRevoked = "revoked"
+)
+
+// Token 访问令牌，保存在 tokens/<id>.json，只保存令牌密钥的SHA-256摘要，
+// 客户端使用的令牌为 <id>.<密钥>，只在创建时显示一次
+type Token struct {
+	metadata.Header
+
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash"`
+	Name      string     `json:"name"`      // 令牌的使用者，用于日志
+	ReadOnly  bool       `json:"read_only"` // 只读令牌只能调用GET接口
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (t *Token) Status() string {
+	switch {
+	case t.RevokedAt != nil:
+		return TokenRevoked
+	case time.Now().After(t.ExpiresAt):
+		return TokenExpired
+	default:
+		return TokenActive
+	}
+}
+
+var errInvalidToken = errors.New("the token is not valid")
+
+// TokenStore 将访问令牌保存在目录中（通常为 home/api），
+// 服务运行期间也可以由命令行创建和吊销令牌，因此每次都从文件中读取
+type TokenStore struct {
+	lock sync.Mutex
+	dir  string
+}
+
+func NewTokenStore(dir string) (*TokenStore, error) {
+	err := os.MkdirAll(path.Join(dir, "tokens"), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenStore{
+		dir: dir,
+	}, nil
+}
+
+func newRandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isValidTokenID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *TokenStore) tokenPath(id string) string {
+	return path.Join(s.dir, "tokens", id+".json")
+}
+
+// CreateToken 生成新的访问令牌，返回的令牌只在此时可见
+func (s *TokenStore) CreateToken(name string, validity time.Duration, readOnly bool) (*Token, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id, err := newRandomHex(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := newRandomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	token := &Token{
+		Header:    metadata.NewHeader(metadata.KindAPIToken),
+		ID:        id,
+		Hash:      hashToken(secret),
+		Name:      name,
+		ReadOnly:  readOnly,
+		CreatedAt: now,
+		ExpiresAt: now.Add(validity),
+	}
+
+	err = metadata.Write(s.tokenPath(id), token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return token, id + "." + secret, nil
+}
+
+func (s *TokenStore) getToken(id string) (*Token, error) {
+	if !isValidTokenID(id) {
+		return nil, errInvalidToken
+	}
+
+	var token Token
+	err := metadata.Read(s.tokenPath(id), metadata.KindAPIToken, &token)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListTokens 返回全部访问令牌（按创建时间排序）
+func (s *TokenStore) ListTokens() ([]*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(path.Join(s.dir, "tokens"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Token, 0, len(entries))
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !isValidTokenID(id) {
+			continue
+		}
+
+		token, err := s.getToken(id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, token)
+	}
+
+	slices.SortFunc(res, func(a, b *Token) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// RevokeToken 吊销访问令牌，立即生效
+func (s *TokenStore) RevokeToken(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	token, err := s.getToken(id)
+	if errors.Is(err, errInvalidToken) {
+		return fmt.Errorf("token %s not found", id)
+	} else if err != nil {
+		return err
+	} else if token.RevokedAt != nil {
+		return fmt.Errorf("token %s has been revoked", id)
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+	return metadata.Write(s.tokenPath(id), token)
+}
+
+// findToken 查找与令牌（<id>.<密钥>）匹配的有效令牌
+func (s *TokenStore) findToken(value string) (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id, secret, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+
+	token, err := s.getToken(id)
+	if err != nil {
+		return nil, err
+	} else if subtle.ConstantTimeCompare([]byte(token.Hash), []byte(hashToken(secret))) != 1 {
+		return nil, errInvalidToken
+	} else if token.Status() != TokenActive {
+		return nil, fmt.Errorf("the token is %s", token.Status())
+	}
+	return token, nil
+}
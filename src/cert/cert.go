@@ -2,18 +2,15 @@ package cert
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/gob"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/metadata"
 	"github.com/SongZihuan/MyCA/src/utils"
 	"math/big"
 	"net"
 	"net/url"
-	"os"
 	"time"
 )
 
@@ -22,13 +19,17 @@ type CAInfo interface {
 	GetIssuingCertificateURL() []string
 	GetOCSPServer() []string
 	GetCRLDistributionPoints() []string
+	GetSignatureAlgorithm() x509.SignatureAlgorithm
+	Reference() *metadata.Reference
 }
 
 type CertInfo struct {
-	SerialNumber       *big.Int
-	SelfCertificateURL []string
-	CA                 CAInfo
-	FilePath           string `gob:"-"`
+	SerialNumber       *big.Int            // 证书的序列号，旧版本创建的证书为0
+	SelfCertificateURL []string            // 已不再使用，仅用于读取旧版本的信息文件
+	CA                 CAInfo              // 签发CA，仅在签发证书时使用，不保存到信息文件中（旧版本的gob信息文件中保存了副本）
+	Issuer             *metadata.Reference // 对签发CA的引用
+	RandSource         string              // 生成私钥时使用的随机源，旧版本（使用math/rand）创建的证书为空
+	FilePath           string              `gob:"-"`
 }
 
 func NewCertInfo(filepath string, ca CAInfo) (*CertInfo, error) {
@@ -36,56 +37,135 @@ func NewCertInfo(filepath string, ca CAInfo) (*CertInfo, error) {
 		SerialNumber: big.NewInt(0),
 		FilePath:     filepath,
 		CA:           ca,
+		Issuer:       ca.Reference(),
+		RandSource:   utils.RandSource(),
 	}
 
 	return info, nil
 }
 
 func init() {
-	gob.Register(CertInfo{})
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/cert.CertInfo", &CertInfo{})
+}
+
+// certInfoFile 由CA签发的证书的信息文件（cert-info.json）的格式
+type certInfoFile struct {
+	metadata.Header
+	Issuer       *metadata.Reference `json:"issuer"`
+	SerialNumber string              `json:"serial_number,omitempty"`
+	RandSource   string              `json:"rand_source,omitempty"`
 }
 
 func GetCertInfo(filepath string) (*CertInfo, error) {
-	file, err := os.Open(filepath)
+	if metadata.NeedMigrate(filepath) {
+		info, _, err := migrateCertInfo(filepath)
+		if err != nil {
+			return nil, err
+		} else if info == nil {
+			return nil, fmt.Errorf("%s is a %s file, not %s", filepath, metadata.KindSelfCert, metadata.KindCert)
+		}
+		return info, nil
+	}
+
+	var f certInfoFile
+	err := metadata.Read(filepath, metadata.KindCert, &f)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	var res CertInfo
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&res)
+	serialNumber, err := metadata.ParseBigInt(f.SerialNumber)
 	if err != nil {
 		return nil, err
+	} else if serialNumber == nil {
+		serialNumber = big.NewInt(0)
 	}
 
-	res.FilePath = filepath
-
-	return &res, nil
+	return &CertInfo{
+		SerialNumber: serialNumber,
+		Issuer:       f.Issuer,
+		RandSource:   f.RandSource,
+		FilePath:     filepath,
+	}, nil
 }
 
 func (info *CertInfo) SaveCertInfo() error {
-	file, err := os.OpenFile(info.FilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	f := &certInfoFile{
+		Header:     metadata.NewHeader(metadata.KindCert),
+		Issuer:     info.Issuer,
+		RandSource: info.RandSource,
+	}
+
+	if info.SerialNumber != nil && info.SerialNumber.Sign() > 0 {
+		f.SerialNumber = metadata.FormatBigInt(info.SerialNumber)
+	}
+
+	return metadata.Write(info.FilePath, f)
+}
+
+// legacyCertInfo 旧版本的cert-info.gob中保存CertInfo或SelfCertInfo，使用两者字段的并集解码，CA不为nil时为CertInfo
+type legacyCertInfo struct {
+	SerialNumber          *big.Int
+	CA                    CAInfo
+	OCSPServer            []string
+	IssuingCertificateURL []string
+	CRLDistributionPoints []string
+	RandSource            string
+	SignatureAlgorithm    x509.SignatureAlgorithm
+}
+
+// migrateCertInfo 读取旧版本的cert-info.gob，保存为cert-info.json后删除旧文件，返回值中不为nil的一个为信息文件的内容
+func migrateCertInfo(filepath string) (*CertInfo, *SelfCertInfo, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var legacy legacyCertInfo
+	err := metadata.ReadLegacy(legacyPath, &legacy)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(*info) // 不需要以指针形式出现
+	var info *CertInfo
+	var selfInfo *SelfCertInfo
+
+	if legacy.CA != nil {
+		info = &CertInfo{
+			SerialNumber: legacy.SerialNumber,
+			Issuer:       legacy.CA.Reference(),
+			RandSource:   legacy.RandSource,
+			FilePath:     filepath,
+		}
+		err = info.SaveCertInfo()
+	} else {
+		selfInfo = &SelfCertInfo{
+			OCSPServer:            legacy.OCSPServer,
+			IssuingCertificateURL: legacy.IssuingCertificateURL,
+			CRLDistributionPoints: legacy.CRLDistributionPoints,
+			RandSource:            legacy.RandSource,
+			SignatureAlgorithm:    legacy.SignatureAlgorithm,
+			FilePath:              filepath,
+		}
+		err = selfInfo.SaveSelfCert()
+	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	return nil
+	err = metadata.RemoveLegacy(legacyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, selfInfo, nil
 }
 
+// GetSerialNumber 由签发CA分配证书的序列号，并记录在证书的信息中
 func (info *CertInfo) GetSerialNumber() (*big.Int, error) {
-	return info.CA.NewCertSerialNumber()
+	serialNumber, err := info.CA.NewCertSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	info.SerialNumber = new(big.Int).Set(serialNumber)
+	return serialNumber, nil
 }
 
 func (info *CertInfo) NewCert() *big.Int {
@@ -97,10 +177,7 @@ func (info *CertInfo) GetIssuingCertificateURL() []string {
 }
 
 // CreateCert 创建由CA签名的IP、域名证书
-func CreateCert(infoFilePath string, caInfo CAInfo, cryptoType utils.CryptoType, keyLength int, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey) (*x509.Certificate, crypto.PrivateKey, *CertInfo, error) {
-	var privKey crypto.PrivateKey
-	var pubKey crypto.PublicKey
-
+func CreateCert(infoFilePath string, caInfo CAInfo, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, policies []*utils.CertificatePolicy, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, crypto.PrivateKey, *CertInfo, error) {
 	info, err := NewCertInfo(infoFilePath, caInfo)
 	if err != nil {
 		return nil, nil, nil, err
@@ -117,41 +194,30 @@ func CreateCert(infoFilePath string, caInfo CAInfo, cryptoType utils.CryptoType,
 		extKeyUsage = utils.CopySlice(extKeyUsage)
 	}
 
-	switch cryptoType {
-	case utils.CryptoTypeRsa:
-		if keyLength != 2048 && keyLength != 4096 {
-			return nil, nil, nil, fmt.Errorf("unsupported RSA key length: %d", keyLength)
-		}
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if key != nil {
+		info.RandSource = utils.RandSourceExternal
+	}
 
-		priv, err := rsa.GenerateKey(utils.Rander(), keyLength)
-		if err != nil {
-			return nil, nil, nil, err
-		}
+	cert, err := createCert(info, pubKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, ca, caKey, signatureAlgorithm)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-		privKey = priv
-		pubKey = &priv.PublicKey
-	case utils.CryptoTypeEcc:
-		fallthrough
-	case utils.CryptoTypeEcdsa:
-		var curve elliptic.Curve
-		switch keyLength {
-		case 256:
-			curve = elliptic.P256()
-		case 384:
-			curve = elliptic.P384()
-		case 521:
-			curve = elliptic.P521()
-		default:
-			return nil, nil, nil, fmt.Errorf("unsupported ECC key length: %d", keyLength)
-		}
-		priv, err := ecdsa.GenerateKey(curve, utils.Rander())
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		privKey = priv
-		pubKey = &priv.PublicKey
-	default:
-		return nil, nil, nil, fmt.Errorf("unsupported crypto type: %s", cryptoType)
+	return cert, privKey, info, nil
+}
+
+// createCert 使用给定的公钥创建由CA签名的证书，signatureAlgorithm为0时使用CA的默认签名算法
+func createCert(info *CertInfo, pubKey crypto.PublicKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, policies []*utils.CertificatePolicy, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, error) {
+	if signatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		signatureAlgorithm = info.CA.GetSignatureAlgorithm()
+	}
+
+	err := utils.CheckSignatureAlgorithm(signatureAlgorithm, ca.PublicKey)
+	if err != nil {
+		return nil, err
 	}
 
 	if notBefore.Equal(time.Time{}) {
@@ -164,19 +230,20 @@ func CreateCert(infoFilePath string, caInfo CAInfo, cryptoType utils.CryptoType,
 
 	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+		return nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
 	}
 
 	serialNumber, err := info.GetSerialNumber()
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+		return nil, fmt.Errorf("get new serial number failed: %s", err.Error())
 	}
 
 	template := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject:      subject.ToPkixName(),
-		NotBefore:    notBefore,
-		NotAfter:     notAfter,
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: signatureAlgorithm,
+		Subject:            subject.ToPkixName(),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
 
 		KeyUsage:    keyUsage,
 		ExtKeyUsage: extKeyUsage, // 允许全部扩展用途
@@ -198,15 +265,20 @@ func CreateCert(infoFilePath string, caInfo CAInfo, cryptoType utils.CryptoType,
 		CRLDistributionPoints: info.CA.GetCRLDistributionPoints(),
 	}
 
+	err = utils.ApplyCertificatePolicies(template, policies)
+	if err != nil {
+		return nil, err
+	}
+
 	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
 	cert, err := x509.ParseCertificate(derBytes)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	return cert, privKey, info, nil
+	return cert, nil
 }
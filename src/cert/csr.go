@@ -0,0 +1,95 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cert
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/url"
+	"time"
+)
+
+// CreateCertFromCSR 根据外部生成的证书签名请求（PKCS#10）创建由CA签名的证书，私钥不经过MyCA
+func CreateCertFromCSR(infoFilePath string, caInfo CAInfo, csr *x509.CertificateRequest, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, policies []*utils.CertificatePolicy, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, *CertInfo, error) {
+	err := csr.CheckSignature()
+	if err != nil {
+		return nil, nil, fmt.Errorf("csr signature check failed: %s", err.Error())
+	}
+
+	return CreateCertFromPublicKey(infoFilePath, caInfo, csr.PublicKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, ca, caKey, signatureAlgorithm)
+}
+
+// CreateCertFromPublicKey 为外部持有的私钥对应的公钥创建由CA签名的证书，调用者需已验证请求者持有该私钥（例如CMP请求的POP）
+func CreateCertFromPublicKey(infoFilePath string, caInfo CAInfo, pubKey crypto.PublicKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, policies []*utils.CertificatePolicy, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, *CertInfo, error) {
+	info, err := NewCertInfo(infoFilePath, caInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = subject.SetCNIfEmpty(domains, ips, emails, urls) // 兜底，确保CN被设置
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if extKeyUsage == nil {
+		extKeyUsage = make([]x509.ExtKeyUsage, 0, 0)
+	} else {
+		extKeyUsage = utils.CopySlice(extKeyUsage)
+	}
+
+	err = CheckIssuePolicy(ca, pubKey, extKeyUsage, notBefore, notAfter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := createCert(info, pubKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, ca, caKey, signatureAlgorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, info, nil
+}
+
+// CheckIssuePolicy 检查待签发的证书是否符合签发CA的限制
+func CheckIssuePolicy(ca *x509.Certificate, pubKey crypto.PublicKey, extKeyUsage []x509.ExtKeyUsage, notBefore time.Time, notAfter time.Time) error {
+	err := utils.CheckPublicKey(pubKey)
+	if err != nil {
+		return err
+	}
+
+	if !notBefore.Equal(time.Time{}) && notBefore.Before(ca.NotBefore) {
+		return fmt.Errorf("the certificate is valid before the CA (%s)", ca.NotBefore.Format(time.DateTime))
+	}
+
+	if !notAfter.Equal(time.Time{}) && notAfter.After(ca.NotAfter) {
+		return fmt.Errorf("the certificate is valid after the CA expires (%s)", ca.NotAfter.Format(time.DateTime))
+	}
+
+	if len(ca.ExtKeyUsage) == 0 {
+		return nil
+	}
+
+	for _, caUsage := range ca.ExtKeyUsage {
+		if caUsage == x509.ExtKeyUsageAny {
+			return nil
+		}
+	}
+
+UsageCycle:
+	for _, usage := range extKeyUsage {
+		for _, caUsage := range ca.ExtKeyUsage {
+			if usage == caUsage {
+				continue UsageCycle
+			}
+		}
+		return fmt.Errorf("the ext key usage is not allowed by the CA: %d", usage)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cert
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"time"
+)
+
+// RenewCert 根据已有的证书重新签发由同一CA签名的证书
+// 主题、SAN、密钥用途、扩展密钥用途、证书策略以及AIA、CRL、OCSP地址均沿用旧证书，pubKey为新证书的公钥（重新生成私钥时与旧证书不同）
+func RenewCert(infoFilePath string, caInfo CAInfo, pubKey crypto.PublicKey, old *x509.Certificate, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, *CertInfo, error) {
+	info, err := NewCertInfo(infoFilePath, caInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if signatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		signatureAlgorithm = caInfo.GetSignatureAlgorithm()
+	}
+
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, ca.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = CheckIssuePolicy(ca, pubKey, old.ExtKeyUsage, notBefore, notAfter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+	}
+
+	serialNumber, err := info.GetSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+	}
+
+	template := renewTemplate(old, serialNumber, signatureAlgorithm, notBefore, notAfter, ski)
+	template.AuthorityKeyId = ca.SubjectKeyId
+
+	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, info, nil
+}
+
+// RenewSelfCert 根据已有的自签名证书重新签发自签名证书，signatureAlgorithm为0时由私钥决定
+func RenewSelfCert(infoFilePath string, key crypto.PrivateKey, old *x509.Certificate, notBefore time.Time, notAfter time.Time, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, *SelfCertInfo, error) {
+	info, err := NewSelfCertInfo(infoFilePath, old.OCSPServer, old.IssuingCertificateURL, old.CRLDistributionPoints)
+	if err != nil {
+		return nil, nil, err
+	}
+	info.SignatureAlgorithm = signatureAlgorithm
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("the private key can not be used to sign")
+	}
+	pubKey := signer.Public()
+
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+	}
+
+	serialNumber, err := info.NewCertSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+	}
+
+	template := renewTemplate(old, serialNumber, signatureAlgorithm, notBefore, notAfter, ski)
+	template.AuthorityKeyId = []byte(ski)
+
+	derBytes, err := x509.CreateCertificate(utils.Rander(), template, template, pubKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, info, nil
+}
+
+// renewTemplate 根据旧证书生成新证书的模板，主题使用旧证书的原始编码，避免重新编码后与旧证书不一致
+func renewTemplate(old *x509.Certificate, serialNumber *big.Int, signatureAlgorithm x509.SignatureAlgorithm, notBefore time.Time, notAfter time.Time, ski string) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: signatureAlgorithm,
+		RawSubject:         old.RawSubject,
+		Subject:            old.Subject,
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+
+		KeyUsage:           old.KeyUsage,
+		ExtKeyUsage:        utils.CopySlice(old.ExtKeyUsage),
+		UnknownExtKeyUsage: utils.CopySlice(old.UnknownExtKeyUsage),
+
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+
+		DNSNames:       utils.CopySlice(old.DNSNames),
+		IPAddresses:    utils.CopySlice(old.IPAddresses),
+		EmailAddresses: utils.CopySlice(old.EmailAddresses),
+		URIs:           utils.CopySlice(old.URIs),
+
+		SubjectKeyId: []byte(ski),
+
+		OCSPServer:            utils.CopySlice(old.OCSPServer),
+		IssuingCertificateURL: utils.CopySlice(old.IssuingCertificateURL),
+		CRLDistributionPoints: utils.CopySlice(old.CRLDistributionPoints),
+	}
+
+	// 证书策略扩展（包括CPS地址和用户通知）沿用旧证书的原始编码
+	for _, ext := range old.Extensions {
+		if ext.Id.Equal(utils.OIDExtensionCertificatePolicies) {
+			template.ExtraExtensions = append(template.ExtraExtensions, ext)
+		}
+	}
+
+	return template
+}
@@ -6,19 +6,15 @@ package cert
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/gob"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/metadata"
 	"github.com/SongZihuan/MyCA/src/utils"
 	"math/big"
 	"net"
 	"net/url"
-	"os"
 	"time"
 )
 
@@ -26,12 +22,14 @@ type SelfCertInfo struct {
 	OCSPServer            []string
 	IssuingCertificateURL []string
 	CRLDistributionPoints []string
+	RandSource            string                  // 生成私钥时使用的随机源，旧版本（使用math/rand）创建的证书为空
+	SignatureAlgorithm    x509.SignatureAlgorithm // 自签名使用的签名算法，为0时由私钥决定
 
 	FilePath string `gob:"-"`
 }
 
 func init() {
-	gob.Register(SelfCertInfo{})
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/cert.SelfCertInfo", &SelfCertInfo{})
 }
 
 func NewSelfCertInfo(filepath string, ocsp []string, issuerURL []string, crlURL []string) (*SelfCertInfo, error) {
@@ -39,53 +37,69 @@ func NewSelfCertInfo(filepath string, ocsp []string, issuerURL []string, crlURL
 		OCSPServer:            ocsp,
 		IssuingCertificateURL: issuerURL,
 		CRLDistributionPoints: crlURL,
+		RandSource:            utils.RandSource(),
 		FilePath:              filepath,
 	}
 
 	return info, nil
 }
 
+// selfCertInfoFile 自签名证书的信息文件（cert-info.json）的格式
+type selfCertInfoFile struct {
+	metadata.Header
+	OCSPServer            []string `json:"ocsp_server,omitempty"`
+	IssuingCertificateURL []string `json:"issuing_certificate_url,omitempty"`
+	CRLDistributionPoints []string `json:"crl_distribution_points,omitempty"`
+	RandSource            string   `json:"rand_source,omitempty"`
+	SignatureAlgorithm    string   `json:"signature_algorithm,omitempty"`
+}
+
 func GetSelfCertInfo(filepath string) (*SelfCertInfo, error) {
-	file, err := os.Open(filepath)
+	if metadata.NeedMigrate(filepath) {
+		_, info, err := migrateCertInfo(filepath)
+		if err != nil {
+			return nil, err
+		} else if info == nil {
+			return nil, fmt.Errorf("%s is a %s file, not %s", filepath, metadata.KindCert, metadata.KindSelfCert)
+		}
+		return info, nil
+	}
+
+	var f selfCertInfoFile
+	err := metadata.Read(filepath, metadata.KindSelfCert, &f)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	var res SelfCertInfo
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&res)
+	signatureAlgorithm, err := metadata.ParseSignatureAlgorithm(f.SignatureAlgorithm)
 	if err != nil {
 		return nil, err
 	}
 
-	res.FilePath = filepath
-
-	return &res, nil
+	return &SelfCertInfo{
+		OCSPServer:            f.OCSPServer,
+		IssuingCertificateURL: f.IssuingCertificateURL,
+		CRLDistributionPoints: f.CRLDistributionPoints,
+		RandSource:            f.RandSource,
+		SignatureAlgorithm:    signatureAlgorithm,
+		FilePath:              filepath,
+	}, nil
 }
 
 func (info *SelfCertInfo) SaveSelfCert() error {
-	file, err := os.OpenFile(info.FilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return err
-	}
-	defer func() {
-		_ = file.Close()
-	}()
-
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(*info) // 不需要以指针形式出现
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return metadata.Write(info.FilePath, &selfCertInfoFile{
+		Header:                metadata.NewHeader(metadata.KindSelfCert),
+		OCSPServer:            info.OCSPServer,
+		IssuingCertificateURL: info.IssuingCertificateURL,
+		CRLDistributionPoints: info.CRLDistributionPoints,
+		RandSource:            info.RandSource,
+		SignatureAlgorithm:    metadata.FormatSignatureAlgorithm(info.SignatureAlgorithm),
+	})
 }
 
+// NewCertSerialNumber 自签名证书没有签发索引，使用完全随机的128位序列号避免重复
 func (info *SelfCertInfo) NewCertSerialNumber() (*big.Int, error) {
-	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(40)))
+	return utils.NextSerialNumber(utils.SerialModeRandom, nil)
 }
 
 func (info *SelfCertInfo) GetIssuingCertificateURL() []string {
@@ -100,15 +114,17 @@ func (info *SelfCertInfo) GetCRLDistributionPoints() []string {
 	return info.CRLDistributionPoints
 }
 
-// CreateSelfCert 创建自签名域名、IP证书
-func CreateSelfCert(infoFilePath string, cryptoType utils.CryptoType, keyLength int, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, ocsp []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time) (*x509.Certificate, crypto.PrivateKey, *SelfCertInfo, error) {
-	var privKey crypto.PrivateKey
-	var pubKey crypto.PublicKey
+func (info *SelfCertInfo) GetSignatureAlgorithm() x509.SignatureAlgorithm {
+	return info.SignatureAlgorithm
+}
 
+// CreateSelfCert 创建自签名域名、IP证书
+func CreateSelfCert(infoFilePath string, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, domains []string, ips []net.IP, emails []string, urls []*url.URL, ocsp []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, crypto.PrivateKey, *SelfCertInfo, error) {
 	info, err := NewSelfCertInfo(infoFilePath, ocsp, selfURL, crlURL)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	info.SignatureAlgorithm = signatureAlgorithm
 
 	err = subject.SetCNIfEmpty() // 兜底，确保CN被设置
 	if err != nil {
@@ -121,41 +137,16 @@ func CreateSelfCert(infoFilePath string, cryptoType utils.CryptoType, keyLength
 		extKeyUsage = utils.CopySlice(extKeyUsage)
 	}
 
-	switch cryptoType {
-	case utils.CryptoTypeRsa:
-		if keyLength != 2048 && keyLength != 4096 {
-			return nil, nil, nil, fmt.Errorf("unsupported RSA key length: %d", keyLength)
-		}
-
-		priv, err := rsa.GenerateKey(utils.Rander(), keyLength)
-		if err != nil {
-			return nil, nil, nil, err
-		}
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if key != nil {
+		info.RandSource = utils.RandSourceExternal
+	}
 
-		privKey = priv
-		pubKey = &priv.PublicKey
-	case utils.CryptoTypeEcc:
-		fallthrough
-	case utils.CryptoTypeEcdsa:
-		var curve elliptic.Curve
-		switch keyLength {
-		case 256:
-			curve = elliptic.P256()
-		case 384:
-			curve = elliptic.P384()
-		case 521:
-			curve = elliptic.P521()
-		default:
-			return nil, nil, nil, fmt.Errorf("unsupported ECC key length: %d", keyLength)
-		}
-		priv, err := ecdsa.GenerateKey(curve, utils.Rander())
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		privKey = priv
-		pubKey = &priv.PublicKey
-	default:
-		return nil, nil, nil, fmt.Errorf("unsupported crypto type: %s", cryptoType)
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, pubKey)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	if notBefore.Equal(time.Time{}) {
@@ -177,10 +168,11 @@ func CreateSelfCert(infoFilePath string, cryptoType utils.CryptoType, keyLength
 	}
 
 	template := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject:      subject.ToPkixName(),
-		NotBefore:    notBefore,
-		NotAfter:     notAfter,
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: info.SignatureAlgorithm,
+		Subject:            subject.ToPkixName(),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
 
 		KeyUsage:    keyUsage,
 		ExtKeyUsage: extKeyUsage, // 允许全部扩展用途
@@ -0,0 +1,203 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmpserver
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+// 以下结构体对应 RFC 4210（CMP，显式标签）以及 RFC 4211（CRMF，隐式标签）中定义的ASN.1结构
+
+var (
+	oidPasswordBasedMAC = asn1.ObjectIdentifier{1, 2, 840, 113533, 7, 66, 13}
+	oidImplicitConfirm  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 4, 13}
+	oidRegCtrlOldCertID = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 5, 1, 5}
+
+	oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidExtensionReasonCode     = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+// PKIBody 的类型（标签）
+const (
+	bodyIR       = 0
+	bodyIP       = 1
+	bodyCR       = 2
+	bodyCP       = 3
+	bodyP10CR    = 4
+	bodyKUR      = 7
+	bodyKUP      = 8
+	bodyRR       = 11
+	bodyRP       = 12
+	bodyPKIConf  = 19
+	bodyError    = 23
+	bodyCertConf = 24
+)
+
+var bodyNames = map[int]string{
+	bodyIR:       "ir",
+	bodyCR:       "cr",
+	bodyP10CR:    "p10cr",
+	bodyKUR:      "kur",
+	bodyRR:       "rr",
+	bodyCertConf: "certConf",
+}
+
+// PKIStatus
+const (
+	statusAccepted  = 0
+	statusRejection = 2
+)
+
+// PKIFailureInfo 的位
+const (
+	failBadAlg             = 0
+	failBadMessageCheck    = 1
+	failBadRequest         = 2
+	failBadTime            = 3
+	failBadCertID          = 4
+	failBadDataFormat      = 5
+	failWrongAuthority     = 6
+	failBadPOP             = 9
+	failCertRevoked        = 10
+	failWrongIntegrity     = 12
+	failBadRecipientNonce  = 13
+	failBadCertTemplate    = 19
+	failSignerNotTrusted   = 20
+	failTransactionInUse   = 21
+	failUnsupportedVersion = 22
+	failNotAuthorized      = 23
+	failSystemFailure      = 25
+)
+
+type pkiMessage struct {
+	Header     asn1.RawValue
+	Body       asn1.RawValue   // [n] 显式标签的 PKIBody
+	Protection asn1.BitString  `asn1:"explicit,optional,tag:0"`
+	ExtraCerts []asn1.RawValue `asn1:"explicit,optional,tag:1"`
+}
+
+// protectedPart 保护（MAC或签名）覆盖的内容
+type protectedPart struct {
+	Header asn1.RawValue
+	Body   asn1.RawValue
+}
+
+type pkiHeader struct {
+	PVNO          int
+	Sender        asn1.RawValue            // GeneralName
+	Recipient     asn1.RawValue            // GeneralName
+	MessageTime   time.Time                `asn1:"generalized,explicit,optional,tag:0"`
+	ProtectionAlg pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:1"`
+	SenderKID     []byte                   `asn1:"explicit,optional,tag:2"`
+	RecipKID      []byte                   `asn1:"explicit,optional,tag:3"`
+	TransactionID []byte                   `asn1:"explicit,optional,tag:4"`
+	SenderNonce   []byte                   `asn1:"explicit,optional,tag:5"`
+	RecipNonce    []byte                   `asn1:"explicit,optional,tag:6"`
+	FreeText      asn1.RawValue            `asn1:"explicit,optional,tag:7"`
+	GeneralInfo   []infoTypeAndValue       `asn1:"explicit,optional,tag:8"`
+}
+
+type infoTypeAndValue struct {
+	InfoType  asn1.ObjectIdentifier
+	InfoValue asn1.RawValue `asn1:"optional"`
+}
+
+// pbmParameter RFC 4210 5.1.3.1
+type pbmParameter struct {
+	Salt           []byte
+	OWF            pkix.AlgorithmIdentifier
+	IterationCount int
+	MAC            pkix.AlgorithmIdentifier
+}
+
+type certReqMsg struct {
+	CertReq asn1.RawValue // certRequest，POP的签名覆盖其DER编码
+	POPO    asn1.RawValue `asn1:"optional"`
+	RegInfo asn1.RawValue `asn1:"optional"`
+}
+
+type certRequest struct {
+	CertReqID    *big.Int
+	CertTemplate certTemplate
+	Controls     []attributeTypeAndValue `asn1:"optional"`
+}
+
+type attributeTypeAndValue struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// certTemplate 使用隐式标签，Name为CHOICE类型因此使用显式标签
+type certTemplate struct {
+	Version      int              `asn1:"optional,tag:0"`
+	SerialNumber *big.Int         `asn1:"optional,tag:1"`
+	SigningAlg   asn1.RawValue    `asn1:"optional,tag:2"`
+	Issuer       asn1.RawValue    `asn1:"optional,explicit,tag:3"`
+	Validity     asn1.RawValue    `asn1:"optional,tag:4"`
+	Subject      asn1.RawValue    `asn1:"optional,explicit,tag:5"`
+	PublicKey    asn1.RawValue    `asn1:"optional,tag:6"` // SubjectPublicKeyInfo 的内容
+	IssuerUID    asn1.RawValue    `asn1:"optional,tag:7"`
+	SubjectUID   asn1.RawValue    `asn1:"optional,tag:8"`
+	Extensions   []pkix.Extension `asn1:"optional,tag:9"`
+}
+
+// popoSigningKey ProofOfPossession 的 signature [1]
+type popoSigningKey struct {
+	POPOSKInput asn1.RawValue `asn1:"optional,tag:0"`
+	Algorithm   pkix.AlgorithmIdentifier
+	Signature   asn1.BitString
+}
+
+// certID oldCertId 控制（RFC 4211 6.5）
+type certID struct {
+	Issuer       asn1.RawValue // GeneralName
+	SerialNumber *big.Int
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"` // PKIFreeText，UTF8String序列
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type certRepMessage struct {
+	CAPubs   []asn1.RawValue `asn1:"explicit,optional,tag:1"`
+	Response []certResponse
+}
+
+type certResponse struct {
+	CertReqID        *big.Int
+	Status           pkiStatusInfo
+	CertifiedKeyPair certifiedKeyPair `asn1:"optional"`
+}
+
+type certifiedKeyPair struct {
+	Certificate asn1.RawValue // CertOrEncCert 的 certificate [0]（显式标签，由 explicitTag 构造）
+}
+
+type revDetails struct {
+	CertDetails     certTemplate
+	CRLEntryDetails []pkix.Extension `asn1:"optional"`
+}
+
+type revRepContent struct {
+	Status []pkiStatusInfo
+}
+
+type certStatus struct {
+	CertHash   []byte
+	CertReqID  *big.Int
+	StatusInfo pkiStatusInfo            `asn1:"optional"`
+	HashAlg    pkix.AlgorithmIdentifier `asn1:"explicit,optional,tag:0"` // RFC 9480 CMP v3
+}
+
+type errorMsgContent struct {
+	PKIStatusInfo pkiStatusInfo
+	ErrorCode     int             `asn1:"optional"`
+	ErrorDetails  []asn1.RawValue `asn1:"optional"` // PKIFreeText
+}
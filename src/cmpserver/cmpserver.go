@@ -0,0 +1,848 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package cmpserver 实现 RFC 4210/9483 CMP（Certificate Management Protocol）服务，通过HTTP（RFC 6712）传输，
+// 支持 ir、cr、kur、p10cr、rr 和 certConf 消息，请求使用共享密钥的PBM或MyCA签发的证书的签名保护（ir和p10cr必须使用PBM）
+package cmpserver
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRequestSize = 64 * 1024
+
+const contentTypePKIXCMP = "application/pkixcmp"
+
+// confirmWaitTime 等待客户端发送 certConf 的时间，超时后不再接受确认（证书保持有效）
+const confirmWaitTime = 10 * time.Minute
+
+// IssueRequest 签发请求，调用时消息保护、持有证明（POP）和客户端的授权已检查
+type IssueRequest struct {
+	Type           string                   // ir、cr、kur 或 p10cr
+	CSR            *x509.CertificateRequest // p10cr 时为客户端的CSR，其余为nil
+	PublicKey      crypto.PublicKey
+	Subject        pkix.Name
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+	ExtKeyUsage    []x509.ExtKeyUsage
+	Old            *x509.Certificate // kur 时为被更新的证书（即请求的签名者证书）
+}
+
+// IssueFunc 签发证书，返回的错误以拒绝状态返回给客户端
+type IssueFunc func(req *IssueRequest) (*x509.Certificate, error)
+
+// BadCertTemplateError 请求的证书不被允许（例如请求了不允许的扩展密钥用途），IssueFunc 返回该错误时以 badCertTemplate 拒绝
+type BadCertTemplateError struct {
+	Err error
+}
+
+func (e *BadCertTemplateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BadCertTemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Config CMP服务的配置
+type Config struct {
+	CACerts []*x509.Certificate // 签发CA的证书链（签发CA在前），作为响应的 extraCerts
+	CAKey   crypto.PrivateKey   // 签发CA的私钥，用于签名没有使用PBM的请求的响应（未设置 Signer 时）
+	Store   *Store
+
+	Signer    *x509.Certificate // 可选的CMP签名证书（由签发CA签发），设置后使用其私钥签名响应
+	SignerKey crypto.PrivateKey
+	Issue     IssueFunc
+
+	VerifySigner func(chain []*x509.Certificate) error                    // 验证签名保护使用的证书（chain[0]）由MyCA管理的CA签发、未过期且未被吊销
+	Revoke       func(cert *x509.Certificate, reason revoke.Reason) error // 吊销签发CA签发的证书
+}
+
+// transaction 等待 certConf 的事务
+type transaction struct {
+	certReqID   *big.Int
+	cert        *x509.Certificate
+	secret      string            // 请求使用的共享密钥引用值，签名保护时为空
+	signer      *x509.Certificate // 请求的签名者证书
+	senderNonce []byte            // 响应中的 senderNonce，certConf 的 recipNonce 必须与其相同
+	expiresAt   time.Time
+}
+
+// response 响应的 PKIBody
+type response struct {
+	bodyType        int
+	body            []byte
+	implicitConfirm bool
+	senderNonce     []byte // 为nil时生成新的 senderNonce
+}
+
+// Server CMP服务，实现 http.Handler，请求的路径不影响处理（客户端通常使用 /.well-known/cmp）
+type Server struct {
+	lock         sync.Mutex
+	ca           *x509.Certificate
+	caCerts      []*x509.Certificate
+	caKey        crypto.PrivateKey
+	signer       *x509.Certificate // 签名响应使用的证书，未设置签名证书时为签发CA
+	signerKey    crypto.PrivateKey
+	store        *Store
+	issue        IssueFunc
+	verifySigner func(chain []*x509.Certificate) error
+	revoke       func(cert *x509.Certificate, reason revoke.Reason) error
+	transactions map[string]*transaction
+}
+
+func NewServer(config *Config) (*Server, error) {
+	if len(config.CACerts) == 0 || config.CAKey == nil || config.Store == nil || config.Issue == nil {
+		return nil, fmt.Errorf("the CA certificates, CA key, store and issue function must be set")
+	} else if config.VerifySigner == nil || config.Revoke == nil {
+		return nil, fmt.Errorf("the signer verification and revocation functions must be set")
+	}
+
+	err := utils.CheckKeyPair(config.CACerts[0], config.CAKey)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, signerKey := config.CACerts[0], config.CAKey
+	if config.Signer != nil {
+		if config.SignerKey == nil {
+			return nil, fmt.Errorf("the private key of the signer must be set")
+		} else if err := config.Signer.CheckSignatureFrom(config.CACerts[0]); err != nil {
+			return nil, fmt.Errorf("the signer is not issued by the CA: %s", err.Error())
+		} else if config.Signer.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+			return nil, fmt.Errorf("the signer does not have the digital signature key usage")
+		}
+
+		err = utils.CheckKeyPair(config.Signer, config.SignerKey)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, signerKey = config.Signer, config.SignerKey
+	}
+
+	return &Server{
+		ca:           config.CACerts[0],
+		caCerts:      config.CACerts,
+		caKey:        config.CAKey,
+		signer:       signer,
+		signerKey:    signerKey,
+		store:        config.Store,
+		issue:        config.Issue,
+		verifySigner: config.VerifySigner,
+		revoke:       config.Revoke,
+		transactions: make(map[string]*transaction),
+	}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "the CMP messages must be sent by POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if len(data) > maxRequestSize {
+		http.Error(w, "the request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	req, err := parseRequest(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("not a valid PKIMessage: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	res, err := s.handle(req)
+	if err != nil {
+		res = failure(failSystemFailure, err.Error())
+	}
+
+	msg, err := s.marshalResponse(req, res)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// RFC 6712 3.3 错误消息同样使用200状态码返回
+	w.Header().Set("Content-Type", contentTypePKIXCMP)
+	_, _ = w.Write(msg)
+}
+
+// failure 消息级别的错误（error消息）
+func failure(fail int, text string) *response {
+	body, err := asn1.Marshal(errorMsgContent{PKIStatusInfo: rejection(fail, text)})
+	if err != nil {
+		panic(err) // 编码固定的结构不会失败
+	}
+	return &response{bodyType: bodyError, body: body}
+}
+
+// handle 处理请求，客户端的错误以error消息或拒绝状态返回，服务端的错误返回error
+func (s *Server) handle(req *request) (*response, error) {
+	if req.header.PVNO != 2 && req.header.PVNO != 3 {
+		return failure(failUnsupportedVersion, fmt.Sprintf("unsupported pvno: %d", req.header.PVNO)), nil
+	} else if len(req.header.TransactionID) == 0 {
+		return failure(failBadRequest, "the transactionID must be set"), nil
+	}
+
+	res := s.verifyProtection(req)
+	if res != nil {
+		return res, nil
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	for id, tx := range s.transactions {
+		if now.After(tx.expiresAt) {
+			delete(s.transactions, id)
+		}
+	}
+
+	switch req.bodyType {
+	case bodyIR, bodyCR, bodyKUR:
+		return s.certRequest(req)
+	case bodyP10CR:
+		return s.p10Request(req)
+	case bodyRR:
+		return s.revocationRequest(req)
+	case bodyCertConf:
+		return s.certConf(req)
+	default:
+		return failure(failBadRequest, fmt.Sprintf("unsupported message type: [%d]", req.bodyType)), nil
+	}
+}
+
+// verifyProtection 验证消息的PBM或签名保护，验证失败时返回error消息
+func (s *Server) verifyProtection(req *request) *response {
+	alg := req.header.ProtectionAlg
+	if len(alg.Algorithm) == 0 || len(req.protection) == 0 {
+		return failure(failBadMessageCheck, "the message is not protected")
+	}
+
+	if alg.Algorithm.Equal(oidPasswordBasedMAC) {
+		secret, err := s.store.findSecret(string(req.header.SenderKID))
+		if errors.Is(err, errNotFound) {
+			return failure(failBadMessageCheck, "the secret of the senderKID is not found")
+		} else if err != nil {
+			return failure(failBadMessageCheck, err.Error())
+		}
+
+		err = verifyPBM(alg, []byte(secret.Secret), req.protected, req.protection)
+		if err != nil {
+			return failure(failBadMessageCheck, err.Error())
+		}
+
+		req.secret = secret
+		return nil
+	}
+
+	if len(req.extraCerts) == 0 {
+		return failure(failBadMessageCheck, "the certificate of the signer is not found in extraCerts")
+	}
+
+	signer := req.extraCerts[0]
+	err := verifySignature(signer.PublicKey, alg, req.protected, req.protection)
+	if err != nil {
+		return failure(failBadMessageCheck, err.Error())
+	}
+
+	err = s.verifySigner(req.extraCerts)
+	if err != nil {
+		return failure(failSignerNotTrusted, err.Error())
+	}
+
+	req.signer = signer
+	return nil
+}
+
+// isIssuedByCA 证书是否由签发CA签发
+func (s *Server) isIssuedByCA(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawIssuer, s.ca.RawSubject) && c.CheckSignatureFrom(s.ca) == nil
+}
+
+// certRep 只包含一个 CertResponse 的 CertRepMessage
+func certRep(bodyType int, certReqID *big.Int, status pkiStatusInfo, cert *x509.Certificate) (*response, error) {
+	res := certResponse{
+		CertReqID: certReqID,
+		Status:    status,
+	}
+
+	if cert != nil {
+		res.CertifiedKeyPair.Certificate = explicitTag(0, cert.Raw)
+	}
+
+	body, err := asn1.Marshal(certRepMessage{Response: []certResponse{res}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response{bodyType: bodyType, body: body}, nil
+}
+
+// certRequest 处理 ir、cr 和 kur，只支持包含一个 CertReqMsg 的请求（RFC 9483 4.1）
+func (s *Server) certRequest(req *request) (*response, error) {
+	repType := map[int]int{bodyIR: bodyIP, bodyCR: bodyCP, bodyKUR: bodyKUP}[req.bodyType]
+
+	var msgs []certReqMsg
+	rest, err := asn1.Unmarshal(req.body, &msgs)
+	if err != nil || len(rest) != 0 {
+		return failure(failBadDataFormat, "not a valid CertReqMessages"), nil
+	} else if len(msgs) != 1 {
+		return failure(failBadRequest, "only one certificate request is supported in a message"), nil
+	}
+
+	var cr certRequest
+	rest, err = asn1.Unmarshal(msgs[0].CertReq.FullBytes, &cr)
+	if err != nil || len(rest) != 0 || cr.CertReqID == nil {
+		return failure(failBadDataFormat, "not a valid CertRequest"), nil
+	}
+
+	switch req.bodyType {
+	case bodyIR:
+		if req.secret == nil {
+			return failure(failNotAuthorized, "the ir must be protected by the PBM of a shared secret"), nil
+		}
+	case bodyCR:
+		if req.signer != nil && !s.isIssuedByCA(req.signer) {
+			return failure(failWrongAuthority, "the certificate signing the cr is not issued by this CA"), nil
+		}
+	case bodyKUR:
+		if req.signer == nil {
+			return failure(failNotAuthorized, "the kur must be protected by the signature of the certificate to update"), nil
+		} else if !s.isIssuedByCA(req.signer) {
+			return failure(failWrongAuthority, "the certificate to update is not issued by this CA"), nil
+		}
+	}
+
+	issueReq, status := s.parseCertTemplate(&cr.CertTemplate)
+	if status != nil {
+		return certRep(repType, cr.CertReqID, *status, nil)
+	}
+
+	err = verifyPOP(msgs[0].POPO, msgs[0].CertReq.FullBytes, issueReq.PublicKey)
+	if err != nil {
+		return certRep(repType, cr.CertReqID, rejection(failBadPOP, err.Error()), nil)
+	}
+
+	if req.bodyType == bodyKUR {
+		status = s.prepareKeyUpdate(req.signer, &cr, issueReq)
+		if status != nil {
+			return certRep(repType, cr.CertReqID, *status, nil)
+		}
+	} else if req.bodyType == bodyCR && req.signer != nil {
+		status = matchSignerNames(req.signer, issueReq)
+		if status != nil {
+			return certRep(repType, cr.CertReqID, *status, nil)
+		}
+	}
+
+	issueReq.Type = bodyNames[req.bodyType]
+	return s.issueCertificate(req, repType, cr.CertReqID, issueReq)
+}
+
+// parseCertTemplate 根据 CertTemplate 生成签发请求，模板无效时返回拒绝状态
+func (s *Server) parseCertTemplate(t *certTemplate) (*IssueRequest, *pkiStatusInfo) {
+	reject := func(format string, a ...any) (*IssueRequest, *pkiStatusInfo) {
+		status := rejection(failBadCertTemplate, fmt.Sprintf(format, a...))
+		return nil, &status
+	}
+
+	if len(t.PublicKey.Bytes) == 0 {
+		return reject("the public key must be set, central key generation is not supported")
+	}
+
+	pub, err := parsePublicKey(t.PublicKey)
+	if err != nil {
+		return reject("not a valid public key: %s", err.Error())
+	}
+
+	res := &IssueRequest{
+		PublicKey: pub,
+	}
+
+	if len(t.Issuer.Bytes) != 0 {
+		_, rawIssuer, err := parseName(t.Issuer)
+		if err != nil {
+			return reject("not a valid issuer: %s", err.Error())
+		} else if !bytes.Equal(rawIssuer, s.ca.RawSubject) {
+			status := rejection(failWrongAuthority, "the issuer in the template is not this CA")
+			return nil, &status
+		}
+	}
+
+	if len(t.Subject.Bytes) != 0 {
+		res.Subject, _, err = parseName(t.Subject)
+		if err != nil {
+			return reject("not a valid subject: %s", err.Error())
+		}
+	}
+
+	for _, ext := range t.Extensions {
+		if !ext.Id.Equal(oidExtensionSubjectAltName) {
+			continue
+		}
+
+		san, err := parseSubjectAltName(ext.Value)
+		if err != nil {
+			return reject("%s", err.Error())
+		}
+
+		res.DNSNames = san.DNSNames
+		res.EmailAddresses = san.EmailAddresses
+		res.IPAddresses = san.IPAddresses
+		res.URIs = san.URIs
+	}
+
+	res.ExtKeyUsage, _, _, err = utils.ParseCSRExtKeyUsage(&x509.CertificateRequest{Extensions: t.Extensions})
+	if err != nil {
+		return reject("not a valid ext key usage: %s", err.Error())
+	}
+
+	return res, nil
+}
+
+// prepareKeyUpdate 检查 kur 的旧证书，模板没有主题和SAN时沿用旧证书的值，否则必须与旧证书相同
+func (s *Server) prepareKeyUpdate(old *x509.Certificate, cr *certRequest, issueReq *IssueRequest) *pkiStatusInfo {
+	for _, control := range cr.Controls {
+		if !control.Type.Equal(oidRegCtrlOldCertID) {
+			continue
+		}
+
+		var id certID
+		_, err := asn1.Unmarshal(control.Value.FullBytes, &id)
+		if err != nil || id.SerialNumber == nil {
+			status := rejection(failBadDataFormat, "not a valid oldCertId")
+			return &status
+		} else if id.SerialNumber.Cmp(old.SerialNumber) != 0 {
+			status := rejection(failBadCertID, "the oldCertId does not match the certificate signing the request")
+			return &status
+		}
+	}
+
+	if pub, ok := old.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok && pub.Equal(issueReq.PublicKey) {
+		status := rejection(failBadCertTemplate, "the key update request must use a new public key")
+		return &status
+	}
+
+	status := matchSignerNames(old, issueReq)
+	if status != nil {
+		return status
+	}
+
+	if len(issueReq.ExtKeyUsage) == 0 {
+		issueReq.ExtKeyUsage = old.ExtKeyUsage
+	}
+
+	issueReq.Old = old
+	return nil
+}
+
+// matchSignerNames 模板没有主题和SAN时沿用签名者证书的值，否则主题和SAN必须与签名者证书相同，
+// 避免使用任一有效证书申请或更新得到其他名称的证书
+func matchSignerNames(signer *x509.Certificate, issueReq *IssueRequest) *pkiStatusInfo {
+	if len(issueReq.Subject.Names) == 0 && len(issueReq.DNSNames) == 0 && len(issueReq.EmailAddresses) == 0 && len(issueReq.IPAddresses) == 0 && len(issueReq.URIs) == 0 {
+		issueReq.Subject = signer.Subject
+		issueReq.DNSNames = signer.DNSNames
+		issueReq.EmailAddresses = signer.EmailAddresses
+		issueReq.IPAddresses = signer.IPAddresses
+		issueReq.URIs = signer.URIs
+		return nil
+	}
+
+	if issueReq.Subject.String() != signer.Subject.String() {
+		status := rejection(failBadCertTemplate, fmt.Sprintf("the subject in the template (%s) is not the same as the signer certificate (%s)", issueReq.Subject.String(), signer.Subject.String()))
+		return &status
+	}
+
+	reqIPs := make([]string, 0, len(issueReq.IPAddresses))
+	for _, ip := range issueReq.IPAddresses {
+		reqIPs = append(reqIPs, ip.String())
+	}
+	signerIPs := make([]string, 0, len(signer.IPAddresses))
+	for _, ip := range signer.IPAddresses {
+		signerIPs = append(signerIPs, ip.String())
+	}
+
+	reqURIs := make([]string, 0, len(issueReq.URIs))
+	for _, u := range issueReq.URIs {
+		reqURIs = append(reqURIs, u.String())
+	}
+	signerURIs := make([]string, 0, len(signer.URIs))
+	for _, u := range signer.URIs {
+		signerURIs = append(signerURIs, u.String())
+	}
+
+	if !sameSet(issueReq.DNSNames, signer.DNSNames) || !sameSet(reqIPs, signerIPs) || !sameSet(issueReq.EmailAddresses, signer.EmailAddresses) || !sameSet(reqURIs, signerURIs) {
+		status := rejection(failBadCertTemplate, "the subject alternative names in the template are not the same as the signer certificate")
+		return &status
+	}
+
+	return nil
+}
+
+// sameSet 忽略大小写、顺序和重复项比较两组名称
+func sameSet(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	for i := range a {
+		a[i] = strings.ToLower(a[i])
+	}
+	for i := range b {
+		b[i] = strings.ToLower(b[i])
+	}
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// verifyPOP 验证签名形式的持有证明（RFC 4211 4.1），签名覆盖 CertRequest 的DER编码
+func verifyPOP(popo asn1.RawValue, certReq []byte, pub crypto.PublicKey) error {
+	if len(popo.FullBytes) == 0 || popo.Class != asn1.ClassContextSpecific || popo.Tag != 1 {
+		return fmt.Errorf("only the signature proof-of-possession is supported")
+	}
+
+	var sk popoSigningKey
+	_, err := asn1.UnmarshalWithParams(popo.FullBytes, &sk, "tag:1")
+	if err != nil {
+		return fmt.Errorf("not a valid POPOSigningKey: %s", err.Error())
+	} else if len(sk.POPOSKInput.FullBytes) != 0 {
+		return fmt.Errorf("the poposkInput is not supported")
+	}
+
+	err = verifySignature(pub, sk.Algorithm, certReq, sk.Signature.RightAlign())
+	if err != nil {
+		return fmt.Errorf("the proof-of-possession signature is not valid: %s", err.Error())
+	}
+	return nil
+}
+
+// p10Request 处理 p10cr，响应为 cp，certReqId 固定为-1（RFC 4210 5.3.4）
+func (s *Server) p10Request(req *request) (*response, error) {
+	certReqID := big.NewInt(-1)
+
+	if req.secret == nil {
+		return failure(failNotAuthorized, "the p10cr must be protected by the PBM of a shared secret"), nil
+	}
+
+	csr, err := x509.ParseCertificateRequest(req.body)
+	if err != nil {
+		return failure(failBadDataFormat, fmt.Sprintf("not a valid CSR: %s", err.Error())), nil
+	} else if csr.CheckSignature() != nil {
+		return certRep(bodyCP, certReqID, rejection(failBadPOP, "the signature of the CSR is not valid"), nil)
+	}
+
+	extKeyUsage, _, _, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		return certRep(bodyCP, certReqID, rejection(failBadCertTemplate, fmt.Sprintf("not a valid ext key usage: %s", err.Error())), nil)
+	}
+
+	return s.issueCertificate(req, bodyCP, certReqID, &IssueRequest{
+		Type:           bodyNames[bodyP10CR],
+		CSR:            csr,
+		PublicKey:      csr.PublicKey,
+		Subject:        csr.Subject,
+		DNSNames:       csr.DNSNames,
+		EmailAddresses: csr.EmailAddresses,
+		IPAddresses:    csr.IPAddresses,
+		URIs:           csr.URIs,
+		ExtKeyUsage:    extKeyUsage,
+	})
+}
+
+// issueCertificate 签发证书，客户端未请求 implicitConfirm 时等待其发送 certConf（调用者需持有锁）
+func (s *Server) issueCertificate(req *request, repType int, certReqID *big.Int, issueReq *IssueRequest) (*response, error) {
+	id := hex.EncodeToString(req.header.TransactionID)
+	if _, ok := s.transactions[id]; ok {
+		return failure(failTransactionInUse, "the transactionID is in use"), nil
+	}
+
+	if req.secret != nil {
+		// 重新读取共享密钥，避免并发的请求超过其可签发的证书数
+		secret, err := s.store.findSecret(req.secret.Reference)
+		if err != nil {
+			return certRep(repType, certReqID, rejection(failNotAuthorized, err.Error()), nil)
+		} else if secret.Status() != SecretActive {
+			return certRep(repType, certReqID, rejection(failNotAuthorized, fmt.Sprintf("the secret %s is %s", secret.Reference, secret.Status())), nil)
+		}
+	}
+
+	userCert, err := s.issue(issueReq)
+	if err != nil {
+		var templateErr *BadCertTemplateError
+		if errors.As(err, &templateErr) {
+			return certRep(repType, certReqID, rejection(failBadCertTemplate, err.Error()), nil)
+		}
+		return certRep(repType, certReqID, rejection(failBadRequest, err.Error()), nil)
+	}
+
+	if req.secret != nil {
+		err = s.store.useSecret(req.secret.Reference)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := certRep(repType, certReqID, accepted(), userCert)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.implicitConfirm() {
+		res.implicitConfirm = true
+		return res, nil
+	}
+
+	res.senderNonce, err = newNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &transaction{
+		certReqID:   certReqID,
+		cert:        userCert,
+		signer:      req.signer,
+		senderNonce: res.senderNonce,
+		expiresAt:   time.Now().Add(confirmWaitTime),
+	}
+	if req.secret != nil {
+		tx.secret = req.secret.Reference
+	}
+
+	s.transactions[id] = tx
+	return res, nil
+}
+
+// revocationRequest 处理 rr，请求必须使用被吊销的证书签名，只支持包含一个 RevDetails 的请求（RFC 9483 4.2）
+func (s *Server) revocationRequest(req *request) (*response, error) {
+	revRep := func(status pkiStatusInfo) (*response, error) {
+		body, err := asn1.Marshal(revRepContent{Status: []pkiStatusInfo{status}})
+		if err != nil {
+			return nil, err
+		}
+		return &response{bodyType: bodyRP, body: body}, nil
+	}
+
+	var details []revDetails
+	rest, err := asn1.Unmarshal(req.body, &details)
+	if err != nil || len(rest) != 0 {
+		return failure(failBadDataFormat, "not a valid RevReqContent"), nil
+	} else if len(details) != 1 {
+		return failure(failBadRequest, "only one revocation request is supported in a message"), nil
+	} else if req.signer == nil {
+		return failure(failNotAuthorized, "the rr must be protected by the signature of the certificate to revoke"), nil
+	}
+
+	t := details[0].CertDetails
+	if t.SerialNumber == nil {
+		return revRep(rejection(failBadCertID, "the serial number must be set"))
+	}
+
+	if len(t.Issuer.Bytes) != 0 {
+		_, rawIssuer, err := parseName(t.Issuer)
+		if err != nil || !bytes.Equal(rawIssuer, s.ca.RawSubject) {
+			return revRep(rejection(failBadCertID, "the issuer is not this CA"))
+		}
+	}
+
+	if !s.isIssuedByCA(req.signer) || req.signer.SerialNumber.Cmp(t.SerialNumber) != 0 {
+		return revRep(rejection(failNotAuthorized, "only the certificate signing the request can be revoked"))
+	}
+
+	reason := revoke.ReasonUnspecified
+	for _, ext := range details[0].CRLEntryDetails {
+		if !ext.Id.Equal(oidExtensionReasonCode) {
+			continue
+		}
+
+		var code asn1.Enumerated
+		_, err := asn1.Unmarshal(ext.Value, &code)
+		if err != nil || code < 0 || code > 10 || code == 7 || revoke.Reason(code) == revoke.ReasonRemoveFromCRL {
+			return revRep(rejection(failBadRequest, "not a valid reason code"))
+		}
+		reason = revoke.Reason(code)
+	}
+
+	err = s.revoke(req.signer, reason)
+	if err != nil {
+		return revRep(rejection(failBadRequest, err.Error()))
+	}
+
+	return revRep(accepted())
+}
+
+// certConf 处理证书确认，客户端拒绝证书时吊销该证书，响应为 pkiconf
+func (s *Server) certConf(req *request) (*response, error) {
+	id := hex.EncodeToString(req.header.TransactionID)
+	tx, ok := s.transactions[id]
+	if !ok {
+		return failure(failBadRequest, "no certificate of the transaction is waiting for confirmation"), nil
+	} else if !bytes.Equal(req.header.RecipNonce, tx.senderNonce) {
+		return failure(failBadRecipientNonce, "the recipNonce does not match"), nil
+	}
+
+	// certConf 必须使用与请求相同的共享密钥，或者由请求的签名者（或新证书）签名
+	if tx.secret != "" {
+		if req.secret == nil || req.secret.Reference != tx.secret {
+			return failure(failWrongIntegrity, "the certConf must be protected by the secret of the request"), nil
+		}
+	} else if req.signer == nil || (!bytes.Equal(req.signer.Raw, tx.signer.Raw) && !bytes.Equal(req.signer.Raw, tx.cert.Raw)) {
+		return failure(failWrongIntegrity, "the certConf must be signed by the signer of the request or the new certificate"), nil
+	}
+
+	var statuses []certStatus
+	rest, err := asn1.Unmarshal(req.body, &statuses)
+	if err != nil || len(rest) != 0 {
+		return failure(failBadDataFormat, "not a valid CertConfirmContent"), nil
+	}
+
+	delete(s.transactions, id)
+
+	// 空的 CertConfirmContent 表示拒绝全部证书（RFC 4210 5.3.18）
+	rejected := len(statuses) == 0
+	if !rejected {
+		status := statuses[0]
+		if len(statuses) != 1 || status.CertReqID == nil || status.CertReqID.Cmp(tx.certReqID) != 0 {
+			return failure(failBadCertID, "the certReqId does not match"), nil
+		}
+
+		var hashAlg *pkix.AlgorithmIdentifier
+		if len(status.HashAlg.Algorithm) != 0 {
+			hashAlg = &status.HashAlg
+		}
+
+		hash, err := certHash(tx.cert, hashAlg)
+		if err != nil {
+			return failure(failBadAlg, err.Error()), nil
+		} else if !bytes.Equal(hash, status.CertHash) {
+			return failure(failBadCertID, "the certHash does not match the certificate"), nil
+		}
+
+		rejected = status.StatusInfo.Status == statusRejection
+	}
+
+	if rejected {
+		err = s.revoke(tx.cert, revoke.ReasonCessationOfOperation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &response{bodyType: bodyPKIConf, body: asn1.NullBytes}, nil
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, 16)
+	_, err := io.ReadFull(utils.Rander(), nonce)
+	if err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// marshalResponse 生成响应，请求使用PBM且验证通过时使用相同的共享密钥保护响应，否则使用签名证书（或CA）的私钥签名
+func (s *Server) marshalResponse(req *request, res *response) ([]byte, error) {
+	senderNonce := res.senderNonce
+	if senderNonce == nil {
+		var err error
+		senderNonce, err = newNonce()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pvno := 2
+	if req.header.PVNO == 3 {
+		pvno = 3
+	}
+
+	recipient := req.header.Sender
+	if len(recipient.FullBytes) == 0 {
+		recipient = directoryName([]byte{0x30, 0x00}) // NULL-DN
+	}
+
+	header := pkiHeader{
+		PVNO:          pvno,
+		Sender:        directoryName(s.signer.RawSubject),
+		Recipient:     recipient,
+		MessageTime:   time.Now().UTC().Truncate(time.Second),
+		TransactionID: req.header.TransactionID,
+		SenderNonce:   senderNonce,
+		RecipNonce:    req.header.SenderNonce,
+	}
+
+	if res.implicitConfirm {
+		header.GeneralInfo = []infoTypeAndValue{{InfoType: oidImplicitConfirm, InfoValue: asn1.RawValue{FullBytes: asn1.NullBytes}}}
+	}
+
+	body := explicitTag(res.bodyType, res.body)
+
+	var headerRaw asn1.RawValue
+	protected := func(alg pkix.AlgorithmIdentifier) ([]byte, error) {
+		header.ProtectionAlg = alg
+
+		headerDER, err := asn1.Marshal(header)
+		if err != nil {
+			return nil, err
+		}
+
+		headerRaw = asn1.RawValue{FullBytes: headerDER}
+		return asn1.Marshal(protectedPart{Header: headerRaw, Body: body})
+	}
+
+	var protection []byte
+	var err error
+	if req.secret != nil {
+		header.RecipKID = req.header.SenderKID
+		_, protection, err = protectPBM(req.header.ProtectionAlg, []byte(req.secret.Secret), protected)
+	} else {
+		header.SenderKID = s.signer.SubjectKeyId
+		_, protection, err = protectSignature(s.signerKey, s.signer.PublicKey, protected)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// RFC 9483 3.3 签名保护使用的证书必须是 extraCerts 中的第一个证书
+	extraCerts := make([]asn1.RawValue, 0, len(s.caCerts)+1)
+	if s.signer != s.ca {
+		extraCerts = append(extraCerts, asn1.RawValue{FullBytes: s.signer.Raw})
+	}
+	for _, c := range s.caCerts {
+		extraCerts = append(extraCerts, asn1.RawValue{FullBytes: c.Raw})
+	}
+
+	return asn1.Marshal(pkiMessage{
+		Header:     headerRaw,
+		Body:       body,
+		Protection: asn1.BitString{Bytes: protection, BitLength: len(protection) * 8},
+		ExtraCerts: extraCerts,
+	})
+}
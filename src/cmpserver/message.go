@@ -0,0 +1,204 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmpserver
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// request 解析后的请求
+type request struct {
+	header     pkiHeader
+	bodyType   int
+	body       []byte // PKIBody 中的内容（不包含标签）
+	protected  []byte // ProtectedPart 的DER编码
+	protection []byte
+	extraCerts []*x509.Certificate
+
+	secret *Secret           // 使用PBM保护且验证通过时为使用的共享密钥
+	signer *x509.Certificate // 使用签名保护且验证通过时为签名者的证书（extraCerts中的第一个证书）
+}
+
+func parseRequest(data []byte) (*request, error) {
+	var msg pkiMessage
+	rest, err := asn1.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after PKIMessage")
+	}
+
+	req := &request{
+		protection: msg.Protection.RightAlign(),
+	}
+
+	rest, err = asn1.Unmarshal(msg.Header.FullBytes, &req.header)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid PKIHeader: %s", err.Error())
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after PKIHeader")
+	}
+
+	// PKIBody 是显式标签的CHOICE，encoding/asn1 不会解开 RawValue 的显式标签
+	if msg.Body.Class != asn1.ClassContextSpecific || !msg.Body.IsCompound {
+		return nil, fmt.Errorf("not a valid PKIBody")
+	}
+	req.bodyType = msg.Body.Tag
+	req.body, err = elementContent(msg.Body.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid PKIBody: %s", err.Error())
+	}
+
+	req.protected, err = asn1.Marshal(protectedPart{Header: msg.Header, Body: msg.Body})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, raw := range msg.ExtraCerts {
+		c, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid certificate in extraCerts: %s", err.Error())
+		}
+		req.extraCerts = append(req.extraCerts, c)
+	}
+
+	return req, nil
+}
+
+// elementContent 检查数据是单个完整的元素并返回该元素（p10cr为CSR，其余为SEQUENCE）
+func elementContent(data []byte) ([]byte, error) {
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data")
+	}
+	return raw.FullBytes, nil
+}
+
+// implicitConfirm 请求是否包含 implicitConfirm（RFC 4210 5.1.1.1）
+func (r *request) implicitConfirm() bool {
+	for _, info := range r.header.GeneralInfo {
+		if info.InfoType.Equal(oidImplicitConfirm) {
+			return true
+		}
+	}
+	return false
+}
+
+// explicitTag 构造显式标签，encoding/asn1 编码 RawValue 时会忽略结构体标签中的 explicit
+func explicitTag(tag int, inner []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: inner}
+}
+
+// directoryName GeneralName 的 directoryName [4]
+func directoryName(rawName []byte) asn1.RawValue {
+	return explicitTag(4, rawName)
+}
+
+// freeText PKIFreeText，必须使用UTF8String
+func freeText(text string) []asn1.RawValue {
+	if text == "" {
+		return nil
+	}
+	return []asn1.RawValue{{Tag: asn1.TagUTF8String, Bytes: []byte(text)}}
+}
+
+func failureInfo(bit int) asn1.BitString {
+	b := make([]byte, bit/8+1)
+	b[bit/8] = 0x80 >> (bit % 8)
+	return asn1.BitString{Bytes: b, BitLength: bit + 1}
+}
+
+func accepted() pkiStatusInfo {
+	return pkiStatusInfo{Status: statusAccepted}
+}
+
+func rejection(fail int, text string) pkiStatusInfo {
+	return pkiStatusInfo{
+		Status:       statusRejection,
+		StatusString: freeText(text),
+		FailInfo:     failureInfo(fail),
+	}
+}
+
+// parseName 解析 CertTemplate 中的 Name（显式标签内的SEQUENCE）
+func parseName(raw asn1.RawValue) (pkix.Name, []byte, error) {
+	var rdn pkix.RDNSequence
+	rest, err := asn1.Unmarshal(raw.Bytes, &rdn)
+	if err != nil {
+		return pkix.Name{}, nil, err
+	} else if len(rest) != 0 {
+		return pkix.Name{}, nil, fmt.Errorf("trailing data after name")
+	}
+
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdn)
+	return name, raw.Bytes, nil
+}
+
+// parsePublicKey 解析 CertTemplate 中隐式标签的 SubjectPublicKeyInfo
+func parsePublicKey(raw asn1.RawValue) (crypto.PublicKey, error) {
+	spki, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: raw.Bytes})
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParsePKIXPublicKey(spki)
+}
+
+// subjectAltName 证书模板中的SAN
+type subjectAltName struct {
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+}
+
+// parseSubjectAltName 解析SAN扩展（RFC 5280 4.2.1.6），只支持域名、邮箱、IP和URI
+func parseSubjectAltName(value []byte) (*subjectAltName, error) {
+	var names []asn1.RawValue
+	rest, err := asn1.Unmarshal(value, &names)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after subject alternative name")
+	}
+
+	res := new(subjectAltName)
+	for _, n := range names {
+		if n.Class != asn1.ClassContextSpecific {
+			return nil, fmt.Errorf("not a valid general name")
+		}
+
+		switch n.Tag {
+		case 1:
+			res.EmailAddresses = append(res.EmailAddresses, string(n.Bytes))
+		case 2:
+			res.DNSNames = append(res.DNSNames, string(n.Bytes))
+		case 6:
+			u, err := url.Parse(string(n.Bytes))
+			if err != nil {
+				return nil, fmt.Errorf("not a valid URI: %s", string(n.Bytes))
+			}
+			res.URIs = append(res.URIs, u)
+		case 7:
+			if len(n.Bytes) != net.IPv4len && len(n.Bytes) != net.IPv6len {
+				return nil, fmt.Errorf("not a valid IP address")
+			}
+			res.IPAddresses = append(res.IPAddresses, append(net.IP{}, n.Bytes...))
+		default:
+			return nil, fmt.Errorf("unsupported type of subject alternative name: [%d]", n.Tag)
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmpserver
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"hash"
+	"io"
+)
+
+// pbmMaxIterationCount PBM的最大迭代次数，避免客户端通过过大的迭代次数消耗服务端资源
+const pbmMaxIterationCount = 100000
+
+// pbmIterationCount 请求的PBM参数无效时响应使用的迭代次数
+const pbmIterationCount = 10000
+
+var owfOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+var macOIDs = map[crypto.Hash][]asn1.ObjectIdentifier{
+	crypto.SHA1:   {{1, 3, 6, 1, 5, 5, 8, 1, 2}, {1, 2, 840, 113549, 2, 7}}, // HMAC-SHA1（RFC 4210）和 hmacWithSHA1
+	crypto.SHA224: {{1, 2, 840, 113549, 2, 8}},
+	crypto.SHA256: {{1, 2, 840, 113549, 2, 9}},
+	crypto.SHA384: {{1, 2, 840, 113549, 2, 10}},
+	crypto.SHA512: {{1, 2, 840, 113549, 2, 11}},
+}
+
+// signatureAlgorithms 签名保护和POP支持的签名算法（RSA-PSS需要解析参数，暂不支持）
+var signatureAlgorithms = []struct {
+	oid       asn1.ObjectIdentifier
+	algorithm x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{utils.OIDSignatureECDSAWithSHA256, x509.ECDSAWithSHA256},
+	{utils.OIDSignatureECDSAWithSHA384, x509.ECDSAWithSHA384},
+	{utils.OIDSignatureECDSAWithSHA512, x509.ECDSAWithSHA512},
+	{utils.OIDSignatureEd25519, x509.PureEd25519},
+}
+
+func hashFromOID(oids map[crypto.Hash]asn1.ObjectIdentifier, oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for h, o := range oids {
+		if o.Equal(oid) {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+func macFromOID(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for h, list := range macOIDs {
+		for _, o := range list {
+			if o.Equal(oid) {
+				return h, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func signatureAlgorithmFromOID(oid asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+	for _, a := range signatureAlgorithms {
+		if a.oid.Equal(oid) {
+			return a.algorithm
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// verifySignature 使用公钥验证签名保护或POP的签名
+func verifySignature(pub crypto.PublicKey, alg pkix.AlgorithmIdentifier, signed []byte, signature []byte) error {
+	algorithm := signatureAlgorithmFromOID(alg.Algorithm)
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return fmt.Errorf("unsupported signature algorithm: %s", alg.Algorithm.String())
+	}
+
+	return (&x509.Certificate{PublicKey: pub}).CheckSignature(algorithm, signed, signature)
+}
+
+// parsePBMParameter 解析并检查PBM参数，返回单向函数和MAC使用的哈希算法
+func parsePBMParameter(alg pkix.AlgorithmIdentifier) (*pbmParameter, crypto.Hash, crypto.Hash, error) {
+	var params pbmParameter
+	rest, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("not a valid PBMParameter: %s", err.Error())
+	} else if len(rest) != 0 {
+		return nil, 0, 0, fmt.Errorf("trailing data after PBMParameter")
+	}
+
+	owf, ok := hashFromOID(owfOIDs, params.OWF.Algorithm)
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("unsupported one-way function of PBM: %s", params.OWF.Algorithm.String())
+	}
+
+	mac, ok := macFromOID(params.MAC.Algorithm)
+	if !ok {
+		return nil, 0, 0, fmt.Errorf("unsupported MAC algorithm of PBM: %s", params.MAC.Algorithm.String())
+	}
+
+	if params.IterationCount <= 0 || params.IterationCount > pbmMaxIterationCount {
+		return nil, 0, 0, fmt.Errorf("not a valid iteration count of PBM: %d", params.IterationCount)
+	} else if len(params.Salt) == 0 {
+		return nil, 0, 0, fmt.Errorf("the salt of PBM is empty")
+	}
+
+	return &params, owf, mac, nil
+}
+
+// pbmMAC RFC 4210 5.1.3.1 计算基于口令的MAC：BASEKEY = OWF(...OWF(secret || salt))，共计算 iterationCount 次
+func pbmMAC(params *pbmParameter, owf crypto.Hash, mac crypto.Hash, secret []byte, data []byte) []byte {
+	h := owf.New()
+	h.Write(secret)
+	h.Write(params.Salt)
+	key := h.Sum(nil)
+
+	for i := 1; i < params.IterationCount; i++ {
+		h.Reset()
+		h.Write(key)
+		key = h.Sum(nil)
+	}
+
+	m := hmac.New(func() hash.Hash { return mac.New() }, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// verifyPBM 验证使用共享密钥的MAC保护
+func verifyPBM(alg pkix.AlgorithmIdentifier, secret []byte, data []byte, protection []byte) error {
+	params, owf, mac, err := parsePBMParameter(alg)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(pbmMAC(params, owf, mac, secret, data), protection) {
+		return fmt.Errorf("the MAC of the message is not valid")
+	}
+	return nil
+}
+
+// protectPBM 使用与请求相同的PBM参数（新的盐）计算响应的MAC，请求的参数无效时使用SHA-256
+func protectPBM(reqAlg pkix.AlgorithmIdentifier, secret []byte, data func(alg pkix.AlgorithmIdentifier) ([]byte, error)) (pkix.AlgorithmIdentifier, []byte, error) {
+	params, owf, mac, err := parsePBMParameter(reqAlg)
+	if err != nil {
+		params = &pbmParameter{
+			OWF:            pkix.AlgorithmIdentifier{Algorithm: owfOIDs[crypto.SHA256]},
+			IterationCount: pbmIterationCount,
+			MAC:            pkix.AlgorithmIdentifier{Algorithm: macOIDs[crypto.SHA256][0]},
+		}
+		owf, mac = crypto.SHA256, crypto.SHA256
+	}
+
+	params.Salt = make([]byte, 16)
+	_, err = io.ReadFull(utils.Rander(), params.Salt)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	paramsDER, err := asn1.Marshal(*params)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	alg := pkix.AlgorithmIdentifier{
+		Algorithm:  oidPasswordBasedMAC,
+		Parameters: asn1.RawValue{FullBytes: paramsDER},
+	}
+
+	// 保护覆盖的消息头包含保护算法，因此需要先确定参数再生成消息头
+	protected, err := data(alg)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	return alg, pbmMAC(params, owf, mac, secret, protected), nil
+}
+
+// protectSignature 使用签名证书（或CA）的私钥签名响应
+func protectSignature(key crypto.PrivateKey, pub crypto.PublicKey, data func(alg pkix.AlgorithmIdentifier) ([]byte, error)) (pkix.AlgorithmIdentifier, []byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return pkix.AlgorithmIdentifier{}, nil, fmt.Errorf("the private key of the signer can not be used to sign")
+	}
+
+	h, alg, err := utils.SignatureAlgorithmForKey(pub)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	protected, err := data(alg)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	signature, err := utils.SignData(signer, h, protected)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, nil, err
+	}
+
+	return alg, signature, nil
+}
+
+// certHash 计算 certConf 中的证书摘要，未指定哈希算法时使用证书签名算法的哈希算法（RFC 9481 3.3）
+func certHash(cert *x509.Certificate, hashAlg *pkix.AlgorithmIdentifier) ([]byte, error) {
+	var h crypto.Hash
+	if hashAlg != nil {
+		var ok bool
+		h, ok = hashFromOID(owfOIDs, hashAlg.Algorithm)
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash algorithm: %s", hashAlg.Algorithm.String())
+		}
+	} else {
+		switch cert.SignatureAlgorithm {
+		case x509.SHA256WithRSA, x509.SHA256WithRSAPSS, x509.ECDSAWithSHA256:
+			h = crypto.SHA256
+		case x509.SHA384WithRSA, x509.SHA384WithRSAPSS, x509.ECDSAWithSHA384:
+			h = crypto.SHA384
+		case x509.SHA512WithRSA, x509.SHA512WithRSAPSS, x509.ECDSAWithSHA512, x509.PureEd25519:
+			h = crypto.SHA512
+		default:
+			return nil, fmt.Errorf("unsupported signature algorithm of the certificate: %s", cert.SignatureAlgorithm.String())
+		}
+	}
+
+	d := h.New()
+	d.Write(cert.Raw)
+	return d.Sum(nil), nil
+}
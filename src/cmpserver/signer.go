@@ -0,0 +1,77 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmpserver
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"time"
+)
+
+// CreateSigner 使用CA签发CMP响应的签名证书，RFC 9483 3.1 要求签名保护使用的证书包含 digitalSignature 密钥用途，
+// 而CA证书通常只有 keyCertSign 和 cRLSign
+func CreateSigner(caInfo cert.CAInfo, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey) (*x509.Certificate, crypto.PrivateKey, error) {
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+
+	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+	}
+
+	serialNumber, err := caInfo.NewCertSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+	}
+
+	subject := pkix.Name{
+		Country:            ca.Subject.Country,
+		Organization:       ca.Subject.Organization,
+		OrganizationalUnit: ca.Subject.OrganizationalUnit,
+		CommonName:         fmt.Sprintf("%s CMP Signer", ca.Subject.CommonName),
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: caInfo.GetSignatureAlgorithm(),
+		Subject:            subject,
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+
+		KeyUsage: x509.KeyUsageDigitalSignature,
+
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+
+		SubjectKeyId:   []byte(ski),
+		AuthorityKeyId: ca.SubjectKeyId,
+
+		IssuingCertificateURL: caInfo.GetIssuingCertificateURL(),
+		OCSPServer:            caInfo.GetOCSPServer(),
+		CRLDistributionPoints: caInfo.GetCRLDistributionPoints(),
+	}
+
+	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signer, privKey, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package cmpserver
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 共享密钥的状态（根据字段计算，不保存在文件中）
+const (
+	SecretActive  = "active"
+	SecretUsed    = "used"
+	SecretExpired = "expired"
+	SecretRevoked = "revoked"
+)
+
+// Secret PBM（基于口令的MAC）使用的共享密钥，保存在 secrets/<reference>.json，
+// 计算MAC需要密钥原文，因此与SCEP的挑战密码不同，密钥以明文保存（文件权限为0600）
+type Secret struct {
+	metadata.Header
+
+	Reference string     `json:"reference"` // 客户端在 senderKID 中发送的引用值
+	Secret    string     `json:"secret"`
+	Comment   string     `json:"comment,omitempty"`
+	MaxUses   int        `json:"max_uses"` // 可签发的证书数，0表示不限制
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *Secret) Status() string {
+	switch {
+	case s.RevokedAt != nil:
+		return SecretRevoked
+	case time.Now().After(s.ExpiresAt):
+		return SecretExpired
+	case s.MaxUses > 0 && s.Uses >= s.MaxUses:
+		return SecretUsed
+	default:
+		return SecretActive
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+// Store 将共享密钥保存在目录中（通常为 home/cmp/<ICA名称>），
+// 服务运行期间也可以由命令行创建和吊销共享密钥，因此每次都从文件中读取
+type Store struct {
+	lock sync.Mutex
+	dir  string
+}
+
+func NewStore(dir string) (*Store, error) {
+	err := os.MkdirAll(path.Join(dir, "secrets"), 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+func newRandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isValidReference(reference string) bool {
+	if len(reference) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(reference)
+	return err == nil && strings.ToLower(reference) == reference
+}
+
+func (s *Store) secretPath(reference string) string {
+	return path.Join(s.dir, "secrets", reference+".json")
+}
+
+// CreateSecret 生成新的共享密钥
+func (s *Store) CreateSecret(validity time.Duration, maxUses int, comment string) (*Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	reference, err := newRandomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newRandomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := &Secret{
+		Header:    metadata.NewHeader(metadata.KindCMPSecret),
+		Reference: reference,
+		Secret:    secret,
+		Comment:   comment,
+		MaxUses:   maxUses,
+		CreatedAt: now,
+		ExpiresAt: now.Add(validity),
+	}
+
+	err = metadata.Write(s.secretPath(reference), res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *Store) getSecret(reference string) (*Secret, error) {
+	if !isValidReference(reference) {
+		return nil, errNotFound
+	}
+
+	var secret Secret
+	err := metadata.Read(s.secretPath(reference), metadata.KindCMPSecret, &secret)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// ListSecrets 返回全部共享密钥（按创建时间排序）
+func (s *Store) ListSecrets() ([]*Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(path.Join(s.dir, "secrets"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Secret, 0, len(entries))
+	for _, e := range entries {
+		reference, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !isValidReference(reference) {
+			continue
+		}
+
+		secret, err := s.getSecret(reference)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, secret)
+	}
+
+	slices.SortFunc(res, func(a, b *Secret) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// RevokeSecret 吊销共享密钥，已签发的证书不受影响
+func (s *Store) RevokeSecret(reference string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secret, err := s.getSecret(reference)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("secret %s not found", reference)
+	} else if err != nil {
+		return err
+	} else if secret.RevokedAt != nil {
+		return fmt.Errorf("secret %s has been revoked", reference)
+	}
+
+	now := time.Now()
+	secret.RevokedAt = &now
+	return metadata.Write(s.secretPath(reference), secret)
+}
+
+// findSecret 查找未过期且未被吊销的共享密钥，次数已用完的共享密钥仍可用于保护 certConf，签发前由调用者检查其状态
+func (s *Store) findSecret(reference string) (*Secret, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secret, err := s.getSecret(reference)
+	if err != nil {
+		return nil, err
+	} else if status := secret.Status(); status == SecretExpired || status == SecretRevoked {
+		return nil, fmt.Errorf("the secret %s is %s", reference, secret.Status())
+	}
+	return secret, nil
+}
+
+// useSecret 增加共享密钥的使用次数
+func (s *Store) useSecret(reference string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	secret, err := s.getSecret(reference)
+	if err != nil {
+		return err
+	}
+
+	secret.Uses++
+	return metadata.Write(s.secretPath(reference), secret)
+}
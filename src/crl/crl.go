@@ -0,0 +1,154 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package crl 根据CA的吊销记录生成证书吊销列表（完整CRL以及增量CRL）
+package crl
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"os"
+	"time"
+)
+
+const PemTypeX509CRL = "X509 CRL"
+
+var OIDExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+type CAInfo interface {
+	NewCRLNumber() *big.Int
+	GetBaseCRL() (*big.Int, time.Time)
+	SetBaseCRL(number *big.Int, thisUpdate time.Time)
+	GetSignatureAlgorithm() x509.SignatureAlgorithm
+}
+
+// CreateCRL 生成完整CRL，包含吊销数据库中的全部记录，并将其记录为之后增量CRL的基础
+func CreateCRL(caInfo CAInfo, db *revoke.RevocationDB, ca *x509.Certificate, caKey crypto.PrivateKey, thisUpdate time.Time, nextUpdate time.Time) (*x509.RevocationList, error) {
+	entries := make([]x509.RevocationListEntry, 0, len(db.Revoked))
+	for _, r := range db.Revoked {
+		entries = append(entries, newEntry(r))
+	}
+
+	number := caInfo.NewCRLNumber()
+
+	res, err := createCRL(caInfo.GetSignatureAlgorithm(), number, entries, nil, ca, caKey, thisUpdate, nextUpdate)
+	if err != nil {
+		return nil, err
+	}
+
+	caInfo.SetBaseCRL(number, thisUpdate)
+
+	return res, nil
+}
+
+// CreateDeltaCRL 生成增量CRL，只包含最近一次完整CRL之后吊销的记录
+func CreateDeltaCRL(caInfo CAInfo, db *revoke.RevocationDB, ca *x509.Certificate, caKey crypto.PrivateKey, thisUpdate time.Time, nextUpdate time.Time) (*x509.RevocationList, error) {
+	baseNumber, baseAt := caInfo.GetBaseCRL()
+	if baseNumber == nil {
+		return nil, fmt.Errorf("no complete CRL has been generated, generate a complete CRL first")
+	}
+
+	entries := make([]x509.RevocationListEntry, 0, len(db.Revoked))
+	for _, r := range db.Revoked {
+		if r.RevokedAt.After(baseAt) {
+			entries = append(entries, newEntry(r))
+		}
+	}
+
+	value, err := asn1.Marshal(baseNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	deltaIndicator := pkix.Extension{
+		Id:       OIDExtensionDeltaCRLIndicator,
+		Critical: true, // RFC 5280 5.2.4 要求为关键扩展
+		Value:    value,
+	}
+
+	return createCRL(caInfo.GetSignatureAlgorithm(), caInfo.NewCRLNumber(), entries, []pkix.Extension{deltaIndicator}, ca, caKey, thisUpdate, nextUpdate)
+}
+
+func newEntry(r *revoke.RevokedCert) x509.RevocationListEntry {
+	return x509.RevocationListEntry{
+		SerialNumber:   r.SerialNumber,
+		RevocationTime: r.RevokedAt,
+		ReasonCode:     int(r.Reason), // 为0（unspecified）时不写入原因扩展，符合RFC 5280的建议
+	}
+}
+
+func createCRL(signatureAlgorithm x509.SignatureAlgorithm, number *big.Int, entries []x509.RevocationListEntry, extensions []pkix.Extension, ca *x509.Certificate, caKey crypto.PrivateKey, thisUpdate time.Time, nextUpdate time.Time) (*x509.RevocationList, error) {
+	signer, ok := caKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the private key of CA can not be used to sign")
+	}
+
+	if ca.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, fmt.Errorf("the CA does not have the CRL sign key usage")
+	}
+
+	if !nextUpdate.After(thisUpdate) {
+		return nil, fmt.Errorf("the next update must be after this update")
+	}
+
+	template := &x509.RevocationList{
+		SignatureAlgorithm:        signatureAlgorithm,
+		Number:                    number,
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+		ExtraExtensions:           extensions,
+	}
+
+	derBytes, err := x509.CreateRevocationList(utils.Rander(), template, ca, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseRevocationList(derBytes)
+}
+
+// SaveCRL 保存CRL（PEM格式以及DER格式）
+func SaveCRL(crl *x509.RevocationList, pemSavePath string, derSavePath string) error {
+	crlPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  PemTypeX509CRL,
+		Bytes: crl.Raw,
+	})
+
+	err := os.WriteFile(pemSavePath, crlPEM, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(derSavePath, crl.Raw, 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ReadCRL 读取CRL文件（PEM格式或DER格式）
+func ReadCRL(filePath string) (*x509.RevocationList, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != PemTypeX509CRL {
+			return nil, fmt.Errorf("pem type of crl error")
+		}
+		data = block.Bytes
+	}
+
+	return x509.ParseRevocationList(data)
+}
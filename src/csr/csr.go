@@ -0,0 +1,238 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package csr 生成证书签名请求（PKCS#10），用于向外部CA申请证书
+package csr
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/gob"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/url"
+	"time"
+)
+
+// CSRInfo 记录待签发请求的信息，CA相关的URL将在导入已签发的证书后写入ICA信息
+type CSRInfo struct {
+	IsCA                  bool
+	MaxPathLen            int
+	OCSPServer            []string
+	IssuingCertificateURL []string
+	CRLDistributionPoints []string
+	CreateAt              time.Time
+	RandSource            string                  // 生成私钥时使用的随机源
+	SignatureAlgorithm    x509.SignatureAlgorithm // CSR的签名算法，导入已签发的ICA后作为ICA默认的签名算法
+
+	FilePath string `gob:"-"`
+}
+
+func init() {
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/csr.CSRInfo", &CSRInfo{})
+}
+
+func NewCSRInfo(filepath string, isCA bool, maxPathLen int, ocsp []string, issuerURL []string, crlURL []string) (*CSRInfo, error) {
+	info := &CSRInfo{
+		IsCA:                  isCA,
+		MaxPathLen:            maxPathLen,
+		OCSPServer:            ocsp,
+		IssuingCertificateURL: issuerURL,
+		CRLDistributionPoints: crlURL,
+		CreateAt:              time.Now(),
+		RandSource:            utils.RandSource(),
+		FilePath:              filepath,
+	}
+
+	return info, nil
+}
+
+// csrInfoFile 待签发请求的信息文件（csr-info.json）的格式
+type csrInfoFile struct {
+	metadata.Header
+	IsCA                  bool       `json:"is_ca"`
+	MaxPathLen            int        `json:"max_path_len"`
+	OCSPServer            []string   `json:"ocsp_server,omitempty"`
+	IssuingCertificateURL []string   `json:"issuing_certificate_url,omitempty"`
+	CRLDistributionPoints []string   `json:"crl_distribution_points,omitempty"`
+	CreateAt              *time.Time `json:"create_at,omitempty"`
+	RandSource            string     `json:"rand_source,omitempty"`
+	SignatureAlgorithm    string     `json:"signature_algorithm,omitempty"`
+}
+
+func GetCSRInfo(filepath string) (*CSRInfo, error) {
+	if metadata.NeedMigrate(filepath) {
+		return migrateCSRInfo(filepath)
+	}
+
+	var f csrInfoFile
+	err := metadata.Read(filepath, metadata.KindCSR, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureAlgorithm, err := metadata.ParseSignatureAlgorithm(f.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSRInfo{
+		IsCA:                  f.IsCA,
+		MaxPathLen:            f.MaxPathLen,
+		OCSPServer:            f.OCSPServer,
+		IssuingCertificateURL: f.IssuingCertificateURL,
+		CRLDistributionPoints: f.CRLDistributionPoints,
+		CreateAt:              metadata.ParseTime(f.CreateAt),
+		RandSource:            f.RandSource,
+		SignatureAlgorithm:    signatureAlgorithm,
+		FilePath:              filepath,
+	}, nil
+}
+
+// migrateCSRInfo 读取旧版本的csr-info.gob，保存为csr-info.json后删除旧文件
+func migrateCSRInfo(filepath string) (*CSRInfo, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var res CSRInfo
+	err := metadata.ReadLegacy(legacyPath, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	res.FilePath = filepath
+
+	err = res.SaveCSRInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	err = metadata.RemoveLegacy(legacyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (info *CSRInfo) SaveCSRInfo() error {
+	return metadata.Write(info.FilePath, &csrInfoFile{
+		Header:                metadata.NewHeader(metadata.KindCSR),
+		IsCA:                  info.IsCA,
+		MaxPathLen:            info.MaxPathLen,
+		OCSPServer:            info.OCSPServer,
+		IssuingCertificateURL: info.IssuingCertificateURL,
+		CRLDistributionPoints: info.CRLDistributionPoints,
+		CreateAt:              metadata.FormatTime(info.CreateAt),
+		RandSource:            info.RandSource,
+		SignatureAlgorithm:    metadata.FormatSignatureAlgorithm(info.SignatureAlgorithm),
+	})
+}
+
+// CreateCSR 生成私钥以及证书签名请求
+func CreateCSR(infoFilePath string, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, isCA bool, maxPathLen int, domains []string, ips []net.IP, emails []string, urls []*url.URL, ocsp []string, selfURL []string, crlURL []string, signatureAlgorithm x509.SignatureAlgorithm) (*x509.CertificateRequest, crypto.PrivateKey, *CSRInfo, error) {
+	info, err := NewCSRInfo(infoFilePath, isCA, maxPathLen, ocsp, selfURL, crlURL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	info.SignatureAlgorithm = signatureAlgorithm
+
+	if isCA {
+		err = subject.SetCNIfEmpty() // 兜底，确保CN被设置
+	} else {
+		err = subject.SetCNIfEmpty(domains, ips, emails, urls) // 兜底，确保CN被设置
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if key != nil {
+		info.RandSource = utils.RandSourceExternal
+	}
+
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, pubKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	extensions := make([]pkix.Extension, 0, 3)
+
+	basicConstraints, err := utils.MarshalBasicConstraintsExtension(isCA, maxPathLen)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	extensions = append(extensions, basicConstraints)
+
+	if keyUsage != 0 {
+		ext, err := utils.MarshalKeyUsageExtension(keyUsage)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		extensions = append(extensions, ext)
+	}
+
+	if len(extKeyUsage) != 0 {
+		ext, err := utils.MarshalExtKeyUsageExtension(extKeyUsage)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		extensions = append(extensions, ext)
+	}
+
+	template := &x509.CertificateRequest{
+		SignatureAlgorithm: signatureAlgorithm,
+		Subject:            subject.ToPkixName(),
+
+		DNSNames:       domains,
+		IPAddresses:    ips,
+		EmailAddresses: emails,
+		URIs:           urls,
+
+		ExtraExtensions: extensions,
+	}
+
+	derBytes, err := x509.CreateCertificateRequest(utils.Rander(), template, privKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	csr, err := x509.ParseCertificateRequest(derBytes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return csr, privKey, info, nil
+}
+
+// CheckSignedCertificate 检查外部CA签发的证书是否与待签发请求匹配
+func CheckSignedCertificate(cert *x509.Certificate, key crypto.PrivateKey, info *CSRInfo, chain []*x509.Certificate) error {
+	err := utils.CheckKeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	if info.IsCA && (!cert.BasicConstraintsValid || !cert.IsCA) {
+		return fmt.Errorf("the certificate is not a CA")
+	}
+
+	if len(chain) != 0 {
+		err = cert.CheckSignatureFrom(chain[0])
+		if err != nil {
+			return fmt.Errorf("the certificate is not signed by the first certificate of the chain: %s", err.Error())
+		}
+	}
+
+	now := time.Now()
+	if now.After(cert.NotAfter) {
+		return fmt.Errorf("the certificate has expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package estserver 实现 RFC 7030 EST（Enrollment over Secure Transport）服务，
+// 客户端使用HTTP基本认证或已有的客户端证书认证，证书由调用者提供的 EnrollFunc 签发
+package estserver
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/pkcs7"
+	"net/http"
+)
+
+const maxRequestSize = 64 * 1024
+
+const (
+	wellKnownPrefix = "/.well-known/est"
+	basicAuthRealm  = "MyCA EST"
+
+	contentTypePKCS10    = "application/pkcs10"
+	contentTypePKCS7     = "application/pkcs7-mime"
+	contentTypePKCS7Cert = "application/pkcs7-mime; smime-type=certs-only"
+	contentTypePKCS8     = "application/pkcs8"
+)
+
+// Client 通过认证的客户端，Username 和 Certificate 至少有一个不为空
+type Client struct {
+	Username    string            // HTTP基本认证的用户名
+	Certificate *x509.Certificate // TLS客户端证书
+}
+
+// EnrollRequest 签发请求，调用时CSR的签名已检查
+type EnrollRequest struct {
+	CSR    *x509.CertificateRequest
+	Client *Client
+	Old    *x509.Certificate // simplereenroll 时为被续期的证书（即客户端证书）
+	Key    crypto.PrivateKey // serverkeygen 时为服务端生成的私钥
+}
+
+// EnrollFunc 签发证书，返回的错误会作为拒绝原因返回给客户端
+type EnrollFunc func(req *EnrollRequest) (*x509.Certificate, error)
+
+// Config EST服务的配置
+type Config struct {
+	CACerts          []*x509.Certificate                   // cacerts 返回的CA证书（签发CA在前）
+	Users            map[string]string                     // HTTP基本认证的用户名和密码
+	VerifyClientCert func(chain []*x509.Certificate) error // 验证TLS客户端证书（证书链、用途和吊销状态），为nil时不接受客户端证书认证
+	Enroll           EnrollFunc
+}
+
+// Server EST服务，实现 http.Handler，必须通过HTTPS提供服务（TLS配置应请求但不验证客户端证书）
+type Server struct {
+	cacerts          []byte
+	users            map[string]string
+	verifyClientCert func(chain []*x509.Certificate) error
+	enroll           EnrollFunc
+	handler          http.Handler
+}
+
+func NewServer(config *Config) (*Server, error) {
+	if len(config.CACerts) == 0 || config.Enroll == nil {
+		return nil, fmt.Errorf("the CA certificates and enroll function must be set")
+	} else if len(config.Users) == 0 && config.VerifyClientCert == nil {
+		return nil, fmt.Errorf("at least one of the users and client certificate verification must be set")
+	}
+
+	cacerts, err := pkcs7.MarshalCertsOnly(config.CACerts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		cacerts:          cacerts,
+		users:            config.Users,
+		verifyClientCert: config.VerifyClientCert,
+		enroll:           config.Enroll,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+wellKnownPrefix+"/cacerts", s.getCACerts)
+	mux.HandleFunc("GET "+wellKnownPrefix+"/csrattrs", s.getCSRAttrs)
+	mux.HandleFunc("POST "+wellKnownPrefix+"/simpleenroll", s.simpleEnroll)
+	mux.HandleFunc("POST "+wellKnownPrefix+"/simplereenroll", s.simpleReenroll)
+	mux.HandleFunc("POST "+wellKnownPrefix+"/serverkeygen", s.serverKeyGen)
+	s.handler = mux
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// authenticate RFC 7030 3.2.3 和 3.3.2，优先使用TLS客户端证书，失败时返回的错误可直接返回给客户端
+func (s *Server) authenticate(r *http.Request) (*Client, *httpError) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) != 0 && s.verifyClientCert != nil {
+		err := s.verifyClientCert(r.TLS.PeerCertificates)
+		if err != nil {
+			return nil, newHTTPError(http.StatusUnauthorized, "client certificate verification failed: %s", err.Error())
+		}
+		return &Client{Certificate: r.TLS.PeerCertificates[0]}, nil
+	}
+
+	username, password, ok := r.BasicAuth()
+	if ok && len(s.users) != 0 {
+		expected, exists := s.users[username]
+		if exists && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1 {
+			return &Client{Username: username}, nil
+		}
+	}
+
+	return nil, newHTTPError(http.StatusUnauthorized, "authentication required")
+}
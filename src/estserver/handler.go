@@ -0,0 +1,313 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package estserver
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/pkcs7"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+type httpError struct {
+	Status int
+	Detail string
+}
+
+func newHTTPError(status int, format string, args ...any) *httpError {
+	return &httpError{
+		Status: status,
+		Detail: fmt.Sprintf(format, args...),
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, e *httpError) {
+	if e.Status == http.StatusUnauthorized && len(s.users) != 0 {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", basicAuthRealm))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(e.Status)
+	_, _ = fmt.Fprintln(w, e.Detail)
+}
+
+// encodeBase64 RFC 7030 4.1.3 要求的 base64 编码（Content-Transfer-Encoding: base64），每行64个字符
+func encodeBase64(der []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(der)
+
+	var buf bytes.Buffer
+	for len(encoded) > 64 {
+		buf.WriteString(encoded[:64])
+		buf.WriteString("\n")
+		encoded = encoded[64:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\n")
+
+	return buf.Bytes()
+}
+
+func writeBase64(w http.ResponseWriter, contentType string, der []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Transfer-Encoding", "base64")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(encodeBase64(der))
+}
+
+// readCSR 读取请求中的CSR，除了 RFC 7030 规定的 base64 编码外，也接受DER和PEM格式
+func readCSR(r *http.Request) (*x509.CertificateRequest, *httpError) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "read request failed: %s", err.Error())
+	} else if len(data) > maxRequestSize {
+		return nil, newHTTPError(http.StatusRequestEntityTooLarge, "the request is too large")
+	}
+
+	var der []byte
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	} else if decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(data)), "")); err == nil {
+		der = decoded
+	} else {
+		der = data
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "not a valid PKCS#10 certificate request: %s", err.Error())
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "the signature of the certificate request is not valid: %s", err.Error())
+	}
+
+	return csr, nil
+}
+
+// issue 签发证书并以 certs-only 格式返回
+func (s *Server) issue(req *EnrollRequest) ([]byte, *httpError) {
+	c, err := s.enroll(req)
+	if err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "enrollment rejected: %s", err.Error())
+	}
+
+	res, err := pkcs7.MarshalCertsOnly([]*x509.Certificate{c})
+	if err != nil {
+		return nil, newHTTPError(http.StatusInternalServerError, "%s", err.Error())
+	}
+
+	return res, nil
+}
+
+// getCACerts RFC 7030 4.1，无需认证
+func (s *Server) getCACerts(w http.ResponseWriter, _ *http.Request) {
+	writeBase64(w, contentTypePKCS7, s.cacerts)
+}
+
+// getCSRAttrs RFC 7030 4.5，服务不要求CSR包含特定属性
+func (s *Server) getCSRAttrs(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// simpleEnroll RFC 7030 4.2.1
+func (s *Server) simpleEnroll(w http.ResponseWriter, r *http.Request) {
+	client, e := s.authenticate(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	csr, e := readCSR(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	res, e := s.issue(&EnrollRequest{CSR: csr, Client: client})
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	writeBase64(w, contentTypePKCS7Cert, res)
+}
+
+// simpleReenroll RFC 7030 4.2.2，客户端必须使用被续期的证书认证，CSR的主题和SAN必须与该证书相同
+func (s *Server) simpleReenroll(w http.ResponseWriter, r *http.Request) {
+	client, e := s.authenticate(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	} else if client.Certificate == nil {
+		s.writeError(w, newHTTPError(http.StatusUnauthorized, "reenrollment requires the client certificate to be renewed"))
+		return
+	}
+
+	csr, e := readCSR(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	e = checkReenroll(csr, client.Certificate)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	res, e := s.issue(&EnrollRequest{CSR: csr, Client: client, Old: client.Certificate})
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	writeBase64(w, contentTypePKCS7Cert, res)
+}
+
+// serverKeyGen RFC 7030 4.4，生成与CSR公钥类型和长度相同的私钥，使用CSR的主题和扩展签发证书
+func (s *Server) serverKeyGen(w http.ResponseWriter, r *http.Request) {
+	client, e := s.authenticate(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	csr, e := readCSR(r)
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	cryptoType, keyLength, err := utils.GetPublicKeyCryptoType(csr.PublicKey)
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusBadRequest, "%s", err.Error()))
+		return
+	}
+
+	key, _, err := utils.GenerateKey(cryptoType, keyLength)
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusBadRequest, "generate key failed: %s", err.Error()))
+		return
+	}
+
+	// 使用生成的私钥重新创建CSR，ExtraExtensions 会保留原CSR中的SAN等全部扩展
+	keyCSRDER, err := x509.CreateCertificateRequest(utils.Rander(), &x509.CertificateRequest{
+		Subject:         csr.Subject,
+		ExtraExtensions: csr.Extensions,
+	}, key)
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusInternalServerError, "%s", err.Error()))
+		return
+	}
+
+	keyCSR, err := x509.ParseCertificateRequest(keyCSRDER)
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusInternalServerError, "%s", err.Error()))
+		return
+	}
+
+	res, e := s.issue(&EnrollRequest{CSR: keyCSR, Client: client, Key: key})
+	if e != nil {
+		s.writeError(w, e)
+		return
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusInternalServerError, "%s", err.Error()))
+		return
+	}
+
+	// RFC 7030 4.4.2 multipart/mixed，私钥在前，证书在后
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		der         []byte
+	}{
+		{contentTypePKCS8, keyDER},
+		{contentTypePKCS7Cert, res},
+	} {
+		header := make(textproto.MIMEHeader, 2)
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+
+		pw, err := mw.CreatePart(header)
+		if err != nil {
+			s.writeError(w, newHTTPError(http.StatusInternalServerError, "%s", err.Error()))
+			return
+		}
+
+		_, _ = pw.Write(encodeBase64(part.der))
+	}
+
+	err = mw.Close()
+	if err != nil {
+		s.writeError(w, newHTTPError(http.StatusInternalServerError, "%s", err.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "multipart/mixed; boundary="+mw.Boundary())
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body.Bytes())
+}
+
+// checkReenroll RFC 7030 4.2.2 要求CSR的主题和SAN与被续期的证书相同
+func checkReenroll(csr *x509.CertificateRequest, old *x509.Certificate) *httpError {
+	if csr.Subject.String() != old.Subject.String() {
+		return newHTTPError(http.StatusBadRequest, "the subject of the certificate request (%s) is not the same as the certificate to be renewed (%s)", csr.Subject.String(), old.Subject.String())
+	}
+
+	csrIPs := ipStrings(csr.IPAddresses)
+	oldIPs := ipStrings(old.IPAddresses)
+
+	var csrURIs, oldURIs []string
+	for _, u := range csr.URIs {
+		csrURIs = append(csrURIs, u.String())
+	}
+	for _, u := range old.URIs {
+		oldURIs = append(oldURIs, u.String())
+	}
+
+	if !sameSet(csr.DNSNames, old.DNSNames) || !sameSet(csrIPs, oldIPs) || !sameSet(csr.EmailAddresses, old.EmailAddresses) || !sameSet(csrURIs, oldURIs) {
+		return newHTTPError(http.StatusBadRequest, "the subject alternative names of the certificate request are not the same as the certificate to be renewed")
+	}
+
+	return nil
+}
+
+func ipStrings(ips []net.IP) []string {
+	res := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		res = append(res, ip.String())
+	}
+	return res
+}
+
+func sameSet(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	for i := range a {
+		a[i] = strings.ToLower(a[i])
+	}
+	for i := range b {
+		b[i] = strings.ToLower(b[i])
+	}
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
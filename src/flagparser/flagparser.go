@@ -25,6 +25,7 @@ func InitFlagParser() error {
 	flag.BoolVar(&version, "version", false, "show version")
 	flag.BoolVar(&version, "v", false, "show version")
 	flag.StringVar(&Home, "home", path.Join(currentUser.HomeDir, ".myca"), "set home directory")
+	flag.Usage = printUsage
 
 	flag.Parse()
 
@@ -38,5 +39,9 @@ func InitFlagParser() error {
 		return StopRun
 	}
 
+	if flag.NArg() != 0 {
+		return parseSubCommand(flag.Args())
+	}
+
 	return nil
 }
@@ -0,0 +1,935 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package flagparser
+
+import (
+	"flag"
+)
+
+type KeyOption struct {
+	CryptoType  string
+	KeyLength   int
+	KeyFile     string
+	KeyFrom     string
+	KeyPassword string
+}
+
+func (o *KeyOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.CryptoType, "crypto", "ECDSA", "crypto type: RSA / ECDSA / Ed25519")
+	fs.IntVar(&o.KeyLength, "key-length", 0, "key length, RSA: 2048/3072/4096/8192, ECDSA: 256/384/521, Ed25519: 256 (default RSA 2048, ECDSA 256, Ed25519 256)")
+	fs.StringVar(&o.KeyFile, "key-file", "", "use an existing private key file (PEM or DER, PKCS#8 / PKCS#1 / SEC1) instead of generating a new key, -crypto and -key-length are ignored")
+	fs.StringVar(&o.KeyFrom, "key-from", "", "use the private key of an existing entry in MyCA, e.g. RCA/RCA-MyRootCA, ICA/ICA-MyICA, CERT/CERT-example.com, PENDING/CSR-MySubCA")
+	fs.StringVar(&o.KeyPassword, "key-password", "", "the password of the existing private key")
+}
+
+type SignatureOption struct {
+	SignatureAlgorithm string
+}
+
+func (o *SignatureOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.SignatureAlgorithm, "sig-alg", "", "signature algorithm, e.g. SHA256WithRSA / SHA384WithRSAPSS / ECDSAWithSHA384 (default the issuer's default, or decided by the key)")
+}
+
+type SubjectOption struct {
+	Country            StringSlice
+	Province           StringSlice
+	Locality           StringSlice
+	Organization       StringSlice
+	OrganizationalUnit StringSlice
+	StreetAddress      StringSlice
+	PostalCode         StringSlice
+	CommonName         string
+}
+
+func (o *SubjectOption) IsEmpty() bool {
+	return len(o.Country) == 0 && len(o.Province) == 0 && len(o.Locality) == 0 && len(o.Organization) == 0 &&
+		len(o.OrganizationalUnit) == 0 && len(o.StreetAddress) == 0 && len(o.PostalCode) == 0 && o.CommonName == ""
+}
+
+func (o *SubjectOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.Country, "country", "subject country name, only two capital letters (repeatable)")
+	fs.Var(&o.Province, "province", "subject province or state (repeatable)")
+	fs.Var(&o.Locality, "locality", "subject city (repeatable)")
+	fs.Var(&o.Organization, "org", "subject organization or company name (repeatable)")
+	fs.Var(&o.OrganizationalUnit, "org-unit", "subject organization unit name (repeatable)")
+	fs.Var(&o.StreetAddress, "street", "subject street address (repeatable)")
+	fs.Var(&o.PostalCode, "postal-code", "subject postal code (repeatable)")
+	fs.StringVar(&o.CommonName, "cn", "", "subject common name")
+}
+
+type UsageOption struct {
+	KeyUsage    StringSlice
+	ExtKeyUsage StringSlice
+}
+
+func (o *UsageOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.KeyUsage, "key-usage", "key usage, e.g. DigitalSignature / CertSign; \"default\" means the default key usage (repeatable)")
+	fs.Var(&o.ExtKeyUsage, "ext-key-usage", "ext key usage, e.g. ServerAuth / ClientAuth; \"all\" or \"none\" (repeatable, default all)")
+}
+
+type URLOption struct {
+	OCSP       StringSlice
+	IssuingURL StringSlice
+	CRL        StringSlice
+}
+
+func (o *URLOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.OCSP, "ocsp", "OCSP server URL (repeatable)")
+	fs.Var(&o.IssuingURL, "issuing-url", "issuing certificate URL (repeatable)")
+	fs.Var(&o.CRL, "crl", "CRL distribution point URL (repeatable)")
+}
+
+type NameConstraintOption struct {
+	PermitDNS    StringSlice
+	ExcludeDNS   StringSlice
+	PermitIP     StringSlice
+	ExcludeIP    StringSlice
+	PermitEmail  StringSlice
+	ExcludeEmail StringSlice
+	PermitURI    StringSlice
+	ExcludeURI   StringSlice
+}
+
+func (o *NameConstraintOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.PermitDNS, "permit-dns", "permitted DNS domain of the name constraints, a leading '.' means subdomains only (repeatable)")
+	fs.Var(&o.ExcludeDNS, "exclude-dns", "excluded DNS domain of the name constraints (repeatable)")
+	fs.Var(&o.PermitIP, "permit-ip", "permitted IP range (CIDR) of the name constraints (repeatable)")
+	fs.Var(&o.ExcludeIP, "exclude-ip", "excluded IP range (CIDR) of the name constraints (repeatable)")
+	fs.Var(&o.PermitEmail, "permit-email", "permitted email address or domain of the name constraints (repeatable)")
+	fs.Var(&o.ExcludeEmail, "exclude-email", "excluded email address or domain of the name constraints (repeatable)")
+	fs.Var(&o.PermitURI, "permit-uri", "permitted URI domain of the name constraints (repeatable)")
+	fs.Var(&o.ExcludeURI, "exclude-uri", "excluded URI domain of the name constraints (repeatable)")
+}
+
+// AllowExtKeyUsageOption 在线服务允许客户端请求的扩展密钥用途
+type AllowExtKeyUsageOption struct {
+	AllowExtKeyUsage StringSlice
+}
+
+func (o *AllowExtKeyUsageOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.AllowExtKeyUsage, "allow-ext-key-usage", "the ext key usage the clients can request, e.g. ServerAuth / ClientAuth; \"all\" excludes Any, CodeSigning and OCSPSigning which must be named explicitly (repeatable, default ServerAuth and ClientAuth)")
+}
+
+type PolicyOption struct {
+	Policy StringSlice
+}
+
+func (o *PolicyOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.Policy, "policy", "the name of the certificate policy defined in the issuer CA (repeatable)")
+}
+
+type CAPolicyOption struct {
+	Policy                StringSlice
+	PolicyDef             StringSlice
+	PolicyMapping         StringSlice
+	RequireExplicitPolicy int
+	InhibitPolicyMapping  int
+	InhibitAnyPolicy      int
+}
+
+func (o *CAPolicyOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.Policy, "policy", "the name of the certificate policy in the CA certificate, defined in the issuer CA or by -policy-def, 'any' means anyPolicy (repeatable)")
+	fs.Var(&o.PolicyDef, "policy-def", "define a named certificate policy of the new CA: name=OID[;cps=URL][;notice=TEXT] (repeatable)")
+	fs.Var(&o.PolicyMapping, "policy-mapping", "map the policy of the issuer CA to the policy of the new ICA: issuer=subject, name or OID (repeatable)")
+	fs.IntVar(&o.RequireExplicitPolicy, "require-explicit-policy", -1, "the requireExplicitPolicy of the policy constraints, -1 means not set")
+	fs.IntVar(&o.InhibitPolicyMapping, "inhibit-policy-mapping", -1, "the inhibitPolicyMapping of the policy constraints, -1 means not set")
+	fs.IntVar(&o.InhibitAnyPolicy, "inhibit-any-policy", -1, "the inhibitAnyPolicy (skip certs), -1 means not set")
+}
+
+type SANOption struct {
+	DNS     StringSlice
+	IP      StringSlice
+	Email   StringSlice
+	URI     StringSlice
+	Resolve bool
+}
+
+func (o *SANOption) IsEmpty() bool {
+	return len(o.DNS) == 0 && len(o.IP) == 0 && len(o.Email) == 0 && len(o.URI) == 0
+}
+
+func (o *SANOption) setFlags(fs *flag.FlagSet) {
+	fs.Var(&o.DNS, "dns", "domain (repeatable)")
+	fs.Var(&o.IP, "ip", "IPv4/IPv6 (repeatable)")
+	fs.Var(&o.Email, "email", "email (repeatable)")
+	fs.Var(&o.URI, "uri", "URI (repeatable)")
+	fs.BoolVar(&o.Resolve, "resolve", false, "resolve the domains and add the ip to the certificate")
+}
+
+type IssuerOption struct {
+	Issuer         string
+	IssuerType     string
+	IssuerPassword string
+}
+
+func (o *IssuerOption) setFlags(fs *flag.FlagSet, defaultType string) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the issuer CA")
+	fs.StringVar(&o.IssuerType, "issuer-type", defaultType, "the type of the issuer CA: RCA / ICA")
+	fs.StringVar(&o.IssuerPassword, "issuer-password", "", "the password of the issuer CA private key")
+}
+
+type SaveOption struct {
+	Name     string
+	Password string
+	Force    bool
+}
+
+func (o *SaveOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Name, "name", "", "the directory name to save (default is generated from the common name)")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key (default no password)")
+	fs.BoolVar(&o.Force, "force", false, "overwrite the duplicate file")
+}
+
+type RCACreateOption struct {
+	KeyOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	URLOption
+	NameConstraintOption
+	CAPolicyOption
+	SaveOption
+
+	Validity   string
+	MaxPathLen int
+	SerialMode string
+}
+
+func (o *RCACreateOption) setFlags(fs *flag.FlagSet) {
+	o.KeyOption.setFlags(fs)
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.URLOption.setFlags(fs)
+	o.NameConstraintOption.setFlags(fs)
+	o.CAPolicyOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "10y", "validity, e.g. 10y / 6m / 30d")
+	fs.IntVar(&o.MaxPathLen, "max-path-len", -1, "the ca max path len limit, -1 means no limit")
+	fs.StringVar(&o.SerialMode, "serial-mode", "sequential", "the serial number mode of the certificates issued by the CA: sequential (random increment) / random (fully random 128-bit)")
+}
+
+type ICACreateOption struct {
+	IssuerOption
+	KeyOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	URLOption
+	NameConstraintOption
+	CAPolicyOption
+	SaveOption
+
+	Validity             string
+	MaxPathLen           int
+	CASignatureAlgorithm string
+	SerialMode           string
+}
+
+func (o *ICACreateOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "RCA")
+	o.KeyOption.setFlags(fs)
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.URLOption.setFlags(fs)
+	o.NameConstraintOption.setFlags(fs)
+	o.CAPolicyOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "5y", "validity, e.g. 10y / 6m / 30d")
+	fs.IntVar(&o.MaxPathLen, "max-path-len", -1, "the ca max path len limit, -1 means no limit")
+	fs.StringVar(&o.SerialMode, "serial-mode", "sequential", "the serial number mode of the certificates issued by the CA: sequential (random increment) / random (fully random 128-bit)")
+	fs.StringVar(&o.CASignatureAlgorithm, "ca-sig-alg", "", "the default signature algorithm used by the new ICA to issue certificates and CRLs (default decided by the key)")
+}
+
+type CertIssueOption struct {
+	IssuerOption
+	KeyOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	SANOption
+	PolicyOption
+	SaveOption
+
+	Validity string
+}
+
+func (o *CertIssueOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	o.KeyOption.setFlags(fs)
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.SANOption.setFlags(fs)
+	o.PolicyOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "5y", "validity, e.g. 10y / 6m / 30d")
+}
+
+type CertSelfOption struct {
+	KeyOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	SANOption
+	URLOption
+	SaveOption
+
+	Validity string
+}
+
+func (o *CertSelfOption) setFlags(fs *flag.FlagSet) {
+	o.KeyOption.setFlags(fs)
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.SANOption.setFlags(fs)
+	o.URLOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "5y", "validity, e.g. 10y / 6m / 30d")
+}
+
+type CertSignOption struct {
+	IssuerOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	SANOption
+	PolicyOption
+
+	CSR      string
+	Validity string
+	Name     string
+	Force    bool
+}
+
+func (o *CertSignOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.SANOption.setFlags(fs)
+	o.PolicyOption.setFlags(fs)
+	fs.StringVar(&o.CSR, "csr", "", "the path of the CSR file (PEM or DER)")
+	fs.StringVar(&o.Validity, "validity", "5y", "validity, e.g. 10y / 6m / 30d")
+	fs.StringVar(&o.Name, "name", "", "the directory name to save (default is generated from the common name)")
+	fs.BoolVar(&o.Force, "force", false, "overwrite the duplicate file")
+}
+
+type CSRCreateOption struct {
+	KeyOption
+	SignatureOption
+	SubjectOption
+	UsageOption
+	SANOption
+	URLOption
+	SaveOption
+
+	CA         bool
+	MaxPathLen int
+}
+
+func (o *CSRCreateOption) setFlags(fs *flag.FlagSet) {
+	o.KeyOption.setFlags(fs)
+	o.SignatureOption.setFlags(fs)
+	o.SubjectOption.setFlags(fs)
+	o.UsageOption.setFlags(fs)
+	o.SANOption.setFlags(fs)
+	o.URLOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.BoolVar(&o.CA, "ca", false, "the request is for an ICA which will be signed by an external CA")
+	fs.IntVar(&o.MaxPathLen, "max-path-len", -1, "the ca max path len limit, -1 means no limit")
+}
+
+type ICAImportSignedOption struct {
+	Pending  string
+	Cert     string
+	Chain    string
+	Password string
+	Name     string
+	Force    bool
+}
+
+func (o *ICAImportSignedOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Pending, "pending", "", "the name of the pending request")
+	fs.StringVar(&o.Cert, "cert", "", "the path of the signed certificate (PEM or DER, may include the upstream chain)")
+	fs.StringVar(&o.Chain, "chain", "", "the path of the upstream chain (optional)")
+	fs.StringVar(&o.Password, "password", "", "the password of the pending private key")
+	fs.StringVar(&o.Name, "name", "", "the directory name to save (default is generated from the common name)")
+	fs.BoolVar(&o.Force, "force", false, "overwrite the duplicate file")
+}
+
+type CertRevokeOption struct {
+	Cert        string
+	CertType    string
+	Serial      string
+	Fingerprint string
+	Reason      string
+	Issuer      string
+	IssuerType  string
+}
+
+func (o *CertRevokeOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Cert, "cert", "", "the directory name of the certificate to revoke")
+	fs.StringVar(&o.CertType, "cert-type", "CERT", "the type of the certificate to revoke: CERT or ICA")
+	fs.StringVar(&o.Serial, "serial", "", "the serial number (hex) of the certificate to revoke")
+	fs.StringVar(&o.Fingerprint, "fingerprint", "", "the SHA-256 fingerprint of the certificate to revoke")
+	fs.StringVar(&o.Reason, "reason", "unspecified", "the revocation reason (RFC 5280), name or code, e.g. keyCompromise / 1")
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the issuer (required when revoking a serial number which is not saved in MyCA)")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the issuer: RCA or ICA")
+}
+
+type RevokeListOption struct {
+	Issuer     string
+	IssuerType string
+}
+
+func (o *RevokeListOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the CA")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the CA: RCA or ICA")
+}
+
+type CRLCreateOption struct {
+	IssuerOption
+
+	NextUpdate string
+	Delta      bool
+}
+
+func (o *CRLCreateOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	fs.StringVar(&o.NextUpdate, "next-update", "7d", "the next update interval of the CRL, e.g. 7d / 12h")
+	fs.BoolVar(&o.Delta, "delta", false, "generate a delta CRL which only contains the certificates revoked after the last complete CRL")
+}
+
+type OCSPSignerOption struct {
+	IssuerOption
+	KeyOption
+
+	Validity string
+	Password string
+}
+
+func (o *OCSPSignerOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	o.KeyOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "30d", "validity, e.g. 30d / 6m")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key (default no password)")
+}
+
+type ServeOCSPOption struct {
+	Listen     string
+	RCA        StringSlice
+	ICA        StringSlice
+	Password   string
+	CAPassword StringSlice
+	NextUpdate string
+}
+
+func (o *ServeOCSPOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:8080", "the address to listen")
+	fs.Var(&o.RCA, "rca", "the directory name of the RCA to serve (repeatable)")
+	fs.Var(&o.ICA, "ica", "the directory name of the ICA to serve (repeatable)")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key of the OCSP signing certificate (or the CA when it has no OCSP signing certificate)")
+	fs.Var(&o.CAPassword, "ca-password", "the password of one CA in the form <name>=<password>, e.g. ICA-MyICA=my_password, overriding -password for this CA (repeatable)")
+	fs.StringVar(&o.NextUpdate, "next-update", "1h", "the next update interval of the OCSP response")
+}
+
+type ServeACMEOption struct {
+	PolicyOption
+	AllowExtKeyUsageOption
+
+	Listen      string
+	BaseURL     string
+	ICA         string
+	Password    string
+	Validity    string
+	TLSCert     string
+	TLSKey      string
+	HTTPPort    int
+	TLSPort     int
+	DNSResolver string
+}
+
+func (o *ServeACMEOption) setFlags(fs *flag.FlagSet) {
+	o.PolicyOption.setFlags(fs)
+	o.AllowExtKeyUsageOption.setFlags(fs)
+
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:14000", "the address to listen")
+	fs.StringVar(&o.BaseURL, "base-url", "", "the URL used by the clients to access the server, e.g. https://acme.example.com (default http(s)://<listen>)")
+	fs.StringVar(&o.ICA, "ica", "", "the directory name of the ICA which issues the certificates")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key of the ICA (default no password)")
+	fs.StringVar(&o.Validity, "validity", "90d", "the validity of the issued certificates")
+	fs.StringVar(&o.TLSCert, "tls-cert", "", "the certificate (PEM) of the server, serve HTTPS when it is set")
+	fs.StringVar(&o.TLSKey, "tls-key", "", "the private key (PEM, no password) of the server")
+	fs.IntVar(&o.HTTPPort, "http-port", 80, "the port to connect to when validating the http-01 challenges")
+	fs.IntVar(&o.TLSPort, "tls-port", 443, "the port to connect to when validating the tls-alpn-01 challenges")
+	fs.StringVar(&o.DNSResolver, "dns-resolver", "", "the DNS server (host[:port]) used to validate the challenges (default the system resolver)")
+}
+
+type ServeESTOption struct {
+	IssuerOption
+	PolicyOption
+	AllowExtKeyUsageOption
+
+	Listen         string
+	Validity       string
+	User           StringSlice
+	TLSCert        string
+	TLSKey         string
+	TLSKeyPassword string
+	KeyGenPassword string
+}
+
+func (o *ServeESTOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	o.PolicyOption.setFlags(fs)
+	o.AllowExtKeyUsageOption.setFlags(fs)
+
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:8443", "the address to listen")
+	fs.StringVar(&o.Validity, "validity", "365d", "the validity of the issued certificates")
+	fs.Var(&o.User, "user", "the user of HTTP basic authentication, format: name:password (repeatable)")
+	fs.StringVar(&o.TLSCert, "tls-cert", "", "the certificate (PEM) of the server, EST must be served over HTTPS")
+	fs.StringVar(&o.TLSKey, "tls-key", "", "the private key (PEM or DER) of the server")
+	fs.StringVar(&o.TLSKeyPassword, "tls-key-password", "", "the password of the private key of the server")
+	fs.StringVar(&o.KeyGenPassword, "keygen-password", "", "the password to protect the private keys generated by serverkeygen when they are saved in home (default no password)")
+}
+
+type ServeSCEPOption struct {
+	IssuerOption
+	PolicyOption
+	AllowExtKeyUsageOption
+
+	Listen         string
+	Validity       string
+	RAPassword     string
+	ManualApproval bool
+}
+
+func (o *ServeSCEPOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	o.PolicyOption.setFlags(fs)
+	o.AllowExtKeyUsageOption.setFlags(fs)
+
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:8081", "the address to listen")
+	fs.StringVar(&o.Validity, "validity", "365d", "the validity of the issued certificates")
+	fs.StringVar(&o.RAPassword, "ra-password", "", "the password of the private key of the RA certificate")
+	fs.BoolVar(&o.ManualApproval, "manual-approval", false, "keep the requests without challenge password pending until approved by scep request approve (default reject them)")
+}
+
+type SCEPRAOption struct {
+	IssuerOption
+
+	KeyLength int
+	Validity  string
+	Password  string
+}
+
+func (o *SCEPRAOption) setFlags(fs *flag.FlagSet) {
+	o.IssuerOption.setFlags(fs, "ICA")
+	fs.IntVar(&o.KeyLength, "key-length", 2048, "the length of the RSA key of the RA: 2048/3072/4096")
+	fs.StringVar(&o.Validity, "validity", "365d", "validity, e.g. 365d / 1y")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key (default no password)")
+}
+
+// SCEPStoreOption 选择SCEP服务的CA，用于管理挑战密码和签发请求
+type SCEPStoreOption struct {
+	Issuer     string
+	IssuerType string
+}
+
+func (o *SCEPStoreOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the CA")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the CA: RCA or ICA")
+}
+
+type SCEPChallengeCreateOption struct {
+	SCEPStoreOption
+
+	Validity string
+	Uses     int
+	Comment  string
+}
+
+func (o *SCEPChallengeCreateOption) setFlags(fs *flag.FlagSet) {
+	o.SCEPStoreOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "7d", "the validity of the challenge password")
+	fs.IntVar(&o.Uses, "uses", 1, "the maximum number of certificates issued with the challenge password (0 means unlimited)")
+	fs.StringVar(&o.Comment, "comment", "", "the comment of the challenge password, e.g. the device name")
+}
+
+type SCEPIDOption struct {
+	SCEPStoreOption
+
+	ID     string
+	Reason string
+}
+
+func (o *SCEPIDOption) setFlags(fs *flag.FlagSet) {
+	o.SCEPStoreOption.setFlags(fs)
+	fs.StringVar(&o.ID, "id", "", "the id of the challenge password or request")
+}
+
+func (o *SCEPIDOption) setRejectFlags(fs *flag.FlagSet) {
+	o.setFlags(fs)
+	fs.StringVar(&o.Reason, "reason", "", "the reason of the rejection")
+}
+
+type SCEPRequestListOption struct {
+	SCEPStoreOption
+
+	Status string
+}
+
+func (o *SCEPRequestListOption) setFlags(fs *flag.FlagSet) {
+	o.SCEPStoreOption.setFlags(fs)
+	fs.StringVar(&o.Status, "status", "", "only show the requests with the status: pending / approved / rejected / issued")
+}
+
+type ServeCMPOption struct {
+	PolicyOption
+	AllowExtKeyUsageOption
+
+	Listen         string
+	ICA            string
+	Password       string
+	Validity       string
+	SignerPassword string
+}
+
+func (o *ServeCMPOption) setFlags(fs *flag.FlagSet) {
+	o.PolicyOption.setFlags(fs)
+	o.AllowExtKeyUsageOption.setFlags(fs)
+
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:8082", "the address to listen")
+	fs.StringVar(&o.ICA, "ica", "", "the directory name of the ICA which issues the certificates")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key of the ICA (default no password)")
+	fs.StringVar(&o.Validity, "validity", "365d", "the validity of the issued certificates")
+	fs.StringVar(&o.SignerPassword, "signer-password", "", "the password of the private key of the CMP signer certificate")
+}
+
+type CMPSignerOption struct {
+	KeyOption
+
+	ICA         string
+	ICAPassword string
+	Validity    string
+	Password    string
+}
+
+func (o *CMPSignerOption) setFlags(fs *flag.FlagSet) {
+	o.KeyOption.setFlags(fs)
+	fs.StringVar(&o.ICA, "ica", "", "the directory name of the ICA")
+	fs.StringVar(&o.ICAPassword, "ica-password", "", "the password of the private key of the ICA")
+	fs.StringVar(&o.Validity, "validity", "365d", "validity, e.g. 365d / 1y")
+	fs.StringVar(&o.Password, "password", "", "the password of the private key (default no password)")
+}
+
+// CMPStoreOption 选择CMP服务的ICA，用于管理共享密钥
+type CMPStoreOption struct {
+	ICA string
+}
+
+func (o *CMPStoreOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.ICA, "ica", "", "the directory name of the ICA")
+}
+
+type CMPSecretCreateOption struct {
+	CMPStoreOption
+
+	Validity string
+	Uses     int
+	Comment  string
+}
+
+func (o *CMPSecretCreateOption) setFlags(fs *flag.FlagSet) {
+	o.CMPStoreOption.setFlags(fs)
+	fs.StringVar(&o.Validity, "validity", "7d", "the validity of the secret")
+	fs.IntVar(&o.Uses, "uses", 1, "the maximum number of certificates issued with the secret (0 means unlimited)")
+	fs.StringVar(&o.Comment, "comment", "", "the comment of the secret, e.g. the device name")
+}
+
+type CMPSecretRevokeOption struct {
+	CMPStoreOption
+
+	Reference string
+}
+
+func (o *CMPSecretRevokeOption) setFlags(fs *flag.FlagSet) {
+	o.CMPStoreOption.setFlags(fs)
+	fs.StringVar(&o.Reference, "ref", "", "the reference of the secret")
+}
+
+type ServeAPIOption struct {
+	AllowExtKeyUsageOption
+
+	Listen         string
+	TLSCert        string
+	TLSKey         string
+	TLSKeyPassword string
+	ClientCA       StringSlice
+}
+
+func (o *ServeAPIOption) setFlags(fs *flag.FlagSet) {
+	o.AllowExtKeyUsageOption.setFlags(fs)
+
+	fs.StringVar(&o.Listen, "listen", "127.0.0.1:8083", "the address to listen")
+	fs.StringVar(&o.TLSCert, "tls-cert", "", "the certificate (PEM) of the server (default serve over HTTP)")
+	fs.StringVar(&o.TLSKey, "tls-key", "", "the private key (PEM or DER) of the server")
+	fs.StringVar(&o.TLSKeyPassword, "tls-key-password", "", "the password of the private key of the server")
+	fs.Var(&o.ClientCA, "client-ca", "accept the TLS client certificates issued by the CA, format: RCA/<name> or ICA/<name> (repeatable, requires -tls-cert)")
+}
+
+type APITokenCreateOption struct {
+	Name     string
+	Validity string
+	ReadOnly bool
+}
+
+func (o *APITokenCreateOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Name, "name", "", "the name of the token, e.g. the tool which uses it")
+	fs.StringVar(&o.Validity, "validity", "90d", "the validity of the token")
+	fs.BoolVar(&o.ReadOnly, "read-only", false, "the token can only call the GET endpoints")
+}
+
+type APITokenRevokeOption struct {
+	ID string
+}
+
+func (o *APITokenRevokeOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.ID, "id", "", "the id of the token")
+}
+
+type CertRenewOption struct {
+	SignatureOption
+
+	Name           string
+	Validity       string
+	Rekey          bool
+	CryptoType     string
+	KeyLength      int
+	KeyFile        string
+	KeyFrom        string
+	KeyPassword    string
+	Password       string
+	NewPassword    string
+	IssuerPassword string
+}
+
+func (o *CertRenewOption) setFlags(fs *flag.FlagSet) {
+	o.SignatureOption.setFlags(fs)
+	fs.StringVar(&o.Name, "name", "", "the directory name of the certificate to renew")
+	fs.StringVar(&o.Validity, "validity", "", "validity of the new certificate, e.g. 1y / 90d (default the same as the current certificate)")
+	fs.BoolVar(&o.Rekey, "rekey", false, "generate a new private key instead of reusing the current one")
+	fs.StringVar(&o.CryptoType, "crypto", "", "crypto type of the new key: RSA / ECDSA / Ed25519, implies -rekey (default the same as the current key)")
+	fs.IntVar(&o.KeyLength, "key-length", 0, "key length of the new key, implies -rekey (default the same as the current key)")
+	fs.StringVar(&o.KeyFile, "key-file", "", "rekey with an existing private key file (PEM, PKCS#8), implies -rekey")
+	fs.StringVar(&o.KeyFrom, "key-from", "", "rekey with the private key of an existing entry in MyCA, e.g. PENDING/CSR-example.com, implies -rekey")
+	fs.StringVar(&o.KeyPassword, "key-password", "", "the password of the private key set by -key-file or -key-from")
+	fs.StringVar(&o.Password, "password", "", "the password of the current private key")
+	fs.StringVar(&o.NewPassword, "new-password", "", "the password of the new private key file (default the same as -password)")
+	fs.StringVar(&o.IssuerPassword, "issuer-password", "", "the password of the issuer CA private key")
+}
+
+// ListOption rca list、ica list和cert list的参数
+type ListOption struct {
+	Format string
+}
+
+func (o *ListOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Format, "format", "table", "output format: table / json")
+}
+
+type PolicyAddOption struct {
+	Issuer     string
+	IssuerType string
+	Def        StringSlice
+}
+
+func (o *PolicyAddOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the CA")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the CA: RCA or ICA")
+	fs.Var(&o.Def, "def", "the named certificate policy: name=OID[;cps=URL][;notice=TEXT], replace the policy with the same name (repeatable)")
+}
+
+type PolicyListOption struct {
+	Issuer     string
+	IssuerType string
+	Format     string
+}
+
+func (o *PolicyListOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the CA")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the CA: RCA or ICA")
+	fs.StringVar(&o.Format, "format", "table", "output format: table / json")
+}
+
+type IssuedListOption struct {
+	Issuer        string
+	IssuerType    string
+	Search        string
+	Status        string
+	Type          string
+	ExpiresWithin string
+}
+
+func (o *IssuedListOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Issuer, "issuer", "", "the directory name of the CA (default all CA)")
+	fs.StringVar(&o.IssuerType, "issuer-type", "ICA", "the type of the CA: RCA or ICA")
+	fs.StringVar(&o.Search, "search", "", "only show the certificates whose subject, SAN, serial number (hex), fingerprint or path contains the text")
+	fs.StringVar(&o.Status, "status", "", "only show the certificates with the status: valid / revoked / expired / superseded")
+	fs.StringVar(&o.Type, "type", "", "only show the certificates with the type: ICA / CERT / OCSP-SIGNER / SCEP-RA / CMP-SIGNER")
+	fs.StringVar(&o.ExpiresWithin, "expires-within", "", "only show the certificates which will expire within the duration, e.g. 30d")
+}
+
+// CAImportOption ca import的参数
+type CAImportOption struct {
+	SignatureOption
+	URLOption
+	SaveOption
+
+	Cert        string
+	Key         string
+	Chain       string
+	PFX         string
+	KeyPassword string
+	SerialMode  string
+}
+
+func (o *CAImportOption) setFlags(fs *flag.FlagSet) {
+	o.SignatureOption.setFlags(fs)
+	o.URLOption.setFlags(fs)
+	o.SaveOption.setFlags(fs)
+	fs.StringVar(&o.Cert, "cert", "", "the CA certificate file (PEM or DER, may include the upstream chain and the private key)")
+	fs.StringVar(&o.Key, "key", "", "the private key file (PEM or DER, PKCS#8 / PKCS#1 / SEC1, default read from -cert)")
+	fs.StringVar(&o.Chain, "chain", "", "the upstream chain file of an ICA (optional, not needed if the issuer is managed by MyCA)")
+	fs.StringVar(&o.PFX, "pfx", "", "the PFX (PKCS#12) file which contains the certificate, private key and chain, instead of -cert and -key")
+	fs.StringVar(&o.KeyPassword, "key-password", "", "the password of the private key file or the PFX file")
+	fs.StringVar(&o.SerialMode, "serial-mode", "sequential", "serial number mode: sequential / random")
+}
+
+// InspectOption inspect的参数
+type InspectOption struct {
+	File     string
+	Password string
+	Format   string
+}
+
+func (o *InspectOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "file", "", "the certificate, chain, CSR, CRL, PFX or SPX file (PEM or DER)")
+	fs.StringVar(&o.Password, "password", "", "the password of the PFX file or the encrypted private key")
+	fs.StringVar(&o.Format, "format", "text", "output format: text / json")
+}
+
+// VerifyOption verify的参数
+type VerifyOption struct {
+	File     string
+	Password string
+	Purpose  string
+	Hostname string
+	Email    string
+	Time     string
+	Roots    string
+	Format   string
+}
+
+func (o *VerifyOption) setFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "file", "", "the certificate or chain (the first certificate is verified) file, PEM, DER, PFX or SPX")
+	fs.StringVar(&o.Password, "password", "", "the password of the PFX file")
+	fs.StringVar(&o.Purpose, "purpose", "server", "the purpose of the certificate: server / client / code / email / any")
+	fs.StringVar(&o.Hostname, "hostname", "", "the DNS name or IP address the certificate must be valid for")
+	fs.StringVar(&o.Email, "email", "", "the email address the certificate must be valid for")
+	fs.StringVar(&o.Time, "time", "", "verify at the time (RFC 3339, \"2006-01-02 15:04:05\" or \"2006-01-02\", default now)")
+	fs.StringVar(&o.Roots, "roots", "", "the additional trusted root certificates file (PEM or DER), besides the RCA in home")
+	fs.StringVar(&o.Format, "format", "text", "output format: text / json")
+}
+
+var RCACreate RCACreateOption
+var ICACreate ICACreateOption
+var CertIssue CertIssueOption
+var CertSelf CertSelfOption
+var CertSign CertSignOption
+var CSRCreate CSRCreateOption
+var ICAImportSigned ICAImportSignedOption
+var CertRevoke CertRevokeOption
+var RevokeList RevokeListOption
+var CRLCreate CRLCreateOption
+var OCSPSigner OCSPSignerOption
+var ServeOCSP ServeOCSPOption
+var ServeACME ServeACMEOption
+var ServeEST ServeESTOption
+var ServeSCEP ServeSCEPOption
+var SCEPRA SCEPRAOption
+var SCEPChallengeCreate SCEPChallengeCreateOption
+var SCEPChallengeList SCEPStoreOption
+var SCEPChallengeRevoke SCEPIDOption
+var SCEPRequestList SCEPRequestListOption
+var SCEPRequestApprove SCEPIDOption
+var SCEPRequestReject SCEPIDOption
+var ServeCMP ServeCMPOption
+var CMPSigner CMPSignerOption
+var CMPSecretCreate CMPSecretCreateOption
+var CMPSecretList CMPStoreOption
+var CMPSecretRevoke CMPSecretRevokeOption
+var ServeAPI ServeAPIOption
+var APITokenCreate APITokenCreateOption
+var APITokenRevoke APITokenRevokeOption
+var CertRenew CertRenewOption
+var IssuedList IssuedListOption
+var RCAList ListOption
+var ICAList ListOption
+var CertList ListOption
+var Inspect InspectOption
+var CAImport CAImportOption
+var Verify VerifyOption
+var PolicyAdd PolicyAddOption
+var PolicyList PolicyListOption
+
+func init() {
+	addSubCommand("rca list", "show all RCA", RCAList.setFlags)
+	addSubCommand("ica list", "show all ICA", ICAList.setFlags)
+	addSubCommand("cert list", "show all user certificates", CertList.setFlags)
+	addSubCommand("rca create", "create RCA (self signed)", RCACreate.setFlags)
+	addSubCommand("ica create", "create ICA from RCA or another ICA", ICACreate.setFlags)
+	addSubCommand("ca import", "import an existing CA (e.g. created by OpenSSL) as RCA (self signed) or ICA", CAImport.setFlags)
+	addSubCommand("cert issue", "create user certificate from RCA or ICA", CertIssue.setFlags)
+	addSubCommand("cert self", "create user certificate (self signed)", CertSelf.setFlags)
+	addSubCommand("cert renew", "renew a user certificate from the same issuer and archive the previous one", CertRenew.setFlags)
+	addSubCommand("cert sign", "sign an external CSR (PKCS#10) with RCA or ICA", CertSign.setFlags)
+	addSubCommand("csr list", "show all pending CSR", nil)
+	addSubCommand("csr create", "create a key and CSR to be signed by an external CA", CSRCreate.setFlags)
+	addSubCommand("ica import-signed", "import the ICA signed by an external CA for a pending CSR", ICAImportSigned.setFlags)
+	addSubCommand("cert revoke", "revoke an ICA or user certificate", CertRevoke.setFlags)
+	addSubCommand("revoke list", "show the revoked certificates of RCA or ICA", RevokeList.setFlags)
+	addSubCommand("crl create", "generate the CRL (or delta CRL) of RCA or ICA", CRLCreate.setFlags)
+	addSubCommand("ocsp signer", "create the delegated OCSP signing certificate of RCA or ICA", OCSPSigner.setFlags)
+	addSubCommand("serve ocsp", "run the OCSP responder for RCA and ICA", ServeOCSP.setFlags)
+	addSubCommand("serve acme", "run the ACME (RFC 8555) server which issues certificates from an ICA", ServeACME.setFlags)
+	addSubCommand("serve est", "run the EST (RFC 7030) enrollment server which issues certificates from RCA or ICA", ServeEST.setFlags)
+	addSubCommand("serve scep", "run the SCEP (RFC 8894) server which issues certificates from RCA or ICA", ServeSCEP.setFlags)
+	addSubCommand("scep ra", "create the SCEP RA certificate (RSA) of RCA or ICA", SCEPRA.setFlags)
+	addSubCommand("scep challenge create", "generate a SCEP challenge password", SCEPChallengeCreate.setFlags)
+	addSubCommand("scep challenge list", "show the SCEP challenge passwords", SCEPChallengeList.setFlags)
+	addSubCommand("scep challenge revoke", "revoke a SCEP challenge password", SCEPChallengeRevoke.setFlags)
+	addSubCommand("scep request list", "show the SCEP requests", SCEPRequestList.setFlags)
+	addSubCommand("scep request approve", "approve a pending SCEP request, the certificate is issued when the client polls", SCEPRequestApprove.setFlags)
+	addSubCommand("scep request reject", "reject a pending SCEP request", SCEPRequestReject.setRejectFlags)
+	addSubCommand("serve cmp", "run the CMP (RFC 4210/9483) server which issues and revokes certificates of an ICA", ServeCMP.setFlags)
+	addSubCommand("cmp signer", "create the CMP signer certificate of ICA for signing the responses", CMPSigner.setFlags)
+	addSubCommand("cmp secret create", "generate a CMP shared secret for password-based MAC", CMPSecretCreate.setFlags)
+	addSubCommand("cmp secret list", "show the CMP shared secrets", CMPSecretList.setFlags)
+	addSubCommand("cmp secret revoke", "revoke a CMP shared secret", CMPSecretRevoke.setFlags)
+	addSubCommand("serve api", "run the authenticated JSON REST API of MyCA (OpenAPI description at /api/v1/openapi.json)", ServeAPI.setFlags)
+	addSubCommand("api token create", "generate a bearer token of the REST API", APITokenCreate.setFlags)
+	addSubCommand("api token list", "show the tokens of the REST API", nil)
+	addSubCommand("api token revoke", "revoke a token of the REST API", APITokenRevoke.setFlags)
+	addSubCommand("policy add", "define named certificate policies in the metadata of RCA or ICA", PolicyAdd.setFlags)
+	addSubCommand("policy list", "show the named certificate policies of RCA or ICA", PolicyList.setFlags)
+	addSubCommand("issued list", "list and search the certificates issued by RCA or ICA", IssuedList.setFlags)
+	addSubCommand("inspect", "show all fields and extensions of a certificate, chain, CSR, CRL, PFX or SPX file", Inspect.setFlags)
+	addSubCommand("verify", "verify the certificate chain, purpose, hostname, validity and revocation status with the RCA and ICA in home", Verify.setFlags)
+	addSubCommand("audit keys", "find the weak private keys (e.g. generated by math/rand in old versions) which should be rotated", nil)
+}
@@ -0,0 +1,98 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package flagparser
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type SubCommand struct {
+	Name    string
+	Usage   string
+	FlagSet *flag.FlagSet
+}
+
+var subCommandList = make([]*SubCommand, 0, 20)
+
+// Command 被选中的子命令（例如：rca create），为空表示进入交互模式
+var Command string
+
+func addSubCommand(name string, usage string, setFlags func(fs *flag.FlagSet)) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if setFlags != nil {
+		setFlags(fs)
+	}
+
+	sc := &SubCommand{
+		Name:    name,
+		Usage:   usage,
+		FlagSet: fs,
+	}
+
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(fs.Output(), "Usage: myca [global options] %s [options]\n", sc.Name)
+		_, _ = fmt.Fprintf(fs.Output(), "  %s\n", sc.Usage)
+		_, _ = fmt.Fprintf(fs.Output(), "Options:\n")
+		fs.PrintDefaults()
+	}
+
+	subCommandList = append(subCommandList, sc)
+}
+
+func findSubCommand(args []string) (*SubCommand, []string) {
+	// 优先匹配更长的命令（例如：rca create），再匹配单个单词的命令
+	for n := 3; n >= 1; n-- {
+		if len(args) < n {
+			continue
+		}
+
+		name := strings.Join(args[:n], " ")
+		for _, sc := range subCommandList {
+			if sc.Name == name {
+				return sc, args[n:]
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func parseSubCommand(args []string) error {
+	sc, subArgs := findSubCommand(args)
+	if sc == nil {
+		return fmt.Errorf("unknown command: %s", strings.Join(args, " "))
+	}
+
+	err := sc.FlagSet.Parse(subArgs)
+	if errors.Is(err, flag.ErrHelp) {
+		return StopRun
+	} else if err != nil {
+		return err
+	}
+
+	if sc.FlagSet.NArg() != 0 {
+		return fmt.Errorf("unexpected argument: %s", strings.Join(sc.FlagSet.Args(), " "))
+	}
+
+	Command = sc.Name
+	return nil
+}
+
+func printUsage() {
+	out := flag.CommandLine.Output()
+	_, _ = fmt.Fprintf(out, "Usage: %s [global options] [command] [options]\n", os.Args[0])
+	_, _ = fmt.Fprintf(out, "Without command, MyCA enter the interactive mode.\n")
+	_, _ = fmt.Fprintf(out, "Global Options:\n")
+	flag.PrintDefaults()
+	_, _ = fmt.Fprintf(out, "Commands:\n")
+	for _, sc := range subCommandList {
+		_, _ = fmt.Fprintf(out, "  %-22s %s\n", sc.Name, sc.Usage)
+	}
+	_, _ = fmt.Fprintf(out, "Use \"%s [command] -help\" for more information about a command.\n", os.Args[0])
+}
@@ -0,0 +1,33 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package flagparser
+
+import (
+	"strings"
+)
+
+// StringSlice 可重复指定的字符串参数，例如：-dns a.com -dns b.com
+type StringSlice []string
+
+func (s *StringSlice) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *StringSlice) Set(value string) error {
+	if value = strings.TrimSpace(value); value != "" {
+		*s = append(*s, value)
+	}
+	return nil
+}
+
+func (s *StringSlice) Value() []string {
+	if s == nil {
+		return make([]string, 0, 0)
+	}
+	return []string(*s)
+}
@@ -106,8 +106,8 @@ func (c *CertSubject) SetCNIfEmpty(args ...any) error {
 	} else if len(args) == 4 {
 		domains, ok1 := args[0].([]string)
 		ips, ok2 := args[1].([]net.IP)
-		emails, ok3 := args[3].([]string)
-		urls, ok4 := args[4].([]*url.URL)
+		emails, ok3 := args[2].([]string)
+		urls, ok4 := args[3].([]*url.URL)
 
 		if !ok1 || !ok2 || !ok3 || !ok4 {
 			return fmt.Errorf("args error")
@@ -144,3 +144,35 @@ func (c *CertSubject) ToPkixName() pkix.Name {
 		SerialNumber:       "", // 与证书的`SerialNumber`不同，默认可以不设置
 	}
 }
+
+// NewCertSubjectFromPkixName 根据 pkix.Name 创建 CertSubject（例如：来自证书签名请求或已有证书）
+func NewCertSubjectFromPkixName(name pkix.Name) (*CertSubject, error) {
+	res := NewCertSubject()
+
+	for _, item := range []struct {
+		Name  string
+		Value []string
+	}{
+		{"C", name.Country},
+		{"ST", name.Province},
+		{"L", name.Locality},
+		{"O", name.Organization},
+		{"OU", name.OrganizationalUnit},
+		{"SA", name.StreetAddress},
+		{"PC", name.PostalCode},
+	} {
+		err := res.Set(item.Name, item.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name.CommonName != "" {
+		err := res.Set("CN", []string{name.CommonName})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
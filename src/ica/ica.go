@@ -2,22 +2,26 @@ package ica
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/issuance"
+	"github.com/SongZihuan/MyCA/src/metadata"
 	"github.com/SongZihuan/MyCA/src/utils"
+	"io/fs"
 	"math/big"
-	"os"
+	"path"
+	"sync"
 	"time"
 )
 
 type UpstreamCAInfo interface {
+	NewCertSerialNumber() (*big.Int, error)
 	GetIssuingCertificateURL() []string
+	GetSignatureAlgorithm() x509.SignatureAlgorithm
+	Reference() *metadata.Reference
 }
 
 type ICAInfo struct {
@@ -25,17 +29,54 @@ type ICAInfo struct {
 	OCSPServer            []string
 	IssuingCertificateURL []string
 	CRLDistributionPoints []string
-	CA                    UpstreamCAInfo
-	FilePath              string `gob:"-"`
+	CA                    UpstreamCAInfo             // 上级CA，仅在创建ICA时使用，不保存到信息文件中（旧版本的gob信息文件中保存了副本）
+	Issuer                *metadata.Reference        // 对上级CA的引用
+	CRLNumber             *big.Int                   // 最近一次签发的CRL的编号（包括增量CRL）
+	BaseCRLNumber         *big.Int                   // 最近一次签发的完整CRL的编号，增量CRL以此为基础
+	BaseCRLAt             time.Time                  // 最近一次签发的完整CRL的thisUpdate
+	RandSource            string                     // 生成私钥时使用的随机源，旧版本（使用math/rand）创建的ICA为空
+	SignatureAlgorithm    x509.SignatureAlgorithm    // 签发证书和CRL默认使用的签名算法，为0时由私钥决定
+	SerialMode            string                     // 证书序列号的分配模式，为空时（旧版本创建的CA）使用顺序模式
+	NameConstraints       *utils.NameConstraints     // ICA证书的名称约束，为nil时表示没有约束
+	Policies              []*utils.CertificatePolicy // 可供签发证书时选择的命名证书策略
+	FilePath              string                     `gob:"-"`
+}
+
+// ExternalCAInfo 外部CA（例如公共CA或企业根CA）的信息，用于导入由外部CA签发的ICA
+type ExternalCAInfo struct {
+	IssuingCertificateURL []string
+}
+
+func (info *ExternalCAInfo) GetIssuingCertificateURL() []string {
+	return info.IssuingCertificateURL
+}
+
+// NewCertSerialNumber 外部CA签发的证书的序列号不由MyCA分配
+func (info *ExternalCAInfo) NewCertSerialNumber() (*big.Int, error) {
+	return nil, fmt.Errorf("the external CA can not issue certificates by MyCA")
+}
+
+// GetSignatureAlgorithm 外部CA的签名算法不由MyCA决定
+func (info *ExternalCAInfo) GetSignatureAlgorithm() x509.SignatureAlgorithm {
+	return x509.UnknownSignatureAlgorithm
+}
+
+func (info *ExternalCAInfo) Reference() *metadata.Reference {
+	return &metadata.Reference{
+		Type:                  metadata.ReferenceExternal,
+		IssuingCertificateURL: info.IssuingCertificateURL,
+	}
 }
 
 func init() {
-	gob.Register(ICAInfo{})
+	// 旧版本的信息文件（gob）中保存了上级CA信息的副本，迁移时需要解码
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/ica.ExternalCAInfo", &ExternalCAInfo{})
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/ica.ICAInfo", &ICAInfo{})
 }
 
 func NewICAInfo(filepath string, ca UpstreamCAInfo, ocsp []string, issuerURL []string, crlURL []string) (*ICAInfo, error) {
 	randMax := new(big.Int).Lsh(big.NewInt(1), uint(40))
-	randSerialNumber, err := rand.Int(rand.Reader, randMax)
+	randSerialNumber, err := utils.RandInt(randMax)
 	if err != nil {
 		return nil, fmt.Errorf("error generating random number: %s", err.Error())
 	}
@@ -46,59 +87,164 @@ func NewICAInfo(filepath string, ca UpstreamCAInfo, ocsp []string, issuerURL []s
 		IssuingCertificateURL: issuerURL,
 		CRLDistributionPoints: crlURL,
 		CA:                    ca,
+		Issuer:                ca.Reference(),
+		RandSource:            utils.RandSource(),
 		FilePath:              filepath,
 	}
 
 	return info, nil
 }
 
+// icaInfoFile ICA信息文件（ica-info.json）的格式
+type icaInfoFile struct {
+	metadata.Header
+	Issuer                *metadata.Reference `json:"issuer"`
+	SerialNumber          string              `json:"serial_number"`
+	SerialMode            string              `json:"serial_mode,omitempty"`
+	OCSPServer            []string            `json:"ocsp_server,omitempty"`
+	IssuingCertificateURL []string            `json:"issuing_certificate_url,omitempty"`
+	CRLDistributionPoints []string            `json:"crl_distribution_points,omitempty"`
+	CRLNumber             string              `json:"crl_number,omitempty"`
+	BaseCRLNumber         string              `json:"base_crl_number,omitempty"`
+	BaseCRLAt             *time.Time          `json:"base_crl_at,omitempty"`
+	RandSource            string              `json:"rand_source,omitempty"`
+	SignatureAlgorithm    string              `json:"signature_algorithm,omitempty"`
+
+	NameConstraints *utils.NameConstraints     `json:"name_constraints,omitempty"`
+	Policies        []*utils.CertificatePolicy `json:"policies,omitempty"`
+}
+
 func GetICAInfo(filepath string) (*ICAInfo, error) {
-	file, err := os.Open(filepath)
+	if metadata.NeedMigrate(filepath) {
+		return migrateICAInfo(filepath)
+	}
+
+	var f icaInfoFile
+	err := metadata.Read(filepath, metadata.KindICA, &f)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	var res ICAInfo
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&res)
+	res := &ICAInfo{
+		Issuer:                f.Issuer,
+		OCSPServer:            f.OCSPServer,
+		IssuingCertificateURL: f.IssuingCertificateURL,
+		CRLDistributionPoints: f.CRLDistributionPoints,
+		BaseCRLAt:             metadata.ParseTime(f.BaseCRLAt),
+		RandSource:            f.RandSource,
+		SerialMode:            f.SerialMode,
+		NameConstraints:       f.NameConstraints,
+		Policies:              f.Policies,
+		FilePath:              filepath,
+	}
+
+	res.SerialNumber, err = metadata.ParseBigInt(f.SerialNumber)
 	if err != nil {
 		return nil, err
+	} else if res.SerialNumber == nil {
+		return nil, fmt.Errorf("%s: serial number is missing", filepath)
 	}
 
-	res.FilePath = filepath
+	res.CRLNumber, err = metadata.ParseBigInt(f.CRLNumber)
+	if err != nil {
+		return nil, err
+	}
 
-	return &res, nil
+	res.BaseCRLNumber, err = metadata.ParseBigInt(f.BaseCRLNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	res.SignatureAlgorithm, err = metadata.ParseSignatureAlgorithm(f.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-func (info *ICAInfo) SaveICAInfo() error {
-	file, err := os.OpenFile(info.FilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+// migrateICAInfo 读取旧版本的ica-info.gob，将其中上级CA的副本替换为引用，保存为ica-info.json后删除旧文件
+func migrateICAInfo(filepath string) (*ICAInfo, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var res ICAInfo
+	err := metadata.ReadLegacy(legacyPath, &res)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(*info) // 不需要以指针形式出现
+	if res.CA != nil {
+		res.Issuer = res.CA.Reference()
+	}
+	res.CA = nil
+	res.FilePath = filepath
+
+	err = res.SaveICAInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	err = metadata.RemoveLegacy(legacyPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &res, nil
+}
+
+func (info *ICAInfo) SaveICAInfo() error {
+	return metadata.Write(info.FilePath, &icaInfoFile{
+		Header:                metadata.NewHeader(metadata.KindICA),
+		Issuer:                info.Issuer,
+		SerialNumber:          metadata.FormatBigInt(info.SerialNumber),
+		SerialMode:            info.SerialMode,
+		OCSPServer:            info.OCSPServer,
+		IssuingCertificateURL: info.IssuingCertificateURL,
+		CRLDistributionPoints: info.CRLDistributionPoints,
+		CRLNumber:             metadata.FormatBigInt(info.CRLNumber),
+		BaseCRLNumber:         metadata.FormatBigInt(info.BaseCRLNumber),
+		BaseCRLAt:             metadata.FormatTime(info.BaseCRLAt),
+		RandSource:            info.RandSource,
+		SignatureAlgorithm:    metadata.FormatSignatureAlgorithm(info.SignatureAlgorithm),
+		NameConstraints:       info.NameConstraints,
+		Policies:              info.Policies,
+	})
 }
 
+// serialNumberLock 保护分配序列号时对信息文件的读取和写入
+var serialNumberLock sync.Mutex
+
+// NewCertSerialNumber 分配新的证书序列号，序列号不与签发索引中的记录重复，并在签发证书前保存到信息文件中，避免下次签发时重复使用。
+// 长期运行的服务（EST、ACME等）持有的信息可能已过期（例如CRL编号已被命令行更新），因此重新读取信息文件并只更新其中的序列号
 func (info *ICAInfo) NewCertSerialNumber() (*big.Int, error) {
-	randMax := new(big.Int).Lsh(big.NewInt(1), uint(40))
-	addSerialNumber, err := rand.Int(rand.Reader, randMax)
+	serialNumberLock.Lock()
+	defer serialNumberLock.Unlock()
+
+	saved, err := GetICAInfo(info.FilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		saved = info // 正在创建的CA，信息文件尚未保存
+	} else if err != nil {
+		return nil, err
+	}
+
+	lastSerialNumber := info.SerialNumber
+	if saved.SerialNumber.Cmp(lastSerialNumber) > 0 {
+		lastSerialNumber = saved.SerialNumber
+	}
+
+	serialNumber, err := issuance.NewSerialNumber(path.Dir(info.FilePath), info.SerialMode, lastSerialNumber)
 	if err != nil {
-		return nil, fmt.Errorf("error generating random number: %s", err.Error())
+		return nil, err
 	}
 
-	return info.SerialNumber.Add(info.SerialNumber, addSerialNumber), nil
+	saved.SerialNumber = new(big.Int).Set(serialNumber)
+	err = saved.SaveICAInfo()
+	if err != nil {
+		return nil, fmt.Errorf("save the serial number failed: %s", err.Error())
+	}
+
+	info.SerialNumber = new(big.Int).Set(serialNumber)
+	return serialNumber, nil
 }
 
 func (info *ICAInfo) GetIssuingCertificateURL() []string {
@@ -113,15 +259,55 @@ func (info *ICAInfo) GetCRLDistributionPoints() []string {
 	return info.CRLDistributionPoints
 }
 
-// CreateICA 创建中间CA证书
-func CreateICA(infoFilePath string, caInfo UpstreamCAInfo, cryptoType utils.CryptoType, keyLength int, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, maxPathLen int, selfOSCP []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey) (*x509.Certificate, crypto.PrivateKey, *ICAInfo, error) {
-	var privKey crypto.PrivateKey
-	var pubKey crypto.PublicKey
+func (info *ICAInfo) GetSignatureAlgorithm() x509.SignatureAlgorithm {
+	return info.SignatureAlgorithm
+}
+
+// Reference 返回下级ICA和证书的信息文件中对该ICA的引用
+func (info *ICAInfo) Reference() *metadata.Reference {
+	return metadata.NewReference(metadata.ReferenceICA, info.FilePath)
+}
+
+// NewCRLNumber 返回新的CRL编号，CRL编号单调递增（旧版本创建的CA从1开始）
+func (info *ICAInfo) NewCRLNumber() *big.Int {
+	if info.CRLNumber == nil {
+		info.CRLNumber = big.NewInt(0)
+	}
+
+	info.CRLNumber = new(big.Int).Add(info.CRLNumber, big.NewInt(1))
+	return new(big.Int).Set(info.CRLNumber)
+}
+
+func (info *ICAInfo) GetBaseCRL() (*big.Int, time.Time) {
+	return info.BaseCRLNumber, info.BaseCRLAt
+}
 
+func (info *ICAInfo) SetBaseCRL(number *big.Int, thisUpdate time.Time) {
+	info.BaseCRLNumber = new(big.Int).Set(number)
+	info.BaseCRLAt = thisUpdate
+}
+
+// CreateICA 创建中间CA证书
+// signatureAlgorithm 为上级CA签发该证书使用的签名算法，为0时使用上级CA的默认签名算法；icaSignatureAlgorithm 为该ICA之后签发证书默认使用的签名算法
+func CreateICA(infoFilePath string, caInfo UpstreamCAInfo, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, maxPathLen int, nameConstraints *utils.NameConstraints, policies *utils.PolicySettings, selfOSCP []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey, signatureAlgorithm x509.SignatureAlgorithm, icaSignatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, crypto.PrivateKey, *ICAInfo, error) {
 	info, err := NewICAInfo(infoFilePath, caInfo, selfOSCP, selfURL, crlURL)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	info.SignatureAlgorithm = icaSignatureAlgorithm
+
+	if !nameConstraints.IsEmpty() {
+		info.NameConstraints = nameConstraints
+	}
+
+	if signatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		signatureAlgorithm = caInfo.GetSignatureAlgorithm()
+	}
+
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, ca.PublicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	err = subject.SetCNIfEmpty() // 兜底，确保CN被设置
 	if err != nil {
@@ -134,41 +320,16 @@ func CreateICA(infoFilePath string, caInfo UpstreamCAInfo, cryptoType utils.Cryp
 		extKeyUsage = utils.CopySlice(extKeyUsage)
 	}
 
-	switch cryptoType {
-	case utils.CryptoTypeRsa:
-		if keyLength != 2048 && keyLength != 4096 {
-			return nil, nil, nil, fmt.Errorf("unsupported RSA key length: %d", keyLength)
-		}
-
-		priv, err := rsa.GenerateKey(utils.Rander(), keyLength)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-
-		privKey = priv
-		pubKey = &priv.PublicKey
-	case utils.CryptoTypeEcc:
-		fallthrough
-	case utils.CryptoTypeEcdsa:
-		var curve elliptic.Curve
-		switch keyLength {
-		case 256:
-			curve = elliptic.P256()
-		case 384:
-			curve = elliptic.P384()
-		case 521:
-			curve = elliptic.P521()
-		default:
-			return nil, nil, nil, fmt.Errorf("unsupported ECC key length: %d", keyLength)
-		}
-		priv, err := ecdsa.GenerateKey(curve, utils.Rander())
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		privKey = priv
-		pubKey = &priv.PublicKey
-	default:
-		return nil, nil, nil, fmt.Errorf("unsupported crypto type: %s", cryptoType)
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if key != nil {
+		info.RandSource = utils.RandSourceExternal
+	}
+
+	err = utils.CheckSignatureAlgorithm(icaSignatureAlgorithm, pubKey)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	if notBefore.Equal(time.Time{}) {
@@ -184,16 +345,17 @@ func CreateICA(infoFilePath string, caInfo UpstreamCAInfo, cryptoType utils.Cryp
 		return nil, nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
 	}
 
-	serialNumber, err := info.NewCertSerialNumber()
+	serialNumber, err := caInfo.NewCertSerialNumber() // 序列号由上级CA分配
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
 	}
 
 	template := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject:      subject.ToPkixName(),
-		NotBefore:    notBefore,
-		NotAfter:     notAfter,
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: signatureAlgorithm,
+		Subject:            subject.ToPkixName(),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
 
 		KeyUsage:    keyUsage,
 		ExtKeyUsage: extKeyUsage, // 允许全部扩展用途
@@ -212,6 +374,16 @@ func CreateICA(infoFilePath string, caInfo UpstreamCAInfo, cryptoType utils.Cryp
 		CRLDistributionPoints: info.CRLDistributionPoints,
 	}
 
+	err = info.NameConstraints.Apply(template)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = policies.Apply(template)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
 	if err != nil {
 		return nil, nil, nil, err
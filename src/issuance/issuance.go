@@ -0,0 +1,355 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package issuance 保存CA签发的证书的索引，每个CA（RCA或ICA）在其目录下拥有独立的索引
+package issuance
+
+import (
+	"crypto/x509"
+	"encoding/gob"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"path"
+	"strings"
+	"time"
+)
+
+// IndexFileName 签发索引保存在CA目录下的文件名
+const IndexFileName = "issued-db.json"
+
+// serialNumberRetry 分配序列号时与索引中已有的序列号重复的最大重试次数
+const serialNumberRetry = 16
+
+// Status 证书的状态
+type Status string
+
+const (
+	StatusValid      Status = "valid"
+	StatusRevoked    Status = "revoked"
+	StatusExpired    Status = "expired"
+	StatusSuperseded Status = "superseded" // 证书已被续期后的新证书取代（在过期前仍然有效）
+)
+
+var StatusList = []Status{StatusValid, StatusRevoked, StatusExpired, StatusSuperseded}
+
+// ParseStatus 解析证书的状态（不区分大小写）
+func ParseStatus(s string) (Status, error) {
+	for _, status := range StatusList {
+		if strings.EqualFold(strings.TrimSpace(s), string(status)) {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown status: %s", s)
+}
+
+// IssuedCert 一条签发记录
+type IssuedCert struct {
+	SerialNumber   *big.Int
+	Type           string // ICA、CERT、OCSP-SIGNER、SCEP-RA或CMP-SIGNER，仅有吊销记录的证书为空
+	Subject        string
+	DNSNames       []string
+	IPAddresses    []string
+	EmailAddresses []string
+	URIs           []string
+	NotBefore      time.Time
+	NotAfter       time.Time
+	Fingerprint    string    // 证书的SHA-256指纹（小写十六进制）
+	Path           string    // 证书所在的目录（相对于home目录），证书不由MyCA保存时为空
+	IssuedAt       time.Time // 记录的时间（根据已有证书重建索引时为证书的notBefore）
+	RevokedAt      time.Time // 未吊销时为零值
+	SupersededBy   *big.Int  // 续期后取代该证书的新证书的序列号，未被取代时为nil
+}
+
+// Status 返回证书在now时的状态，吊销优先于取代和过期
+func (c *IssuedCert) Status(now time.Time) Status {
+	if !c.RevokedAt.IsZero() {
+		return StatusRevoked
+	} else if !c.NotAfter.IsZero() && now.After(c.NotAfter) {
+		return StatusExpired
+	} else if c.SupersededBy != nil {
+		return StatusSuperseded
+	}
+	return StatusValid
+}
+
+// SANs 返回证书的全部主题备用名称
+func (c *IssuedCert) SANs() []string {
+	res := make([]string, 0, len(c.DNSNames)+len(c.IPAddresses)+len(c.EmailAddresses)+len(c.URIs))
+	res = append(res, c.DNSNames...)
+	res = append(res, c.IPAddresses...)
+	res = append(res, c.EmailAddresses...)
+	res = append(res, c.URIs...)
+	return res
+}
+
+// Match 判断证书的主题、SAN、序列号（十六进制）、指纹或目录是否包含query（不区分大小写）
+func (c *IssuedCert) Match(query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return true
+	}
+
+	fields := append([]string{c.Subject, c.SerialNumber.Text(16), c.Fingerprint, c.Path}, c.SANs()...)
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Index struct {
+	Issued []*IssuedCert
+
+	FilePath string `gob:"-"`
+}
+
+func init() {
+	// 旧版本的签发索引（issued-db.gob）迁移时需要解码
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/issuance.Index", &Index{})
+}
+
+// indexFile 签发索引（issued-db.json）的格式
+type indexFile struct {
+	metadata.Header
+	Issued []*issuedCertFile `json:"issued"`
+}
+
+type issuedCertFile struct {
+	SerialNumber   string     `json:"serial_number"`
+	Type           string     `json:"type,omitempty"`
+	Subject        string     `json:"subject,omitempty"`
+	DNSNames       []string   `json:"dns_names,omitempty"`
+	IPAddresses    []string   `json:"ip_addresses,omitempty"`
+	EmailAddresses []string   `json:"email_addresses,omitempty"`
+	URIs           []string   `json:"uris,omitempty"`
+	NotBefore      *time.Time `json:"not_before,omitempty"`
+	NotAfter       *time.Time `json:"not_after,omitempty"`
+	Fingerprint    string     `json:"sha256_fingerprint,omitempty"`
+	Path           string     `json:"path,omitempty"`
+	IssuedAt       time.Time  `json:"issued_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	SupersededBy   string     `json:"superseded_by,omitempty"`
+}
+
+func NewIndex(filepath string) (*Index, error) {
+	idx := &Index{
+		Issued:   make([]*IssuedCert, 0, 10),
+		FilePath: filepath,
+	}
+
+	return idx, nil
+}
+
+// GetIndex 读取签发索引，文件不存在时返回空索引，旧版本的gob索引在读取时迁移
+func GetIndex(filepath string) (*Index, error) {
+	if metadata.NeedMigrate(filepath) {
+		return migrateIndex(filepath)
+	} else if !utils.IsExists(filepath) {
+		return NewIndex(filepath)
+	}
+
+	var f indexFile
+	err := metadata.Read(filepath, metadata.KindIssuanceIndex, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Index{
+		Issued:   make([]*IssuedCert, 0, len(f.Issued)),
+		FilePath: filepath,
+	}
+
+	for _, c := range f.Issued {
+		serialNumber, err := metadata.ParseBigInt(c.SerialNumber)
+		if err != nil {
+			return nil, err
+		} else if serialNumber == nil {
+			return nil, fmt.Errorf("%s: serial number is missing", filepath)
+		}
+
+		supersededBy, err := metadata.ParseBigInt(c.SupersededBy)
+		if err != nil {
+			return nil, err
+		}
+
+		res.Issued = append(res.Issued, &IssuedCert{
+			SerialNumber:   serialNumber,
+			Type:           c.Type,
+			Subject:        c.Subject,
+			DNSNames:       c.DNSNames,
+			IPAddresses:    c.IPAddresses,
+			EmailAddresses: c.EmailAddresses,
+			URIs:           c.URIs,
+			NotBefore:      metadata.ParseTime(c.NotBefore),
+			NotAfter:       metadata.ParseTime(c.NotAfter),
+			Fingerprint:    c.Fingerprint,
+			Path:           c.Path,
+			IssuedAt:       c.IssuedAt,
+			RevokedAt:      metadata.ParseTime(c.RevokedAt),
+			SupersededBy:   supersededBy,
+		})
+	}
+
+	return res, nil
+}
+
+// migrateIndex 读取旧版本的issued-db.gob，保存为issued-db.json后删除旧文件
+func migrateIndex(filepath string) (*Index, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var res Index
+	err := metadata.ReadLegacy(legacyPath, &res)
+	if err != nil {
+		return nil, err
+	}
+	res.FilePath = filepath
+
+	err = res.SaveIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	err = metadata.RemoveLegacy(legacyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// SaveIndex 保存签发索引
+func (idx *Index) SaveIndex() error {
+	f := &indexFile{
+		Header: metadata.NewHeader(metadata.KindIssuanceIndex),
+		Issued: make([]*issuedCertFile, 0, len(idx.Issued)),
+	}
+
+	for _, c := range idx.Issued {
+		f.Issued = append(f.Issued, &issuedCertFile{
+			SerialNumber:   metadata.FormatBigInt(c.SerialNumber),
+			Type:           c.Type,
+			Subject:        c.Subject,
+			DNSNames:       c.DNSNames,
+			IPAddresses:    c.IPAddresses,
+			EmailAddresses: c.EmailAddresses,
+			URIs:           c.URIs,
+			NotBefore:      metadata.FormatTime(c.NotBefore),
+			NotAfter:       metadata.FormatTime(c.NotAfter),
+			Fingerprint:    c.Fingerprint,
+			Path:           c.Path,
+			IssuedAt:       c.IssuedAt.UTC(),
+			RevokedAt:      metadata.FormatTime(c.RevokedAt),
+			SupersededBy:   metadata.FormatBigInt(c.SupersededBy),
+		})
+	}
+
+	return metadata.Write(idx.FilePath, f)
+}
+
+// Find 根据序列号查找签发记录，不存在时返回nil
+func (idx *Index) Find(serialNumber *big.Int) *IssuedCert {
+	for _, c := range idx.Issued {
+		if c.SerialNumber.Cmp(serialNumber) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+func (idx *Index) IsIssued(serialNumber *big.Int) bool {
+	return idx.Find(serialNumber) != nil
+}
+
+// Add 记录新签发的证书，同一序列号不能重复记录
+func (idx *Index) Add(cert *x509.Certificate, certType string, dirPath string, issuedAt time.Time) (*IssuedCert, error) {
+	if c := idx.Find(cert.SerialNumber); c != nil {
+		return nil, fmt.Errorf("the serial number %s has been issued to %s", cert.SerialNumber.Text(16), c.Subject)
+	}
+
+	res := &IssuedCert{
+		SerialNumber:   new(big.Int).Set(cert.SerialNumber),
+		Type:           certType,
+		Subject:        cert.Subject.String(),
+		DNSNames:       append([]string{}, cert.DNSNames...),
+		IPAddresses:    make([]string, 0, len(cert.IPAddresses)),
+		EmailAddresses: append([]string{}, cert.EmailAddresses...),
+		URIs:           make([]string, 0, len(cert.URIs)),
+		NotBefore:      cert.NotBefore,
+		NotAfter:       cert.NotAfter,
+		Fingerprint:    revoke.Fingerprint(cert),
+		Path:           dirPath,
+		IssuedAt:       issuedAt.UTC(),
+	}
+
+	for _, ip := range cert.IPAddresses {
+		res.IPAddresses = append(res.IPAddresses, ip.String())
+	}
+
+	for _, u := range cert.URIs {
+		res.URIs = append(res.URIs, u.String())
+	}
+
+	idx.Issued = append(idx.Issued, res)
+
+	return res, nil
+}
+
+// SetRevoked 根据吊销记录记录证书被吊销，证书不在索引中时（例如证书文件已被删除）只保留吊销记录中的信息
+func (idx *Index) SetRevoked(r *revoke.RevokedCert) *IssuedCert {
+	c := idx.Find(r.SerialNumber)
+	if c == nil {
+		c = &IssuedCert{
+			SerialNumber: new(big.Int).Set(r.SerialNumber),
+			Subject:      r.Subject,
+			NotAfter:     r.NotAfter,
+			Fingerprint:  r.Fingerprint,
+			IssuedAt:     r.RevokedAt.UTC(),
+		}
+		idx.Issued = append(idx.Issued, c)
+	}
+
+	c.RevokedAt = r.RevokedAt.UTC()
+	return c
+}
+
+// Supersede 记录证书被续期后的新证书取代，旧证书被归档到newPath
+func (idx *Index) Supersede(serialNumber *big.Int, by *big.Int, newPath string) *IssuedCert {
+	c := idx.Find(serialNumber)
+	if c == nil {
+		return nil
+	}
+
+	c.SupersededBy = new(big.Int).Set(by)
+	c.Path = newPath
+	return c
+}
+
+// NewSerialNumber 为CA分配新的证书序列号，保证不与CA目录中签发索引的记录重复
+// mode为分配模式（utils.SerialModeSequential或utils.SerialModeRandom），current为上一次分配的序列号
+func NewSerialNumber(caDirPath string, mode string, current *big.Int) (*big.Int, error) {
+	idx, err := GetIndex(path.Join(caDirPath, IndexFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < serialNumberRetry; i++ {
+		serialNumber, err := utils.NextSerialNumber(mode, current)
+		if err != nil {
+			return nil, err
+		}
+
+		if !idx.IsIssued(serialNumber) {
+			return serialNumber, nil
+		}
+
+		current = serialNumber // 顺序模式下跳过已使用的序列号
+	}
+
+	return nil, fmt.Errorf("can not allocate an unused serial number after %d retries", serialNumberRetry)
+}
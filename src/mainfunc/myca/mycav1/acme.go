@@ -0,0 +1,135 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/acmeserver"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"time"
+)
+
+// acmeIssuer 使用ICA签发ACME订单的证书，证书保存在 home/cert/ACME-<CN>-<订单ID> 中
+type acmeIssuer struct {
+	*onlineIssuer
+}
+
+func (i *acmeIssuer) checkIdentifiers(domains []string, ips []net.IP) error {
+	return i.checkNameConstraints(domains, ips, nil, nil)
+}
+
+func (i *acmeIssuer) issue(orderID string, csr *x509.CertificateRequest, domains []string, ips []net.IP) (*x509.Certificate, []byte, error) {
+	// CSR的CN已由ACME服务检查是订单的标识之一，CSR中没有邮箱和URI
+	subject := global.NewCertSubject()
+	subject.CN = csr.Subject.CommonName
+	err := subject.SetCNIfEmpty(domains, ips, csr.EmailAddresses, csr.URIs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userCert, dirPath, err := i.onlineIssuer.issue(&onlineRequest{
+		DirName: fmt.Sprintf("ACME-%s-%s", subject.CN, orderID[:8]),
+		CSR:     csr,
+		Subject: subject,
+		Domains: domains,
+		IPs:     ips,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chainPEM, err := os.ReadFile(path.Join(dirPath, "fullchain.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return userCert, chainPEM, nil
+}
+
+// acmeStoreDirPath ACME服务的账户、订单和授权保存在 home/acme/<ICA名称> 中
+func acmeStoreDirPath(icaName string) string {
+	return path.Join(home, "acme", icaName)
+}
+
+func CommandServeACME(opt *flagparser.ServeACMEOption) error {
+	if opt.ICA == "" {
+		return fmt.Errorf("the ica must be set")
+	} else if !utils.IsValidFilename(opt.ICA) {
+		return fmt.Errorf("not a valid name: %s", opt.ICA)
+	} else if (opt.TLSCert == "") != (opt.TLSKey == "") {
+		return fmt.Errorf("the tls-cert and tls-key must be set together")
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	extKeyUsage, err := parseAllowExtKeyUsageOption(opt.AllowExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := loadICA(opt.ICA, func() string {
+		return opt.Password
+	})
+	if err != nil {
+		return err
+	}
+
+	online, err := newOnlineIssuer(caCert, caKey, caFullchain, caInfo, validity, opt.Policy.Value())
+	if err != nil {
+		return err
+	}
+	online.setExtKeyUsage(extKeyUsage)
+
+	issuer := &acmeIssuer{onlineIssuer: online}
+
+	store, err := acmeserver.NewStore(acmeStoreDirPath(opt.ICA))
+	if err != nil {
+		return err
+	}
+
+	baseURL := opt.BaseURL
+	if baseURL == "" {
+		scheme := "http"
+		if opt.TLSCert != "" {
+			scheme = "https"
+		}
+		baseURL = fmt.Sprintf("%s://%s", scheme, opt.Listen)
+	}
+
+	server, err := acmeserver.NewServer(&acmeserver.Config{
+		BaseURL:          baseURL,
+		Store:            store,
+		Validator:        acmeserver.NewValidator(opt.HTTPPort, opt.TLSPort, opt.DNSResolver, 0),
+		CheckIdentifiers: issuer.checkIdentifiers,
+		Issue:            issuer.issue,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serve ICA/%s, store directory: %s\n", opt.ICA, acmeStoreDirPath(opt.ICA))
+	fmt.Printf("ACME server listening on %s, directory: %s\n", opt.Listen, server.DirectoryURL())
+
+	if opt.TLSCert != "" {
+		return httpServer.ListenAndServeTLS(opt.TLSCert, opt.TLSKey)
+	}
+	return httpServer.ListenAndServe()
+}
@@ -1,6 +1,7 @@
 package mycav1
 
 import (
+	"crypto/x509"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/cert"
 	"github.com/SongZihuan/MyCA/src/ica"
@@ -22,37 +23,19 @@ func ShowAllICA() {
 	showAllICA()
 }
 
+func ShowAllPending() {
+	showAllPending()
+}
+
 func CreateRCA() {
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
-	}
-
-	fmt.Println("Crypto: ", cryptoType, keyLength)
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	signatureAlgorithm := ReadSignatureAlgorithm(cryptoType)
+	serialMode := ReadSerialMode()
 
 	subject, err := ReadSubject()
 	if err != nil {
@@ -89,6 +72,18 @@ func CreateRCA() {
 		fmt.Printf("OK, CA can create %d layers of ica.\n", maxPathLen)
 	}
 
+	nameConstraints, err := ReadNameConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	policies, policyLibrary, err := ReadCAPolicies(nil, false)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
 	ocspURLs := make([]string, 0, 10)
 	for {
 		fmt.Printf("Enter your OCSP Server URL [empty to stop]: ")
@@ -164,14 +159,7 @@ func CreateRCA() {
 		return
 	}
 
-	infoPath := path.Join(dirPath, "rca-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "rca-info.json")
 
 	if utils.IsExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
@@ -191,37 +179,23 @@ func CreateRCA() {
 		return
 	}
 
-	caCert, key, rcaInfo, err := rootca.CreateRCA(infoPath, cryptoType, keyLength, subject, keyUsage, extKeyUsage, maxPathLen, ocspURLs, issurURLs, crlURLs, notBefore, notAfter)
+	caCert, key, rcaInfo, err := rootca.CreateRCA(infoPath, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, maxPathLen, nameConstraints, policies, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, signatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = rcaInfo.SaveRCAInfo()
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SaveCertificate(caCert, []byte{}, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(rcaInfo, keyRandSource)
+	rcaInfo.SerialMode = serialMode
+	rcaInfo.Policies = policyLibrary
 
-	err = utils.SavePrivateKey(key, password, keyPath)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SaveSPX(key, password, caCert, []byte{}, spxPath)
+	err = rcaInfo.SaveRCAInfo()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, caCert, []byte{}, pfxPath)
+	err = saveCertificateAndKey(dirPath, caCert, key, password, []byte{})
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
@@ -237,36 +211,14 @@ func CreateICAFromRCA() {
 		return
 	}
 
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
 	}
 
-	fmt.Println("Crypto: ", cryptoType, keyLength)
+	signatureAlgorithm := ReadSignatureAlgorithm(cryptoType)
+	serialMode := ReadSerialMode()
 
 	subject, err := ReadSubject()
 	if err != nil {
@@ -316,6 +268,18 @@ func CreateICAFromRCA() {
 		fmt.Printf("OK, CA can create %d layers of ica.\n", maxPathLen)
 	}
 
+	nameConstraints, err := ReadNameConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	policies, policyLibrary, err := ReadCAPolicies(rcaInfo.Policies, true)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
 	selfOcspURLs := make([]string, 0, 10)
 	for {
 		fmt.Printf("Enter your OCSP Server URL [empty to stop]: ")
@@ -391,14 +355,7 @@ func CreateICAFromRCA() {
 		return
 	}
 
-	infoPath := path.Join(dirPath, "ica-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "ica-info.json")
 
 	if utils.IsExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
@@ -418,37 +375,29 @@ func CreateICAFromRCA() {
 		return
 	}
 
-	caCert, key, icaInfo, err := ica.CreateICA(infoPath, rcaInfo, cryptoType, keyLength, subject, keyUsage, extKeyUsage, maxPathLen, selfOcspURLs, selfIssurURLs, crlURLs, notBefore, notAfter, rcaCert, rcaKey)
+	caCert, key, icaInfo, err := ica.CreateICA(infoPath, rcaInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, maxPathLen, nameConstraints, policies, selfOcspURLs, selfIssurURLs, crlURLs, notBefore, notAfter, rcaCert, rcaKey, x509.UnknownSignatureAlgorithm, signatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = icaInfo.SaveICAInfo()
+	err = recordIssuance(rcaInfo, caCert, "ICA", dirPath)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SaveCertificate(caCert, rcaFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SavePrivateKey(key, password, keyPath)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(icaInfo, keyRandSource)
+	icaInfo.SerialMode = serialMode
+	icaInfo.Policies = policyLibrary
 
-	err = utils.SaveSPX(key, password, caCert, rcaFullchain, spxPath)
+	err = icaInfo.SaveICAInfo()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, caCert, rcaFullchain, pfxPath)
+	err = saveCertificateAndKey(dirPath, caCert, key, password, rcaFullchain)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
@@ -464,36 +413,14 @@ func CreateICAFromICA() {
 		return
 	}
 
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
 	}
 
-	fmt.Println("Crypto: ", cryptoType, keyLength)
+	signatureAlgorithm := ReadSignatureAlgorithm(cryptoType)
+	serialMode := ReadSerialMode()
 
 	subject, err := ReadSubject()
 	if err != nil {
@@ -543,6 +470,18 @@ func CreateICAFromICA() {
 		fmt.Printf("OK, CA can create %d layers of ica.\n", maxPathLen)
 	}
 
+	nameConstraints, err := ReadNameConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	policies, policyLibrary, err := ReadCAPolicies(icaInfo.Policies, true)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
 	ocspURLs := make([]string, 0, 10)
 	for {
 		fmt.Printf("Enter your OCSP Server URL [empty to stop]: ")
@@ -618,16 +557,9 @@ func CreateICAFromICA() {
 		return
 	}
 
-	infoPath := path.Join(dirPath, "ica-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "ica-info.json")
 
-	if utils.IsExists(cert1Path) || utils.IsExists(cert2Path) || utils.IsExists(fullchain1Path) || utils.IsExists(fullchain2Path) || utils.IsExists(keyPath) {
+	if isCertificateExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
 		if !ReadBoolDefaultNoPrint() {
 			return
@@ -645,37 +577,29 @@ func CreateICAFromICA() {
 		return
 	}
 
-	caCert, key, newIcaInfo, err := ica.CreateICA(infoPath, icaInfo, cryptoType, keyLength, subject, keyUsage, extKeyUsage, maxPathLen, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, icaCert, icaKey)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = newIcaInfo.SaveICAInfo()
+	caCert, key, newIcaInfo, err := ica.CreateICA(infoPath, icaInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, maxPathLen, nameConstraints, policies, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, icaCert, icaKey, x509.UnknownSignatureAlgorithm, signatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SaveCertificate(caCert, icaFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
+	err = recordIssuance(icaInfo, caCert, "ICA", dirPath)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePrivateKey(key, password, keyPath)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(newIcaInfo, keyRandSource)
+	newIcaInfo.SerialMode = serialMode
+	newIcaInfo.Policies = policyLibrary
 
-	err = utils.SaveSPX(key, password, caCert, icaFullchain, spxPath)
+	err = newIcaInfo.SaveICAInfo()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, caCert, icaFullchain, pfxPath)
+	err = saveCertificateAndKey(dirPath, caCert, key, password, icaFullchain)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
@@ -691,37 +615,12 @@ func CreateUserCertFromRCA() {
 		return
 	}
 
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
 	}
 
-	fmt.Println("Crypto: ", cryptoType, keyLength)
-
 	subject, err := ReadSubject()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
@@ -865,22 +764,25 @@ func CreateUserCertFromRCA() {
 		return
 	}
 
+	if !confirmNameConstraints(rcaCert, rcaFullchain, domains, ips, emails, urls) {
+		return
+	}
+
+	policies, err := ReadCertificatePolicies(rcaInfo.Policies)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
 	_, dirPath, err := ReadDir(homeCert, "CERT-", subject)
 	if err != nil {
 		fmt.Printf("Error: %s", err.Error())
 		return
 	}
 
-	infoPath := path.Join(dirPath, "cert-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "cert-info.json")
 
-	if utils.IsExists(cert1Path) || utils.IsExists(cert2Path) || utils.IsExists(fullchain1Path) || utils.IsExists(fullchain2Path) || utils.IsExists(keyPath) {
+	if isCertificateExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
 		if !ReadBoolDefaultNoPrint() {
 			return
@@ -898,37 +800,27 @@ func CreateUserCertFromRCA() {
 		return
 	}
 
-	userCert, key, certInfo, err := cert.CreateCert(infoPath, rcaInfo, cryptoType, keyLength, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, notBefore, notAfter, rcaCert, rcaKey)
+	userCert, key, certInfo, err := cert.CreateCert(infoPath, rcaInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, rcaCert, rcaKey, x509.UnknownSignatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = certInfo.SaveCertInfo()
+	err = recordIssuance(rcaInfo, userCert, "CERT", dirPath)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SaveCertificate(userCert, rcaFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(certInfo, keyRandSource)
 
-	err = utils.SavePrivateKey(key, password, keyPath)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SaveSPX(key, password, userCert, rcaFullchain, spxPath)
+	err = certInfo.SaveCertInfo()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, userCert, rcaFullchain, pfxPath)
+	err = saveCertificateAndKey(dirPath, userCert, key, password, rcaFullchain)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
@@ -944,37 +836,12 @@ func CreateUserCertFromICA() {
 		return
 	}
 
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
 	}
 
-	fmt.Println("Crypto: ", cryptoType, keyLength)
-
 	subject, err := ReadSubject()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
@@ -1118,22 +985,25 @@ func CreateUserCertFromICA() {
 		return
 	}
 
+	if !confirmNameConstraints(icaCert, icaFullchain, domains, ips, emails, urls) {
+		return
+	}
+
+	policies, err := ReadCertificatePolicies(icaInfo.Policies)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
 	_, dirPath, err := ReadDir(homeCert, "CERT-", subject)
 	if err != nil {
 		fmt.Printf("Error: %s", err.Error())
 		return
 	}
 
-	infoPath := path.Join(dirPath, "cert-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "cert-info.json")
 
-	if utils.IsExists(cert1Path) || utils.IsExists(cert2Path) || utils.IsExists(fullchain1Path) || utils.IsExists(fullchain2Path) || utils.IsExists(keyPath) {
+	if isCertificateExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
 		if !ReadBoolDefaultNoPrint() {
 			return
@@ -1151,76 +1021,43 @@ func CreateUserCertFromICA() {
 		return
 	}
 
-	userCert, key, certInfo, err := cert.CreateCert(infoPath, icaInfo, cryptoType, keyLength, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, notBefore, notAfter, icaCert, icaKey)
+	userCert, key, certInfo, err := cert.CreateCert(infoPath, icaInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, icaCert, icaKey, x509.UnknownSignatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = certInfo.SaveCertInfo()
+	err = recordIssuance(icaInfo, userCert, "CERT", dirPath)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SaveCertificate(userCert, icaFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(certInfo, keyRandSource)
 
-	err = utils.SavePrivateKey(key, password, keyPath)
+	err = certInfo.SaveCertInfo()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SaveSPX(key, password, userCert, icaFullchain, spxPath)
+	err = saveCertificateAndKey(dirPath, userCert, key, password, icaFullchain)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, userCert, icaFullchain, pfxPath)
+	fmt.Println("Success, save directory: ", dirPath)
+}
+
+func CreateUserCertSelf() {
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	fmt.Println("Success, save directory: ", dirPath)
-}
-
-func CreateUserCertSelf() {
-	fmt.Println(cryptoMenu)
-	fmt.Printf(">>> ")
-
-	var cryptoType utils.CryptoType
-	var keyLength int
-
-	switch ReadNumber() {
-	case 1:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 2048
-	case 2:
-		cryptoType = utils.CryptoTypeRsa
-		keyLength = 4096
-	case 0:
-		fallthrough
-	default:
-		fmt.Println("Warn: Use Default")
-		fallthrough
-	case 3:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 256
-	case 4:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 384
-	case 5:
-		cryptoType = utils.CryptoTypeEcdsa
-		keyLength = 521
-	}
-
-	fmt.Println("Crypto: ", cryptoType, keyLength)
+	signatureAlgorithm := ReadSignatureAlgorithm(cryptoType)
 
 	subject, err := ReadSubject()
 	if err != nil {
@@ -1431,16 +1268,9 @@ func CreateUserCertSelf() {
 		return
 	}
 
-	infoPath := path.Join(dirPath, "cert-info.gob")
-	cert1Path := path.Join(dirPath, "cert.pem")
-	cert2Path := path.Join(dirPath, "cert.cer")
-	fullchain1Path := path.Join(dirPath, "fullchain.pem")
-	fullchain2Path := path.Join(dirPath, "fullchain.cer")
-	keyPath := path.Join(dirPath, "key.pem")
-	spxPath := path.Join(dirPath, "cert.spx")
-	pfxPath := path.Join(dirPath, "cert.pfx")
+	infoPath := path.Join(dirPath, "cert-info.json")
 
-	if utils.IsExists(cert1Path) || utils.IsExists(cert2Path) || utils.IsExists(fullchain1Path) || utils.IsExists(fullchain2Path) || utils.IsExists(keyPath) {
+	if isCertificateExists(dirPath) {
 		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
 		if !ReadBoolDefaultNoPrint() {
 			return
@@ -1458,37 +1288,21 @@ func CreateUserCertSelf() {
 		return
 	}
 
-	userCert, key, certInfo, err := cert.CreateSelfCert(infoPath, cryptoType, keyLength, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, ocspURLs, issurURLs, crlURLs, notBefore, notAfter)
+	userCert, key, certInfo, err := cert.CreateSelfCert(infoPath, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, signatureAlgorithm)
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = certInfo.SaveSelfCert()
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
+	setKeyRandSource(certInfo, keyRandSource)
 
-	err = utils.SaveCertificate(userCert, []byte{}, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SavePrivateKey(key, password, keyPath)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-		return
-	}
-
-	err = utils.SaveSPX(key, password, userCert, []byte{}, spxPath)
+	err = certInfo.SaveSelfCert()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
 	}
 
-	err = utils.SavePFX(key, password, userCert, []byte{}, pfxPath)
+	err = saveCertificateAndKey(dirPath, userCert, key, password, []byte{})
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		return
@@ -0,0 +1,680 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/apiserver"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// apiBackend 实现REST API的操作，签发、吊销和生成CRL会修改CA的索引和信息文件，因此逐个处理
+type apiBackend struct {
+	lock        sync.Mutex
+	extKeyUsage []x509.ExtKeyUsage // 客户端可以请求的扩展密钥用途
+}
+
+// apiCertificate 证书的详细信息和PEM格式的证书链
+type apiCertificate struct {
+	*entryDetail
+	Certificate string `json:"certificate,omitempty"`
+	Chain       string `json:"chain,omitempty"`
+}
+
+// apiIssuedCert 签发记录，序列号使用十六进制
+type apiIssuedCert struct {
+	SerialNumber   string     `json:"serial_number"`
+	Status         string     `json:"status"`
+	Type           string     `json:"type,omitempty"`
+	Subject        string     `json:"subject,omitempty"`
+	DNSNames       []string   `json:"dns_names,omitempty"`
+	IPAddresses    []string   `json:"ip_addresses,omitempty"`
+	EmailAddresses []string   `json:"email_addresses,omitempty"`
+	URIs           []string   `json:"uris,omitempty"`
+	NotBefore      *time.Time `json:"not_before,omitempty"`
+	NotAfter       time.Time  `json:"not_after"`
+	Fingerprint    string     `json:"sha256_fingerprint,omitempty"`
+	Path           string     `json:"path,omitempty"`
+	IssuedAt       time.Time  `json:"issued_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	SupersededBy   string     `json:"superseded_by,omitempty"`
+}
+
+// apiIssuedList 一个CA的签发记录
+type apiIssuedList struct {
+	IssuerType   string           `json:"issuer_type"`
+	Issuer       string           `json:"issuer"`
+	Total        int              `json:"total"`
+	Certificates []*apiIssuedCert `json:"certificates"`
+}
+
+// checkLocalName 检查API路径中的目录名，证书不存在时返回404
+func checkLocalName(certType string, name string) error {
+	if !utils.IsValidFilename(name) || !utils.IsExists(path.Join(certTypeHome(certType), name, "cert.pem")) {
+		return apiserver.NotFound("%s %s not found", certType, name)
+	}
+	return nil
+}
+
+func (b *apiBackend) ListCAs(caType string) (any, error) {
+	types := []string{"RCA", "ICA"}
+	if caType != "" {
+		types = []string{caType}
+	}
+
+	res := make([]*entryDetail, 0, 10)
+	for _, t := range types {
+		_, entries, err := loadEntryDetails(t)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, entries...)
+	}
+
+	return res, nil
+}
+
+func (b *apiBackend) GetCA(caType string, name string) (any, error) {
+	return loadAPICertificate(caType, name)
+}
+
+func (b *apiBackend) GetChain(caType string, name string) ([]byte, error) {
+	err := checkLocalName(caType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path.Join(certTypeHome(caType), name, "fullchain.pem"))
+}
+
+func (b *apiBackend) GetCRL(caType string, name string, delta bool) ([]byte, error) {
+	err := checkLocalName(caType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	baseName := "crl"
+	if delta {
+		baseName = "delta-crl"
+	}
+
+	crlPath := path.Join(certTypeHome(caType), name, baseName+".crl")
+	if !utils.IsExists(crlPath) {
+		return nil, apiserver.NotFound("no %s has been published by %s %s", strings.ToUpper(baseName), caType, name)
+	}
+
+	return os.ReadFile(crlPath)
+}
+
+func (b *apiBackend) GenerateCRL(caType string, name string, req *apiserver.CRLRequest, client *apiserver.Client) (any, error) {
+	err := checkLocalName(caType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	nextUpdate := time.Hour * 24 * 7
+	if req.NextUpdate != "" {
+		nextUpdate = utils.ReadTimeDuration(req.NextUpdate)
+		if nextUpdate <= 0 {
+			return nil, fmt.Errorf("not a valid next update interval: %s", req.NextUpdate)
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	caCert, caKey, _, caInfo, err := parseIssuerOption(&flagparser.IssuerOption{
+		Issuer:         name,
+		IssuerType:     caType,
+		IssuerPassword: req.IssuerPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	crlPath, number, err := generateCRL(caCert, caKey, caInfo, nextUpdate, req.Delta)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("API: %s generated CRL (number: %s) of %s %s\n", client.String(), number.String(), caType, name)
+	return map[string]any{
+		"number": number.String(),
+		"delta":  req.Delta,
+		"path":   homeRelativePath(crlPath),
+	}, nil
+}
+
+// Issue 签发证书，与EST等在线服务相同，证书保存在 home/cert 中并记录到签发索引
+func (b *apiBackend) Issue(caType string, name string, req *apiserver.IssueRequest, client *apiserver.Client) (*apiserver.IssueResult, error) {
+	err := checkLocalName(caType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	validity := time.Hour * 24 * 365
+	if req.Validity != "" {
+		validity = utils.ReadTimeDuration(req.Validity)
+		if validity <= 0 {
+			return nil, fmt.Errorf("not a valid validity: %s", req.Validity)
+		}
+	}
+
+	if req.Name != "" && !utils.IsValidFilename(req.Name) {
+		return nil, fmt.Errorf("not a valid name: %s", req.Name)
+	}
+
+	onlineReq, err := parseAPIIssueRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// 指定的目录名已存在时不添加序号，避免客户端得到与请求不同的目录
+	if req.Name != "" && isCertificateExists(path.Join(homeCert, req.Name)) {
+		return nil, &apiserver.Error{Status: http.StatusConflict, Detail: fmt.Sprintf("certificate %s already exists", req.Name)}
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&flagparser.IssuerOption{
+		Issuer:         name,
+		IssuerType:     caType,
+		IssuerPassword: req.IssuerPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := newOnlineIssuer(caCert, caKey, caFullchain, caInfo, validity, req.Policies)
+	if err != nil {
+		return nil, err
+	}
+	issuer.setExtKeyUsage(b.extKeyUsage)
+
+	userCert, dirPath, err := issuer.issue(onlineReq)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("API: %s issued %s by %s %s\n", client.String(), userCert.Subject.CommonName, caType, name)
+
+	res := &apiserver.IssueResult{
+		Name:         path.Base(dirPath),
+		Path:         homeRelativePath(dirPath),
+		SerialNumber: userCert.SerialNumber.Text(16),
+		Certificate:  string(pem.EncodeToMemory(&pem.Block{Type: utils.PemTypeCertificate, Bytes: userCert.Raw})),
+	}
+
+	chain, err := os.ReadFile(path.Join(dirPath, "fullchain.pem"))
+	if err != nil {
+		return nil, err
+	}
+	res.Chain = string(chain)
+
+	if onlineReq.Key != nil {
+		key, err := os.ReadFile(path.Join(dirPath, "key.pem")) // 已使用 key_password 加密
+		if err != nil {
+			return nil, err
+		}
+		res.PrivateKey = string(key)
+	}
+
+	return res, nil
+}
+
+// parseAPIIssueRequest 解析签发请求，请求包含CSR时默认使用CSR中的主题、SAN和扩展密钥用途，否则由服务端生成私钥
+func parseAPIIssueRequest(req *apiserver.IssueRequest) (*onlineRequest, error) {
+	res := &onlineRequest{
+		KeyPassword: req.KeyPassword,
+	}
+
+	var csr *x509.CertificateRequest
+	if req.CSR != "" {
+		block, _ := pem.Decode([]byte(req.CSR))
+		if block == nil || (block.Type != utils.PemTypeCertificateRequest && block.Type != utils.PemTypeNewCertificateRequest) {
+			return nil, fmt.Errorf("the csr must be a PEM encoded certificate request")
+		}
+
+		var err error
+		csr, err = x509.ParseCertificateRequest(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid certificate request: %s", err.Error())
+		}
+
+		err = csr.CheckSignature()
+		if err != nil {
+			return nil, fmt.Errorf("the signature of the certificate request is not valid: %s", err.Error())
+		}
+
+		if req.CryptoType != "" || req.KeyLength != 0 || req.KeyPassword != "" {
+			return nil, fmt.Errorf("crypto, key_length and key_password can not be used with csr")
+		}
+
+		res.CSR = csr
+	} else {
+		cryptoType := req.CryptoType
+		if cryptoType == "" {
+			cryptoType = string(utils.CryptoTypeEcdsa)
+		}
+
+		keyType, keyLength, err := parseKeyOption(&flagparser.KeyOption{CryptoType: cryptoType, KeyLength: req.KeyLength})
+		if err != nil {
+			return nil, err
+		}
+
+		res.Key, res.PublicKey, err = utils.GenerateKeyIfNil(nil, keyType, keyLength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if req.Subject != nil {
+		res.Subject, err = parseSubjectOption(&flagparser.SubjectOption{
+			Country:            req.Subject.Country,
+			Province:           req.Subject.Province,
+			Locality:           req.Subject.Locality,
+			Organization:       req.Subject.Organization,
+			OrganizationalUnit: req.Subject.OrganizationalUnit,
+			StreetAddress:      req.Subject.StreetAddress,
+			PostalCode:         req.Subject.PostalCode,
+			CommonName:         req.Subject.CommonName,
+		})
+	} else if csr != nil {
+		res.Subject, err = global.NewCertSubjectFromPkixName(csr.Subject)
+	} else {
+		res.Subject = global.NewCertSubject()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.DNSNames) != 0 || len(req.IPAddresses) != 0 || len(req.EmailAddresses) != 0 || len(req.URIs) != 0 || csr == nil {
+		res.Domains, res.IPs, res.Emails, res.URLs, err = parseSANOption(&flagparser.SANOption{
+			DNS:   req.DNSNames,
+			IP:    req.IPAddresses,
+			Email: req.EmailAddresses,
+			URI:   req.URIs,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, domain := range csr.DNSNames {
+			if !utils.IsValidDomain(domain) {
+				return nil, fmt.Errorf("not a valid domain: %s", domain)
+			}
+		}
+
+		for _, email := range csr.EmailAddresses {
+			if !utils.IsValidEmail(email) {
+				return nil, fmt.Errorf("not a valid email: %s", email)
+			}
+		}
+
+		res.Domains, res.IPs, res.Emails, res.URLs = csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs
+	}
+
+	if len(req.ExtKeyUsage) != 0 {
+		res.ExtKeyUsage, err = parseExtKeyUsageOption(req.ExtKeyUsage)
+	} else if csr != nil {
+		res.ExtKeyUsage, _, _, err = utils.ParseCSRExtKeyUsage(csr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Subject.SetCNIfEmpty(res.Domains, res.IPs, res.Emails, res.URLs)
+	if err != nil {
+		return nil, err
+	}
+
+	res.DirName = req.Name
+	if res.DirName == "" {
+		res.DirName = fmt.Sprintf("API-%s-%s", res.Subject.CN, time.Now().Format("20060102150405"))
+	}
+
+	return res, nil
+}
+
+func (b *apiBackend) ListCertificates() (any, error) {
+	_, res, err := loadEntryDetails("CERT")
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (b *apiBackend) GetCertificate(name string) (any, error) {
+	return loadAPICertificate("CERT", name)
+}
+
+// loadAPICertificate 读取证书的详细信息、证书和证书链
+func loadAPICertificate(certType string, name string) (*apiCertificate, error) {
+	err := checkLocalName(certType, name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &apiCertificate{
+		entryDetail: loadEntryDetail(certType, name, time.Now()),
+	}
+
+	dirPath := path.Join(certTypeHome(certType), name)
+	if data, err := os.ReadFile(path.Join(dirPath, "cert.pem")); err == nil {
+		res.Certificate = string(data)
+	}
+	if data, err := os.ReadFile(path.Join(dirPath, "fullchain.pem")); err == nil {
+		res.Chain = string(data)
+	}
+
+	return res, nil
+}
+
+func (b *apiBackend) ListIssued(query *apiserver.IssuedQuery) (any, error) {
+	filter, err := newIssuedFilter(query.Search, query.Status, query.Type, query.ExpiresWithin)
+	if err != nil {
+		return nil, err
+	}
+
+	var cas []*localCertificate
+	if query.Issuer != "" {
+		issuerType := query.IssuerType
+		if issuerType == "" {
+			issuerType = "ICA"
+		}
+
+		err = checkLocalName(issuerType, query.Issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		ca, err := loadLocalCA(issuerType, query.Issuer)
+		if err != nil {
+			return nil, err
+		}
+		cas = []*localCertificate{ca}
+	} else {
+		cas = loadAllLocalCertificate("RCA", "ICA")
+	}
+
+	now := time.Now()
+	res := make([]*apiIssuedList, 0, len(cas))
+	for _, ca := range cas {
+		total, issued, err := filterIssuanceIndex(ca, filter, now)
+		if err != nil {
+			return nil, err
+		}
+
+		list := &apiIssuedList{
+			IssuerType:   ca.Type,
+			Issuer:       ca.Name,
+			Total:        total,
+			Certificates: make([]*apiIssuedCert, 0, len(issued)),
+		}
+
+		for _, c := range issued {
+			r := &apiIssuedCert{
+				SerialNumber:   c.SerialNumber.Text(16),
+				Status:         string(c.Status(now)),
+				Type:           c.Type,
+				Subject:        c.Subject,
+				DNSNames:       c.DNSNames,
+				IPAddresses:    c.IPAddresses,
+				EmailAddresses: c.EmailAddresses,
+				URIs:           c.URIs,
+				NotAfter:       c.NotAfter,
+				Fingerprint:    c.Fingerprint,
+				Path:           c.Path,
+				IssuedAt:       c.IssuedAt,
+			}
+			if !c.NotBefore.IsZero() {
+				r.NotBefore = &c.NotBefore
+			}
+			if !c.RevokedAt.IsZero() {
+				r.RevokedAt = &c.RevokedAt
+			}
+			if c.SupersededBy != nil {
+				r.SupersededBy = c.SupersededBy.Text(16)
+			}
+			list.Certificates = append(list.Certificates, r)
+		}
+
+		res = append(res, list)
+	}
+
+	return res, nil
+}
+
+func (b *apiBackend) Revoke(req *apiserver.RevokeRequest, client *apiserver.Client) (any, error) {
+	if req.Name == "" && req.SerialNumber == "" && req.Fingerprint == "" {
+		return nil, fmt.Errorf("one of name, serial_number or fingerprint must be set")
+	}
+
+	opt := &flagparser.CertRevokeOption{
+		Cert:        req.Name,
+		CertType:    req.CertType,
+		Serial:      req.SerialNumber,
+		Fingerprint: req.Fingerprint,
+		Reason:      req.Reason,
+		Issuer:      req.Issuer,
+		IssuerType:  req.IssuerType,
+	}
+	if opt.CertType == "" {
+		opt.CertType = "CERT"
+	}
+	if opt.IssuerType == "" {
+		opt.IssuerType = "ICA"
+	}
+	if opt.Reason == "" {
+		opt.Reason = "unspecified"
+	}
+
+	if opt.Cert != "" {
+		err := checkLocalName(opt.CertType, opt.Cert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	target, issuer, record, err := revokeByOption(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("API: %s revoked %s by %s\n", client.String(), target, issuer.String())
+	return map[string]any{
+		"serial_number": record.SerialNumber.Text(16),
+		"issuer_type":   issuer.Type,
+		"issuer":        issuer.Name,
+		"reason":        record.Reason.String(),
+		"revoked_at":    record.RevokedAt,
+	}, nil
+}
+
+// apiClientVerifier 验证TLS客户端证书可用于客户端认证、未被吊销，并且由允许的CA直接签发
+func apiClientVerifier(clientCAs []*localCertificate) func(chain []*x509.Certificate) error {
+	return func(chain []*x509.Certificate) error {
+		err := verifyClientCertificate(chain)
+		if err != nil {
+			return err
+		}
+
+		for _, ca := range clientCAs {
+			if bytes.Equal(chain[0].RawIssuer, ca.Cert.RawSubject) && chain[0].CheckSignatureFrom(ca.Cert) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("the client certificate is not issued by the allowed CA")
+	}
+}
+
+// apiStoreDirPath REST API的访问令牌保存在 home/api 中
+func apiStoreDirPath() string {
+	return path.Join(home, "api")
+}
+
+func CommandCreateAPIToken(opt *flagparser.APITokenCreateOption) error {
+	if opt.Name == "" {
+		return fmt.Errorf("the name must be set")
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	store, err := apiserver.NewTokenStore(apiStoreDirPath())
+	if err != nil {
+		return err
+	}
+
+	token, value, err := store.CreateToken(opt.Name, validity, opt.ReadOnly)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, token %s (expires at %s, read-only: %v)\n", token.ID, token.ExpiresAt.Format(time.DateTime), token.ReadOnly)
+	fmt.Println("Token (only shown once): ", value)
+	return nil
+}
+
+func CommandListAPIToken() error {
+	store, err := apiserver.NewTokenStore(apiStoreDirPath())
+	if err != nil {
+		return err
+	}
+
+	tokens, err := store.ListTokens()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Tokens: ", len(tokens))
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " ID\tNAME\tSTATUS\tREAD-ONLY\tCREATED AT\tEXPIRES AT")
+
+	for _, t := range tokens {
+		_, _ = fmt.Fprintf(w, " %s\t%s\t%s\t%v\t%s\t%s\n", t.ID, t.Name, t.Status(), t.ReadOnly, t.CreatedAt.Format(time.DateTime), t.ExpiresAt.Format(time.DateTime))
+	}
+
+	return w.Flush()
+}
+
+func CommandRevokeAPIToken(opt *flagparser.APITokenRevokeOption) error {
+	if opt.ID == "" {
+		return fmt.Errorf("the id must be set")
+	}
+
+	store, err := apiserver.NewTokenStore(apiStoreDirPath())
+	if err != nil {
+		return err
+	}
+
+	err = store.RevokeToken(opt.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, token %s has been revoked\n", opt.ID)
+	return nil
+}
+
+func CommandServeAPI(opt *flagparser.ServeAPIOption) error {
+	if (opt.TLSCert == "") != (opt.TLSKey == "") {
+		return fmt.Errorf("the tls-cert and tls-key must be set together")
+	} else if len(opt.ClientCA) != 0 && opt.TLSCert == "" {
+		return fmt.Errorf("the client-ca requires the tls-cert and tls-key")
+	}
+
+	extKeyUsage, err := parseAllowExtKeyUsageOption(opt.AllowExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	clientCAs := make([]*localCertificate, 0, len(opt.ClientCA))
+	for _, s := range opt.ClientCA.Value() {
+		caType, name, ok := strings.Cut(s, "/")
+		if !ok {
+			return fmt.Errorf("not a valid client CA (format: RCA/<name> or ICA/<name>): %s", s)
+		}
+
+		ca, err := loadLocalCA(caType, name)
+		if err != nil {
+			return err
+		}
+		clientCAs = append(clientCAs, ca)
+	}
+
+	store, err := apiserver.NewTokenStore(apiStoreDirPath())
+	if err != nil {
+		return err
+	}
+
+	config := &apiserver.Config{
+		Tokens:  store,
+		Backend: &apiBackend{extKeyUsage: extKeyUsage},
+	}
+	if len(clientCAs) != 0 {
+		config.VerifyClientCert = apiClientVerifier(clientCAs)
+	}
+
+	server, err := apiserver.NewServer(config)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	for _, ca := range clientCAs {
+		fmt.Printf("Accept the client certificates issued by %s\n", ca.String())
+	}
+
+	if opt.TLSCert == "" {
+		fmt.Println("Warning: serve over HTTP, the tokens and private keys are sent in plaintext, set -tls-cert and -tls-key to serve over HTTPS")
+		fmt.Printf("API server listening on %s, url: http://%s/api/v1 (OpenAPI: http://%s/api/v1/openapi.json)\n", opt.Listen, opt.Listen, opt.Listen)
+		return httpServer.ListenAndServe()
+	}
+
+	tlsCert, err := loadTLSCertificate(opt.TLSCert, opt.TLSKey, opt.TLSKeyPassword)
+	if err != nil {
+		return err
+	}
+
+	httpServer.TLSConfig = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{tlsCert},
+		ClientAuth:   tls.RequestClientCert, // 客户端证书由 apiClientVerifier 验证
+	}
+
+	fmt.Printf("API server listening on %s, url: https://%s/api/v1 (OpenAPI: https://%s/api/v1/openapi.json)\n", opt.Listen, opt.Listen, opt.Listen)
+	return httpServer.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,263 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"path"
+)
+
+// keyAuditResult 私钥审计的结果，Problems为空表示未发现问题
+type keyAuditResult struct {
+	Type       string // RCA、ICA、CERT、PENDING、OCSP-SIGNER、SCEP-RA或CMP-SIGNER
+	DirPath    string
+	Subject    string
+	KeyType    string
+	RandSource string
+	Problems   []string
+}
+
+func (r *keyAuditResult) IsWeak() bool {
+	return len(r.Problems) != 0
+}
+
+func AuditKeys() {
+	results := auditKeys()
+	if len(results) == 0 {
+		fmt.Println("No private key found.")
+		return
+	}
+
+	printKeyAuditResults(results)
+}
+
+func CommandAuditKeys() error {
+	results := auditKeys()
+	if len(results) == 0 {
+		fmt.Println("No private key found.")
+		return nil
+	}
+
+	if weak := printKeyAuditResults(results); weak != 0 {
+		return fmt.Errorf("found %d weak keys", weak)
+	}
+
+	return nil
+}
+
+func printKeyAuditResults(results []*keyAuditResult) (weak int) {
+	for i, r := range results {
+		status := "OK"
+		if r.IsWeak() {
+			status = "WEAK"
+			weak++
+		}
+
+		randSource := r.RandSource
+		if randSource == "" {
+			randSource = "unknown"
+		}
+
+		fmt.Printf("%d. [%s] %s %s\n", i+1, status, r.Type, r.DirPath)
+		fmt.Printf("   Subject: %s\n", r.Subject)
+		fmt.Printf("   Key: %s, Random Source: %s\n", r.KeyType, randSource)
+		for _, p := range r.Problems {
+			fmt.Printf("   - %s\n", p)
+		}
+	}
+
+	fmt.Printf("Total: %d, Weak: %d\n", len(results), weak)
+	if weak != 0 {
+		fmt.Println("The weak keys should be rotated: issue new certificates with new keys, and revoke the old ones.")
+	}
+
+	return weak
+}
+
+// auditKeys 检查home目录中由MyCA生成的全部私钥
+// v1.0.0及更早的版本使用以时间为种子的math/rand生成私钥，这些私钥的信息文件中没有记录随机源，可以通过创建时间推算出私钥
+func auditKeys() []*keyAuditResult {
+	res := make([]*keyAuditResult, 0, 10)
+
+	for _, item := range []struct {
+		certType string
+		infoName string
+	}{{"RCA", "rca-info.json"}, {"ICA", "ica-info.json"}, {"CERT", "cert-info.json"}} {
+		names, err := utils.ReadDirOnlyDir(certTypeHome(item.certType))
+		if err != nil {
+			continue
+		}
+
+		for _, name := range names {
+			dirPath := path.Join(certTypeHome(item.certType), name)
+			if r := auditKeyDir(item.certType, dirPath, item.infoName); r != nil {
+				res = append(res, r)
+			}
+
+			if item.certType != "CERT" {
+				if r := auditKeyDir("OCSP-SIGNER", ocspSignerDirPath(dirPath), "cert-info.json"); r != nil {
+					res = append(res, r)
+				}
+
+				if r := auditKeyDir("SCEP-RA", scepRADirPath(dirPath), "cert-info.json"); r != nil {
+					res = append(res, r)
+				}
+
+				if r := auditKeyDir("CMP-SIGNER", cmpSignerDirPath(dirPath), "cert-info.json"); r != nil {
+					res = append(res, r)
+				}
+			}
+		}
+	}
+
+	names, err := utils.ReadDirOnlyDir(homePending)
+	if err == nil {
+		for _, name := range names {
+			if r := auditKeyDir("PENDING", path.Join(homePending, name), "csr-info.json"); r != nil {
+				res = append(res, r)
+			}
+		}
+	}
+
+	return res
+}
+
+// auditKeyDir 审计目录中的私钥，公钥从证书或CSR中读取，因此无需私钥密码；目录中没有私钥时返回nil
+func auditKeyDir(certType string, dirPath string, infoName string) *keyAuditResult {
+	if !utils.IsExists(path.Join(dirPath, "key.pem")) {
+		return nil // 例如签发外部CSR得到的证书，私钥不由MyCA保管
+	}
+
+	res := &keyAuditResult{
+		Type:     certType,
+		DirPath:  dirPath,
+		Problems: make([]string, 0, 2),
+	}
+
+	pubKey, subject, err := readAuditPublicKey(certType, dirPath)
+	if err != nil {
+		res.KeyType = "unknown"
+		res.Problems = append(res.Problems, fmt.Sprintf("can not read the public key: %s", err.Error()))
+	} else {
+		res.Subject = subject
+		res.KeyType, err = checkPublicKeyStrength(pubKey)
+		if err != nil {
+			res.Problems = append(res.Problems, err.Error())
+		}
+	}
+
+	res.RandSource, err = readRandSource(certType, path.Join(dirPath, infoName))
+	if err != nil {
+		res.Problems = append(res.Problems, fmt.Sprintf("can not read the info file: %s", err.Error()))
+	} else if res.RandSource == "" || res.RandSource == utils.RandSourceMathRand {
+		res.Problems = append(res.Problems, "created by MyCA v1.0.0 or earlier, the key was generated by math/rand seeded with the creation time and is predictable")
+	} else if res.RandSource != utils.RandSourceCryptoRand && res.RandSource != utils.RandSourceExternal { // 外部导入的私钥无法判断其随机源
+		res.Problems = append(res.Problems, fmt.Sprintf("the key was generated by a non-standard random source: %s", res.RandSource))
+	}
+
+	return res
+}
+
+func readAuditPublicKey(certType string, dirPath string) (crypto.PublicKey, string, error) {
+	if certType == "PENDING" {
+		csrPEM, err := utils.ReadPemBlock(path.Join(dirPath, "csr.pem"))
+		if err != nil {
+			return nil, "", err
+		} else if csrPEM.Type != utils.PemTypeCertificateRequest {
+			return nil, "", fmt.Errorf("pem type of csr error")
+		}
+
+		req, err := x509.ParseCertificateRequest(csrPEM.Bytes)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return req.PublicKey, req.Subject.String(), nil
+	}
+
+	certPEM, err := utils.ReadPemBlock(path.Join(dirPath, "cert.pem"))
+	if err != nil {
+		return nil, "", err
+	} else if certPEM.Type != utils.PemTypeCertificate {
+		return nil, "", fmt.Errorf("pem type of cert error")
+	}
+
+	c, err := x509.ParseCertificate(certPEM.Bytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return c.PublicKey, c.Subject.String(), nil
+}
+
+// checkPublicKeyStrength 返回密钥的类型，密钥强度不足时返回错误
+func checkPublicKeyStrength(pubKey crypto.PublicKey) (string, error) {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		keyType := fmt.Sprintf("RSA %d", key.N.BitLen())
+		if key.N.BitLen() < 2048 {
+			return keyType, fmt.Errorf("the RSA key is shorter than 2048 bits")
+		}
+		return keyType, nil
+	case *ecdsa.PublicKey:
+		keyType := fmt.Sprintf("ECDSA %s", key.Curve.Params().Name)
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+			return keyType, nil
+		default:
+			return keyType, fmt.Errorf("unsupported elliptic curve")
+		}
+	case ed25519.PublicKey:
+		return "Ed25519", nil
+	default:
+		return "unknown", fmt.Errorf("unsupported public key type: %T", pubKey)
+	}
+}
+
+// readRandSource 从信息文件中读取生成私钥时使用的随机源
+func readRandSource(certType string, infoPath string) (string, error) {
+	switch certType {
+	case "RCA":
+		info, err := rootca.GetRCAInfo(infoPath)
+		if err != nil {
+			return "", err
+		}
+		return info.RandSource, nil
+	case "ICA":
+		info, err := ica.GetICAInfo(infoPath)
+		if err != nil {
+			return "", err
+		}
+		return info.RandSource, nil
+	case "PENDING":
+		info, err := csr.GetCSRInfo(infoPath)
+		if err != nil {
+			return "", err
+		}
+		return info.RandSource, nil
+	default:
+		info, err := cert.GetCertInfo(infoPath)
+		if err == nil {
+			return info.RandSource, nil
+		}
+
+		selfInfo, selfErr := cert.GetSelfCertInfo(infoPath) // 自签名证书的信息文件
+		if selfErr != nil {
+			return "", err
+		}
+		return selfInfo.RandSource, nil
+	}
+}
@@ -0,0 +1,381 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/cmpserver"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// cmpIssuer 签发CMP请求的证书并处理吊销请求，证书保存在 home/cert/CMP-<CN>-<时间> 中
+type cmpIssuer struct {
+	*onlineIssuer
+}
+
+func (i *cmpIssuer) issue(req *cmpserver.IssueRequest) (*x509.Certificate, error) {
+	for _, domain := range req.DNSNames {
+		if !utils.IsValidDomain(domain) {
+			return nil, fmt.Errorf("not a valid domain: %s", domain)
+		}
+	}
+
+	for _, email := range req.EmailAddresses {
+		if !utils.IsValidEmail(email) {
+			return nil, fmt.Errorf("not a valid email: %s", email)
+		}
+	}
+
+	subject, err := global.NewCertSubjectFromPkixName(req.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = subject.SetCNIfEmpty(req.DNSNames, req.IPAddresses, req.EmailAddresses, req.URIs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 客户端（例如共享密钥的持有者）只能请求允许的扩展密钥用途
+	_, err = i.checkExtKeyUsage(req.ExtKeyUsage)
+	if err != nil {
+		return nil, &cmpserver.BadCertTemplateError{Err: err}
+	}
+
+	userCert, _, err := i.onlineIssuer.issue(&onlineRequest{
+		DirName:     fmt.Sprintf("CMP-%s-%s", subject.CN, time.Now().Format("20060102150405")),
+		CSR:         req.CSR,
+		PublicKey:   req.PublicKey,
+		Subject:     subject,
+		Domains:     req.DNSNames,
+		IPs:         req.IPAddresses,
+		Emails:      req.EmailAddresses,
+		URLs:        req.URIs,
+		ExtKeyUsage: req.ExtKeyUsage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Old != nil {
+		err = i.supersede(req.Old, userCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return userCert, nil
+}
+
+// verifySigner 验证签名保护使用的证书由home中的CA签发，并且证书链、有效期和吊销状态有效
+func (i *cmpIssuer) verifySigner(chain []*x509.Certificate) error {
+	res := verifyCertificates(chain, &verifyRequest{
+		Purpose: "any",
+		Time:    time.Now(),
+	})
+	if len(res.Errors) != 0 {
+		return fmt.Errorf("%s", strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+// revoke 吊销证书并记录到签发CA的吊销数据库和签发索引中
+func (i *cmpIssuer) revoke(c *x509.Certificate, reason revoke.Reason) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	issuer, _, err := revokeCertificate(&localCertificate{Cert: c}, reason)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Revoked %s (serial number: %s) by %s, reason: %s\n", c.Subject.CommonName, c.SerialNumber.Text(16), issuer.String(), reason.String())
+	return nil
+}
+
+// cmpStoreDirPath CMP服务的共享密钥保存在 home/cmp/<ICA名称> 中
+func cmpStoreDirPath(icaName string) string {
+	return path.Join(home, "cmp", icaName)
+}
+
+func openCMPStore(icaName string) (*cmpserver.Store, error) {
+	if icaName == "" {
+		return nil, fmt.Errorf("the ica must be set")
+	} else if !utils.IsValidFilename(icaName) {
+		return nil, fmt.Errorf("not a valid name: %s", icaName)
+	} else if !utils.IsExists(path.Join(home, "ica", icaName, "cert.pem")) {
+		return nil, fmt.Errorf("ICA %s not found", icaName)
+	}
+
+	return cmpserver.NewStore(cmpStoreDirPath(icaName))
+}
+
+func cmpSignerDirPath(caDirPath string) string {
+	return path.Join(caDirPath, "cmp-signer")
+}
+
+// createCMPSigner 签发CMP响应的签名证书，保存在CA目录的cmp-signer子目录中（会覆盖旧的签名证书）
+func createCMPSigner(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo, cryptoType utils.CryptoType, keyLength int, existingKey crypto.PrivateKey, keyRandSource string, validity time.Duration, password string) (string, error) {
+	caDirPath, err := caInfoDirPath(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	dirPath := cmpSignerDirPath(caDirPath)
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	notBefore := time.Now()
+	signerCert, signerKey, err := cmpserver.CreateSigner(caInfo, cryptoType, keyLength, existingKey, notBefore, notBefore.Add(validity), caCert, caKey)
+	if err != nil {
+		return "", err
+	}
+
+	err = recordIssuance(caInfo, signerCert, "CMP-SIGNER", dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if oldSigners, err := utils.ReadCertificates(path.Join(dirPath, "cert.pem")); err == nil && len(oldSigners) != 0 {
+		err = recordSupersede(caInfo, oldSigners[0], signerCert, "") // 旧的签名证书会被覆盖
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = saveCAInfo(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	signerInfo, err := cert.NewCertInfo(path.Join(dirPath, "cert-info.json"), caInfo)
+	if err != nil {
+		return "", err
+	}
+	signerInfo.SerialNumber = signerCert.SerialNumber
+	setKeyRandSource(signerInfo, keyRandSource)
+
+	err = saveCertificateAndKey(dirPath, signerCert, signerKey, password, caFullchain)
+	if err != nil {
+		return "", err
+	}
+
+	err = signerInfo.SaveCertInfo()
+	if err != nil {
+		return "", err
+	}
+
+	return dirPath, nil
+}
+
+func CommandCreateCMPSigner(opt *flagparser.CMPSignerOption) error {
+	if opt.ICA == "" {
+		return fmt.Errorf("the ica must be set")
+	} else if !utils.IsValidFilename(opt.ICA) {
+		return fmt.Errorf("not a valid name: %s", opt.ICA)
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := loadICA(opt.ICA, func() string {
+		return opt.ICAPassword
+	})
+	if err != nil {
+		return err
+	}
+
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	dirPath, err := createCMPSigner(caCert, caKey, caFullchain, caInfo, cryptoType, keyLength, existingKey, keyRandSource, validity, opt.Password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateCMPSecret(opt *flagparser.CMPSecretCreateOption) error {
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	} else if opt.Uses < 0 {
+		return fmt.Errorf("not a valid uses: %d", opt.Uses)
+	}
+
+	store, err := openCMPStore(opt.ICA)
+	if err != nil {
+		return err
+	}
+
+	secret, err := store.CreateSecret(validity, opt.Uses, opt.Comment)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, secret of ICA/%s (expires at %s)\n", opt.ICA, secret.ExpiresAt.Format(time.DateTime))
+	fmt.Println("Reference (senderKID): ", secret.Reference)
+	fmt.Println("Secret: ", secret.Secret)
+	return nil
+}
+
+func CommandListCMPSecret(opt *flagparser.CMPStoreOption) error {
+	store, err := openCMPStore(opt.ICA)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := store.ListSecrets()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Secrets: ", len(secrets))
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " REFERENCE\tSTATUS\tUSES\tCREATED AT\tEXPIRES AT\tCOMMENT")
+
+	for _, s := range secrets {
+		uses := fmt.Sprintf("%d", s.Uses)
+		if s.MaxUses > 0 {
+			uses = fmt.Sprintf("%d/%d", s.Uses, s.MaxUses)
+		}
+		_, _ = fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t%s\n", s.Reference, s.Status(), uses, s.CreatedAt.Format(time.DateTime), s.ExpiresAt.Format(time.DateTime), s.Comment)
+	}
+
+	return w.Flush()
+}
+
+func CommandRevokeCMPSecret(opt *flagparser.CMPSecretRevokeOption) error {
+	if opt.Reference == "" {
+		return fmt.Errorf("the ref must be set")
+	}
+
+	store, err := openCMPStore(opt.ICA)
+	if err != nil {
+		return err
+	}
+
+	err = store.RevokeSecret(opt.Reference)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, secret %s has been revoked\n", opt.Reference)
+	return nil
+}
+
+func CommandServeCMP(opt *flagparser.ServeCMPOption) error {
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	extKeyUsage, err := parseAllowExtKeyUsageOption(opt.AllowExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	store, err := openCMPStore(opt.ICA)
+	if err != nil {
+		return err
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := loadICA(opt.ICA, func() string {
+		return opt.Password
+	})
+	if err != nil {
+		return err
+	}
+
+	online, err := newOnlineIssuer(caCert, caKey, caFullchain, caInfo, validity, opt.Policy.Value())
+	if err != nil {
+		return err
+	}
+	online.setExtKeyUsage(extKeyUsage)
+
+	issuer := &cmpIssuer{onlineIssuer: online}
+
+	caCerts, err := utils.ParseCertificates(caFullchain)
+	if err != nil {
+		return err
+	}
+
+	// 签名证书不存在时使用ICA私钥签名响应，ICA证书没有 digitalSignature 密钥用途，部分客户端会拒绝这样的响应
+	var signerCert *x509.Certificate
+	var signerKey crypto.PrivateKey
+	signerDirPath := cmpSignerDirPath(path.Join(home, "ica", opt.ICA))
+	if utils.IsExists(path.Join(signerDirPath, "cert.pem")) {
+		certs, err := utils.ReadCertificates(path.Join(signerDirPath, "cert.pem"))
+		if err != nil {
+			return err
+		}
+
+		signerKey, err = readPrivateKey(path.Join(signerDirPath, "key.pem"), func() string {
+			return opt.SignerPassword
+		})
+		if err != nil {
+			return err
+		}
+
+		signerCert = certs[0]
+	} else {
+		fmt.Printf("Warning: the CMP signer certificate of ICA/%s not found, sign the responses with the private key of the ICA\n", opt.ICA)
+	}
+
+	server, err := cmpserver.NewServer(&cmpserver.Config{
+		CACerts:      caCerts,
+		CAKey:        caKey,
+		Store:        store,
+		Signer:       signerCert,
+		SignerKey:    signerKey,
+		Issue:        issuer.issue,
+		VerifySigner: issuer.verifySigner,
+		Revoke:       issuer.revoke,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serve ICA/%s, store directory: %s\n", opt.ICA, cmpStoreDirPath(opt.ICA))
+	fmt.Printf("CMP server listening on %s, url: http://%s/.well-known/cmp\n", opt.Listen, opt.Listen)
+
+	return httpServer.ListenAndServe()
+}
@@ -0,0 +1,1155 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/ocspserver"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// RunCommand 以非交互模式执行命令行指定的子命令
+func RunCommand() (exitcode int) {
+	var err error
+
+	switch flagparser.Command {
+	case "rca list":
+		err = CommandListEntry("RCA", &flagparser.RCAList)
+	case "ica list":
+		err = CommandListEntry("ICA", &flagparser.ICAList)
+	case "cert list":
+		err = CommandListEntry("CERT", &flagparser.CertList)
+	case "rca create":
+		err = CommandCreateRCA(&flagparser.RCACreate)
+	case "ica create":
+		err = CommandCreateICA(&flagparser.ICACreate)
+	case "ca import":
+		err = CommandImportCA(&flagparser.CAImport)
+	case "cert issue":
+		err = CommandCreateUserCert(&flagparser.CertIssue)
+	case "cert self":
+		err = CommandCreateUserCertSelf(&flagparser.CertSelf)
+	case "cert renew":
+		err = CommandRenewCert(&flagparser.CertRenew)
+	case "cert sign":
+		err = CommandSignCSR(&flagparser.CertSign)
+	case "csr list":
+		ShowAllPending()
+	case "csr create":
+		err = CommandCreateCSR(&flagparser.CSRCreate)
+	case "ica import-signed":
+		err = CommandImportSignedICA(&flagparser.ICAImportSigned)
+	case "cert revoke":
+		err = CommandRevokeCert(&flagparser.CertRevoke)
+	case "revoke list":
+		err = CommandShowRevoked(&flagparser.RevokeList)
+	case "crl create":
+		err = CommandCreateCRL(&flagparser.CRLCreate)
+	case "ocsp signer":
+		err = CommandCreateOCSPSigner(&flagparser.OCSPSigner)
+	case "serve ocsp":
+		err = CommandServeOCSP(&flagparser.ServeOCSP)
+	case "serve acme":
+		err = CommandServeACME(&flagparser.ServeACME)
+	case "serve est":
+		err = CommandServeEST(&flagparser.ServeEST)
+	case "serve scep":
+		err = CommandServeSCEP(&flagparser.ServeSCEP)
+	case "scep ra":
+		err = CommandCreateSCEPRA(&flagparser.SCEPRA)
+	case "scep challenge create":
+		err = CommandCreateSCEPChallenge(&flagparser.SCEPChallengeCreate)
+	case "scep challenge list":
+		err = CommandListSCEPChallenge(&flagparser.SCEPChallengeList)
+	case "scep challenge revoke":
+		err = CommandRevokeSCEPChallenge(&flagparser.SCEPChallengeRevoke)
+	case "scep request list":
+		err = CommandListSCEPRequest(&flagparser.SCEPRequestList)
+	case "scep request approve":
+		err = CommandApproveSCEPRequest(&flagparser.SCEPRequestApprove)
+	case "scep request reject":
+		err = CommandRejectSCEPRequest(&flagparser.SCEPRequestReject)
+	case "serve cmp":
+		err = CommandServeCMP(&flagparser.ServeCMP)
+	case "cmp signer":
+		err = CommandCreateCMPSigner(&flagparser.CMPSigner)
+	case "cmp secret create":
+		err = CommandCreateCMPSecret(&flagparser.CMPSecretCreate)
+	case "cmp secret list":
+		err = CommandListCMPSecret(&flagparser.CMPSecretList)
+	case "cmp secret revoke":
+		err = CommandRevokeCMPSecret(&flagparser.CMPSecretRevoke)
+	case "serve api":
+		err = CommandServeAPI(&flagparser.ServeAPI)
+	case "api token create":
+		err = CommandCreateAPIToken(&flagparser.APITokenCreate)
+	case "api token list":
+		err = CommandListAPIToken()
+	case "api token revoke":
+		err = CommandRevokeAPIToken(&flagparser.APITokenRevoke)
+	case "policy add":
+		err = CommandAddPolicy(&flagparser.PolicyAdd)
+	case "policy list":
+		err = CommandListPolicy(&flagparser.PolicyList)
+	case "issued list":
+		err = CommandListIssued(&flagparser.IssuedList)
+	case "inspect":
+		err = CommandInspect(&flagparser.Inspect)
+	case "verify":
+		err = CommandVerify(&flagparser.Verify)
+	case "audit keys":
+		err = CommandAuditKeys()
+	default:
+		err = fmt.Errorf("unknown command: %s", flagparser.Command)
+	}
+
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func CommandCreateRCA(opt *flagparser.RCACreateOption) error {
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	serialMode, err := utils.ParseSerialMode(opt.SerialMode)
+	if err != nil {
+		return err
+	}
+
+	subject, err := parseSubjectOption(&opt.SubjectOption)
+	if err != nil {
+		return err
+	}
+
+	notBefore, notAfter, err := parseValidityOption(opt.Validity)
+	if err != nil {
+		return err
+	}
+
+	keyUsage, err := parseKeyUsageOption(opt.KeyUsage.Value(), "rca")
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	maxPathLen, err := parseMaxPathLenOption(nil, opt.MaxPathLen)
+	if err != nil {
+		return err
+	}
+
+	ocspURLs, err := parseURLOption(opt.OCSP.Value())
+	if err != nil {
+		return err
+	}
+
+	issurURLs, err := parseURLOption(opt.IssuingURL.Value())
+	if err != nil {
+		return err
+	}
+
+	crlURLs, err := parseURLOption(opt.CRL.Value())
+	if err != nil {
+		return err
+	}
+
+	nameConstraints, err := parseNameConstraintOption(&opt.NameConstraintOption)
+	if err != nil {
+		return err
+	}
+
+	policies, policyLibrary, err := parseCAPolicyOption(&opt.CAPolicyOption, nil, false)
+	if err != nil {
+		return err
+	}
+
+	err = subject.SetCNIfEmpty()
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeRCA, "RCA-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	caCert, key, rcaInfo, err := rootca.CreateRCA(path.Join(dirPath, "rca-info.json"), cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, maxPathLen, nameConstraints, policies, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	setKeyRandSource(rcaInfo, keyRandSource)
+	rcaInfo.SerialMode = serialMode
+	rcaInfo.Policies = policyLibrary
+
+	err = rcaInfo.SaveRCAInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateAndKey(dirPath, caCert, key, opt.Password, []byte{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateICA(opt *flagparser.ICACreateOption) error {
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	serialMode, err := utils.ParseSerialMode(opt.SerialMode)
+	if err != nil {
+		return err
+	}
+
+	icaSignatureAlgorithm, err := utils.ParseSignatureAlgorithm(opt.CASignatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	subject, err := parseSubjectOption(&opt.SubjectOption)
+	if err != nil {
+		return err
+	}
+
+	notBefore, notAfter, err := parseValidityOption(opt.Validity)
+	if err != nil {
+		return err
+	}
+
+	keyUsage, err := parseKeyUsageOption(opt.KeyUsage.Value(), "ica")
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	maxPathLen, err := parseMaxPathLenOption(caCert, opt.MaxPathLen)
+	if err != nil {
+		return err
+	}
+
+	ocspURLs, err := parseURLOption(opt.OCSP.Value())
+	if err != nil {
+		return err
+	}
+
+	issurURLs, err := parseURLOption(opt.IssuingURL.Value())
+	if err != nil {
+		return err
+	}
+
+	crlURLs, err := parseURLOption(opt.CRL.Value())
+	if err != nil {
+		return err
+	}
+
+	nameConstraints, err := parseNameConstraintOption(&opt.NameConstraintOption)
+	if err != nil {
+		return err
+	}
+
+	policies, policyLibrary, err := parseCAPolicyOption(&opt.CAPolicyOption, caCertificatePolicies(caInfo), true)
+	if err != nil {
+		return err
+	}
+
+	err = subject.SetCNIfEmpty()
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeICA, "ICA-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	icaCert, key, icaInfo, err := ica.CreateICA(path.Join(dirPath, "ica-info.json"), caInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, maxPathLen, nameConstraints, policies, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, caCert, caKey, signatureAlgorithm, icaSignatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	err = recordIssuance(caInfo, icaCert, "ICA", dirPath)
+	if err != nil {
+		return err
+	}
+
+	setKeyRandSource(icaInfo, keyRandSource)
+	icaInfo.SerialMode = serialMode
+	icaInfo.Policies = policyLibrary
+
+	err = icaInfo.SaveICAInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateAndKey(dirPath, icaCert, key, opt.Password, caFullchain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateUserCert(opt *flagparser.CertIssueOption) error {
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	subject, err := parseSubjectOption(&opt.SubjectOption)
+	if err != nil {
+		return err
+	}
+
+	notBefore, notAfter, err := parseValidityOption(opt.Validity)
+	if err != nil {
+		return err
+	}
+
+	keyUsage, err := parseKeyUsageOption(opt.KeyUsage.Value(), "cert")
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	domains, ips, emails, urls, err := parseSANOption(&opt.SANOption)
+	if err != nil {
+		return err
+	}
+
+	policies, err := selectCertificatePolicies(caCertificatePolicies(caInfo), opt.Policy.Value(), false)
+	if err != nil {
+		return err
+	}
+
+	err = checkNameConstraints(caCert, caFullchain, domains, ips, emails, urls)
+	if err != nil {
+		return err
+	}
+
+	err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeCert, "CERT-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	userCert, key, certInfo, err := cert.CreateCert(path.Join(dirPath, "cert-info.json"), caInfo, cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, caCert, caKey, signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	err = recordIssuance(caInfo, userCert, "CERT", dirPath)
+	if err != nil {
+		return err
+	}
+
+	setKeyRandSource(certInfo, keyRandSource)
+
+	err = certInfo.SaveCertInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateAndKey(dirPath, userCert, key, opt.Password, caFullchain)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateUserCertSelf(opt *flagparser.CertSelfOption) error {
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	subject, err := parseSubjectOption(&opt.SubjectOption)
+	if err != nil {
+		return err
+	}
+
+	notBefore, notAfter, err := parseValidityOption(opt.Validity)
+	if err != nil {
+		return err
+	}
+
+	keyUsage, err := parseKeyUsageOption(opt.KeyUsage.Value(), "cert")
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	domains, ips, emails, urls, err := parseSANOption(&opt.SANOption)
+	if err != nil {
+		return err
+	}
+
+	ocspURLs, err := parseURLOption(opt.OCSP.Value())
+	if err != nil {
+		return err
+	}
+
+	issurURLs, err := parseURLOption(opt.IssuingURL.Value())
+	if err != nil {
+		return err
+	}
+
+	crlURLs, err := parseURLOption(opt.CRL.Value())
+	if err != nil {
+		return err
+	}
+
+	err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeCert, "SELF-CERT-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	userCert, key, certInfo, err := cert.CreateSelfCert(path.Join(dirPath, "cert-info.json"), cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, ocspURLs, issurURLs, crlURLs, notBefore, notAfter, signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	setKeyRandSource(certInfo, keyRandSource)
+
+	err = certInfo.SaveSelfCert()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateAndKey(dirPath, userCert, key, opt.Password, []byte{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandSignCSR(opt *flagparser.CertSignOption) error {
+	if opt.CSR == "" {
+		return fmt.Errorf("the csr must be set")
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	csr, err := utils.ReadCertificateRequest(opt.CSR)
+	if err != nil {
+		return err
+	}
+
+	showCertificateRequest(csr)
+
+	// 未指定的部分使用证书签名请求中申请的内容
+	var subject *global.CertSubject
+	if opt.SubjectOption.IsEmpty() {
+		subject, err = global.NewCertSubjectFromPkixName(csr.Subject)
+	} else {
+		subject, err = parseSubjectOption(&opt.SubjectOption)
+	}
+	if err != nil {
+		return err
+	}
+
+	domains, ips, emails, urls := csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs
+	if !opt.SANOption.IsEmpty() {
+		domains, ips, emails, urls, err = parseSANOption(&opt.SANOption)
+		if err != nil {
+			return err
+		}
+	}
+
+	keyUsage, ok, err := utils.ParseCSRKeyUsage(csr)
+	if err != nil {
+		return err
+	} else if !ok || len(opt.KeyUsage) != 0 {
+		keyUsage, err = parseKeyUsageOption(opt.KeyUsage.Value(), "cert")
+		if err != nil {
+			return err
+		}
+	}
+
+	extKeyUsage, _, ok, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		return err
+	} else if !ok || len(opt.ExtKeyUsage) != 0 {
+		extKeyUsage, err = parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	notBefore, notAfter, err := parseValidityOption(opt.Validity)
+	if err != nil {
+		return err
+	}
+
+	policies, err := selectCertificatePolicies(caCertificatePolicies(caInfo), opt.Policy.Value(), false)
+	if err != nil {
+		return err
+	}
+
+	err = checkNameConstraints(caCert, caFullchain, domains, ips, emails, urls)
+	if err != nil {
+		return err
+	}
+
+	err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeCert, "CERT-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	userCert, certInfo, err := cert.CreateCertFromCSR(path.Join(dirPath, "cert-info.json"), caInfo, csr, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, caCert, caKey, signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	err = recordIssuance(caInfo, userCert, "CERT", dirPath)
+	if err != nil {
+		return err
+	}
+
+	err = certInfo.SaveCertInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateOnly(dirPath, userCert, caFullchain)
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateRequest(dirPath, csr)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateCSR(opt *flagparser.CSRCreateOption) error {
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	subject, err := parseSubjectOption(&opt.SubjectOption)
+	if err != nil {
+		return err
+	}
+
+	certType := "auto_cert"
+	if opt.CA {
+		certType = "ica"
+	}
+
+	keyUsage, err := parseKeyUsageOption(opt.KeyUsage.Value(), certType)
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseExtKeyUsageOption(opt.ExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	maxPathLen, err := parseMaxPathLenOption(nil, opt.MaxPathLen)
+	if err != nil {
+		return err
+	}
+
+	domains, ips, emails, urls, err := parseSANOption(&opt.SANOption)
+	if err != nil {
+		return err
+	}
+
+	ocspURLs, err := parseURLOption(opt.OCSP.Value())
+	if err != nil {
+		return err
+	}
+
+	issurURLs, err := parseURLOption(opt.IssuingURL.Value())
+	if err != nil {
+		return err
+	}
+
+	crlURLs, err := parseURLOption(opt.CRL.Value())
+	if err != nil {
+		return err
+	}
+
+	if opt.CA {
+		err = subject.SetCNIfEmpty()
+	} else {
+		err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	}
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homePending, "CSR-", subject, opt.Name, true)
+	if err != nil {
+		return err
+	} else if isPendingExists(dirPath) && !opt.Force {
+		return fmt.Errorf("there is a duplicate file in %s, use -force to overwrite it", dirPath)
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	req, key, csrInfo, err := csr.CreateCSR(path.Join(dirPath, "csr-info.json"), cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, opt.CA, maxPathLen, domains, ips, emails, urls, ocspURLs, issurURLs, crlURLs, signatureAlgorithm)
+	if err != nil {
+		return err
+	}
+
+	setKeyRandSource(csrInfo, keyRandSource)
+
+	err = savePending(dirPath, req, key, opt.Password, csrInfo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandImportSignedICA(opt *flagparser.ICAImportSignedOption) error {
+	if opt.Pending == "" {
+		return fmt.Errorf("the pending request must be set")
+	} else if !utils.IsValidFilename(opt.Pending) {
+		return fmt.Errorf("not a valid pending name: %s", opt.Pending)
+	} else if opt.Cert == "" {
+		return fmt.Errorf("the signed certificate must be set")
+	}
+
+	passwordFunc := func() string {
+		return opt.Password
+	}
+
+	_, key, password, csrInfo, err := loadPending(opt.Pending, passwordFunc)
+	if err != nil {
+		return err
+	}
+
+	if !csrInfo.IsCA {
+		return fmt.Errorf("the request is not for a CA")
+	}
+
+	certs, err := utils.ReadCertificates(opt.Cert)
+	if err != nil {
+		return err
+	} else if len(certs) == 0 {
+		return fmt.Errorf("no certificate found in %s", opt.Cert)
+	}
+
+	chain := certs[1:]
+	if opt.Chain != "" {
+		chain, err = utils.ReadCertificates(opt.Chain)
+		if err != nil {
+			return err
+		} else if len(chain) == 0 {
+			return fmt.Errorf("no certificate found in %s", opt.Chain)
+		}
+	}
+
+	icaCert := certs[0]
+	err = csr.CheckSignedCertificate(icaCert, key, csrInfo, chain)
+	if err != nil {
+		return err
+	}
+
+	subject, err := global.NewCertSubjectFromPkixName(icaCert.Subject)
+	if err != nil {
+		return err
+	}
+
+	dirPath, err := parseSaveOption(homeICA, "ICA-", subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = saveSignedICA(dirPath, icaCert, chain, key, password, csrInfo)
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(path.Join(homePending, opt.Pending))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandRevokeCert(opt *flagparser.CertRevokeOption) error {
+	target, issuer, _, err := revokeByOption(opt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, %s has been revoked by %s\n", target, issuer.String())
+	return nil
+}
+
+// revokeByOption 根据目录名、序列号或指纹吊销证书，返回被吊销证书的描述、签发CA和吊销记录
+func revokeByOption(opt *flagparser.CertRevokeOption) (string, *localCertificate, *revoke.RevokedCert, error) {
+	reason, err := revoke.ParseReason(opt.Reason)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	certType := strings.ToUpper(opt.CertType)
+	if certType != "CERT" && certType != "ICA" {
+		return "", nil, nil, fmt.Errorf("unknown cert type: %s", opt.CertType)
+	}
+
+	var serialNumber *big.Int
+	if opt.Serial != "" {
+		serialNumber, err = parseSerialNumber(opt.Serial)
+		if err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	fingerprint := revoke.NormalizeFingerprint(opt.Fingerprint)
+
+	if opt.Cert == "" && serialNumber == nil && fingerprint == "" {
+		return "", nil, nil, fmt.Errorf("one of -cert, -serial or -fingerprint must be set")
+	} else if opt.Cert != "" && !utils.IsValidFilename(opt.Cert) {
+		return "", nil, nil, fmt.Errorf("not a valid name: %s", opt.Cert)
+	}
+
+	var issuer *localCertificate
+	if opt.Issuer != "" {
+		issuer, err = loadLocalCA(opt.IssuerType, opt.Issuer)
+		if err != nil {
+			return "", nil, nil, err
+		}
+	}
+
+	targets, err := findLocalCertificate(certType, opt.Cert, serialNumber, fingerprint, issuer)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	if len(targets) > 1 {
+		return "", nil, nil, fmt.Errorf("more than one certificate matched, use -issuer or -fingerprint to select one")
+	} else if len(targets) == 1 {
+		issuer, record, err := revokeCertificate(targets[0], reason)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		return targets[0].String(), issuer, record, nil
+	}
+
+	// 证书不在MyCA中（例如文件已被删除），只能根据签发CA和序列号吊销
+	if issuer == nil || serialNumber == nil {
+		return "", nil, nil, fmt.Errorf("certificate not found, use -issuer and -serial to revoke a certificate which is not saved in MyCA")
+	}
+
+	lock, err := lockCA(issuer.DirPath())
+	if err != nil {
+		return "", nil, nil, err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	db, err := revoke.GetRevocationDB(revocationDBPath(issuer.DirPath()))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	record, err := db.RevokeSerialNumber(serialNumber, reason, time.Now())
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = db.SaveRevocationDB()
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	err = recordRevocation(issuer, record) // 例如已被续期并归档的旧证书
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return fmt.Sprintf("serial number %s", serialNumber.Text(16)), issuer, record, nil
+}
+
+func CommandShowRevoked(opt *flagparser.RevokeListOption) error {
+	if opt.Issuer == "" {
+		return fmt.Errorf("the issuer must be set")
+	}
+
+	ca, err := loadLocalCA(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	return showRevocationDB(ca)
+}
+
+func CommandCreateCRL(opt *flagparser.CRLCreateOption) error {
+	caCert, caKey, _, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	nextUpdate := utils.ReadTimeDuration(opt.NextUpdate)
+	if nextUpdate <= 0 {
+		return fmt.Errorf("not a valid next update interval: %s", opt.NextUpdate)
+	}
+
+	crlPath, number, err := generateCRL(caCert, caKey, caInfo, nextUpdate, opt.Delta)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, CRL number: %s, save path: %s\n", number.String(), crlPath)
+	return nil
+}
+
+func CommandCreateOCSPSigner(opt *flagparser.OCSPSignerOption) error {
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	cryptoType, keyLength, err := parseKeyOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(&opt.KeyOption)
+	if err != nil {
+		return err
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	dirPath, err := createOCSPSigner(caCert, caKey, caFullchain, caInfo, cryptoType, keyLength, existingKey, keyRandSource, validity, opt.Password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandServeOCSP(opt *flagparser.ServeOCSPOption) error {
+	nextUpdate := utils.ReadTimeDuration(opt.NextUpdate)
+	if nextUpdate <= 0 {
+		return fmt.Errorf("not a valid next update interval: %s", opt.NextUpdate)
+	}
+
+	passwords := make(map[string]string, len(opt.CAPassword))
+	for _, item := range opt.CAPassword.Value() {
+		name, password, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("not a valid CA password, expected <name>=<password>: %s", name)
+		} else if _, ok := passwords[name]; ok {
+			return fmt.Errorf("duplicate CA password: %s", name)
+		}
+		passwords[name] = password
+	}
+
+	served := make(map[string]bool, len(passwords))
+	issuers := make([]*ocspserver.Issuer, 0, len(opt.RCA)+len(opt.ICA))
+	for _, item := range []struct {
+		caType string
+		names  []string
+	}{{"RCA", opt.RCA.Value()}, {"ICA", opt.ICA.Value()}} {
+		for _, name := range item.names {
+			ca, err := loadLocalCA(item.caType, name)
+			if err != nil {
+				return err
+			}
+
+			password, ok := passwords[name]
+			if ok {
+				served[name] = true
+			} else {
+				password = opt.Password
+			}
+
+			issuer, err := loadOCSPIssuer(ca, func() string {
+				return password
+			})
+			if err != nil {
+				return fmt.Errorf("load %s failed: %s", ca.String(), err.Error())
+			}
+
+			issuers = append(issuers, issuer)
+			fmt.Printf("Serve %s, signed by: %s\n", ca.String(), issuer.Signer.Subject.String())
+		}
+	}
+
+	if len(issuers) == 0 {
+		return fmt.Errorf("at least one -rca or -ica must be set")
+	}
+
+	for name := range passwords {
+		if !served[name] {
+			return fmt.Errorf("the CA of the password is not served: %s", name)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           ocspserver.NewResponder(nextUpdate, issuers...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("OCSP responder listening on %s\n", opt.Listen)
+	return server.ListenAndServe()
+}
+
+func CommandRenewCert(opt *flagparser.CertRenewOption) error {
+	if opt.Name == "" {
+		return fmt.Errorf("the name must be set")
+	} else if !utils.IsValidFilename(opt.Name) {
+		return fmt.Errorf("not a valid name: %s", opt.Name)
+	}
+
+	target, err := loadLocalCertificate("CERT", opt.Name)
+	if err != nil {
+		return err
+	}
+
+	signatureAlgorithm, err := parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	var validity time.Duration
+	if opt.Validity != "" {
+		validity = utils.ReadTimeDuration(opt.Validity)
+		if validity <= 0 {
+			return fmt.Errorf("not a valid validity: %s", opt.Validity)
+		}
+	}
+
+	keyOption := &flagparser.KeyOption{
+		CryptoType:  opt.CryptoType,
+		KeyLength:   opt.KeyLength,
+		KeyFile:     opt.KeyFile,
+		KeyFrom:     opt.KeyFrom,
+		KeyPassword: opt.KeyPassword,
+	}
+
+	existingKey, keyRandSource, err := parseKeyFileOption(keyOption)
+	if err != nil {
+		return err
+	}
+
+	var cryptoType utils.CryptoType
+	var keyLength int
+	rekey := opt.Rekey || existingKey != nil || opt.CryptoType != "" || opt.KeyLength != 0
+	if rekey && existingKey == nil {
+		if keyOption.CryptoType == "" { // 默认与当前私钥的类型相同
+			currentType, currentLength, err := utils.GetPublicKeyCryptoType(target.Cert.PublicKey)
+			if err != nil {
+				return err
+			}
+
+			keyOption.CryptoType = string(currentType)
+			if keyOption.KeyLength == 0 {
+				keyOption.KeyLength = currentLength
+			}
+		}
+
+		cryptoType, keyLength, err = parseKeyOption(keyOption)
+		if err != nil {
+			return err
+		}
+	}
+
+	newPassword := opt.NewPassword
+	if newPassword == "" {
+		newPassword = opt.Password
+	}
+
+	historyPath, err := renewCertificate(target, &renewOption{
+		Rekey:              rekey,
+		CryptoType:         cryptoType,
+		KeyLength:          keyLength,
+		Key:                existingKey,
+		KeyRandSource:      keyRandSource,
+		Validity:           validity,
+		SignatureAlgorithm: signatureAlgorithm,
+		PasswordFunc: func() string {
+			return opt.Password
+		},
+		IssuerPasswordFunc: func() string {
+			return opt.IssuerPassword
+		},
+		NewPassword: newPassword,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", target.DirPath())
+	fmt.Println("The previous certificate has been archived to: ", historyPath)
+	return nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/url"
+)
+
+// checkNameConstraints 签发证书前检查备用名称是否满足签发者及其上级CA（来自CA的fullchain）的名称约束
+func checkNameConstraints(caCert *x509.Certificate, caFullchain []byte, domains []string, ips []net.IP, emails []string, urls []*url.URL) error {
+	chain := []*x509.Certificate{caCert}
+
+	if len(caFullchain) != 0 {
+		certs, err := utils.ParseCertificates(caFullchain)
+		if err != nil {
+			return fmt.Errorf("parse the fullchain of the CA failed: %s", err.Error())
+		}
+		chain = append(chain, certs...)
+	}
+
+	return utils.CheckNameConstraints(chain, domains, ips, emails, urls)
+}
+
+// confirmNameConstraints 交互模式下备用名称不满足名称约束时给出警告，由用户决定是否继续签发
+func confirmNameConstraints(caCert *x509.Certificate, caFullchain []byte, domains []string, ips []net.IP, emails []string, urls []*url.URL) bool {
+	err := checkNameConstraints(caCert, caFullchain, domains, ips, emails, urls)
+	if err == nil {
+		return true
+	}
+
+	fmt.Printf("Warning: %s\n", err.Error())
+	fmt.Printf("The certificate will be rejected by clients that check name constraints. Do you still want to issue it?")
+	return ReadBoolDefaultNoPrint()
+}
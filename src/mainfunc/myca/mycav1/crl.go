@@ -0,0 +1,109 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/crl"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"path"
+	"time"
+)
+
+func GenerateCRLFromRCA() {
+	rcaCert, rcaKey, _, rcaInfo, err := LoadRCA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	generateCRLInteractive(rcaCert, rcaKey, rcaInfo)
+}
+
+func GenerateCRLFromICA() {
+	icaCert, icaKey, _, icaInfo, err := LoadICA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	generateCRLInteractive(icaCert, icaKey, icaInfo)
+}
+
+func generateCRLInteractive(caCert *x509.Certificate, caKey crypto.PrivateKey, caInfo cert.CAInfo) {
+	fmt.Printf("Enter the next update interval of the CRL [default: 7d]: ")
+	nextUpdate := time.Hour * 24 * 7
+	if s := ReadString(); s != "" {
+		nextUpdate = utils.ReadTimeDuration(s)
+		if nextUpdate <= 0 {
+			fmt.Printf("Error: not a valid interval: %s\n", s)
+			return
+		}
+	}
+
+	fmt.Printf("Generate a delta CRL (only contains the certificates revoked after the last complete CRL)?")
+	delta := ReadBoolDefaultNoPrint()
+
+	crlPath, number, err := generateCRL(caCert, caKey, caInfo, nextUpdate, delta)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Success, CRL number: %s, save path: %s\n", number.String(), crlPath)
+}
+
+// generateCRL 生成CRL并保存到CA目录，CRL编号在写入CRL文件前保存，确保编号不会重复使用
+func generateCRL(caCert *x509.Certificate, caKey crypto.PrivateKey, caInfo cert.CAInfo, nextUpdate time.Duration, delta bool) (string, *big.Int, error) {
+	crlInfo, ok := caInfo.(crl.CAInfo)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown CA type")
+	}
+
+	dirPath, err := caInfoDirPath(caInfo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	db, err := revoke.GetRevocationDB(revocationDBPath(dirPath))
+	if err != nil {
+		return "", nil, err
+	}
+
+	thisUpdate := time.Now()
+
+	var revocationList *x509.RevocationList
+	var baseName string
+	if delta {
+		revocationList, err = crl.CreateDeltaCRL(crlInfo, db, caCert, caKey, thisUpdate, thisUpdate.Add(nextUpdate))
+		baseName = "delta-crl"
+	} else {
+		revocationList, err = crl.CreateCRL(crlInfo, db, caCert, caKey, thisUpdate, thisUpdate.Add(nextUpdate))
+		baseName = "crl"
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = saveCAInfo(caInfo)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pemPath := path.Join(dirPath, baseName+".pem")
+	derPath := path.Join(dirPath, baseName+".crl")
+
+	err = crl.SaveCRL(revocationList, pemPath, derPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return pemPath, revocationList.Number, nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path"
+	"time"
+)
+
+func SignCSRFromRCA() {
+	rcaCert, rcaKey, rcaFullchain, rcaInfo, err := LoadRCA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	signCSR(rcaCert, rcaKey, rcaFullchain, rcaInfo)
+}
+
+func SignCSRFromICA() {
+	icaCert, icaKey, icaFullchain, icaInfo, err := LoadICA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	signCSR(icaCert, icaKey, icaFullchain, icaInfo)
+}
+
+func signCSR(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo) {
+	fmt.Printf("Enter the path of the CSR file (PEM or DER): ")
+	csr, err := utils.ReadCertificateRequest(ReadString())
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	showCertificateRequest(csr)
+
+	fmt.Printf("Do you want to sign this certificate request?")
+	if !ReadBoolDefaultYesPrint() {
+		return
+	}
+
+	var subject *global.CertSubject
+	fmt.Printf("Do you accept the requested subject (%s)?", csr.Subject.String())
+	if ReadBoolDefaultYesPrint() {
+		subject, err = global.NewCertSubjectFromPkixName(csr.Subject)
+	} else {
+		subject, err = ReadSubject()
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	domains, ips, emails, urls := csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs
+	fmt.Printf("Do you accept the requested subject alternative names?")
+	if !ReadBoolDefaultYesPrint() {
+		domains, ips, emails, urls, err = readSubjectAltNames()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	keyUsage, ok, err := utils.ParseCSRKeyUsage(csr)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if ok {
+		fmt.Printf("Do you accept the requested key usage?")
+		ok = ReadBoolDefaultYesPrint()
+	}
+
+	if !ok {
+		keyUsage, err = ReadKeyUsage("auto_cert")
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	extKeyUsage, _, ok, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if ok {
+		fmt.Printf("Do you accept the requested ext key usage?")
+		ok = ReadBoolDefaultYesPrint()
+	}
+
+	if !ok {
+		extKeyUsage, err = ReadExtKeyUsage()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	fmt.Printf("Validity: ")
+	validity := ReadTimeDuration(time.Hour * 24 * 365 * 5)
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(validity)
+
+	if notAfter.After(caCert.NotAfter) {
+		fmt.Printf("The validity exceeds the CA (%s), do you want to shorten it to the expiration time of the CA?", caCert.NotAfter.Format(time.DateTime))
+		if !ReadBoolDefaultYesPrint() {
+			return
+		}
+		notAfter = caCert.NotAfter
+	}
+
+	err = cert.CheckIssuePolicy(caCert, csr.PublicKey, extKeyUsage, notBefore, notAfter)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if !confirmNameConstraints(caCert, caFullchain, domains, ips, emails, urls) {
+		return
+	}
+
+	policies, err := ReadCertificatePolicies(caCertificatePolicies(caInfo))
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	_, dirPath, err := ReadDir(homeCert, "CERT-", subject)
+	if err != nil {
+		fmt.Printf("Error: %s", err.Error())
+		return
+	}
+
+	infoPath := path.Join(dirPath, "cert-info.json")
+
+	if isCertificateExists(dirPath) {
+		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
+		if !ReadBoolDefaultNoPrint() {
+			return
+		}
+	} else {
+		fmt.Printf("Do you confirm to save the certificate?")
+		if !ReadBoolDefaultYesPrint() {
+			return
+		}
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	userCert, certInfo, err := cert.CreateCertFromCSR(infoPath, caInfo, csr, subject, keyUsage, extKeyUsage, domains, ips, emails, urls, policies, notBefore, notAfter, caCert, caKey, x509.UnknownSignatureAlgorithm)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = recordIssuance(caInfo, userCert, "CERT", dirPath)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = certInfo.SaveCertInfo()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = saveCertificateOnly(dirPath, userCert, caFullchain)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = saveCertificateRequest(dirPath, csr)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+}
+
+func readSubjectAltNames() ([]string, []net.IP, []string, []*url.URL, error) {
+	domains, err := ReadMoreStringWithPolicy("Enter your domain", func(s string) (string, error) {
+		if !utils.IsValidDomain(s) {
+			return "", NewWarning("not a valid domain")
+		}
+		return s, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	ips, err := ReadMoreStringWithPolicy("Enter your IPv4/IPv6", func(s string) (net.IP, error) {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, NewWarning("not a valid ip")
+		}
+		return ip, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	emails, err := ReadMoreStringWithPolicy("Enter your email", func(s string) (string, error) {
+		email, err := mail.ParseAddress(s)
+		if err != nil {
+			return "", NewWarningF("not a valid email (%s)", err.Error())
+		} else if !utils.IsValidEmail(email.Address) {
+			return "", NewWarningF("not a valid email (%s)", s)
+		}
+		return email.Address, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	urls, err := ReadMoreStringWithPolicy("Enter your URL", func(s string) (*url.URL, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, NewWarning("not a valid url")
+		}
+		return u, nil
+	})
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	return domains, ips, emails, urls, nil
+}
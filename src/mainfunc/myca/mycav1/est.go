@@ -0,0 +1,218 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/estserver"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// estIssuer 签发EST请求的证书，证书保存在 home/cert/EST-<CN>-<时间> 中
+type estIssuer struct {
+	*onlineIssuer
+	keyPassword string
+}
+
+func (i *estIssuer) enroll(req *estserver.EnrollRequest) (*x509.Certificate, error) {
+	csr := req.CSR
+
+	for _, domain := range csr.DNSNames {
+		if !utils.IsValidDomain(domain) {
+			return nil, fmt.Errorf("not a valid domain: %s", domain)
+		}
+	}
+
+	for _, email := range csr.EmailAddresses {
+		if !utils.IsValidEmail(email) {
+			return nil, fmt.Errorf("not a valid email: %s", email)
+		}
+	}
+
+	subject, err := global.NewCertSubjectFromPkixName(csr.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = subject.SetCNIfEmpty(csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs)
+	if err != nil {
+		return nil, err
+	}
+
+	extKeyUsage, _, _, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		return nil, err
+	}
+
+	userCert, _, err := i.issue(&onlineRequest{
+		DirName:     fmt.Sprintf("EST-%s-%s", subject.CN, time.Now().Format("20060102150405")),
+		CSR:         csr,
+		Subject:     subject,
+		Domains:     csr.DNSNames,
+		IPs:         csr.IPAddresses,
+		Emails:      csr.EmailAddresses,
+		URLs:        csr.URIs,
+		ExtKeyUsage: extKeyUsage,
+		Key:         req.Key,
+		KeyPassword: i.keyPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Old != nil {
+		err = i.supersede(req.Old, userCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return userCert, nil
+}
+
+// verifyClientCertificate 验证客户端证书由home中的RCA或ICA签发、可用于客户端认证并且未被吊销
+func verifyClientCertificate(chain []*x509.Certificate) error {
+	res := verifyCertificates(chain, &verifyRequest{
+		Purpose: "client",
+		Time:    time.Now(),
+	})
+	if len(res.Errors) != 0 {
+		return fmt.Errorf("%s", strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+// loadTLSCertificate 读取服务的证书（可以包含证书链）和私钥
+func loadTLSCertificate(certFile string, keyFile string, password string) (tls.Certificate, error) {
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certs, err := utils.ParseCertificates(certData)
+	if err != nil {
+		return tls.Certificate{}, err
+	} else if len(certs) == 0 {
+		return tls.Certificate{}, fmt.Errorf("no certificate found in %s", certFile)
+	}
+
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := utils.ParseAnyPrivateKey(keyData, func() string {
+		return password
+	})
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	res := tls.Certificate{
+		PrivateKey: key,
+		Leaf:       certs[0],
+	}
+	for _, c := range certs {
+		res.Certificate = append(res.Certificate, c.Raw)
+	}
+
+	return res, nil
+}
+
+// parseBasicAuthUsers 解析 name:password 格式的用户
+func parseBasicAuthUsers(users []string) (map[string]string, error) {
+	res := make(map[string]string, len(users))
+	for _, u := range users {
+		name, password, ok := strings.Cut(u, ":")
+		if !ok || name == "" || password == "" {
+			return nil, fmt.Errorf("not a valid user (format: name:password): %s", u)
+		} else if _, exists := res[name]; exists {
+			return nil, fmt.Errorf("duplicate user: %s", name)
+		}
+		res[name] = password
+	}
+	return res, nil
+}
+
+func CommandServeEST(opt *flagparser.ServeESTOption) error {
+	if opt.TLSCert == "" || opt.TLSKey == "" {
+		return fmt.Errorf("the tls-cert and tls-key must be set, EST must be served over HTTPS")
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	users, err := parseBasicAuthUsers(opt.User.Value())
+	if err != nil {
+		return err
+	}
+
+	tlsCert, err := loadTLSCertificate(opt.TLSCert, opt.TLSKey, opt.TLSKeyPassword)
+	if err != nil {
+		return err
+	}
+
+	extKeyUsage, err := parseAllowExtKeyUsageOption(opt.AllowExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	online, err := newOnlineIssuer(caCert, caKey, caFullchain, caInfo, validity, opt.Policy.Value())
+	if err != nil {
+		return err
+	}
+	online.setExtKeyUsage(extKeyUsage)
+
+	issuer := &estIssuer{
+		onlineIssuer: online,
+		keyPassword:  opt.KeyGenPassword,
+	}
+
+	caCerts, err := utils.ParseCertificates(caFullchain)
+	if err != nil {
+		return err
+	}
+
+	server, err := estserver.NewServer(&estserver.Config{
+		CACerts:          caCerts,
+		Users:            users,
+		VerifyClientCert: verifyClientCertificate,
+		Enroll:           issuer.enroll,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{tlsCert},
+			ClientAuth:   tls.RequestClientCert, // 客户端证书由 verifyClientCertificate 验证
+		},
+	}
+
+	fmt.Printf("Serve %s/%s, users: %d\n", strings.ToUpper(opt.IssuerType), opt.Issuer, len(users))
+	fmt.Printf("EST server listening on %s, url: https://%s/.well-known/est\n", opt.Listen, opt.Listen)
+
+	return httpServer.ListenAndServeTLS("", "")
+}
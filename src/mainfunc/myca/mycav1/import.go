@@ -0,0 +1,435 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// importCARequest 导入由其他工具（例如OpenSSL）创建的CA
+type importCARequest struct {
+	Cert               *x509.Certificate
+	Key                crypto.PrivateKey
+	Chain              []*x509.Certificate // 上级CA的证书链（仅ICA），上级CA由MyCA管理时不需要
+	OCSP               []string            // 为nil时使用证书中的地址
+	IssuingURL         []string            // 为nil时使用证书中的地址（仅RCA）
+	CRL                []string            // 为nil时使用证书中的地址
+	SignatureAlgorithm x509.SignatureAlgorithm
+	SerialMode         string
+	Password           string // 保存私钥时使用的密码
+}
+
+// readImportFiles 读取要导入的CA证书和私钥
+// pfxPath不为空时从PFX文件中读取证书、私钥和证书链；否则从certPath读取证书（可包含证书链和私钥，例如SPX文件），keyPath和chainPath可以为空
+func readImportFiles(certPath string, keyPath string, chainPath string, pfxPath string, passwordFunc func() string) (*x509.Certificate, crypto.PrivateKey, []*x509.Certificate, error) {
+	if pfxPath != "" {
+		data, err := os.ReadFile(pfxPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		key, c, chain, err := pkcs12.DecodeChain(data, passwordFunc())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return c, key, chain, nil
+	} else if certPath == "" {
+		return nil, nil, nil, fmt.Errorf("the certificate or PFX file must be set")
+	}
+
+	certs, err := utils.ReadCertificates(certPath)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if len(certs) == 0 {
+		return nil, nil, nil, fmt.Errorf("no certificate found in %s", certPath)
+	}
+
+	if keyPath == "" {
+		keyPath = certPath // 证书和私钥保存在同一个PEM文件中
+	}
+
+	key, err := readPrivateKey(keyPath, passwordFunc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read private key failed: %s", err.Error())
+	}
+
+	chain := certs[1:]
+	if chainPath != "" {
+		chain, err = utils.ReadCertificates(chainPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return certs[0], key, chain, nil
+}
+
+// checkImportCA 检查证书是CA证书，且私钥与证书匹配
+func checkImportCA(c *x509.Certificate, key crypto.PrivateKey, chain []*x509.Certificate) error {
+	err := utils.CheckKeyPair(c, key)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = utils.GetCryptoType(key)
+	if err != nil {
+		return err
+	}
+
+	if !c.BasicConstraintsValid || !c.IsCA {
+		return fmt.Errorf("the certificate is not a CA (basic constraints CA is not set)")
+	} else if c.KeyUsage != 0 && c.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return fmt.Errorf("the key usage of the certificate does not include KeyUsageCertSign")
+	}
+
+	if !isSelfSigned(c) && len(chain) != 0 {
+		err = c.CheckSignatureFrom(chain[0])
+		if err != nil {
+			return fmt.Errorf("the certificate is not issued by the first certificate of the chain: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// importCADir 返回导入的CA的保存位置：自签名证书作为RCA，其余作为ICA
+func importCADir(c *x509.Certificate) (certType string, basePath string, prefix string) {
+	if isSelfSigned(c) {
+		return "RCA", homeRCA, "RCA-"
+	}
+	return "ICA", homeICA, "ICA-"
+}
+
+// loadImportUpstream 返回导入的ICA的上级CA：上级CA由MyCA管理时返回其信息和证书链，否则返回外部CA
+func loadImportUpstream(c *x509.Certificate, chain []*x509.Certificate) (ica.UpstreamCAInfo, []byte, error) {
+	parent, err := findLocalIssuer(c)
+	if err != nil {
+		chainPEM := make([]byte, 0, 1024*len(chain))
+		for _, chainCert := range chain {
+			chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{
+				Type:  utils.PemTypeCertificate,
+				Bytes: chainCert.Raw,
+			})...)
+		}
+
+		return &ica.ExternalCAInfo{
+			IssuingCertificateURL: c.IssuingCertificateURL,
+		}, chainPEM, nil
+	}
+
+	fullchain, err := os.ReadFile(path.Join(parent.DirPath(), "fullchain.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var info cert.CAInfo
+	if parent.Type == "RCA" {
+		info, err = rootca.GetRCAInfo(path.Join(parent.DirPath(), "rca-info.json"))
+	} else {
+		info, err = ica.GetICAInfo(path.Join(parent.DirPath(), "ica-info.json"))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return info, fullchain, nil
+}
+
+func urlsOrDefault(urls []string, defaultURLs []string) []string {
+	if urls == nil {
+		return defaultURLs
+	}
+	return urls
+}
+
+// importCA 按照标准布局保存导入的CA，重新生成CA信息（序列号使用随机的初始值，见 utils.SeedSerialNumber）
+func importCA(dirPath string, req *importCARequest) error {
+	certType, _, _ := importCADir(req.Cert)
+
+	serialNumber, err := utils.SeedSerialNumber()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	ocspURLs := urlsOrDefault(req.OCSP, req.Cert.OCSPServer)
+	crlURLs := urlsOrDefault(req.CRL, req.Cert.CRLDistributionPoints)
+
+	if certType == "RCA" {
+		rcaInfo, err := rootca.NewRCAInfo(path.Join(dirPath, "rca-info.json"), ocspURLs, urlsOrDefault(req.IssuingURL, req.Cert.IssuingCertificateURL), crlURLs)
+		if err != nil {
+			return err
+		}
+		rcaInfo.SerialNumber = serialNumber
+		rcaInfo.SerialMode = req.SerialMode
+		rcaInfo.SignatureAlgorithm = req.SignatureAlgorithm
+		rcaInfo.RandSource = utils.RandSourceExternal
+		rcaInfo.NameConstraints = utils.NewNameConstraintsFromCertificate(req.Cert)
+
+		err = rcaInfo.SaveRCAInfo()
+		if err != nil {
+			return err
+		}
+
+		return saveCertificateAndKey(dirPath, req.Cert, req.Key, req.Password, []byte{})
+	}
+
+	upstream, caFullchain, err := loadImportUpstream(req.Cert, req.Chain)
+	if err != nil {
+		return err
+	}
+
+	// ICA证书中的签发者地址指向上级CA，ICA自己的地址只能由参数指定
+	icaInfo, err := ica.NewICAInfo(path.Join(dirPath, "ica-info.json"), upstream, ocspURLs, urlsOrDefault(req.IssuingURL, []string{}), crlURLs)
+	if err != nil {
+		return err
+	}
+	icaInfo.SerialNumber = serialNumber
+	icaInfo.SerialMode = req.SerialMode
+	icaInfo.SignatureAlgorithm = req.SignatureAlgorithm
+	icaInfo.RandSource = utils.RandSourceExternal
+	icaInfo.NameConstraints = utils.NewNameConstraintsFromCertificate(req.Cert)
+
+	if parentInfo, ok := upstream.(cert.CAInfo); ok {
+		err = recordIssuance(parentInfo, req.Cert, "ICA", dirPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = icaInfo.SaveICAInfo()
+	if err != nil {
+		return err
+	}
+
+	return saveCertificateAndKey(dirPath, req.Cert, req.Key, req.Password, caFullchain)
+}
+
+func showImportCA(c *x509.Certificate) {
+	certType, _, _ := importCADir(c)
+
+	fmt.Printf("The certificate subject: %s\n", c.Subject.String())
+	fmt.Printf("The certificate issuer: %s\n", c.Issuer.String())
+	fmt.Printf("The certificate validity: %s - %s\n", c.NotBefore.Local().Format(time.DateTime), c.NotAfter.Local().Format(time.DateTime))
+
+	if certType == "RCA" {
+		fmt.Println("The certificate is self signed, it will be imported as RCA.")
+	} else if parent, err := findLocalIssuer(c); err == nil {
+		fmt.Printf("The certificate is issued by %s, it will be imported as ICA.\n", parent.String())
+	} else {
+		fmt.Println("The certificate is issued by an external CA, it will be imported as ICA.")
+	}
+
+	if time.Now().After(c.NotAfter) {
+		fmt.Println("Warning: the certificate has expired.")
+	}
+}
+
+func ImportCA() {
+	passwordFunc := func() string {
+		fmt.Printf("Enter the password of the file or private key: ")
+		return ReadPassword()
+	}
+
+	var certPath, keyPath, chainPath, pfxPath string
+
+	fmt.Printf("Import from a PFX (PKCS#12) file?")
+	if ReadBoolDefaultNoPrint() {
+		fmt.Printf("Enter the path of the PFX file: ")
+		pfxPath = ReadString()
+	} else {
+		fmt.Printf("Enter the path of the CA certificate (PEM or DER, may include the chain and the private key): ")
+		certPath = ReadString()
+
+		fmt.Printf("Enter the path of the private key (PEM or DER, PKCS#8 / PKCS#1 / SEC1) [empty if included in the certificate file]: ")
+		keyPath = ReadString()
+	}
+
+	c, key, chain, err := readImportFiles(certPath, keyPath, "", pfxPath, passwordFunc)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	certType, basePath, prefix := importCADir(c)
+	if _, err := findLocalIssuer(c); certType == "ICA" && err != nil && len(chain) == 0 {
+		fmt.Printf("Enter the path of the upstream chain [empty if not needed]: ")
+		chainPath = ReadString()
+		if chainPath != "" {
+			chain, err = utils.ReadCertificates(chainPath)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
+	}
+
+	err = checkImportCA(c, key, chain)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	showImportCA(c)
+
+	req := &importCARequest{
+		Cert:  c,
+		Key:   key,
+		Chain: chain,
+	}
+
+	fmt.Printf("The URLs in the certificate: OCSP [%s], Issuing Certificate [%s], CRL [%s]\n", strings.Join(c.OCSPServer, ", "), strings.Join(c.IssuingCertificateURL, ", "), strings.Join(c.CRLDistributionPoints, ", "))
+	fmt.Printf("Use the URLs in the certificate for the certificates issued by this CA?")
+	if !ReadBoolDefaultYesPrint() {
+		req.OCSP = ReadURLs("Enter your OCSP Server URL")
+		req.IssuingURL = ReadURLs("Enter your Issuing Certificate URL")
+		req.CRL = ReadURLs("Enter your CRL Distribution Points (URL)")
+	}
+
+	cryptoType, _, err := utils.GetCryptoType(key)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	req.SignatureAlgorithm = ReadSignatureAlgorithm(cryptoType)
+	req.SerialMode = ReadSerialMode()
+
+	fmt.Printf("Set a password for private key: ")
+	req.Password = ReadPassword()
+
+	subject, err := global.NewCertSubjectFromPkixName(c.Subject)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	_, dirPath, err := ReadDir(basePath, prefix, subject)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if isCertificateExists(dirPath) {
+		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to import the %s?", certType)
+		if !ReadBoolDefaultNoPrint() {
+			return
+		}
+	} else {
+		fmt.Printf("Do you confirm to import the %s?", certType)
+		if !ReadBoolDefaultYesPrint() {
+			return
+		}
+	}
+
+	err = importCA(dirPath, req)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+}
+
+func CommandImportCA(opt *flagparser.CAImportOption) error {
+	passwordFunc := func() string {
+		return opt.KeyPassword
+	}
+
+	c, key, chain, err := readImportFiles(opt.Cert, opt.Key, opt.Chain, opt.PFX, passwordFunc)
+	if err != nil {
+		return err
+	}
+
+	err = checkImportCA(c, key, chain)
+	if err != nil {
+		return err
+	}
+
+	showImportCA(c)
+
+	req := &importCARequest{
+		Cert:  c,
+		Key:   key,
+		Chain: chain,
+	}
+
+	if len(opt.OCSP) != 0 {
+		req.OCSP, err = parseURLOption(opt.OCSP.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(opt.IssuingURL) != 0 {
+		req.IssuingURL, err = parseURLOption(opt.IssuingURL.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(opt.CRL) != 0 {
+		req.CRL, err = parseURLOption(opt.CRL.Value())
+		if err != nil {
+			return err
+		}
+	}
+
+	req.SignatureAlgorithm, err = parseSignatureOption(&opt.SignatureOption)
+	if err != nil {
+		return err
+	}
+
+	err = utils.CheckSignatureAlgorithm(req.SignatureAlgorithm, c.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	req.SerialMode, err = utils.ParseSerialMode(opt.SerialMode)
+	if err != nil {
+		return err
+	}
+
+	req.Password = opt.Password
+
+	subject, err := global.NewCertSubjectFromPkixName(c.Subject)
+	if err != nil {
+		return err
+	}
+
+	_, basePath, prefix := importCADir(c)
+	dirPath, err := parseSaveOption(basePath, prefix, subject, opt.Name, opt.Force)
+	if err != nil {
+		return err
+	}
+
+	err = importCA(dirPath, req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
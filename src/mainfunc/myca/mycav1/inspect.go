@@ -0,0 +1,962 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/crl"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	inspectFormatText = "text"
+	inspectFormatJSON = "json"
+)
+
+// extensionNameMap 常见扩展的名称，用于显示扩展列表
+var extensionNameMap = map[string]string{
+	"2.5.29.14":               "Subject Key Identifier",
+	"2.5.29.15":               "Key Usage",
+	"2.5.29.17":               "Subject Alternative Name",
+	"2.5.29.19":               "Basic Constraints",
+	"2.5.29.20":               "CRL Number",
+	"2.5.29.21":               "CRL Reason",
+	"2.5.29.27":               "Delta CRL Indicator",
+	"2.5.29.30":               "Name Constraints",
+	"2.5.29.31":               "CRL Distribution Points",
+	"2.5.29.32":               "Certificate Policies",
+	"2.5.29.33":               "Policy Mappings",
+	"2.5.29.35":               "Authority Key Identifier",
+	"2.5.29.36":               "Policy Constraints",
+	"2.5.29.37":               "Ext Key Usage",
+	"2.5.29.54":               "Inhibit Any Policy",
+	"1.3.6.1.5.5.7.1.1":       "Authority Information Access",
+	"1.3.6.1.5.5.7.48.1.2":    "OCSP Nonce",
+	"1.3.6.1.5.5.7.48.1.5":    "OCSP No Check",
+	"1.3.6.1.4.1.11129.2.4.2": "Signed Certificate Timestamps",
+}
+
+// inspectExtension 证书、CSR或CRL中的扩展
+type inspectExtension struct {
+	OID      string `json:"oid"`
+	Name     string `json:"name,omitempty"`
+	Critical bool   `json:"critical"`
+}
+
+type inspectPublicKey struct {
+	Algorithm string `json:"algorithm"`
+	Size      int    `json:"size"`
+}
+
+type inspectBasicConstraints struct {
+	IsCA       bool `json:"is_ca"`
+	MaxPathLen *int `json:"max_path_len,omitempty"` // 仅CA证书，-1表示没有限制
+}
+
+type inspectNameConstraints struct {
+	Critical            bool     `json:"critical"`
+	PermittedDNSDomains []string `json:"permitted_dns_domains,omitempty"`
+	ExcludedDNSDomains  []string `json:"excluded_dns_domains,omitempty"`
+	PermittedIPRanges   []string `json:"permitted_ip_ranges,omitempty"`
+	ExcludedIPRanges    []string `json:"excluded_ip_ranges,omitempty"`
+	PermittedEmails     []string `json:"permitted_emails,omitempty"`
+	ExcludedEmails      []string `json:"excluded_emails,omitempty"`
+	PermittedURIDomains []string `json:"permitted_uri_domains,omitempty"`
+	ExcludedURIDomains  []string `json:"excluded_uri_domains,omitempty"`
+}
+
+type inspectPolicy struct {
+	OID        string   `json:"oid"`
+	CPS        []string `json:"cps,omitempty"`
+	UserNotice []string `json:"user_notice,omitempty"`
+}
+
+type inspectSANs struct {
+	DNSNames       []string `json:"dns,omitempty"`
+	IPAddresses    []string `json:"ip,omitempty"`
+	EmailAddresses []string `json:"email,omitempty"`
+	URIs           []string `json:"uri,omitempty"`
+}
+
+func (s *inspectSANs) empty() bool {
+	return len(s.DNSNames)+len(s.IPAddresses)+len(s.EmailAddresses)+len(s.URIs) == 0
+}
+
+// inspectCertificate 证书的全部字段和扩展
+type inspectCertificate struct {
+	Version               int                      `json:"version"`
+	SerialNumber          string                   `json:"serial_number"`
+	SignatureAlgorithm    string                   `json:"signature_algorithm"`
+	Issuer                string                   `json:"issuer"`
+	Subject               string                   `json:"subject"`
+	NotBefore             time.Time                `json:"not_before"`
+	NotAfter              time.Time                `json:"not_after"`
+	DaysRemaining         int                      `json:"days_remaining"` // 已过期时为负数
+	PublicKey             inspectPublicKey         `json:"public_key"`
+	Fingerprint           string                   `json:"sha256_fingerprint"`
+	SelfSigned            bool                     `json:"self_signed"`
+	IssuedByNext          *bool                    `json:"issued_by_next,omitempty"` // 证书链中是否由下一个证书签发，最后一个证书为空
+	BasicConstraints      *inspectBasicConstraints `json:"basic_constraints,omitempty"`
+	KeyUsage              []string                 `json:"key_usage,omitempty"`
+	ExtKeyUsage           []string                 `json:"ext_key_usage,omitempty"`
+	SubjectKeyID          string                   `json:"subject_key_id,omitempty"`
+	AuthorityKeyID        string                   `json:"authority_key_id,omitempty"`
+	SANs                  *inspectSANs             `json:"sans,omitempty"`
+	OCSPServer            []string                 `json:"ocsp_server,omitempty"`
+	IssuingCertificateURL []string                 `json:"issuing_certificate_url,omitempty"`
+	CRLDistributionPoints []string                 `json:"crl_distribution_points,omitempty"`
+	NameConstraints       *inspectNameConstraints  `json:"name_constraints,omitempty"`
+	Policies              []*inspectPolicy         `json:"policies,omitempty"`
+	Extensions            []*inspectExtension      `json:"extensions"`
+}
+
+// inspectCSR 证书签名请求的全部字段和申请的扩展
+type inspectCSR struct {
+	Subject            string                   `json:"subject"`
+	SignatureAlgorithm string                   `json:"signature_algorithm"`
+	SignatureValid     bool                     `json:"signature_valid"`
+	PublicKey          inspectPublicKey         `json:"public_key"`
+	SANs               *inspectSANs             `json:"sans,omitempty"`
+	BasicConstraints   *inspectBasicConstraints `json:"basic_constraints,omitempty"`
+	KeyUsage           []string                 `json:"key_usage,omitempty"`
+	ExtKeyUsage        []string                 `json:"ext_key_usage,omitempty"`
+	Extensions         []*inspectExtension      `json:"extensions"`
+}
+
+type inspectRevokedCert struct {
+	SerialNumber   string    `json:"serial_number"`
+	RevocationTime time.Time `json:"revocation_time"`
+	Reason         string    `json:"reason,omitempty"`
+}
+
+// inspectCRL 证书吊销列表的全部字段
+type inspectCRL struct {
+	Issuer             string                `json:"issuer"`
+	Number             string                `json:"number,omitempty"`
+	BaseCRLNumber      string                `json:"base_crl_number,omitempty"` // 仅增量CRL
+	SignatureAlgorithm string                `json:"signature_algorithm"`
+	ThisUpdate         time.Time             `json:"this_update"`
+	NextUpdate         *time.Time            `json:"next_update,omitempty"`
+	AuthorityKeyID     string                `json:"authority_key_id,omitempty"`
+	Revoked            []*inspectRevokedCert `json:"revoked"`
+	Extensions         []*inspectExtension   `json:"extensions"`
+}
+
+type inspectPrivateKey struct {
+	Format    string            `json:"format"` // PKCS#8、PKCS#1或SEC1
+	Encrypted bool              `json:"encrypted"`
+	PublicKey *inspectPublicKey `json:"public_key,omitempty"` // 加密且未提供密码时为空
+	Matches   string            `json:"matches_certificate,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// inspectResult 一个文件中的全部内容
+type inspectResult struct {
+	File         string                `json:"file"`
+	Format       string                `json:"format"` // PEM、DER或PKCS#12
+	Certificates []*inspectCertificate `json:"certificates,omitempty"`
+	Requests     []*inspectCSR         `json:"requests,omitempty"`
+	CRLs         []*inspectCRL         `json:"crls,omitempty"`
+	PrivateKeys  []*inspectPrivateKey  `json:"private_keys,omitempty"`
+	Ignored      []string              `json:"ignored,omitempty"` // 无法识别的PEM块类型
+
+	certs []*x509.Certificate
+}
+
+func formatKeyID(id []byte) string {
+	if len(id) == 0 {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
+
+func inspectKeyUsage(keyUsage x509.KeyUsage) []string {
+	res := make([]string, 0, len(KeyUsageList))
+	for _, usage := range KeyUsageList {
+		if keyUsage&usage != 0 {
+			res = append(res, KeyUsageMap[usage])
+		}
+	}
+
+	if keyUsage&x509.KeyUsageEncipherOnly != 0 {
+		res = append(res, "KeyUsageEncipherOnly")
+	}
+
+	if keyUsage&x509.KeyUsageDecipherOnly != 0 {
+		res = append(res, "KeyUsageDecipherOnly")
+	}
+
+	return res
+}
+
+func inspectExtKeyUsage(extKeyUsage []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	res := make([]string, 0, len(extKeyUsage)+len(unknown))
+	for _, usage := range extKeyUsage {
+		if name, ok := ExtKeyUsageMap[usage]; ok {
+			res = append(res, name)
+		} else {
+			res = append(res, fmt.Sprintf("ExtKeyUsage(%d)", usage))
+		}
+	}
+
+	for _, oid := range unknown {
+		res = append(res, oid.String())
+	}
+
+	return res
+}
+
+func inspectExtensions(exts []pkix.Extension) []*inspectExtension {
+	res := make([]*inspectExtension, 0, len(exts))
+	for _, ext := range exts {
+		res = append(res, &inspectExtension{
+			OID:      ext.Id.String(),
+			Name:     extensionNameMap[ext.Id.String()],
+			Critical: ext.Critical,
+		})
+	}
+	return res
+}
+
+func isExtensionCritical(exts []pkix.Extension, oid string) bool {
+	for _, ext := range exts {
+		if ext.Id.String() == oid {
+			return ext.Critical
+		}
+	}
+	return false
+}
+
+func newInspectPublicKey(pubKey crypto.PublicKey) inspectPublicKey {
+	alg, size := publicKeyDetail(pubKey)
+	return inspectPublicKey{
+		Algorithm: alg,
+		Size:      size,
+	}
+}
+
+func newInspectSANs(dns []string, ips []string, emails []string, uris []string) *inspectSANs {
+	res := &inspectSANs{
+		DNSNames:       dns,
+		IPAddresses:    ips,
+		EmailAddresses: emails,
+		URIs:           uris,
+	}
+
+	if res.empty() {
+		return nil
+	}
+	return res
+}
+
+func newInspectCertificate(c *x509.Certificate, now time.Time) *inspectCertificate {
+	res := &inspectCertificate{
+		Version:               c.Version,
+		SerialNumber:          c.SerialNumber.Text(16),
+		SignatureAlgorithm:    c.SignatureAlgorithm.String(),
+		Issuer:                c.Issuer.String(),
+		Subject:               c.Subject.String(),
+		NotBefore:             c.NotBefore,
+		NotAfter:              c.NotAfter,
+		DaysRemaining:         int(c.NotAfter.Sub(now).Hours() / 24),
+		PublicKey:             newInspectPublicKey(c.PublicKey),
+		Fingerprint:           revoke.Fingerprint(c),
+		SelfSigned:            isSelfSigned(c),
+		SubjectKeyID:          formatKeyID(c.SubjectKeyId),
+		AuthorityKeyID:        formatKeyID(c.AuthorityKeyId),
+		OCSPServer:            c.OCSPServer,
+		IssuingCertificateURL: c.IssuingCertificateURL,
+		CRLDistributionPoints: c.CRLDistributionPoints,
+		Extensions:            inspectExtensions(c.Extensions),
+	}
+
+	if c.BasicConstraintsValid {
+		res.BasicConstraints = &inspectBasicConstraints{
+			IsCA: c.IsCA,
+		}
+
+		if c.IsCA {
+			maxPathLen := c.MaxPathLen
+			if maxPathLen == 0 && !c.MaxPathLenZero {
+				maxPathLen = -1
+			}
+			res.BasicConstraints.MaxPathLen = &maxPathLen
+		}
+	}
+
+	if c.KeyUsage != 0 {
+		res.KeyUsage = inspectKeyUsage(c.KeyUsage)
+	}
+
+	if len(c.ExtKeyUsage)+len(c.UnknownExtKeyUsage) != 0 {
+		res.ExtKeyUsage = inspectExtKeyUsage(c.ExtKeyUsage, c.UnknownExtKeyUsage)
+	}
+
+	ips := make([]string, 0, len(c.IPAddresses))
+	for _, ip := range c.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	uris := make([]string, 0, len(c.URIs))
+	for _, u := range c.URIs {
+		uris = append(uris, u.String())
+	}
+
+	res.SANs = newInspectSANs(c.DNSNames, ips, c.EmailAddresses, uris)
+
+	if isExtensionCritical(c.Extensions, "2.5.29.30") || len(c.PermittedDNSDomains)+len(c.ExcludedDNSDomains)+len(c.PermittedIPRanges)+len(c.ExcludedIPRanges)+
+		len(c.PermittedEmailAddresses)+len(c.ExcludedEmailAddresses)+len(c.PermittedURIDomains)+len(c.ExcludedURIDomains) != 0 {
+		nc := &inspectNameConstraints{
+			Critical:            c.PermittedDNSDomainsCritical,
+			PermittedDNSDomains: c.PermittedDNSDomains,
+			ExcludedDNSDomains:  c.ExcludedDNSDomains,
+			PermittedEmails:     c.PermittedEmailAddresses,
+			ExcludedEmails:      c.ExcludedEmailAddresses,
+			PermittedURIDomains: c.PermittedURIDomains,
+			ExcludedURIDomains:  c.ExcludedURIDomains,
+		}
+
+		for _, n := range c.PermittedIPRanges {
+			nc.PermittedIPRanges = append(nc.PermittedIPRanges, n.String())
+		}
+
+		for _, n := range c.ExcludedIPRanges {
+			nc.ExcludedIPRanges = append(nc.ExcludedIPRanges, n.String())
+		}
+
+		res.NameConstraints = nc
+	}
+
+	for _, ext := range c.Extensions {
+		if !ext.Id.Equal(utils.OIDExtensionCertificatePolicies) {
+			continue
+		}
+
+		policies, err := utils.ParseCertificatePolicies(ext.Value)
+		if err != nil {
+			for _, oid := range c.PolicyIdentifiers {
+				res.Policies = append(res.Policies, &inspectPolicy{OID: oid.String()})
+			}
+			break
+		}
+
+		for _, p := range policies {
+			res.Policies = append(res.Policies, &inspectPolicy{
+				OID:        p.Policy.String(),
+				CPS:        p.CPS,
+				UserNotice: p.UserNotice,
+			})
+		}
+	}
+
+	return res
+}
+
+func newInspectCSR(csr *x509.CertificateRequest) *inspectCSR {
+	res := &inspectCSR{
+		Subject:            csr.Subject.String(),
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		SignatureValid:     csr.CheckSignature() == nil,
+		PublicKey:          newInspectPublicKey(csr.PublicKey),
+		Extensions:         inspectExtensions(csr.Extensions),
+	}
+
+	ips := make([]string, 0, len(csr.IPAddresses))
+	for _, ip := range csr.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+
+	uris := make([]string, 0, len(csr.URIs))
+	for _, u := range csr.URIs {
+		uris = append(uris, u.String())
+	}
+
+	res.SANs = newInspectSANs(csr.DNSNames, ips, csr.EmailAddresses, uris)
+
+	if keyUsage, ok, err := utils.ParseCSRKeyUsage(csr); err == nil && ok {
+		res.KeyUsage = inspectKeyUsage(keyUsage)
+	}
+
+	if extKeyUsage, unknown, ok, err := utils.ParseCSRExtKeyUsage(csr); err == nil && ok {
+		res.ExtKeyUsage = inspectExtKeyUsage(extKeyUsage, unknown)
+	}
+
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(utils.OIDExtensionBasicConstraints) {
+			continue
+		}
+
+		var bc struct {
+			IsCA       bool `asn1:"optional"`
+			MaxPathLen int  `asn1:"optional,default:-1"`
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &bc); err == nil {
+			res.BasicConstraints = &inspectBasicConstraints{
+				IsCA: bc.IsCA,
+			}
+
+			if bc.IsCA {
+				res.BasicConstraints.MaxPathLen = &bc.MaxPathLen
+			}
+		}
+	}
+
+	return res
+}
+
+func newInspectCRL(revocationList *x509.RevocationList) *inspectCRL {
+	res := &inspectCRL{
+		Issuer:             revocationList.Issuer.String(),
+		SignatureAlgorithm: revocationList.SignatureAlgorithm.String(),
+		ThisUpdate:         revocationList.ThisUpdate,
+		AuthorityKeyID:     formatKeyID(revocationList.AuthorityKeyId),
+		Revoked:            make([]*inspectRevokedCert, 0, len(revocationList.RevokedCertificateEntries)),
+		Extensions:         inspectExtensions(revocationList.Extensions),
+	}
+
+	if revocationList.Number != nil {
+		res.Number = revocationList.Number.Text(16)
+	}
+
+	if !revocationList.NextUpdate.IsZero() {
+		nextUpdate := revocationList.NextUpdate
+		res.NextUpdate = &nextUpdate
+	}
+
+	for _, ext := range revocationList.Extensions {
+		if !ext.Id.Equal(crl.OIDExtensionDeltaCRLIndicator) {
+			continue
+		}
+
+		var base *big.Int
+		if _, err := asn1.Unmarshal(ext.Value, &base); err == nil {
+			res.BaseCRLNumber = base.Text(16)
+		}
+	}
+
+	for _, r := range revocationList.RevokedCertificateEntries {
+		entry := &inspectRevokedCert{
+			SerialNumber:   r.SerialNumber.Text(16),
+			RevocationTime: r.RevocationTime,
+		}
+
+		if r.ReasonCode != 0 {
+			entry.Reason = revoke.Reason(r.ReasonCode).String()
+		}
+
+		res.Revoked = append(res.Revoked, entry)
+	}
+
+	return res
+}
+
+// newInspectPrivateKey 读取私钥的类型，加密的PKCS#8私钥在提供密码时解密
+func newInspectPrivateKey(block *pem.Block, passwordFunc func() string, certs []*x509.Certificate) *inspectPrivateKey {
+	res := &inspectPrivateKey{}
+
+	var key crypto.PrivateKey
+	var err error
+
+	switch {
+	case block.Type == utils.PemTypePrivateKeyWithPassword:
+		res.Format = "PKCS#8"
+		res.Encrypted = true
+
+		password := ""
+		if passwordFunc != nil {
+			password = passwordFunc()
+		}
+
+		if password == "" {
+			return res
+		}
+		key, _, err = utils.ParserPrivateKey(block.Bytes, password)
+	case block.Type == utils.PemTypePrivateKeyNotPassword:
+		res.Format = "PKCS#8"
+		key, _, err = utils.ParserPrivateKey(block.Bytes)
+	case block.Headers["Proc-Type"] != "":
+		res.Format = strings.TrimSuffix(block.Type, " PRIVATE KEY") // 旧式的PEM加密（RFC 1423）
+		res.Encrypted = true
+		return res
+	case block.Type == utils.PemTypeRSAPrivateKey:
+		res.Format = "PKCS#1"
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case block.Type == utils.PemTypeECPrivateKey:
+		res.Format = "SEC1"
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		res.Format = block.Type
+		return res
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		res.Error = "unknown private key type"
+		return res
+	}
+
+	pubKey := newInspectPublicKey(signer.Public())
+	res.PublicKey = &pubKey
+
+	if len(certs) != 0 {
+		res.Matches = "none"
+		for i, c := range certs {
+			if utils.CheckKeyPair(c, key) == nil {
+				res.Matches = fmt.Sprintf("certificate %d", i+1)
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+func (r *inspectResult) addCertificates(certs []*x509.Certificate) {
+	now := time.Now()
+	for _, c := range certs {
+		r.Certificates = append(r.Certificates, newInspectCertificate(c, now))
+		r.certs = append(r.certs, c)
+	}
+}
+
+// linkChain 标记证书链中的每个证书是否由其后一个证书签发
+func (r *inspectResult) linkChain() {
+	for i := 0; i+1 < len(r.certs); i++ {
+		issued := bytes.Equal(r.certs[i].RawIssuer, r.certs[i+1].RawSubject) && r.certs[i].CheckSignatureFrom(r.certs[i+1]) == nil
+		r.Certificates[i].IssuedByNext = &issued
+	}
+}
+
+// parseInspectPEM 解析PEM文件中的全部块（证书链、CSR、CRL以及SPX中的私钥）
+func parseInspectPEM(res *inspectResult, data []byte, passwordFunc func() string) error {
+	keyBlocks := make([]*pem.Block, 0, 1)
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case utils.PemTypeCertificate:
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return err
+			}
+			res.addCertificates([]*x509.Certificate{c})
+		case utils.PemTypeCertificateRequest, utils.PemTypeNewCertificateRequest:
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil {
+				return err
+			}
+			res.Requests = append(res.Requests, newInspectCSR(csr))
+		case crl.PemTypeX509CRL:
+			revocationList, err := x509.ParseRevocationList(block.Bytes)
+			if err != nil {
+				return err
+			}
+			res.CRLs = append(res.CRLs, newInspectCRL(revocationList))
+		default:
+			if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+				keyBlocks = append(keyBlocks, block) // 私钥在证书之后处理，以便检查私钥与证书是否匹配
+			} else {
+				res.Ignored = append(res.Ignored, block.Type)
+			}
+		}
+	}
+
+	for _, block := range keyBlocks {
+		res.PrivateKeys = append(res.PrivateKeys, newInspectPrivateKey(block, passwordFunc, res.certs))
+	}
+
+	return nil
+}
+
+// parseInspectPKCS12 解析PFX文件，密码错误时通过passwordFunc重新读取密码
+func parseInspectPKCS12(res *inspectResult, data []byte, password string, passwordFunc func() string) error {
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) && passwordFunc != nil {
+		password = passwordFunc()
+		key, leaf, chain, err = pkcs12.DecodeChain(data, password)
+	}
+
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return err
+	} else if err != nil {
+		certs, trustErr := pkcs12.DecodeTrustStore(data, password) // 只包含证书的PFX（信任库）
+		if trustErr != nil {
+			return err
+		}
+
+		res.addCertificates(certs)
+		return nil
+	}
+
+	res.addCertificates(append([]*x509.Certificate{leaf}, chain...))
+
+	if signer, ok := key.(crypto.Signer); ok {
+		pubKey := newInspectPublicKey(signer.Public())
+		pk := &inspectPrivateKey{
+			Format:    "PKCS#12",
+			Encrypted: true,
+			PublicKey: &pubKey,
+			Matches:   "none",
+		}
+
+		if utils.CheckKeyPair(leaf, key) == nil {
+			pk.Matches = "certificate 1"
+		}
+
+		res.PrivateKeys = append(res.PrivateKeys, pk)
+	}
+
+	return nil
+}
+
+// inspectFile 读取并解析证书、证书链、CSR、CRL、PFX或SPX文件（PEM或DER）
+// password为PFX和加密私钥的密码，passwordFunc在需要密码而password为空（或错误）时调用，可以为nil
+func inspectFile(filePath string, password string, passwordFunc func() string) (*inspectResult, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &inspectResult{
+		File: filePath,
+	}
+
+	keyPasswordFunc := func() string {
+		if password == "" && passwordFunc != nil {
+			password = passwordFunc()
+		}
+		return password
+	}
+
+	if block, _ := pem.Decode(data); block != nil {
+		res.Format = "PEM"
+		err = parseInspectPEM(res, data, keyPasswordFunc)
+		if err != nil {
+			return nil, err
+		}
+	} else if certs, err := x509.ParseCertificates(data); err == nil && len(certs) != 0 {
+		res.Format = "DER"
+		res.addCertificates(certs)
+	} else if csr, err := x509.ParseCertificateRequest(data); err == nil {
+		res.Format = "DER"
+		res.Requests = append(res.Requests, newInspectCSR(csr))
+	} else if revocationList, err := x509.ParseRevocationList(data); err == nil {
+		res.Format = "DER"
+		res.CRLs = append(res.CRLs, newInspectCRL(revocationList))
+	} else {
+		res.Format = "PKCS#12"
+		err = parseInspectPKCS12(res, data, password, passwordFunc)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			return nil, err
+		} else if err != nil {
+			return nil, fmt.Errorf("not a certificate, CSR, CRL or PFX file")
+		}
+	}
+
+	if len(res.Certificates)+len(res.Requests)+len(res.CRLs)+len(res.PrivateKeys) == 0 {
+		return nil, fmt.Errorf("no certificate, CSR, CRL or private key found")
+	}
+
+	res.linkChain()
+	return res, nil
+}
+
+func printInspectList(indent string, title string, list []string) {
+	if len(list) != 0 {
+		fmt.Printf("%s%s: %s\n", indent, title, strings.Join(list, ", "))
+	}
+}
+
+func criticalString(critical bool) string {
+	if critical {
+		return " (critical)"
+	}
+	return ""
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func printInspectSANs(indent string, sans *inspectSANs, critical bool) {
+	if sans == nil {
+		return
+	}
+
+	names := make([]string, 0, 10)
+	for _, d := range sans.DNSNames {
+		names = append(names, "DNS:"+d)
+	}
+	for _, ip := range sans.IPAddresses {
+		names = append(names, "IP:"+ip)
+	}
+	for _, e := range sans.EmailAddresses {
+		names = append(names, "Email:"+e)
+	}
+	for _, u := range sans.URIs {
+		names = append(names, "URI:"+u)
+	}
+
+	fmt.Printf("%sSubject Alternative Name%s: %s\n", indent, criticalString(critical), strings.Join(names, ", "))
+}
+
+func printInspectBasicConstraints(indent string, bc *inspectBasicConstraints, critical bool) {
+	if bc == nil {
+		return
+	}
+
+	pathLen := "-"
+	if bc.MaxPathLen != nil && *bc.MaxPathLen < 0 {
+		pathLen = "unlimited"
+	} else if bc.MaxPathLen != nil {
+		pathLen = strconv.Itoa(*bc.MaxPathLen)
+	}
+
+	fmt.Printf("%sBasic Constraints%s: CA: %s, Path Length: %s\n", indent, criticalString(critical), yesNo(bc.IsCA), pathLen)
+}
+
+func printInspectExtensions(indent string, exts []*inspectExtension) {
+	fmt.Printf("%sExtensions (%d):\n", indent, len(exts))
+	for _, ext := range exts {
+		name := ext.Name
+		if name == "" {
+			name = "Unknown"
+		}
+		fmt.Printf("%s  %s %s%s\n", indent, ext.OID, name, criticalString(ext.Critical))
+	}
+}
+
+func (c *inspectCertificate) print(index int, total int, exts map[string]bool) {
+	fmt.Printf("Certificate %d of %d:\n", index, total)
+	fmt.Printf("  Version: %d\n", c.Version)
+	fmt.Printf("  Serial Number: %s\n", c.SerialNumber)
+	fmt.Printf("  Signature Algorithm: %s\n", c.SignatureAlgorithm)
+	fmt.Printf("  Issuer: %s\n", c.Issuer)
+	fmt.Printf("  Subject: %s\n", c.Subject)
+	fmt.Printf("  Not Before: %s\n", c.NotBefore.Local().Format(time.DateTime))
+	if c.DaysRemaining < 0 || time.Now().After(c.NotAfter) {
+		fmt.Printf("  Not After: %s (expired)\n", c.NotAfter.Local().Format(time.DateTime))
+	} else {
+		fmt.Printf("  Not After: %s (%d days remaining)\n", c.NotAfter.Local().Format(time.DateTime), c.DaysRemaining)
+	}
+	fmt.Printf("  Public Key: %s (%d bit)\n", c.PublicKey.Algorithm, c.PublicKey.Size)
+	fmt.Printf("  SHA-256 Fingerprint: %s\n", c.Fingerprint)
+	fmt.Printf("  Self Signed: %s\n", yesNo(c.SelfSigned))
+	if c.IssuedByNext != nil {
+		fmt.Printf("  Issued By Next Certificate: %s\n", yesNo(*c.IssuedByNext))
+	}
+
+	printInspectBasicConstraints("  ", c.BasicConstraints, exts["2.5.29.19"])
+	if len(c.KeyUsage) != 0 {
+		fmt.Printf("  Key Usage%s: %s\n", criticalString(exts["2.5.29.15"]), strings.Join(c.KeyUsage, ", "))
+	}
+	if len(c.ExtKeyUsage) != 0 {
+		fmt.Printf("  Ext Key Usage%s: %s\n", criticalString(exts["2.5.29.37"]), strings.Join(c.ExtKeyUsage, ", "))
+	}
+	if c.SubjectKeyID != "" {
+		fmt.Printf("  Subject Key Identifier: %s\n", c.SubjectKeyID)
+	}
+	if c.AuthorityKeyID != "" {
+		fmt.Printf("  Authority Key Identifier: %s\n", c.AuthorityKeyID)
+	}
+	printInspectSANs("  ", c.SANs, exts["2.5.29.17"])
+	if len(c.OCSPServer)+len(c.IssuingCertificateURL) != 0 {
+		fmt.Println("  Authority Information Access:")
+		printInspectList("    ", "OCSP", c.OCSPServer)
+		printInspectList("    ", "CA Issuers", c.IssuingCertificateURL)
+	}
+	printInspectList("  ", "CRL Distribution Points", c.CRLDistributionPoints)
+
+	if nc := c.NameConstraints; nc != nil {
+		fmt.Printf("  Name Constraints%s:\n", criticalString(nc.Critical))
+		printInspectList("    ", "Permitted DNS", nc.PermittedDNSDomains)
+		printInspectList("    ", "Excluded DNS", nc.ExcludedDNSDomains)
+		printInspectList("    ", "Permitted IP", nc.PermittedIPRanges)
+		printInspectList("    ", "Excluded IP", nc.ExcludedIPRanges)
+		printInspectList("    ", "Permitted Email", nc.PermittedEmails)
+		printInspectList("    ", "Excluded Email", nc.ExcludedEmails)
+		printInspectList("    ", "Permitted URI", nc.PermittedURIDomains)
+		printInspectList("    ", "Excluded URI", nc.ExcludedURIDomains)
+	}
+
+	if len(c.Policies) != 0 {
+		fmt.Printf("  Certificate Policies%s:\n", criticalString(exts["2.5.29.32"]))
+		for _, p := range c.Policies {
+			fmt.Printf("    Policy: %s\n", p.OID)
+			for _, cps := range p.CPS {
+				fmt.Printf("      CPS: %s\n", cps)
+			}
+			for _, notice := range p.UserNotice {
+				fmt.Printf("      User Notice: %s\n", notice)
+			}
+		}
+	}
+
+	printInspectExtensions("  ", c.Extensions)
+}
+
+func (r *inspectCSR) print(index int, total int) {
+	fmt.Printf("Certificate Request %d of %d:\n", index, total)
+	fmt.Printf("  Subject: %s\n", r.Subject)
+	fmt.Printf("  Signature Algorithm: %s\n", r.SignatureAlgorithm)
+	fmt.Printf("  Signature Valid: %s\n", yesNo(r.SignatureValid))
+	fmt.Printf("  Public Key: %s (%d bit)\n", r.PublicKey.Algorithm, r.PublicKey.Size)
+	printInspectSANs("  ", r.SANs, false)
+	printInspectBasicConstraints("  ", r.BasicConstraints, false)
+	printInspectList("  ", "Key Usage", r.KeyUsage)
+	printInspectList("  ", "Ext Key Usage", r.ExtKeyUsage)
+	printInspectExtensions("  ", r.Extensions)
+}
+
+func (c *inspectCRL) print(index int, total int) {
+	fmt.Printf("CRL %d of %d:\n", index, total)
+	fmt.Printf("  Issuer: %s\n", c.Issuer)
+	if c.Number != "" {
+		fmt.Printf("  CRL Number: %s\n", c.Number)
+	}
+	if c.BaseCRLNumber != "" {
+		fmt.Printf("  Delta CRL Of: %s\n", c.BaseCRLNumber)
+	}
+	fmt.Printf("  Signature Algorithm: %s\n", c.SignatureAlgorithm)
+	fmt.Printf("  This Update: %s\n", c.ThisUpdate.Local().Format(time.DateTime))
+	if c.NextUpdate != nil {
+		fmt.Printf("  Next Update: %s\n", c.NextUpdate.Local().Format(time.DateTime))
+	}
+	if c.AuthorityKeyID != "" {
+		fmt.Printf("  Authority Key Identifier: %s\n", c.AuthorityKeyID)
+	}
+	fmt.Printf("  Revoked Certificates (%d):\n", len(c.Revoked))
+	for _, r := range c.Revoked {
+		if r.Reason != "" {
+			fmt.Printf("    %s revoked at %s (%s)\n", r.SerialNumber, r.RevocationTime.Local().Format(time.DateTime), r.Reason)
+		} else {
+			fmt.Printf("    %s revoked at %s\n", r.SerialNumber, r.RevocationTime.Local().Format(time.DateTime))
+		}
+	}
+	printInspectExtensions("  ", c.Extensions)
+}
+
+func (k *inspectPrivateKey) print(index int, total int) {
+	fmt.Printf("Private Key %d of %d:\n", index, total)
+	fmt.Printf("  Format: %s\n", k.Format)
+	fmt.Printf("  Encrypted: %s\n", yesNo(k.Encrypted))
+	if k.PublicKey != nil {
+		fmt.Printf("  Public Key: %s (%d bit)\n", k.PublicKey.Algorithm, k.PublicKey.Size)
+	} else if k.Encrypted && k.Error == "" {
+		fmt.Println("  Public Key: unknown (password required)")
+	}
+	if k.Matches != "" {
+		fmt.Printf("  Matches: %s\n", k.Matches)
+	}
+	if k.Error != "" {
+		fmt.Printf("  Error: %s\n", k.Error)
+	}
+}
+
+func printInspectResult(res *inspectResult) {
+	fmt.Printf("File: %s (%s)\n", res.File, res.Format)
+
+	for i, c := range res.Certificates {
+		exts := make(map[string]bool, len(c.Extensions))
+		for _, ext := range c.Extensions {
+			exts[ext.OID] = ext.Critical
+		}
+		c.print(i+1, len(res.Certificates), exts)
+	}
+
+	for i, r := range res.Requests {
+		r.print(i+1, len(res.Requests))
+	}
+
+	for i, c := range res.CRLs {
+		c.print(i+1, len(res.CRLs))
+	}
+
+	for i, k := range res.PrivateKeys {
+		k.print(i+1, len(res.PrivateKeys))
+	}
+
+	if len(res.Ignored) != 0 {
+		fmt.Printf("Ignored PEM blocks: %s\n", strings.Join(res.Ignored, ", "))
+	}
+}
+
+func InspectFile() {
+	fmt.Printf("File path (certificate, chain, CSR, CRL, PFX or SPX): ")
+	filePath := ReadString()
+	if filePath == "" {
+		fmt.Println("Error: file path is empty")
+		return
+	}
+
+	res, err := inspectFile(filePath, "", func() string {
+		fmt.Printf("Enter the password of the file or private key (leave empty to skip): ")
+		return ReadPassword()
+	})
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	printInspectResult(res)
+}
+
+func CommandInspect(opt *flagparser.InspectOption) error {
+	if opt.File == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	res, err := inspectFile(opt.File, opt.Password, nil)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(opt.Format) {
+	case inspectFormatText:
+		printInspectResult(res)
+		return nil
+	case inspectFormatJSON:
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	default:
+		return fmt.Errorf("unknown format: %s", opt.Format)
+	}
+}
@@ -0,0 +1,402 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/issuance"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func issuanceIndexPath(caDirPath string) string {
+	return path.Join(caDirPath, issuance.IndexFileName)
+}
+
+// caLockFileName CA目录中的锁文件，签发索引和吊销数据库的读-改-写需要持有该锁，
+// 使得同时运行的多个服务（acme、est、scep、cmp、api）和命令行不会互相覆盖对方的修改
+const caLockFileName = "ca.lock"
+
+// lockCA 获取CA的锁，同一进程中也不能重复获取（会阻塞），因此持有锁时只能调用不加锁的函数
+func lockCA(caDirPath string) (*utils.FileLock, error) {
+	return utils.LockFile(path.Join(caDirPath, caLockFileName))
+}
+
+// homeRelativePath 返回相对于home目录的路径，使得home目录被移动后索引仍然有效
+func homeRelativePath(dirPath string) string {
+	rel, err := filepath.Rel(home, dirPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return dirPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// caInfoLocalCertificate 返回CA信息对应的CA证书
+func caInfoLocalCertificate(caInfo cert.CAInfo) (*localCertificate, error) {
+	caDirPath, err := caInfoDirPath(caInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	switch caInfo.(type) {
+	case *rootca.RCAInfo:
+		return loadLocalCertificate("RCA", path.Base(caDirPath))
+	case *ica.ICAInfo:
+		return loadLocalCertificate("ICA", path.Base(caDirPath))
+	default:
+		return nil, fmt.Errorf("unknown CA type")
+	}
+}
+
+// loadIssuanceIndex 读取CA的签发索引，索引不存在时（旧版本创建的CA）根据home目录中已有的证书和吊销记录重建
+func loadIssuanceIndex(ca *localCertificate) (*issuance.Index, error) {
+	indexPath := issuanceIndexPath(ca.DirPath())
+	if utils.IsExists(indexPath) || metadata.NeedMigrate(indexPath) {
+		return issuance.GetIndex(indexPath)
+	}
+
+	idx, err := rebuildIssuanceIndex(ca)
+	if err != nil {
+		return nil, err
+	}
+
+	err = idx.SaveIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+func isIssuedBy(c *x509.Certificate, ca *x509.Certificate) bool {
+	return !bytes.Equal(c.Raw, ca.Raw) && bytes.Equal(c.RawIssuer, ca.RawSubject) && c.CheckSignatureFrom(ca) == nil
+}
+
+func rebuildIssuanceIndex(ca *localCertificate) (*issuance.Index, error) {
+	idx, err := issuance.NewIndex(issuanceIndexPath(ca.DirPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(c *x509.Certificate, certType string, dirPath string) *issuance.IssuedCert {
+		if !isIssuedBy(c, ca.Cert) {
+			return nil
+		}
+
+		res, err := idx.Add(c, certType, homeRelativePath(dirPath), c.NotBefore)
+		if err != nil {
+			return nil // 重复的证书（例如同一证书被保存在多个目录中）只记录一次
+		}
+		return res
+	}
+
+	for _, c := range loadAllLocalCertificate("ICA", "CERT") {
+		add(c.Cert, c.Type, c.DirPath())
+
+		if c.Type != "CERT" {
+			continue
+		}
+
+		names, err := utils.ReadDirOnlyDir(historyDirPath(c.DirPath()))
+		if err != nil {
+			continue
+		}
+
+		for _, name := range names {
+			historyPath := path.Join(historyDirPath(c.DirPath()), name)
+			certs, err := utils.ReadCertificates(path.Join(historyPath, "cert.pem"))
+			if err != nil || len(certs) == 0 {
+				continue
+			}
+
+			if r := add(certs[0], c.Type, historyPath); r != nil {
+				r.SupersededBy = new(big.Int).Set(c.Cert.SerialNumber)
+			}
+		}
+	}
+
+	signerDirPath := ocspSignerDirPath(ca.DirPath())
+	if certs, err := utils.ReadCertificates(path.Join(signerDirPath, "cert.pem")); err == nil && len(certs) != 0 {
+		add(certs[0], "OCSP-SIGNER", signerDirPath)
+	}
+
+	raDirPath := scepRADirPath(ca.DirPath())
+	if certs, err := utils.ReadCertificates(path.Join(raDirPath, "cert.pem")); err == nil && len(certs) != 0 {
+		add(certs[0], "SCEP-RA", raDirPath)
+	}
+
+	cmpSignerPath := cmpSignerDirPath(ca.DirPath())
+	if certs, err := utils.ReadCertificates(path.Join(cmpSignerPath, "cert.pem")); err == nil && len(certs) != 0 {
+		add(certs[0], "CMP-SIGNER", cmpSignerPath)
+	}
+
+	db, err := revoke.GetRevocationDB(revocationDBPath(ca.DirPath()))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range db.Revoked {
+		idx.SetRevoked(r)
+	}
+
+	return idx, nil
+}
+
+// recordIssuance 在签发CA的索引中记录新签发的证书，应在证书签名后、保存证书文件前调用
+func recordIssuance(caInfo cert.CAInfo, c *x509.Certificate, certType string, dirPath string) error {
+	ca, err := caInfoLocalCertificate(caInfo)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCA(ca.DirPath())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	idx, err := loadIssuanceIndex(ca)
+	if err != nil {
+		return err
+	}
+
+	_, err = idx.Add(c, certType, homeRelativePath(dirPath), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return idx.SaveIndex()
+}
+
+// recordRevocation 在签发CA的索引中记录吊销，调用者必须持有CA的锁
+func recordRevocation(ca *localCertificate, record *revoke.RevokedCert) error {
+	idx, err := loadIssuanceIndex(ca)
+	if err != nil {
+		return err
+	}
+
+	idx.SetRevoked(record)
+	return idx.SaveIndex()
+}
+
+// recordSupersede 在签发CA的索引中记录证书被续期后的新证书取代
+func recordSupersede(caInfo cert.CAInfo, old *x509.Certificate, newCert *x509.Certificate, historyPath string) error {
+	ca, err := caInfoLocalCertificate(caInfo)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCA(ca.DirPath())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	idx, err := loadIssuanceIndex(ca)
+	if err != nil {
+		return err
+	}
+
+	if idx.Supersede(old.SerialNumber, newCert.SerialNumber, homeRelativePath(historyPath)) == nil {
+		return nil
+	}
+
+	return idx.SaveIndex()
+}
+
+// issuedFilter 签发记录的筛选条件，为空的条件不参与筛选
+type issuedFilter struct {
+	Query         string
+	Status        issuance.Status
+	Type          string
+	ExpiresWithin time.Duration // 只显示在该时间内过期（且尚未过期）的证书
+}
+
+func (f *issuedFilter) Match(c *issuance.IssuedCert, now time.Time) bool {
+	if !c.Match(f.Query) {
+		return false
+	} else if f.Status != "" && c.Status(now) != f.Status {
+		return false
+	} else if f.Type != "" && !strings.EqualFold(c.Type, f.Type) {
+		return false
+	} else if f.ExpiresWithin > 0 && (now.After(c.NotAfter) || c.NotAfter.After(now.Add(f.ExpiresWithin))) {
+		return false
+	}
+	return true
+}
+
+// newIssuedFilter 根据命令行或API的参数生成筛选条件
+func newIssuedFilter(search string, status string, certType string, expiresWithin string) (*issuedFilter, error) {
+	filter := &issuedFilter{
+		Query: search,
+		Type:  strings.ToUpper(certType),
+	}
+
+	if status != "" {
+		s, err := issuance.ParseStatus(status)
+		if err != nil {
+			return nil, err
+		}
+		filter.Status = s
+	}
+
+	if expiresWithin != "" {
+		filter.ExpiresWithin = utils.ReadTimeDuration(expiresWithin)
+		if filter.ExpiresWithin <= 0 {
+			return nil, fmt.Errorf("not a valid duration: %s", expiresWithin)
+		}
+	}
+
+	return filter, nil
+}
+
+// filterIssuanceIndex 返回CA签发的全部证书数量和符合条件的证书
+func filterIssuanceIndex(ca *localCertificate, filter *issuedFilter, now time.Time) (int, []*issuance.IssuedCert, error) {
+	idx, err := loadIssuanceIndex(ca)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	res := make([]*issuance.IssuedCert, 0, len(idx.Issued))
+	for _, c := range idx.Issued {
+		if filter.Match(c, now) {
+			res = append(res, c)
+		}
+	}
+
+	return len(idx.Issued), res, nil
+}
+
+// showIssuanceIndex 显示CA签发的证书，返回符合条件的证书数量
+func showIssuanceIndex(ca *localCertificate, filter *issuedFilter) (int, error) {
+	now := time.Now()
+	total, res, err := filterIssuanceIndex(ca, filter, now)
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("%s 签发记录 总计: %d 符合条件: %d\n", ca.String(), total, len(res))
+
+	for i, c := range res {
+		certType := c.Type
+		if certType == "" {
+			certType = "UNKNOWN"
+		}
+
+		fmt.Printf(" %d. [%s] %s %s\n", i+1, c.Status(now), certType, c.Subject)
+		fmt.Printf("    serial number: %s\n", c.SerialNumber.Text(16))
+		if sans := c.SANs(); len(sans) != 0 {
+			fmt.Printf("    SAN: %s\n", strings.Join(sans, ", "))
+		}
+		if !c.NotBefore.IsZero() {
+			fmt.Printf("    not before: %s\n", c.NotBefore.Format(time.RFC3339))
+		}
+		fmt.Printf("    not after: %s\n", c.NotAfter.Format(time.RFC3339))
+		if c.Fingerprint != "" {
+			fmt.Printf("    fingerprint (SHA-256): %s\n", c.Fingerprint)
+		}
+		if c.Path != "" {
+			fmt.Printf("    path: %s\n", c.Path)
+		}
+		if !c.RevokedAt.IsZero() {
+			fmt.Printf("    revoked at: %s\n", c.RevokedAt.Format(time.RFC3339))
+		}
+		if c.SupersededBy != nil {
+			fmt.Printf("    superseded by: %s\n", c.SupersededBy.Text(16))
+		}
+	}
+
+	return len(res), nil
+}
+
+func ShowIssuedCertificates() {
+	cas := loadAllLocalCertificate("RCA", "ICA")
+
+	fmt.Println("总计: ", len(cas))
+	for i, c := range cas {
+		fmt.Printf(" %d. %s\n", i+1, c.String())
+	}
+
+	if len(cas) == 0 {
+		fmt.Println("Error: no CA available")
+		return
+	}
+
+	fmt.Printf("Select a CA and enter its serial number (0 means all CA): ")
+	i := ReadNumber() - 1 // 显示的列表是从1开始计数的
+
+	if i >= len(cas) || i < -1 {
+		fmt.Println("Error: invalid serial number")
+		return
+	} else if i >= 0 {
+		cas = cas[i : i+1]
+	}
+
+	filter := &issuedFilter{}
+
+	fmt.Printf("Search (subject, SAN, serial number, fingerprint or path, leave empty for all): ")
+	filter.Query = ReadString()
+
+	fmt.Printf("Status (valid / revoked / expired / superseded, leave empty for all): ")
+	if s := ReadString(); s != "" {
+		status, err := issuance.ParseStatus(s)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		filter.Status = status
+	}
+
+	for _, ca := range cas {
+		_, err := showIssuanceIndex(ca, filter)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+}
+
+func CommandListIssued(opt *flagparser.IssuedListOption) error {
+	filter, err := newIssuedFilter(opt.Search, opt.Status, opt.Type, opt.ExpiresWithin)
+	if err != nil {
+		return err
+	}
+
+	var cas []*localCertificate
+	if opt.Issuer != "" {
+		ca, err := loadLocalCA(opt.IssuerType, opt.Issuer)
+		if err != nil {
+			return err
+		}
+		cas = []*localCertificate{ca}
+	} else {
+		cas = loadAllLocalCertificate("RCA", "ICA")
+	}
+
+	for _, ca := range cas {
+		_, err := showIssuanceIndex(ca, filter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
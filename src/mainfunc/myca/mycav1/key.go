@@ -0,0 +1,243 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// readPrivateKey 读取私钥文件，支持PEM或DER格式的PKCS#8（可加密）、PKCS#1和SEC1私钥，私钥加密时调用passwordFunc读取密码
+func readPrivateKey(keyPath string, passwordFunc func() string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.ParseAnyPrivateKey(data, passwordFunc)
+}
+
+// parseKeyEntry 解析MyCA中的条目，格式为“类型/目录名”，例如 RCA/RCA-MyRootCA 或 PENDING/CSR-MySubCA
+func parseKeyEntry(entry string) (entryType string, dirPath string, err error) {
+	entryType, name, ok := strings.Cut(entry, "/")
+	if !ok {
+		return "", "", fmt.Errorf("not a valid entry (e.g. RCA/RCA-MyRootCA): %s", entry)
+	}
+
+	entryType = strings.ToUpper(strings.TrimSpace(entryType))
+	name = strings.TrimSpace(name)
+	if !utils.IsValidFilename(name) {
+		return "", "", fmt.Errorf("not a valid name: %s", name)
+	}
+
+	switch entryType {
+	case "RCA", "ICA", "CERT":
+		return entryType, path.Join(certTypeHome(entryType), name), nil
+	case "PENDING":
+		return entryType, path.Join(homePending, name), nil
+	default:
+		return "", "", fmt.Errorf("unknown entry type: %s", entryType)
+	}
+}
+
+// readEntryPrivateKey 读取MyCA中条目的私钥，同时返回生成该私钥时使用的随机源（用于密钥审计）
+func readEntryPrivateKey(entry string, passwordFunc func() string) (crypto.PrivateKey, string, error) {
+	entryType, dirPath, err := parseKeyEntry(entry)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := readPrivateKey(path.Join(dirPath, "key.pem"), passwordFunc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	infoName := map[string]string{"RCA": "rca-info.json", "ICA": "ica-info.json", "CERT": "cert-info.json", "PENDING": "csr-info.json"}[entryType]
+	randSource, err := readRandSource(entryType, path.Join(dirPath, infoName))
+	if err != nil {
+		return nil, "", err
+	} else if randSource == "" {
+		randSource = utils.RandSourceMathRand
+	}
+
+	return key, randSource, nil
+}
+
+// keyFileRandSource 返回私钥文件的随机源：私钥文件位于home中的条目（包括OCSP签名证书等子目录和历史版本）时沿用该条目记录的随机源，
+// 使得复用旧版本生成的私钥时密钥审计仍能识别；其余私钥文件视为外部私钥
+func keyFileRandSource(keyPath string) (string, error) {
+	dirPath, err := filepath.Abs(filepath.Dir(keyPath))
+	if err != nil {
+		return "", err
+	} else if p, err := filepath.EvalSymlinks(dirPath); err == nil {
+		dirPath = p
+	}
+
+	homePath, err := filepath.Abs(home)
+	if err != nil {
+		return "", err
+	} else if p, err := filepath.EvalSymlinks(homePath); err == nil {
+		homePath = p
+	}
+
+	if rel, err := filepath.Rel(homePath, dirPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return utils.RandSourceExternal, nil
+	}
+
+	for _, item := range []struct {
+		entryType string
+		infoName  string
+	}{{"RCA", "rca-info.json"}, {"ICA", "ica-info.json"}, {"CERT", "cert-info.json"}, {"PENDING", "csr-info.json"}} {
+		infoPath := path.Join(dirPath, item.infoName)
+		if !utils.IsExists(infoPath) && !metadata.NeedMigrate(infoPath) {
+			continue
+		}
+
+		randSource, err := readRandSource(item.entryType, infoPath)
+		if err != nil {
+			return "", err
+		} else if randSource == "" {
+			randSource = utils.RandSourceMathRand
+		}
+		return randSource, nil
+	}
+
+	return utils.RandSourceExternal, nil
+}
+
+// parseKeyFileOption 读取命令行指定的已有私钥，未指定时返回nil（生成新的私钥）
+func parseKeyFileOption(opt *flagparser.KeyOption) (key crypto.PrivateKey, randSource string, err error) {
+	passwordFunc := func() string {
+		return opt.KeyPassword
+	}
+
+	if opt.KeyFile != "" && opt.KeyFrom != "" {
+		return nil, "", fmt.Errorf("-key-file and -key-from can not be set at the same time")
+	} else if opt.KeyFile != "" {
+		key, err = readPrivateKey(opt.KeyFile, passwordFunc)
+		if err != nil {
+			return nil, "", err
+		}
+
+		randSource, err = keyFileRandSource(opt.KeyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		return key, randSource, nil
+	} else if opt.KeyFrom != "" {
+		return readEntryPrivateKey(opt.KeyFrom, passwordFunc)
+	}
+
+	return nil, "", nil
+}
+
+// ReadKey 选择生成新的私钥或使用已有的私钥（来自文件或MyCA中的条目），使用已有私钥时cryptoType和keyLength由私钥决定
+func ReadKey() (cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, randSource string, err error) {
+	fmt.Println(keyMenu)
+	fmt.Printf(">>> ")
+
+	passwordFunc := func() string {
+		fmt.Printf("Enter the password of the private key: ")
+		return ReadPassword()
+	}
+
+	switch ReadNumber() {
+	case 2:
+		fmt.Printf("Enter the path of the key file (PEM, PKCS#8): ")
+		keyPath := ReadString()
+		key, err = readPrivateKey(keyPath, passwordFunc)
+		if err == nil {
+			randSource, err = keyFileRandSource(keyPath)
+		}
+	case 3:
+		fmt.Printf("Enter the entry (e.g. RCA/RCA-MyRootCA, ICA/ICA-MyICA, CERT/CERT-example.com, PENDING/CSR-MySubCA): ")
+		key, randSource, err = readEntryPrivateKey(ReadString(), passwordFunc)
+	default:
+		cryptoType, keyLength = ReadCryptoType()
+		return cryptoType, keyLength, nil, "", nil
+	}
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+
+	cryptoType, keyLength, err = utils.GetCryptoType(key)
+	if err != nil {
+		return "", 0, nil, "", err
+	}
+
+	fmt.Println("Crypto (existing key): ", cryptoType, keyLength)
+	return cryptoType, keyLength, key, randSource, nil
+}
+
+func ReadCryptoType() (cryptoType utils.CryptoType, keyLength int) {
+	fmt.Println(cryptoMenu)
+	fmt.Printf(">>> ")
+
+	switch ReadNumber() {
+	case 1:
+		cryptoType = utils.CryptoTypeRsa
+		keyLength = 2048
+	case 2:
+		cryptoType = utils.CryptoTypeRsa
+		keyLength = 4096
+	case 0:
+		fallthrough
+	default:
+		fmt.Println("Warn: Use Default")
+		fallthrough
+	case 3:
+		cryptoType = utils.CryptoTypeEcdsa
+		keyLength = 256
+	case 4:
+		cryptoType = utils.CryptoTypeEcdsa
+		keyLength = 384
+	case 5:
+		cryptoType = utils.CryptoTypeEcdsa
+		keyLength = 521
+	case 6:
+		cryptoType = utils.CryptoTypeEd25519
+		keyLength = 256
+	case 7:
+		cryptoType = utils.CryptoTypeRsa
+		keyLength = 3072
+	case 8:
+		cryptoType = utils.CryptoTypeRsa
+		keyLength = 8192
+	}
+
+	fmt.Println("Crypto: ", cryptoType, keyLength)
+	return cryptoType, keyLength
+}
+
+// setKeyRandSource 使用已有私钥时，记录生成该私钥时使用的随机源，使得密钥审计能识别由旧版本生成的私钥
+func setKeyRandSource(info any, randSource string) {
+	if randSource == "" {
+		return
+	}
+
+	switch i := info.(type) {
+	case *rootca.RCAInfo:
+		i.RandSource = randSource
+	case *ica.ICAInfo:
+		i.RandSource = randSource
+	case *cert.CertInfo:
+		i.RandSource = randSource
+	case *cert.SelfCertInfo:
+		i.RandSource = randSource
+	case *csr.CSRInfo:
+		i.RandSource = randSource
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	listFormatTable = "table"
+	listFormatJSON  = "json"
+)
+
+// entryDetail RCA、ICA或用户证书的详细信息，用于列表显示
+type entryDetail struct {
+	Type          string     `json:"type"` // RCA、ICA或CERT
+	Name          string     `json:"name"`
+	Path          string     `json:"path"` // 相对于home目录
+	CommonName    string     `json:"common_name,omitempty"`
+	Subject       string     `json:"subject,omitempty"`
+	Issuer        string     `json:"issuer,omitempty"`
+	SerialNumber  string     `json:"serial_number,omitempty"`
+	KeyAlgorithm  string     `json:"key_algorithm,omitempty"`
+	KeySize       int        `json:"key_size,omitempty"`
+	NotBefore     *time.Time `json:"not_before,omitempty"`
+	NotAfter      *time.Time `json:"not_after,omitempty"`
+	DaysRemaining int        `json:"days_remaining"` // 已过期时为负数
+	IsCA          bool       `json:"is_ca"`
+	MaxPathLen    *int       `json:"max_path_len,omitempty"` // 仅CA证书，-1表示没有限制
+	SANs          []string   `json:"sans,omitempty"`
+	Fingerprint   string     `json:"sha256_fingerprint,omitempty"`
+	KeyEncrypted  *bool      `json:"key_encrypted,omitempty"` // 私钥不由MyCA保存时（例如签发外部CSR得到的证书）为空
+	Error         string     `json:"error,omitempty"`         // 证书无法读取时的错误
+}
+
+// publicKeyDetail 返回公钥的算法和长度（位）
+func publicKeyDetail(pubKey crypto.PublicKey) (string, int) {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("ECDSA %s", key.Curve.Params().Name), key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return "Ed25519", 256
+	default:
+		return "unknown", 0
+	}
+}
+
+func loadEntryDetail(certType string, name string, now time.Time) *entryDetail {
+	dirPath := path.Join(certTypeHome(certType), name)
+	res := &entryDetail{
+		Type: strings.ToUpper(certType),
+		Name: name,
+		Path: homeRelativePath(dirPath),
+	}
+
+	c, err := loadLocalCertificate(certType, name)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	res.CommonName = c.Cert.Subject.CommonName
+	res.Subject = c.Cert.Subject.String()
+	res.Issuer = c.Cert.Issuer.String()
+	res.SerialNumber = c.Cert.SerialNumber.Text(16)
+	res.KeyAlgorithm, res.KeySize = publicKeyDetail(c.Cert.PublicKey)
+	res.NotBefore = &c.Cert.NotBefore
+	res.NotAfter = &c.Cert.NotAfter
+	res.DaysRemaining = int(c.Cert.NotAfter.Sub(now).Hours() / 24)
+	res.IsCA = c.Cert.IsCA
+	res.Fingerprint = revoke.Fingerprint(c.Cert)
+
+	if c.Cert.IsCA {
+		maxPathLen := c.Cert.MaxPathLen
+		if maxPathLen == 0 && !c.Cert.MaxPathLenZero {
+			maxPathLen = -1
+		}
+		res.MaxPathLen = &maxPathLen
+	}
+
+	res.SANs = append(res.SANs, c.Cert.DNSNames...)
+	for _, ip := range c.Cert.IPAddresses {
+		res.SANs = append(res.SANs, ip.String())
+	}
+	res.SANs = append(res.SANs, c.Cert.EmailAddresses...)
+	for _, u := range c.Cert.URIs {
+		res.SANs = append(res.SANs, u.String())
+	}
+
+	if keyPEM, err := utils.ReadPemBlock(path.Join(dirPath, "key.pem")); err == nil {
+		encrypted := keyPEM.Type == utils.PemTypePrivateKeyWithPassword
+		res.KeyEncrypted = &encrypted
+	}
+
+	return res
+}
+
+// loadEntryDetails 读取home目录中某一类型的全部条目，顺序与目录名的顺序一致
+func loadEntryDetails(certType string) ([]string, []*entryDetail, error) {
+	names, err := utils.ReadDirOnlyDir(certTypeHome(certType))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	res := make([]*entryDetail, 0, len(names))
+	for _, name := range names {
+		res = append(res, loadEntryDetail(certType, name, now))
+	}
+
+	return names, res, nil
+}
+
+func (d *entryDetail) validityString() string {
+	if d.NotAfter == nil {
+		return "-"
+	} else if d.DaysRemaining < 0 || time.Now().After(*d.NotAfter) {
+		return "expired"
+	}
+	return fmt.Sprintf("%d days", d.DaysRemaining)
+}
+
+func (d *entryDetail) pathLenString() string {
+	if d.MaxPathLen == nil {
+		return "-"
+	} else if *d.MaxPathLen < 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(*d.MaxPathLen)
+}
+
+func (d *entryDetail) keyEncryptedString() string {
+	if d.KeyEncrypted == nil {
+		return "no key"
+	} else if *d.KeyEncrypted {
+		return "yes"
+	}
+	return "no"
+}
+
+func (d *entryDetail) keyString() string {
+	if d.KeyAlgorithm == "RSA" {
+		return fmt.Sprintf("RSA %d", d.KeySize)
+	}
+	return d.KeyAlgorithm
+}
+
+// printEntryTable 以表格形式显示条目，第一列为交互模式下选择条目使用的编号
+func printEntryTable(entries []*entryDetail) {
+	fmt.Println("总计: ", len(entries))
+	if len(entries) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " #\tNAME\tCOMMON NAME\tISSUER\tSERIAL NUMBER\tKEY\tNOT BEFORE\tNOT AFTER\tREMAINING\tPATH LEN\tKEY ENCRYPTED\tSHA-256 FINGERPRINT")
+
+	for i, d := range entries {
+		if d.Error != "" {
+			_, _ = fmt.Fprintf(w, " %d.\t%s\t(error: %s)\n", i+1, d.Name, d.Error)
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, " %d.\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, d.Name, d.CommonName, d.Issuer, d.SerialNumber, d.keyString(),
+			d.NotBefore.Local().Format(time.DateOnly), d.NotAfter.Local().Format(time.DateOnly), d.validityString(), d.pathLenString(), d.keyEncryptedString(), d.Fingerprint)
+	}
+
+	_ = w.Flush()
+}
+
+func printEntryJSON(entries []*entryDetail) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// showAllEntry 以表格形式显示某一类型的全部条目，返回条目的目录名（用于交互模式下选择）
+func showAllEntry(certType string) []string {
+	names, entries, err := loadEntryDetails(certType)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	}
+
+	printEntryTable(entries)
+	return names
+}
+
+func ShowAllCert() {
+	showAllEntry("CERT")
+}
+
+// CommandListEntry 执行rca list、ica list和cert list
+func CommandListEntry(certType string, opt *flagparser.ListOption) error {
+	_, entries, err := loadEntryDetails(certType)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(opt.Format) {
+	case listFormatTable:
+		printEntryTable(entries)
+		return nil
+	case listFormatJSON:
+		return printEntryJSON(entries)
+	default:
+		return fmt.Errorf("unknown format: %s", opt.Format)
+	}
+}
@@ -69,7 +69,7 @@ func loadICA(name string, passwordFunc func() string) (cert *x509.Certificate, k
 		}
 	}
 
-	icaInfo, err = ica.GetICAInfo(path.Join(home, "ica", name, "ica-info.gob"))
+	icaInfo, err = ica.GetICAInfo(path.Join(home, "ica", name, "ica-info.json"))
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
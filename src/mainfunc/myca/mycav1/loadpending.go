@@ -0,0 +1,74 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"path"
+)
+
+func LoadPending() (name string, req *x509.CertificateRequest, key crypto.PrivateKey, password string, csrInfo *csr.CSRInfo, err error) {
+	c := showAllPending()
+	if len(c) == 0 {
+		return "", nil, nil, "", nil, fmt.Errorf("no pending request available")
+	}
+
+	fmt.Printf("Select a pending request and enter its serial number: ")
+	i := ReadNumber() - 1 // 显示的列表是从1开始计数的
+
+	if i < 0 || i >= len(c) {
+		return "", nil, nil, "", nil, fmt.Errorf("invalid serial number")
+	}
+
+	passwordFunc := func() string {
+		fmt.Printf("Entery the password of the private key: ")
+		return ReadPassword()
+	}
+
+	req, key, password, csrInfo, err = loadPending(c[i], passwordFunc)
+	if err != nil {
+		return "", nil, nil, "", nil, err
+	}
+
+	return c[i], req, key, password, csrInfo, nil
+}
+
+func loadPending(name string, passwordFunc func() string) (req *x509.CertificateRequest, key crypto.PrivateKey, password string, csrInfo *csr.CSRInfo, err error) {
+	req, err = utils.ReadCertificateRequest(path.Join(homePending, name, "csr.pem"))
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	keyPEM, err := utils.ReadPemBlock(path.Join(homePending, name, "key.pem"))
+	if err != nil {
+		return nil, nil, "", nil, err
+	} else if keyPEM.Type != utils.PemTypePrivateKeyWithPassword && keyPEM.Type != utils.PemTypePrivateKeyNotPassword {
+		return nil, nil, "", nil, fmt.Errorf("pem type of key error")
+	}
+
+	if keyPEM.Type == utils.PemTypePrivateKeyWithPassword {
+		password = passwordFunc()
+		key, _, err = utils.ParserPrivateKey(keyPEM.Bytes, password)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+	} else {
+		key, _, err = utils.ParserPrivateKey(keyPEM.Bytes)
+		if err != nil {
+			return nil, nil, "", nil, err
+		}
+	}
+
+	csrInfo, err = csr.GetCSRInfo(path.Join(homePending, name, "csr-info.json"))
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	return req, key, password, csrInfo, nil
+}
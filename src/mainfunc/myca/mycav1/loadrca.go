@@ -69,7 +69,7 @@ func loadRCA(name string, passwordFunc func() string) (cert *x509.Certificate, k
 		}
 	}
 
-	rcaInfo, err = rootca.GetRCAInfo(path.Join(home, "rca", name, "rca-info.gob"))
+	rcaInfo, err = rootca.GetRCAInfo(path.Join(home, "rca", name, "rca-info.json"))
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
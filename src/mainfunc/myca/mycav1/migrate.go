@@ -0,0 +1,82 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/issuance"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// migrateInfoFile 读取信息文件，旧版本的gob信息文件会在读取时被迁移为JSON格式
+func migrateInfoFile(infoPath string) error {
+	switch filepath.Base(infoPath) {
+	case "rca-info.json":
+		_, err := rootca.GetRCAInfo(infoPath)
+		return err
+	case "ica-info.json":
+		_, err := ica.GetICAInfo(infoPath)
+		return err
+	case "csr-info.json":
+		_, err := csr.GetCSRInfo(infoPath)
+		return err
+	case issuance.IndexFileName:
+		_, err := issuance.GetIndex(infoPath)
+		return err
+	case revoke.DBFileName:
+		_, err := revoke.GetRevocationDB(infoPath)
+		return err
+	case "cert-info.json":
+		_, err := cert.GetCertInfo(infoPath)
+		if err != nil {
+			_, err = cert.GetSelfCertInfo(infoPath) // 自签名证书的信息文件
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown info file: %s", infoPath)
+	}
+}
+
+// migrateHome 将home目录中（包括OCSP签名证书和历史版本）旧版本的gob信息文件以及CA的签发索引和吊销数据库迁移为JSON格式，返回迁移的文件数量
+// 迁移失败的文件保持不变，不影响其他文件的迁移
+func migrateHome() int {
+	count := 0
+
+	_ = filepath.WalkDir(home, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		switch d.Name() {
+		case "rca-info.gob", "ica-info.gob", "cert-info.gob", "csr-info.gob", "issued-db.gob", "revoke-db.gob":
+		default:
+			return nil
+		}
+
+		infoPath := filepath.Join(filepath.Dir(p), strings.TrimSuffix(d.Name(), ".gob")+".json")
+		if !metadata.NeedMigrate(infoPath) {
+			return nil
+		}
+
+		err = migrateInfoFile(infoPath)
+		if err != nil {
+			fmt.Printf("Warning: migrate %s failed: %s\n", p, err.Error())
+			return nil
+		}
+
+		count++
+		return nil
+	})
+
+	return count
+}
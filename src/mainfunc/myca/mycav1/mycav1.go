@@ -27,8 +27,7 @@ func MainV1() (exitcode int) {
 	homeRCA = path.Join(home, "rca")
 	homeICA = path.Join(home, "ica")
 	homeCert = path.Join(home, "cert")
-
-	stdinReader = bufio.NewReader(os.Stdin)
+	homePending = path.Join(home, "pending")
 
 	err = os.MkdirAll(home, 0600)
 	if err != nil {
@@ -54,12 +53,29 @@ func MainV1() (exitcode int) {
 		return 1
 	}
 
+	err = os.MkdirAll(path.Join(home, "pending"), 0600)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return 1
+	}
+
+	migrated := migrateHome()
+
+	if flagparser.Command != "" {
+		return RunCommand()
+	}
+
+	stdinReader = bufio.NewReader(os.Stdin)
+
 	stopChan := make(chan int, 2)
 	sigChan := make(chan os.Signal, 10)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	fmt.Println("Welcome to MyCA")
 	fmt.Println("Home Directory: ", home)
+	if migrated > 0 {
+		fmt.Printf("Migrated %d legacy info files (gob) to JSON\n", migrated)
+	}
 
 	go MainCycle(stopChan)
 
@@ -100,6 +116,42 @@ MainCycle:
 			case 8:
 				CreateUserCertSelf()
 			case 9:
+				SignCSRFromRCA()
+			case 10:
+				SignCSRFromICA()
+			case 11:
+				ShowAllPending()
+			case 12:
+				CreateCSR()
+			case 13:
+				ImportSignedICA()
+			case 14:
+				RevokeCertificate()
+			case 15:
+				ShowRevokedCertificates()
+			case 16:
+				GenerateCRLFromRCA()
+			case 17:
+				GenerateCRLFromICA()
+			case 18:
+				CreateOCSPSignerFromRCA()
+			case 19:
+				CreateOCSPSignerFromICA()
+			case 20:
+				AuditKeys()
+			case 21:
+				RenewCertificate()
+			case 22:
+				ShowIssuedCertificates()
+			case 23:
+				ShowAllCert()
+			case 24:
+				InspectFile()
+			case 25:
+				VerifyCertificate()
+			case 26:
+				ImportCA()
+			case 27:
 				stopchan <- 0
 				close(stopchan)
 				return false
@@ -0,0 +1,226 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/issuance"
+	"github.com/SongZihuan/MyCA/src/ocspserver"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+func CreateOCSPSignerFromRCA() {
+	rcaCert, rcaKey, rcaFullchain, rcaInfo, err := LoadRCA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	createOCSPSignerInteractive(rcaCert, rcaKey, rcaFullchain, rcaInfo)
+}
+
+func CreateOCSPSignerFromICA() {
+	icaCert, icaKey, icaFullchain, icaInfo, err := LoadICA()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	createOCSPSignerInteractive(icaCert, icaKey, icaFullchain, icaInfo)
+}
+
+func createOCSPSignerInteractive(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo) {
+	fmt.Printf("Enter the validity of the OCSP signing certificate [default: 30d]: ")
+	validity := time.Hour * 24 * 30
+	if s := ReadString(); s != "" {
+		validity = utils.ReadTimeDuration(s)
+		if validity <= 0 {
+			fmt.Printf("Error: not a valid validity: %s\n", s)
+			return
+		}
+	}
+
+	fmt.Printf("Set a password for private key: ")
+	password := ReadPassword()
+
+	dirPath, err := createOCSPSigner(caCert, caKey, caFullchain, caInfo, utils.CryptoTypeEcdsa, 256, nil, "", validity, password)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+}
+
+func ocspSignerDirPath(caDirPath string) string {
+	return path.Join(caDirPath, "ocsp-signer")
+}
+
+// createOCSPSigner 签发委派的OCSP签名证书，保存在CA目录的ocsp-signer子目录中（会覆盖旧的签名证书）
+func createOCSPSigner(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo, cryptoType utils.CryptoType, keyLength int, existingKey crypto.PrivateKey, keyRandSource string, validity time.Duration, password string) (string, error) {
+	caDirPath, err := caInfoDirPath(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	dirPath := ocspSignerDirPath(caDirPath)
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	notBefore := time.Now()
+	signerCert, signerKey, err := ocspserver.CreateSigner(caInfo, cryptoType, keyLength, existingKey, notBefore, notBefore.Add(validity), caCert, caKey)
+	if err != nil {
+		return "", err
+	}
+
+	err = recordIssuance(caInfo, signerCert, "OCSP-SIGNER", dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if oldSigners, err := utils.ReadCertificates(path.Join(dirPath, "cert.pem")); err == nil && len(oldSigners) != 0 {
+		err = recordSupersede(caInfo, oldSigners[0], signerCert, "") // 旧的签名证书会被覆盖
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = saveCAInfo(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	signerInfo, err := cert.NewCertInfo(path.Join(dirPath, "cert-info.json"), caInfo)
+	if err != nil {
+		return "", err
+	}
+	signerInfo.SerialNumber = signerCert.SerialNumber
+	setKeyRandSource(signerInfo, keyRandSource)
+
+	err = saveCertificateAndKey(dirPath, signerCert, signerKey, password, caFullchain)
+	if err != nil {
+		return "", err
+	}
+
+	err = signerInfo.SaveCertInfo()
+	if err != nil {
+		return "", err
+	}
+
+	return dirPath, nil
+}
+
+// loadOCSPIssuer 读取OCSP服务应答的CA，优先使用委派的OCSP签名证书，不存在时使用CA私钥
+func loadOCSPIssuer(ca *localCertificate, passwordFunc func() string) (*ocspserver.Issuer, error) {
+	signerDirPath := ocspSignerDirPath(ca.DirPath())
+
+	var signerCert *x509.Certificate
+	var signerKey crypto.PrivateKey
+	var err error
+
+	if utils.IsExists(path.Join(signerDirPath, "cert.pem")) {
+		certs, err := utils.ReadCertificates(path.Join(signerDirPath, "cert.pem"))
+		if err != nil {
+			return nil, err
+		}
+
+		signerCert = certs[0]
+		signerKey, err = readPrivateKey(path.Join(signerDirPath, "key.pem"), passwordFunc)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		signerCert = ca.Cert
+		signerKey, err = readPrivateKey(path.Join(ca.DirPath(), "key.pem"), passwordFunc)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	issued, err := newIssuedSerialNumbers(ca)
+	if err != nil {
+		return nil, err
+	}
+	issued.add(signerCert.SerialNumber)
+
+	return ocspserver.NewIssuer(ca.Cert, signerCert, signerKey, revocationDBPath(ca.DirPath()), issued.IsIssued)
+}
+
+// issuedSerialNumbers 根据CA的签发索引判断序列号是否由该CA签发，索引文件变化时重新读取，使得服务运行期间签发的证书也能被识别
+type issuedSerialNumbers struct {
+	lock    sync.Mutex
+	ca      *localCertificate
+	extra   map[string]bool // 不在索引中但应视为已签发的序列号（例如CA自己签名时的CA证书）
+	index   *issuance.Index
+	modTime time.Time
+}
+
+func newIssuedSerialNumbers(ca *localCertificate) (*issuedSerialNumbers, error) {
+	res := &issuedSerialNumbers{
+		ca:    ca,
+		extra: make(map[string]bool, 1),
+	}
+
+	err := res.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (s *issuedSerialNumbers) add(serialNumber *big.Int) {
+	s.extra[serialNumber.Text(16)] = true
+}
+
+func (s *issuedSerialNumbers) indexModTime() time.Time {
+	info, err := os.Stat(issuanceIndexPath(s.ca.DirPath()))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (s *issuedSerialNumbers) load() error {
+	modTime := s.indexModTime()
+
+	index, err := loadIssuanceIndex(s.ca)
+	if err != nil {
+		return err
+	}
+
+	s.index = index
+	s.modTime = modTime
+	return nil
+}
+
+func (s *issuedSerialNumbers) IsIssued(serialNumber *big.Int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.extra[serialNumber.Text(16)] {
+		return true
+	}
+
+	if s.indexModTime().After(s.modTime) {
+		err := s.load()
+		if err != nil {
+			fmt.Printf("Error: reload the issuance index of %s failed: %s\n", s.ca.String(), err.Error())
+		}
+	}
+
+	return s.index.IsIssued(serialNumber)
+}
@@ -0,0 +1,259 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// onlineIssuer 在线服务（ACME、EST等）的签发者，签发的证书与交互式签发相同，保存在 home/cert 中并记录在签发索引中
+type onlineIssuer struct {
+	lock        sync.Mutex
+	caCert      *x509.Certificate
+	caKey       crypto.PrivateKey
+	caFullchain []byte
+	caInfo      cert.CAInfo
+	validity    time.Duration
+	policies    []*utils.CertificatePolicy
+	extKeyUsage []x509.ExtKeyUsage // 客户端可以请求的扩展密钥用途
+}
+
+// onlineDefaultExtKeyUsage 在线服务默认允许客户端请求的扩展密钥用途
+var onlineDefaultExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+
+// sensitiveExtKeyUsage 使证书可以代表CA签名OCSP响应或签名代码的扩展密钥用途，在线服务只有在运维人员明确指定时才允许
+var sensitiveExtKeyUsage = map[x509.ExtKeyUsage]bool{
+	x509.ExtKeyUsageAny:                            true,
+	x509.ExtKeyUsageCodeSigning:                    true,
+	x509.ExtKeyUsageOCSPSigning:                    true,
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: true,
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     true,
+}
+
+// onlineRequest 在线签发请求，Subject和SAN应已由调用者检查
+type onlineRequest struct {
+	DirName     string // home/cert 中的目录名称
+	CSR         *x509.CertificateRequest
+	PublicKey   crypto.PublicKey // CSR为nil时使用（例如CMP的CRMF请求），持有证明应已由调用者检查
+	Subject     *global.CertSubject
+	Domains     []string
+	IPs         []net.IP
+	Emails      []string
+	URLs        []*url.URL
+	ExtKeyUsage []x509.ExtKeyUsage
+	Key         crypto.PrivateKey // 服务端生成的私钥，为nil时只保存证书
+	KeyPassword string
+}
+
+// parseAllowExtKeyUsageOption 解析在线服务的 -allow-ext-key-usage，为空时允许ServerAuth和ClientAuth，
+// "all" 不包括敏感的扩展密钥用途（Any、CodeSigning、OCSPSigning等），这些用途必须单独指定
+func parseAllowExtKeyUsageOption(values []string) ([]x509.ExtKeyUsage, error) {
+	if len(values) == 0 {
+		return utils.CopySlice(onlineDefaultExtKeyUsage), nil
+	}
+
+	res := make([]x509.ExtKeyUsage, 0, len(ExtKeyUsageList))
+	for _, v := range values {
+		usages, err := parseExtKeyUsageOption([]string{v})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, usage := range usages {
+			if strings.EqualFold(v, "all") && sensitiveExtKeyUsage[usage] {
+				continue
+			} else if !slices.Contains(res, usage) {
+				res = append(res, usage)
+			}
+		}
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("at least one ext key usage must be allowed")
+	}
+
+	return res, nil
+}
+
+// newOnlineIssuer 创建在线签发者，默认允许客户端请求ServerAuth和ClientAuth，服务可以通过 setExtKeyUsage 修改
+func newOnlineIssuer(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo, validity time.Duration, policyNames []string) (*onlineIssuer, error) {
+	policies, err := selectCertificatePolicies(caCertificatePolicies(caInfo), policyNames, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &onlineIssuer{
+		caCert:      caCert,
+		caKey:       caKey,
+		caFullchain: caFullchain,
+		caInfo:      caInfo,
+		validity:    validity,
+		policies:    policies,
+		extKeyUsage: utils.CopySlice(onlineDefaultExtKeyUsage),
+	}, nil
+}
+
+// setExtKeyUsage 设置客户端可以请求的扩展密钥用途（由 parseAllowExtKeyUsageOption 解析）
+func (i *onlineIssuer) setExtKeyUsage(extKeyUsage []x509.ExtKeyUsage) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.extKeyUsage = utils.CopySlice(extKeyUsage)
+}
+
+// checkNameConstraints 检查SAN是否符合CA（及其上级CA）的名称约束
+func (i *onlineIssuer) checkNameConstraints(domains []string, ips []net.IP, emails []string, urls []*url.URL) error {
+	return checkNameConstraints(i.caCert, i.caFullchain, domains, ips, emails, urls)
+}
+
+// checkExtKeyUsage 检查客户端请求的扩展密钥用途是否被允许，没有请求扩展密钥用途时使用允许的非敏感用途
+func (i *onlineIssuer) checkExtKeyUsage(requested []x509.ExtKeyUsage) ([]x509.ExtKeyUsage, error) {
+	if len(requested) == 0 {
+		res := make([]x509.ExtKeyUsage, 0, len(i.extKeyUsage))
+		for _, usage := range i.extKeyUsage {
+			if !sensitiveExtKeyUsage[usage] {
+				res = append(res, usage)
+			}
+		}
+
+		if len(res) == 0 {
+			return nil, fmt.Errorf("the ext key usage must be set in the request")
+		}
+		return res, nil
+	}
+
+	for _, usage := range requested {
+		if !slices.Contains(i.extKeyUsage, usage) {
+			name, ok := ExtKeyUsageMap[usage]
+			if !ok {
+				name = fmt.Sprintf("ExtKeyUsage(%d)", usage)
+			}
+			return nil, fmt.Errorf("the ext key usage %s is not allowed", name)
+		}
+	}
+
+	return requested, nil
+}
+
+// issue 签发证书，返回证书和保存目录
+func (i *onlineIssuer) issue(req *onlineRequest) (*x509.Certificate, string, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	err := i.checkNameConstraints(req.Domains, req.IPs, req.Emails, req.URLs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	extKeyUsage, err := i.checkExtKeyUsage(req.ExtKeyUsage)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := processDirName(req.DirName)
+	dirPath, err := parseSaveOption(homeCert, "", req.Subject, name, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// 同名目录已存在时（例如同一秒内的多个请求）添加序号
+	for n := 2; isCertificateExists(dirPath); n++ {
+		dirPath = path.Join(homeCert, fmt.Sprintf("%s-%d", name, n))
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return nil, "", err
+	}
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(i.validity)
+
+	var userCert *x509.Certificate
+	var certInfo *cert.CertInfo
+	if req.CSR != nil {
+		userCert, certInfo, err = cert.CreateCertFromCSR(path.Join(dirPath, "cert-info.json"), i.caInfo, req.CSR, req.Subject, defaultKeyUsage("cert"), extKeyUsage, req.Domains, req.IPs, req.Emails, req.URLs, i.policies, notBefore, notAfter, i.caCert, i.caKey, x509.UnknownSignatureAlgorithm)
+	} else {
+		userCert, certInfo, err = cert.CreateCertFromPublicKey(path.Join(dirPath, "cert-info.json"), i.caInfo, req.PublicKey, req.Subject, defaultKeyUsage("cert"), extKeyUsage, req.Domains, req.IPs, req.Emails, req.URLs, i.policies, notBefore, notAfter, i.caCert, i.caKey, x509.UnknownSignatureAlgorithm)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = recordIssuance(i.caInfo, userCert, "CERT", dirPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = certInfo.SaveCertInfo()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if req.Key != nil {
+		err = saveCertificateAndKey(dirPath, userCert, req.Key, req.KeyPassword, i.caFullchain)
+	} else {
+		err = saveCertificateOnly(dirPath, userCert, i.caFullchain)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if req.CSR != nil {
+		err = saveCertificateRequest(dirPath, req.CSR)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	fmt.Printf("Issued %s (serial number: %s), save directory: %s\n", req.Subject.CN, userCert.SerialNumber.Text(16), dirPath)
+	return userCert, dirPath, nil
+}
+
+// supersede 在签发索引中记录旧证书被新证书取代，旧证书不是由该CA签发时忽略
+func (i *onlineIssuer) supersede(old *x509.Certificate, newCert *x509.Certificate) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	ca, err := caInfoLocalCertificate(i.caInfo)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockCA(ca.DirPath())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	idx, err := loadIssuanceIndex(ca)
+	if err != nil {
+		return err
+	}
+
+	issued := idx.Find(old.SerialNumber)
+	if issued == nil {
+		return nil
+	}
+
+	// 旧证书仍然保存在原来的目录中
+	idx.Supersede(old.SerialNumber, newCert.SerialNumber, issued.Path)
+	return idx.SaveIndex()
+}
@@ -0,0 +1,348 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/mail"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+func parseKeyOption(opt *flagparser.KeyOption) (utils.CryptoType, int, error) {
+	switch utils.CryptoType(strings.ToUpper(opt.CryptoType)) {
+	case utils.CryptoTypeRsa:
+		if opt.KeyLength == 0 {
+			return utils.CryptoTypeRsa, 2048, nil
+		} else if opt.KeyLength != 2048 && opt.KeyLength != 3072 && opt.KeyLength != 4096 && opt.KeyLength != 8192 {
+			return "", 0, fmt.Errorf("unsupported RSA key length: %d", opt.KeyLength)
+		}
+		return utils.CryptoTypeRsa, opt.KeyLength, nil
+	case utils.CryptoTypeEcc:
+		fallthrough
+	case utils.CryptoTypeEcdsa:
+		if opt.KeyLength == 0 {
+			return utils.CryptoTypeEcdsa, 256, nil
+		} else if opt.KeyLength != 256 && opt.KeyLength != 384 && opt.KeyLength != 521 {
+			return "", 0, fmt.Errorf("unsupported ECC key length: %d", opt.KeyLength)
+		}
+		return utils.CryptoTypeEcdsa, opt.KeyLength, nil
+	case utils.CryptoTypeEd25519:
+		if opt.KeyLength != 0 && opt.KeyLength != 256 {
+			return "", 0, fmt.Errorf("unsupported Ed25519 key length: %d", opt.KeyLength)
+		}
+		return utils.CryptoTypeEd25519, 256, nil
+	default:
+		return "", 0, fmt.Errorf("unsupported crypto type: %s", opt.CryptoType)
+	}
+}
+
+func parseSignatureOption(opt *flagparser.SignatureOption) (x509.SignatureAlgorithm, error) {
+	return utils.ParseSignatureAlgorithm(opt.SignatureAlgorithm)
+}
+
+func parseSubjectOption(opt *flagparser.SubjectOption) (*global.CertSubject, error) {
+	res := global.NewCertSubject()
+
+	err := res.Set("C", opt.Country.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("ST", opt.Province.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("L", opt.Locality.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("O", opt.Organization.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("OU", opt.OrganizationalUnit.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("SA", opt.StreetAddress.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	err = res.Set("PC", opt.PostalCode.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.CommonName != "" {
+		err = res.Set("CN", []string{opt.CommonName})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+func parseValidityOption(validity string) (time.Time, time.Time, error) {
+	duration := utils.ReadTimeDuration(validity)
+	if duration <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("not a valid validity: %s", validity)
+	}
+
+	notBefore := time.Now()
+	notAfter := notBefore.Add(duration)
+
+	return notBefore, notAfter, nil
+}
+
+func defaultKeyUsage(certType string) x509.KeyUsage {
+	switch strings.ToLower(certType) {
+	case "ica":
+		fallthrough
+	case "rca":
+		return x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	default:
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	}
+}
+
+func parseKeyUsageOption(values []string, certType string) (x509.KeyUsage, error) {
+	if len(values) == 0 {
+		return defaultKeyUsage(certType), nil
+	}
+
+	var res x509.KeyUsage
+
+MainCycle:
+	for _, v := range values {
+		name := strings.ToLower(v)
+		if name == "default" {
+			res |= defaultKeyUsage(certType)
+			continue MainCycle
+		}
+
+		for _, usage := range KeyUsageList {
+			usageName := strings.ToLower(KeyUsageMap[usage])
+			if name == usageName || "keyusage"+name == usageName {
+				res |= usage
+				continue MainCycle
+			}
+		}
+
+		return 0, fmt.Errorf("unknown key usage: %s", v)
+	}
+
+	return res, nil
+}
+
+func parseExtKeyUsageOption(values []string) ([]x509.ExtKeyUsage, error) {
+	if len(values) == 0 {
+		return utils.CopySlice(ExtKeyUsageList), nil
+	}
+
+	var res = make([]x509.ExtKeyUsage, 0, len(ExtKeyUsageList))
+	var addRecord = make(map[x509.ExtKeyUsage]bool, len(ExtKeyUsageList))
+
+MainCycle:
+	for _, v := range values {
+		name := strings.ToLower(v)
+		switch name {
+		case "all":
+			return utils.CopySlice(ExtKeyUsageList), nil
+		case "none":
+			continue MainCycle
+		}
+
+		for _, extUsage := range ExtKeyUsageList {
+			extUsageName := strings.ToLower(ExtKeyUsageMap[extUsage])
+			if name == extUsageName || "extkeyusage"+name == extUsageName {
+				if !addRecord[extUsage] {
+					res = append(res, extUsage)
+					addRecord[extUsage] = true
+				}
+				continue MainCycle
+			}
+		}
+
+		return nil, fmt.Errorf("unknown ext key usage: %s", v)
+	}
+
+	return res, nil
+}
+
+func parseURLOption(values []string) ([]string, error) {
+	res := make([]string, 0, len(values))
+
+	for _, v := range values {
+		u, err := url.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid URL (%s)", err.Error())
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("not a valid HTTP/HTTPS URL: %s", v)
+		}
+
+		res = append(res, u.String())
+	}
+
+	return res, nil
+}
+
+func parseSANOption(opt *flagparser.SANOption) ([]string, []net.IP, []string, []*url.URL, error) {
+	domains := make([]string, 0, len(opt.DNS))
+	ips := make([]net.IP, 0, len(opt.IP))
+	emails := make([]string, 0, len(opt.Email))
+	urls := make([]*url.URL, 0, len(opt.URI))
+
+	for _, s := range opt.DNS.Value() {
+		if !utils.IsValidDomain(s) {
+			return nil, nil, nil, nil, fmt.Errorf("not a valid domain: %s", s)
+		}
+		domains = append(domains, s)
+	}
+
+	for _, s := range opt.IP.Value() {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, nil, nil, nil, fmt.Errorf("not a valid ip: %s", s)
+		}
+		ips = append(ips, ip)
+	}
+
+	for _, s := range opt.Email.Value() {
+		email, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("not a valid email (%s)", err.Error())
+		} else if !utils.IsValidEmail(email.Address) {
+			return nil, nil, nil, nil, fmt.Errorf("not a valid email: %s", s)
+		}
+		emails = append(emails, email.Address)
+	}
+
+	for _, s := range opt.URI.Value() {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("not a valid url (%s)", err.Error())
+		}
+		urls = append(urls, u)
+	}
+
+	if opt.Resolve {
+		for _, d := range domains {
+			ipsN, err := utils.ResolveDomainToIPs(d)
+			if err != nil {
+				return nil, nil, nil, nil, fmt.Errorf("domain resolve error (%s)", err.Error())
+			}
+			ips = append(ips, ipsN...)
+		}
+	}
+
+	return domains, ips, emails, urls, nil
+}
+
+func parseMaxPathLenOption(parent *x509.Certificate, maxPathLen int) (int, error) {
+	if maxPathLen < 0 {
+		maxPathLen = -1
+	}
+
+	if parent == nil {
+		return maxPathLen, nil
+	}
+
+	if maxPathLen == -1 && parent.MaxPathLen != -1 {
+		return 0, fmt.Errorf("bad max path len: path len must less than father ca")
+	} else if maxPathLen == 0 && parent.MaxPathLen == 0 {
+		return 0, fmt.Errorf("bad max path len: path len must less than father ca")
+	} else if maxPathLen > 0 && parent.MaxPathLen != -1 && parent.MaxPathLen < maxPathLen {
+		return 0, fmt.Errorf("bad max path len: path len must less than father ca")
+	}
+
+	return maxPathLen, nil
+}
+
+func parseNameConstraintOption(opt *flagparser.NameConstraintOption) (*utils.NameConstraints, error) {
+	nameConstraints := &utils.NameConstraints{
+		PermittedDNSDomains:     opt.PermitDNS.Value(),
+		ExcludedDNSDomains:      opt.ExcludeDNS.Value(),
+		PermittedIPRanges:       opt.PermitIP.Value(),
+		ExcludedIPRanges:        opt.ExcludeIP.Value(),
+		PermittedEmailAddresses: opt.PermitEmail.Value(),
+		ExcludedEmailAddresses:  opt.ExcludeEmail.Value(),
+		PermittedURIDomains:     opt.PermitURI.Value(),
+		ExcludedURIDomains:      opt.ExcludeURI.Value(),
+	}
+
+	err := nameConstraints.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	if nameConstraints.IsEmpty() {
+		return nil, nil
+	}
+
+	return nameConstraints, nil
+}
+
+func parseSaveOption(basePath string, defaultPrefix string, subject *global.CertSubject, name string, force bool) (string, error) {
+	if name == "" {
+		if subject.CN == "" {
+			return "", fmt.Errorf("not common name")
+		}
+		name = processDirName(fmt.Sprintf("%s%s", defaultPrefix, subject.CN))
+	}
+
+	if !utils.IsValidFilename(name) {
+		return "", fmt.Errorf("not a valid name: %s", name)
+	}
+
+	dirPath := path.Join(basePath, name)
+	if isCertificateExists(dirPath) && !force {
+		return "", fmt.Errorf("there is a duplicate file in %s, use -force to overwrite it", dirPath)
+	}
+
+	return dirPath, nil
+}
+
+func parseIssuerOption(opt *flagparser.IssuerOption) (*x509.Certificate, crypto.PrivateKey, []byte, cert.CAInfo, error) {
+	if opt.Issuer == "" {
+		return nil, nil, nil, nil, fmt.Errorf("the issuer must be set")
+	}
+
+	passwordFunc := func() string {
+		return opt.IssuerPassword
+	}
+
+	switch strings.ToUpper(opt.IssuerType) {
+	case "RCA":
+		caCert, caKey, caFullchain, caInfo, err := loadRCA(opt.Issuer, passwordFunc)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return caCert, caKey, caFullchain, caInfo, nil
+	case "ICA":
+		caCert, caKey, caFullchain, caInfo, err := loadICA(opt.Issuer, passwordFunc)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return caCert, caKey, caFullchain, caInfo, nil
+	default:
+		return nil, nil, nil, nil, fmt.Errorf("unknown issuer type: %s", opt.IssuerType)
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/csr"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"net"
+	"net/url"
+	"os"
+	"path"
+)
+
+func CreateCSR() {
+	cryptoType, keyLength, existingKey, keyRandSource, err := ReadKey()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	signatureAlgorithm := ReadSignatureAlgorithm(cryptoType)
+
+	fmt.Printf("Is the request for an ICA which will be signed by an external CA?")
+	isCA := ReadBoolDefaultYesPrint()
+
+	subject, err := ReadSubject()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	certType := "auto_cert"
+	if isCA {
+		certType = "ica"
+	}
+
+	keyUsage, err := ReadKeyUsage(certType)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	extKeyUsage, err := ReadExtKeyUsage()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	maxPathLen := -1
+	domains, ips, emails, urls := make([]string, 0, 0), make([]net.IP, 0, 0), make([]string, 0, 0), make([]*url.URL, 0, 0)
+	ocspURLs, issurURLs, crlURLs := make([]string, 0, 0), make([]string, 0, 0), make([]string, 0, 0)
+
+	if isCA {
+		fmt.Printf("Set the ca max path len limit [-1 means no limit]: ")
+		maxPathLen = ReadNumber()
+		if maxPathLen < 0 {
+			maxPathLen = -1
+			fmt.Printf("OK, the CA has not limit to create ica.\n")
+		} else if maxPathLen == 0 {
+			fmt.Printf("OK, the CA can not to create ica.\n")
+		} else {
+			fmt.Printf("OK, CA can create %d layers of ica.\n", maxPathLen)
+		}
+
+		ocspURLs = ReadURLs("Enter your OCSP Server URL")
+		issurURLs = ReadURLs("Enter your Issuing Certificate URL")
+		crlURLs = ReadURLs("Enter your CRL Distribution Points (URL)")
+	} else {
+		domains, ips, emails, urls, err = readSubjectAltNames()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	fmt.Printf("Set a password for private key: ")
+	password := ReadPassword()
+
+	if isCA {
+		err = subject.SetCNIfEmpty()
+	} else {
+		err = subject.SetCNIfEmpty(domains, ips, emails, urls)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		return
+	}
+
+	_, dirPath, err := ReadDir(homePending, "CSR-", subject)
+	if err != nil {
+		fmt.Printf("Error: %s", err.Error())
+		return
+	}
+
+	if isPendingExists(dirPath) {
+		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the request?")
+		if !ReadBoolDefaultNoPrint() {
+			return
+		}
+	} else {
+		fmt.Printf("Do you confirm to save the request?")
+		if !ReadBoolDefaultYesPrint() {
+			return
+		}
+	}
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	req, key, csrInfo, err := csr.CreateCSR(path.Join(dirPath, "csr-info.json"), cryptoType, keyLength, existingKey, subject, keyUsage, extKeyUsage, isCA, maxPathLen, domains, ips, emails, urls, ocspURLs, issurURLs, crlURLs, signatureAlgorithm)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	setKeyRandSource(csrInfo, keyRandSource)
+
+	err = savePending(dirPath, req, key, password, csrInfo)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	fmt.Println("Send the csr.pem to the external CA, and import the signed certificate after it is issued.")
+}
+
+func ImportSignedICA() {
+	name, _, key, password, csrInfo, err := LoadPending()
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		return
+	}
+
+	if !csrInfo.IsCA {
+		fmt.Println("Error: the request is not for a CA")
+		return
+	}
+
+	fmt.Printf("Enter the path of the signed certificate (PEM or DER, may include the upstream chain): ")
+	certs, err := utils.ReadCertificates(ReadString())
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	} else if len(certs) == 0 {
+		fmt.Printf("Error: no certificate found in the file\n")
+		return
+	}
+
+	chain := certs[1:]
+	if len(chain) == 0 {
+		fmt.Printf("Enter the path of the upstream chain [empty if not needed]: ")
+		chainPath := ReadString()
+		if chainPath != "" {
+			chain, err = utils.ReadCertificates(chainPath)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			} else if len(chain) == 0 {
+				fmt.Printf("Error: no certificate found in the upstream chain\n")
+				return
+			}
+		}
+	}
+
+	icaCert := certs[0]
+	err = csr.CheckSignedCertificate(icaCert, key, csrInfo, chain)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("The certificate subject: %s\n", icaCert.Subject.String())
+	fmt.Printf("The certificate issuer: %s\n", icaCert.Issuer.String())
+
+	subject, err := global.NewCertSubjectFromPkixName(icaCert.Subject)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	_, dirPath, err := ReadDir(homeICA, "ICA-", subject)
+	if err != nil {
+		fmt.Printf("Error: %s", err.Error())
+		return
+	}
+
+	if isCertificateExists(dirPath) {
+		fmt.Printf("There is a duplicate file, it will be overwritten. Do you confirm to save the certificate?")
+		if !ReadBoolDefaultNoPrint() {
+			return
+		}
+	} else {
+		fmt.Printf("Do you confirm to save the certificate?")
+		if !ReadBoolDefaultYesPrint() {
+			return
+		}
+	}
+
+	err = saveSignedICA(dirPath, icaCert, chain, key, password, csrInfo)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	err = os.RemoveAll(path.Join(homePending, name))
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+}
+
+func isPendingExists(dirPath string) bool {
+	return utils.IsExists(path.Join(dirPath, "csr.pem")) || utils.IsExists(path.Join(dirPath, "key.pem"))
+}
+
+func savePending(dirPath string, req *x509.CertificateRequest, key crypto.PrivateKey, password string, csrInfo *csr.CSRInfo) error {
+	err := csrInfo.SaveCSRInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateRequest(dirPath, req)
+	if err != nil {
+		return err
+	}
+
+	err = utils.SavePrivateKey(key, password, path.Join(dirPath, "key.pem"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// saveSignedICA 将外部CA签发的ICA按照标准布局保存，使得 loadICA 可以使用
+func saveSignedICA(dirPath string, icaCert *x509.Certificate, chain []*x509.Certificate, key crypto.PrivateKey, password string, csrInfo *csr.CSRInfo) error {
+	chainPEM := make([]byte, 0, 1024*len(chain))
+	for _, c := range chain {
+		chainPEM = append(chainPEM, pem.EncodeToMemory(&pem.Block{
+			Type:  utils.PemTypeCertificate,
+			Bytes: c.Raw,
+		})...)
+	}
+
+	err := os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return err
+	}
+
+	upstream := &ica.ExternalCAInfo{
+		IssuingCertificateURL: icaCert.IssuingCertificateURL,
+	}
+
+	icaInfo, err := ica.NewICAInfo(path.Join(dirPath, "ica-info.json"), upstream, csrInfo.OCSPServer, csrInfo.IssuingCertificateURL, csrInfo.CRLDistributionPoints)
+	if err != nil {
+		return err
+	}
+	icaInfo.RandSource = csrInfo.RandSource // 私钥在生成CSR时创建
+	icaInfo.SignatureAlgorithm = csrInfo.SignatureAlgorithm
+
+	err = icaInfo.SaveICAInfo()
+	if err != nil {
+		return err
+	}
+
+	err = saveCertificateAndKey(dirPath, icaCert, key, password, chainPEM)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// caCertificatePolicies 返回CA信息中的命名证书策略
+func caCertificatePolicies(caInfo any) []*utils.CertificatePolicy {
+	switch info := caInfo.(type) {
+	case *rootca.RCAInfo:
+		return info.Policies
+	case *ica.ICAInfo:
+		return info.Policies
+	default:
+		return nil
+	}
+}
+
+// loadLocalCAInfo 读取CA的信息文件（无需私钥）
+func loadLocalCAInfo(ca *localCertificate) (cert.CAInfo, error) {
+	switch ca.Type {
+	case "RCA":
+		return rootca.GetRCAInfo(path.Join(ca.DirPath(), "rca-info.json"))
+	case "ICA":
+		return ica.GetICAInfo(path.Join(ca.DirPath(), "ica-info.json"))
+	default:
+		return nil, fmt.Errorf("unknown CA type")
+	}
+}
+
+// selectCertificatePolicies 按名称选择证书策略，allowAny为true时（CA证书）允许使用any表示anyPolicy
+func selectCertificatePolicies(policies []*utils.CertificatePolicy, names []string, allowAny bool) ([]*utils.CertificatePolicy, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	res := make([]*utils.CertificatePolicy, 0, len(names))
+	for _, name := range names {
+		if name == utils.AnyPolicyName && !allowAny {
+			return nil, fmt.Errorf("anyPolicy can only be used in the CA certificate")
+		}
+
+		p, err := utils.FindCertificatePolicy(policies, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range res {
+			if r.OID == p.OID {
+				return nil, fmt.Errorf("duplicate certificate policy: %s", name)
+			}
+		}
+
+		res = append(res, p)
+	}
+
+	return res, nil
+}
+
+// resolvePolicyOID 将策略名称转换为OID，已经是OID时原样返回
+func resolvePolicyOID(policies []*utils.CertificatePolicy, s string) (string, error) {
+	if oid, err := utils.ParseObjectIdentifier(s); err == nil {
+		return oid.String(), nil
+	}
+
+	p, err := utils.FindCertificatePolicy(policies, s)
+	if err != nil {
+		return "", err
+	}
+
+	return p.OID, nil
+}
+
+// newCAPolicies 生成新CA的证书策略扩展以及保存在新CA信息中的命名策略，isICA为false时（RCA）不允许策略映射
+// 新CA继承其证书中包含的上级CA的命名策略（证书包含anyPolicy时继承全部），再加上自己定义的策略
+func newCAPolicies(issuerPolicies []*utils.CertificatePolicy, defs []*utils.CertificatePolicy, names []string, mappings []string, isICA bool, requireExplicitPolicy int, inhibitPolicyMapping int, inhibitAnyPolicy int) (*utils.PolicySettings, []*utils.CertificatePolicy, error) {
+	available := utils.MergeCertificatePolicies(issuerPolicies, defs)
+
+	selected, err := selectCertificatePolicies(available, names, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings := &utils.PolicySettings{
+		Policies:              selected,
+		RequireExplicitPolicy: requireExplicitPolicy,
+		InhibitPolicyMapping:  inhibitPolicyMapping,
+		InhibitAnyPolicy:      inhibitAnyPolicy,
+	}
+
+	for _, m := range mappings {
+		issuerDomainPolicy, subjectDomainPolicy, ok := strings.Cut(m, "=")
+		if !ok {
+			return nil, nil, fmt.Errorf("not a valid policy mapping (issuer=subject): %s", m)
+		}
+
+		mapping := &utils.PolicyMapping{}
+
+		mapping.IssuerDomainPolicy, err = resolvePolicyOID(available, strings.TrimSpace(issuerDomainPolicy))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		mapping.SubjectDomainPolicy, err = resolvePolicyOID(available, strings.TrimSpace(subjectDomainPolicy))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		settings.Mappings = append(settings.Mappings, mapping)
+	}
+
+	if len(settings.Mappings) != 0 && !isICA {
+		return nil, nil, fmt.Errorf("policy mappings can only be used in ICA")
+	}
+
+	inherited := make([]*utils.CertificatePolicy, 0, len(selected))
+	for _, p := range selected {
+		if p.Name == utils.AnyPolicyName {
+			inherited = issuerPolicies
+			break
+		}
+		inherited = append(inherited, p)
+	}
+
+	library := utils.MergeCertificatePolicies(inherited, defs)
+	if len(library) == 0 {
+		library = nil
+	}
+
+	if len(settings.Policies) == 0 && len(settings.Mappings) == 0 && requireExplicitPolicy < 0 && inhibitPolicyMapping < 0 && inhibitAnyPolicy < 0 {
+		return nil, library, nil
+	}
+
+	return settings, library, nil
+}
+
+func parseCAPolicyOption(opt *flagparser.CAPolicyOption, issuerPolicies []*utils.CertificatePolicy, isICA bool) (*utils.PolicySettings, []*utils.CertificatePolicy, error) {
+	defs := make([]*utils.CertificatePolicy, 0, len(opt.PolicyDef))
+	for _, d := range opt.PolicyDef.Value() {
+		p, err := utils.ParseCertificatePolicyDef(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		defs = append(defs, p)
+	}
+
+	return newCAPolicies(issuerPolicies, defs, opt.Policy.Value(), opt.PolicyMapping.Value(), isICA, opt.RequireExplicitPolicy, opt.InhibitPolicyMapping, opt.InhibitAnyPolicy)
+}
+
+// ReadCAPolicies 交互模式下读取新CA的证书策略、策略映射和策略约束，issuerPolicies为上级CA的命名策略，isICA为false时（RCA）不读取策略映射
+func ReadCAPolicies(issuerPolicies []*utils.CertificatePolicy, isICA bool) (*utils.PolicySettings, []*utils.CertificatePolicy, error) {
+	fmt.Printf("Set certificate policies (policy OIDs, mappings and constraints)?")
+	if !ReadBoolDefaultNoPrint() {
+		return nil, nil, nil
+	}
+
+	if isICA {
+		printCertificatePolicies(issuerPolicies)
+	}
+
+	defs, err := ReadMoreStringWithPolicy("Define a named policy of the new CA [name=OID;cps=URL;notice=TEXT]", func(s string) (*utils.CertificatePolicy, error) {
+		p, err := utils.ParseCertificatePolicyDef(s)
+		if err != nil {
+			return nil, NewWarning(err.Error())
+		}
+		return p, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := ReadMoreString("Enter the name of the policy in the CA certificate ['any' means anyPolicy]")
+
+	var mappings []string
+	if isICA {
+		mappings = ReadMoreString("Enter the policy mapping [issuer=subject, name or OID]")
+	}
+
+	requireExplicitPolicy := readPolicySkipCerts("Set the requireExplicitPolicy of the policy constraints")
+	inhibitPolicyMapping := readPolicySkipCerts("Set the inhibitPolicyMapping of the policy constraints")
+	inhibitAnyPolicy := readPolicySkipCerts("Set the inhibitAnyPolicy")
+
+	return newCAPolicies(issuerPolicies, defs, names, mappings, isICA, requireExplicitPolicy, inhibitPolicyMapping, inhibitAnyPolicy)
+}
+
+// readPolicySkipCerts 读取策略约束中的证书数量（SkipCerts），为空时返回-1表示不设置
+func readPolicySkipCerts(tips string) int {
+	fmt.Printf("%s [empty means not set]: ", tips)
+	input := ReadString()
+	if input == "" {
+		return -1
+	}
+
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 0 {
+		fmt.Println("Error: not a valid number, not set")
+		return -1
+	}
+
+	return n
+}
+
+// ReadCertificatePolicies 交互模式下从签发CA的命名策略中选择用户证书的证书策略
+func ReadCertificatePolicies(policies []*utils.CertificatePolicy) ([]*utils.CertificatePolicy, error) {
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	printCertificatePolicies(policies)
+	return selectCertificatePolicies(policies, ReadMoreString("Enter the name of the certificate policy"), false)
+}
+
+func printCertificatePolicies(policies []*utils.CertificatePolicy) {
+	fmt.Println("Certificate policies: ", len(policies))
+	if len(policies) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " NAME\tOID\tCPS\tUSER NOTICE")
+
+	for _, p := range policies {
+		_, _ = fmt.Fprintf(w, " %s\t%s\t%s\t%s\n", p.Name, p.OID, strings.Join(p.CPS, ", "), p.UserNotice)
+	}
+
+	_ = w.Flush()
+}
+
+func CommandAddPolicy(opt *flagparser.PolicyAddOption) error {
+	if opt.Issuer == "" {
+		return fmt.Errorf("the issuer must be set")
+	} else if len(opt.Def) == 0 {
+		return fmt.Errorf("the policy definition must be set")
+	}
+
+	ca, err := loadLocalCA(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	caInfo, err := loadLocalCAInfo(ca)
+	if err != nil {
+		return err
+	}
+
+	defs := make([]*utils.CertificatePolicy, 0, len(opt.Def))
+	for _, d := range opt.Def.Value() {
+		p, err := utils.ParseCertificatePolicyDef(d)
+		if err != nil {
+			return err
+		}
+		defs = append(defs, p)
+	}
+
+	switch info := caInfo.(type) {
+	case *rootca.RCAInfo:
+		info.Policies = utils.MergeCertificatePolicies(info.Policies, defs)
+	case *ica.ICAInfo:
+		info.Policies = utils.MergeCertificatePolicies(info.Policies, defs)
+	}
+
+	err = saveCAInfo(caInfo)
+	if err != nil {
+		return err
+	}
+
+	printCertificatePolicies(caCertificatePolicies(caInfo))
+	return nil
+}
+
+func CommandListPolicy(opt *flagparser.PolicyListOption) error {
+	if opt.Issuer == "" {
+		return fmt.Errorf("the issuer must be set")
+	}
+
+	ca, err := loadLocalCA(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	caInfo, err := loadLocalCAInfo(ca)
+	if err != nil {
+		return err
+	}
+
+	policies := caCertificatePolicies(caInfo)
+
+	switch strings.ToLower(opt.Format) {
+	case listFormatTable:
+		printCertificatePolicies(policies)
+		return nil
+	case listFormatJSON:
+		if policies == nil {
+			policies = make([]*utils.CertificatePolicy, 0)
+		}
+
+		data, err := json.MarshalIndent(policies, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+		return nil
+	default:
+		return fmt.Errorf("unknown format: %s", opt.Format)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/SongZihuan/MyCA/src/sysinfo"
 	"github.com/SongZihuan/MyCA/src/utils"
 	"golang.org/x/term"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -98,6 +99,70 @@ func ReadTimeDuration(defaultVal time.Duration) time.Duration {
 	return res
 }
 
+// ReadSignatureAlgorithm 读取签名算法，直接回车表示由私钥决定（RSA使用SHA256WithRSA，ECDSA根据曲线选择哈希）
+func ReadSignatureAlgorithm(cryptoType utils.CryptoType) x509.SignatureAlgorithm {
+	algList := utils.SignatureAlgorithmListForCryptoType(cryptoType)
+	if len(algList) <= 1 {
+		return x509.UnknownSignatureAlgorithm
+	}
+
+	fmt.Println("Signature Algorithm Menu:")
+	for i, alg := range algList {
+		fmt.Printf(" %d) %s\n", i+1, alg.String())
+	}
+	fmt.Printf("Signature Algorithm [default: decided by the key]: ")
+
+	n := ReadNumber()
+	if n == 0 {
+		return x509.UnknownSignatureAlgorithm
+	} else if n < 0 || n > len(algList) {
+		fmt.Println("Warn: Use Default")
+		return x509.UnknownSignatureAlgorithm
+	}
+
+	fmt.Println("Signature Algorithm: ", algList[n-1].String())
+	return algList[n-1]
+}
+
+// ReadSerialMode 读取CA签发证书时序列号的分配模式
+func ReadSerialMode() string {
+	fmt.Printf("Use fully random 128-bit serial numbers (as public CAs do) instead of sequential serial numbers?")
+	if ReadBoolDefaultNoPrint() {
+		return utils.SerialModeRandom
+	}
+	return utils.SerialModeSequential
+}
+
+// ReadNameConstraints 读取CA证书的名称约束，不设置约束时返回nil
+func ReadNameConstraints() (*utils.NameConstraints, error) {
+	fmt.Printf("Set name constraints to restrict the names (DNS, IP, email, URI) the CA can issue?")
+	if !ReadBoolDefaultNoPrint() {
+		return nil, nil
+	}
+
+	nameConstraints := &utils.NameConstraints{
+		PermittedDNSDomains:     ReadMoreString("Enter the permitted DNS domain [a leading '.' means subdomains only]"),
+		ExcludedDNSDomains:      ReadMoreString("Enter the excluded DNS domain"),
+		PermittedIPRanges:       ReadMoreString("Enter the permitted IP range [CIDR, e.g. 10.0.0.0/8]"),
+		ExcludedIPRanges:        ReadMoreString("Enter the excluded IP range [CIDR]"),
+		PermittedEmailAddresses: ReadMoreString("Enter the permitted email address or domain"),
+		ExcludedEmailAddresses:  ReadMoreString("Enter the excluded email address or domain"),
+		PermittedURIDomains:     ReadMoreString("Enter the permitted URI domain"),
+		ExcludedURIDomains:      ReadMoreString("Enter the excluded URI domain"),
+	}
+
+	err := nameConstraints.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	if nameConstraints.IsEmpty() {
+		return nil, nil
+	}
+
+	return nameConstraints, nil
+}
+
 func ReadBoolDefaultYesPrint() bool {
 	fmt.Printf(" [default=yes/no] ")
 	return ReadBoolDefaultYes()
@@ -252,7 +317,7 @@ Please choose a name: `, name1, name2, name3, name4)
 	if ReadBoolDefaultYesPrint() {
 		nameAfterEdit := processDirName(name)
 		if utils.IsValidFilename(nameAfterEdit) {
-			fmt.Printf("The new name (%s) is valid, Are you sure you want to use it?")
+			fmt.Printf("The new name (%s) is valid, Are you sure you want to use it?", nameAfterEdit)
 			if ReadBoolDefaultYesPrint() {
 				return nameAfterEdit, path.Join(basePath, nameAfterEdit), nil
 			}
@@ -606,3 +671,27 @@ func ReadExtKeyUsage() ([]x509.ExtKeyUsage, error) {
 
 	return res, nil
 }
+
+func ReadURLs(tips string) []string {
+	res := make([]string, 0, 10)
+	for {
+		fmt.Printf("%s [empty to stop]: ", tips)
+		input := ReadString()
+		if input == "" {
+			break
+		}
+
+		u, err := url.Parse(input)
+		if err != nil {
+			fmt.Printf("Error: not a valid URL (%s)\n", err.Error())
+			break
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			fmt.Println("Error: not a valid HTTP/HTTPS URL")
+			break
+		}
+
+		res = append(res, u.String())
+	}
+
+	return res
+}
@@ -0,0 +1,357 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// renewArchiveFiles 续期时归档到历史目录的文件
+var renewArchiveFiles = []string{"cert.pem", "cert.cer", "fullchain.pem", "fullchain.cer", "key.pem", "cert.spx", "cert.pfx", "cert-info.json"}
+
+// renewOption 续期证书的参数
+type renewOption struct {
+	Rekey              bool              // 是否更换私钥
+	CryptoType         utils.CryptoType  // 更换私钥时生成新私钥的类型
+	KeyLength          int               // 更换私钥时生成新私钥的长度
+	Key                crypto.PrivateKey // 更换私钥时使用的已有私钥，为nil时生成新的私钥
+	KeyRandSource      string            // 已有私钥的随机源
+	Validity           time.Duration     // 新证书的有效期，为0时与旧证书相同
+	SignatureAlgorithm x509.SignatureAlgorithm
+	PasswordFunc       func() string // 旧私钥的密码
+	IssuerPasswordFunc func() string // 签发CA私钥的密码
+	NewPassword        string        // 保存新私钥使用的密码
+}
+
+func historyDirPath(dirPath string) string {
+	return path.Join(dirPath, "history")
+}
+
+// isSelfSigned 判断证书是否为自签名证书（自签名的用户证书不是CA，不能使用CheckSignatureFrom）
+func isSelfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawSubject, c.RawIssuer) && c.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature) == nil
+}
+
+func RenewCertificate() {
+	certs := loadAllLocalCertificate("CERT")
+
+	fmt.Println("总计: ", len(certs))
+	for i, c := range certs {
+		fmt.Printf(" %d. %s (expires: %s)\n", i+1, c.String(), c.Cert.NotAfter.Format(time.DateTime))
+	}
+
+	if len(certs) == 0 {
+		fmt.Println("Error: no certificate available")
+		return
+	}
+
+	fmt.Printf("Select a certificate and enter its serial number: ")
+	i := ReadNumber() - 1 // 显示的列表是从1开始计数的
+
+	if i < 0 || i >= len(certs) {
+		fmt.Println("Error: invalid serial number")
+		return
+	}
+
+	target := certs[i]
+	showRenewCertificate(target.Cert)
+
+	opt := &renewOption{
+		PasswordFunc: func() string {
+			fmt.Printf("Enter the password of the current private key: ")
+			return ReadPassword()
+		},
+		IssuerPasswordFunc: func() string {
+			fmt.Printf("Enter the password of the issuer private key: ")
+			return ReadPassword()
+		},
+	}
+
+	if utils.IsExists(path.Join(target.DirPath(), "key.pem")) {
+		fmt.Printf("Generate a new private key (rekey)?")
+		opt.Rekey = ReadBoolDefaultNoPrint()
+	} else {
+		fmt.Println("The private key is not kept by MyCA (e.g. issued from a CSR), the public key will be reused.")
+	}
+
+	if opt.Rekey {
+		var err error
+		opt.CryptoType, opt.KeyLength, opt.Key, opt.KeyRandSource, err = ReadKey()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	oldValidity := target.Cert.NotAfter.Sub(target.Cert.NotBefore)
+	fmt.Printf("Validity [default: %s]: ", oldValidity.String())
+	opt.Validity = ReadTimeDuration(oldValidity)
+
+	if opt.Rekey && isSelfSigned(target.Cert) {
+		opt.SignatureAlgorithm = ReadSignatureAlgorithm(opt.CryptoType)
+	}
+
+	if utils.IsExists(path.Join(target.DirPath(), "key.pem")) {
+		fmt.Printf("Enter the password of the new private key file (leave empty for no password): ")
+		opt.NewPassword = ReadPassword()
+	}
+
+	fmt.Printf("Do you confirm to renew %s?", target.String())
+	if !ReadBoolDefaultYesPrint() {
+		return
+	}
+
+	historyPath, err := renewCertificate(target, opt)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Println("Success, save directory: ", target.DirPath())
+	fmt.Println("The previous certificate has been archived to: ", historyPath)
+}
+
+func showRenewCertificate(c *x509.Certificate) {
+	fmt.Println("Certificate:")
+	fmt.Printf("  Subject: %s\n", c.Subject.String())
+	fmt.Printf("  Issuer: %s\n", c.Issuer.String())
+	fmt.Printf("  Not Before: %s\n", c.NotBefore.Format(time.DateTime))
+	fmt.Printf("  Not After: %s\n", c.NotAfter.Format(time.DateTime))
+
+	for _, d := range c.DNSNames {
+		fmt.Printf("  DNS: %s\n", d)
+	}
+
+	for _, ip := range c.IPAddresses {
+		fmt.Printf("  IP: %s\n", ip.String())
+	}
+
+	for _, e := range c.EmailAddresses {
+		fmt.Printf("  Email: %s\n", e)
+	}
+
+	for _, u := range c.URIs {
+		fmt.Printf("  URI: %s\n", u.String())
+	}
+}
+
+// renewCertificate 续期证书：从旧证书中还原证书的内容，由同一CA（或自签名）重新签发，并将旧证书归档到历史目录
+func renewCertificate(target *localCertificate, opt *renewOption) (string, error) {
+	dirPath := target.DirPath()
+	hasKey := utils.IsExists(path.Join(dirPath, "key.pem"))
+
+	if opt.Rekey && !hasKey {
+		return "", fmt.Errorf("the private key is not kept by MyCA, can not rekey")
+	}
+
+	notBefore := time.Now()
+	validity := opt.Validity
+	if validity <= 0 {
+		validity = target.Cert.NotAfter.Sub(target.Cert.NotBefore)
+	}
+	notAfter := notBefore.Add(validity)
+
+	var key crypto.PrivateKey
+	var pubKey crypto.PublicKey
+	var randSource string
+	var err error
+
+	if opt.Rekey {
+		key, pubKey, err = utils.GenerateKeyIfNil(opt.Key, opt.CryptoType, opt.KeyLength)
+		if err != nil {
+			return "", err
+		}
+
+		if opt.Key == nil {
+			randSource = utils.RandSource()
+		} else {
+			randSource = opt.KeyRandSource
+		}
+	} else if hasKey {
+		key, err = readPrivateKey(path.Join(dirPath, "key.pem"), opt.PasswordFunc)
+		if err != nil {
+			return "", err
+		}
+
+		err = utils.CheckKeyPair(target.Cert, key)
+		if err != nil {
+			return "", err
+		}
+
+		pubKey = target.Cert.PublicKey
+
+		randSource, err = readRandSource("CERT", path.Join(dirPath, "cert-info.json"))
+		if err != nil {
+			return "", err
+		} else if randSource == "" {
+			randSource = utils.RandSourceMathRand
+		}
+	} else {
+		pubKey = target.Cert.PublicKey
+	}
+
+	var newCert *x509.Certificate
+	var caFullchain []byte
+	var saveInfo func() error
+	var issuerInfo cert.CAInfo // 自签名证书为nil
+
+	if isSelfSigned(target.Cert) {
+		if key == nil {
+			return "", fmt.Errorf("the private key of the self signed certificate is not found")
+		}
+
+		signatureAlgorithm := opt.SignatureAlgorithm
+		if signatureAlgorithm == x509.UnknownSignatureAlgorithm && !opt.Rekey {
+			if oldInfo, err := cert.GetSelfCertInfo(path.Join(dirPath, "cert-info.json")); err == nil {
+				signatureAlgorithm = oldInfo.SignatureAlgorithm
+			}
+		}
+
+		var info *cert.SelfCertInfo
+		newCert, info, err = cert.RenewSelfCert(path.Join(dirPath, "cert-info.json"), key, target.Cert, notBefore, notAfter, signatureAlgorithm)
+		if err != nil {
+			return "", err
+		}
+
+		setKeyRandSource(info, randSource)
+		saveInfo = info.SaveSelfCert
+	} else {
+		issuer, err := findLocalIssuer(target.Cert)
+		if err != nil {
+			return "", err
+		}
+
+		var caCert *x509.Certificate
+		var caKey crypto.PrivateKey
+		var caInfo cert.CAInfo
+		switch issuer.Type {
+		case "RCA":
+			caCert, caKey, caFullchain, caInfo, err = loadRCA(issuer.Name, opt.IssuerPasswordFunc)
+		default:
+			caCert, caKey, caFullchain, caInfo, err = loadICA(issuer.Name, opt.IssuerPasswordFunc)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		err = checkNameConstraints(caCert, caFullchain, target.Cert.DNSNames, target.Cert.IPAddresses, target.Cert.EmailAddresses, target.Cert.URIs)
+		if err != nil {
+			return "", err
+		}
+
+		var info *cert.CertInfo
+		newCert, info, err = cert.RenewCert(path.Join(dirPath, "cert-info.json"), caInfo, pubKey, target.Cert, notBefore, notAfter, caCert, caKey, opt.SignatureAlgorithm)
+		if err != nil {
+			return "", err
+		}
+
+		err = recordIssuance(caInfo, newCert, "CERT", dirPath)
+		if err != nil {
+			return "", err
+		}
+
+		err = saveCAInfo(caInfo)
+		if err != nil {
+			return "", err
+		}
+
+		setKeyRandSource(info, randSource)
+		saveInfo = info.SaveCertInfo
+		issuerInfo = caInfo
+	}
+
+	historyPath, err := archiveCertificate(dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if issuerInfo != nil {
+		err = recordSupersede(issuerInfo, target.Cert, newCert, historyPath)
+		if err != nil {
+			return historyPath, err
+		}
+	}
+
+	err = saveInfo()
+	if err != nil {
+		return historyPath, err
+	}
+
+	if key != nil {
+		err = saveCertificateAndKey(dirPath, newCert, key, opt.NewPassword, caFullchain)
+	} else {
+		err = saveCertificateOnly(dirPath, newCert, caFullchain)
+	}
+	if err != nil {
+		return historyPath, err
+	}
+
+	return historyPath, nil
+}
+
+// archiveCertificate 将目录中的证书、证书链和私钥移动到历史目录中新的版本子目录（v1、v2...）
+func archiveCertificate(dirPath string) (string, error) {
+	historyPath := historyDirPath(dirPath)
+
+	err := os.MkdirAll(historyPath, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	names, err := utils.ReadDirOnlyDir(historyPath)
+	if err != nil {
+		return "", err
+	}
+
+	version := 0
+	for _, name := range names {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+		if err == nil && strings.HasPrefix(name, "v") && n > version {
+			version = n
+		}
+	}
+
+	versionPath := path.Join(historyPath, fmt.Sprintf("v%d", version+1))
+	err = os.MkdirAll(versionPath, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	for _, name := range renewArchiveFiles {
+		filePath := path.Join(dirPath, name)
+		if !utils.IsExists(filePath) {
+			continue
+		}
+
+		err = os.Rename(filePath, path.Join(versionPath, name))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if utils.IsExists(path.Join(dirPath, "csr.pem")) { // 签发CSR得到的证书续期时沿用其公钥，CSR仍然有效，仅复制
+		data, err := os.ReadFile(path.Join(dirPath, "csr.pem"))
+		if err != nil {
+			return "", err
+		}
+
+		err = os.WriteFile(path.Join(versionPath, "csr.pem"), data, 0600)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return versionPath, nil
+}
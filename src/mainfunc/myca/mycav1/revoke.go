@@ -0,0 +1,297 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"path"
+	"strings"
+	"time"
+)
+
+// localCertificate 保存在MyCA中的证书（RCA、ICA或用户证书）
+type localCertificate struct {
+	Type string // RCA、ICA或CERT
+	Name string
+	Cert *x509.Certificate
+}
+
+func (c *localCertificate) DirPath() string {
+	return path.Join(certTypeHome(c.Type), c.Name)
+}
+
+func (c *localCertificate) String() string {
+	return fmt.Sprintf("%s %s (serial number: %s)", c.Type, c.Name, c.Cert.SerialNumber.Text(16))
+}
+
+func certTypeHome(certType string) string {
+	switch strings.ToUpper(certType) {
+	case "RCA":
+		return homeRCA
+	case "ICA":
+		return homeICA
+	default:
+		return homeCert
+	}
+}
+
+// loadLocalCA 根据类型和目录名读取CA证书
+func loadLocalCA(caType string, name string) (*localCertificate, error) {
+	if t := strings.ToUpper(caType); t != "RCA" && t != "ICA" {
+		return nil, fmt.Errorf("unknown issuer type: %s", caType)
+	} else if !utils.IsValidFilename(name) {
+		return nil, fmt.Errorf("not a valid name: %s", name)
+	}
+
+	return loadLocalCertificate(caType, name)
+}
+
+func revocationDBPath(caDirPath string) string {
+	return path.Join(caDirPath, revoke.DBFileName)
+}
+
+func loadLocalCertificate(certType string, name string) (*localCertificate, error) {
+	certPEM, err := utils.ReadPemBlock(path.Join(certTypeHome(certType), name, "cert.pem"))
+	if err != nil {
+		return nil, err
+	} else if certPEM.Type != utils.PemTypeCertificate {
+		return nil, fmt.Errorf("pem type of cert error")
+	}
+
+	cert, err := x509.ParseCertificate(certPEM.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &localCertificate{
+		Type: strings.ToUpper(certType),
+		Name: name,
+		Cert: cert,
+	}, nil
+}
+
+func loadAllLocalCertificate(certTypes ...string) []*localCertificate {
+	res := make([]*localCertificate, 0, 10)
+
+	for _, certType := range certTypes {
+		names, err := utils.ReadDirOnlyDir(certTypeHome(certType))
+		if err != nil {
+			continue
+		}
+
+		for _, name := range names {
+			c, err := loadLocalCertificate(certType, name)
+			if err != nil {
+				continue // 目录中可能存在未完成的证书，跳过
+			}
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+// findLocalIssuer 查找签发该证书的CA（RCA或ICA）
+func findLocalIssuer(cert *x509.Certificate) (*localCertificate, error) {
+	for _, ca := range loadAllLocalCertificate("RCA", "ICA") {
+		if bytes.Equal(ca.Cert.Raw, cert.Raw) {
+			continue
+		} else if !bytes.Equal(ca.Cert.RawSubject, cert.RawIssuer) {
+			continue
+		}
+
+		if cert.CheckSignatureFrom(ca.Cert) == nil {
+			return ca, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the issuer of the certificate (%s) is not managed by MyCA", cert.Subject.String())
+}
+
+// findLocalCertificate 根据目录名、序列号或指纹查找证书，issuer不为nil时只查找该CA签发的证书
+func findLocalCertificate(certType string, name string, serialNumber *big.Int, fingerprint string, issuer *localCertificate) ([]*localCertificate, error) {
+	if name != "" {
+		c, err := loadLocalCertificate(certType, name)
+		if err != nil {
+			return nil, err
+		}
+		return []*localCertificate{c}, nil
+	}
+
+	res := make([]*localCertificate, 0, 1)
+	for _, c := range loadAllLocalCertificate("ICA", "CERT") {
+		if serialNumber != nil && c.Cert.SerialNumber.Cmp(serialNumber) != 0 {
+			continue
+		} else if fingerprint != "" && revoke.Fingerprint(c.Cert) != fingerprint {
+			continue
+		} else if issuer != nil && (!bytes.Equal(issuer.Cert.RawSubject, c.Cert.RawIssuer) || c.Cert.CheckSignatureFrom(issuer.Cert) != nil) {
+			continue
+		}
+		res = append(res, c)
+	}
+
+	return res, nil
+}
+
+func parseSerialNumber(s string) (*big.Int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.ReplaceAll(s, ":", "")
+
+	res, ok := new(big.Int).SetString(s, 16)
+	if !ok || res.Sign() <= 0 {
+		return nil, fmt.Errorf("not a valid serial number (hex): %s", s)
+	}
+
+	return res, nil
+}
+
+// revokeCertificate 在签发CA的吊销数据库中记录吊销
+func revokeCertificate(target *localCertificate, reason revoke.Reason) (*localCertificate, *revoke.RevokedCert, error) {
+	issuer, err := findLocalIssuer(target.Cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lock, err := lockCA(issuer.DirPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = lock.Unlock()
+	}()
+
+	db, err := revoke.GetRevocationDB(revocationDBPath(issuer.DirPath()))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	record, err := db.Revoke(target.Cert, reason, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = db.SaveRevocationDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = recordRevocation(issuer, record)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return issuer, record, nil
+}
+
+func showRevocationDB(ca *localCertificate) error {
+	db, err := revoke.GetRevocationDB(revocationDBPath(ca.DirPath()))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s 吊销列表 总计: %d\n", ca.String(), len(db.Revoked))
+
+	for i, r := range db.Revoked {
+		fmt.Printf(" %d. serial number: %s\n", i+1, r.SerialNumber.Text(16))
+		fmt.Printf("    revoked at: %s\n", r.RevokedAt.Format(time.RFC3339))
+		fmt.Printf("    reason: %s (%d)\n", r.Reason.String(), int(r.Reason))
+		if r.Subject != "" {
+			fmt.Printf("    subject: %s\n", r.Subject)
+		}
+		if r.Fingerprint != "" {
+			fmt.Printf("    fingerprint (SHA-256): %s\n", r.Fingerprint)
+		}
+	}
+
+	return nil
+}
+
+func readRevocationReason() revoke.Reason {
+	fmt.Println("Revocation Reason:")
+	for _, r := range revoke.ReasonList {
+		fmt.Printf(" %d) %s\n", int(r), r.String())
+	}
+	fmt.Printf("Select the reason [default: unspecified]: ")
+
+	reason, err := revoke.ParseReason(ReadString())
+	if err != nil {
+		fmt.Println("Warn: Use Default")
+		return revoke.ReasonUnspecified
+	}
+
+	return reason
+}
+
+func RevokeCertificate() {
+	certs := loadAllLocalCertificate("ICA", "CERT")
+
+	fmt.Println("总计: ", len(certs))
+	for i, c := range certs {
+		fmt.Printf(" %d. %s\n", i+1, c.String())
+	}
+
+	if len(certs) == 0 {
+		fmt.Println("Error: no certificate available")
+		return
+	}
+
+	fmt.Printf("Select a certificate and enter its serial number: ")
+	i := ReadNumber() - 1 // 显示的列表是从1开始计数的
+
+	if i < 0 || i >= len(certs) {
+		fmt.Println("Error: invalid serial number")
+		return
+	}
+
+	target := certs[i]
+	reason := readRevocationReason()
+
+	fmt.Printf("Do you confirm to revoke %s with reason %s?", target.String(), reason.String())
+	if !ReadBoolDefaultNoPrint() {
+		return
+	}
+
+	issuer, _, err := revokeCertificate(target, reason)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Success, the certificate has been revoked by %s\n", issuer.String())
+}
+
+func ShowRevokedCertificates() {
+	cas := loadAllLocalCertificate("RCA", "ICA")
+
+	fmt.Println("总计: ", len(cas))
+	for i, c := range cas {
+		fmt.Printf(" %d. %s\n", i+1, c.String())
+	}
+
+	if len(cas) == 0 {
+		fmt.Println("Error: no CA available")
+		return
+	}
+
+	fmt.Printf("Select a CA and enter its serial number: ")
+	i := ReadNumber() - 1 // 显示的列表是从1开始计数的
+
+	if i < 0 || i >= len(cas) {
+		fmt.Println("Error: invalid serial number")
+		return
+	}
+
+	err := showRevocationDB(cas[i])
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+}
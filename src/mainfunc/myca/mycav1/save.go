@@ -0,0 +1,106 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/ica"
+	"github.com/SongZihuan/MyCA/src/rootca"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"os"
+	"path"
+)
+
+func isCertificateExists(dirPath string) bool {
+	return utils.IsExists(path.Join(dirPath, "cert.pem")) ||
+		utils.IsExists(path.Join(dirPath, "cert.cer")) ||
+		utils.IsExists(path.Join(dirPath, "fullchain.pem")) ||
+		utils.IsExists(path.Join(dirPath, "fullchain.cer")) ||
+		utils.IsExists(path.Join(dirPath, "key.pem"))
+}
+
+// saveCertificateAndKey 将证书、证书链、私钥以及SPX和PFX文件保存到指定目录
+func saveCertificateAndKey(dirPath string, cert *x509.Certificate, key crypto.PrivateKey, password string, caFullchain []byte) error {
+	cert1Path := path.Join(dirPath, "cert.pem")
+	cert2Path := path.Join(dirPath, "cert.cer")
+	fullchain1Path := path.Join(dirPath, "fullchain.pem")
+	fullchain2Path := path.Join(dirPath, "fullchain.cer")
+	keyPath := path.Join(dirPath, "key.pem")
+	spxPath := path.Join(dirPath, "cert.spx")
+	pfxPath := path.Join(dirPath, "cert.pfx")
+
+	err := utils.SaveCertificate(cert, caFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
+	if err != nil {
+		return err
+	}
+
+	err = utils.SavePrivateKey(key, password, keyPath)
+	if err != nil {
+		return err
+	}
+
+	err = utils.SaveSPX(key, password, cert, caFullchain, spxPath)
+	if err != nil {
+		return err
+	}
+
+	err = utils.SavePFX(key, password, cert, caFullchain, pfxPath)
+	if errors.Is(err, utils.ErrPFXUnsupportedKey) {
+		_ = os.Remove(pfxPath) // 覆盖旧证书时删除旧的PFX文件，避免与新证书不匹配
+		fmt.Printf("Warning: %s, skip saving %s\n", err.Error(), pfxPath)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// saveCertificateOnly 仅保存证书和证书链（私钥不由MyCA保管，例如签发CSR时）
+func saveCertificateOnly(dirPath string, cert *x509.Certificate, caFullchain []byte) error {
+	cert1Path := path.Join(dirPath, "cert.pem")
+	cert2Path := path.Join(dirPath, "cert.cer")
+	fullchain1Path := path.Join(dirPath, "fullchain.pem")
+	fullchain2Path := path.Join(dirPath, "fullchain.cer")
+
+	return utils.SaveCertificate(cert, caFullchain, cert1Path, cert2Path, fullchain1Path, fullchain2Path)
+}
+
+func saveCertificateRequest(dirPath string, csr *x509.CertificateRequest) error {
+	csrPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  utils.PemTypeCertificateRequest,
+		Bytes: csr.Raw,
+	})
+
+	return os.WriteFile(path.Join(dirPath, "csr.pem"), csrPEM, 0600)
+}
+
+// saveCAInfo 保存CA（RCA或ICA）的信息，例如签发证书后更新的序列号
+func saveCAInfo(caInfo cert.CAInfo) error {
+	switch info := caInfo.(type) {
+	case *rootca.RCAInfo:
+		return info.SaveRCAInfo()
+	case *ica.ICAInfo:
+		return info.SaveICAInfo()
+	default:
+		return fmt.Errorf("unknown CA type")
+	}
+}
+
+// caInfoDirPath 返回CA（RCA或ICA）所在的目录
+func caInfoDirPath(caInfo cert.CAInfo) (string, error) {
+	switch info := caInfo.(type) {
+	case *rootca.RCAInfo:
+		return path.Dir(info.FilePath), nil
+	case *ica.ICAInfo:
+		return path.Dir(info.FilePath), nil
+	default:
+		return "", fmt.Errorf("unknown CA type")
+	}
+}
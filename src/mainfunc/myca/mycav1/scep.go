@@ -0,0 +1,476 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/scepserver"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// scepCRLNextUpdate GetCRL 时已发布的CRL过期后，重新生成的CRL的有效期
+const scepCRLNextUpdate = 7 * 24 * time.Hour
+
+// scepIssuer 签发SCEP请求的证书，证书保存在 home/cert/SCEP-<CN>-<时间> 中
+type scepIssuer struct {
+	*onlineIssuer
+	ca *localCertificate
+}
+
+func (i *scepIssuer) issue(req *scepserver.IssueRequest) (*x509.Certificate, error) {
+	csr := req.CSR
+
+	for _, domain := range csr.DNSNames {
+		if !utils.IsValidDomain(domain) {
+			return nil, fmt.Errorf("not a valid domain: %s", domain)
+		}
+	}
+
+	for _, email := range csr.EmailAddresses {
+		if !utils.IsValidEmail(email) {
+			return nil, fmt.Errorf("not a valid email: %s", email)
+		}
+	}
+
+	subject, err := global.NewCertSubjectFromPkixName(csr.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = subject.SetCNIfEmpty(csr.DNSNames, csr.IPAddresses, csr.EmailAddresses, csr.URIs)
+	if err != nil {
+		return nil, err
+	}
+
+	extKeyUsage, _, _, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		return nil, err
+	}
+
+	userCert, _, err := i.onlineIssuer.issue(&onlineRequest{
+		DirName:     fmt.Sprintf("SCEP-%s-%s", subject.CN, time.Now().Format("20060102150405")),
+		CSR:         csr,
+		Subject:     subject,
+		Domains:     csr.DNSNames,
+		IPs:         csr.IPAddresses,
+		Emails:      csr.EmailAddresses,
+		URLs:        csr.URIs,
+		ExtKeyUsage: extKeyUsage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Old != nil {
+		err = i.supersede(req.Old, userCert)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return userCert, nil
+}
+
+// verifyRenewal 验证被续期的证书由该CA签发，并且证书链、有效期和吊销状态有效
+func (i *scepIssuer) verifyRenewal(old *x509.Certificate) error {
+	if !isIssuedBy(old, i.caCert) {
+		return fmt.Errorf("the certificate is not issued by %s", i.ca.String())
+	}
+
+	res := verifyCertificates([]*x509.Certificate{old}, &verifyRequest{
+		Purpose: "any",
+		Time:    time.Now(),
+	})
+	if len(res.Errors) != 0 {
+		return fmt.Errorf("%s", strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+// getCertificate 根据签发索引查找证书，证书不由MyCA保存时返回nil
+func (i *scepIssuer) getCertificate(serialNumber *big.Int) (*x509.Certificate, error) {
+	idx, err := loadIssuanceIndex(i.ca)
+	if err != nil {
+		return nil, err
+	}
+
+	issued := idx.Find(serialNumber)
+	if issued == nil || issued.Path == "" {
+		return nil, nil
+	}
+
+	certPath := path.Join(home, issued.Path, "cert.pem")
+	if !utils.IsExists(certPath) {
+		return nil, nil
+	}
+
+	certs, err := utils.ReadCertificates(certPath)
+	if err != nil {
+		return nil, err
+	} else if len(certs) == 0 || certs[0].SerialNumber.Cmp(serialNumber) != 0 {
+		return nil, nil // 目录中的证书已被续期后的新证书覆盖
+	}
+
+	return certs[0], nil
+}
+
+// getCRL 返回CA已发布的CRL，CRL不存在或已过期时重新生成
+func (i *scepIssuer) getCRL() ([]byte, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	derPath := path.Join(i.ca.DirPath(), "crl.crl")
+	if data, err := os.ReadFile(derPath); err == nil {
+		crl, err := x509.ParseRevocationList(data)
+		if err == nil && crl.CheckSignatureFrom(i.caCert) == nil && time.Now().Before(crl.NextUpdate) {
+			return data, nil
+		}
+	}
+
+	_, number, err := generateCRL(i.caCert, i.caKey, i.caInfo, scepCRLNextUpdate, false)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Generated CRL (number: %s) for GetCRL\n", number.String())
+	return os.ReadFile(derPath)
+}
+
+// scepStoreDirPath SCEP服务的挑战密码和签发请求保存在 home/scep/<rca|ica>/<CA名称> 中
+func scepStoreDirPath(ca *localCertificate) string {
+	return path.Join(home, "scep", strings.ToLower(ca.Type), ca.Name)
+}
+
+func openSCEPStore(issuerType string, issuer string) (*scepserver.Store, *localCertificate, error) {
+	if issuer == "" {
+		return nil, nil, fmt.Errorf("the issuer must be set")
+	}
+
+	ca, err := loadLocalCA(issuerType, issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca.Type = strings.ToUpper(ca.Type)
+
+	store, err := scepserver.NewStore(scepStoreDirPath(ca))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return store, ca, nil
+}
+
+func scepRADirPath(caDirPath string) string {
+	return path.Join(caDirPath, "scep-ra")
+}
+
+// createSCEPRA 签发SCEP的RA证书，保存在CA目录的scep-ra子目录中（会覆盖旧的RA证书）
+func createSCEPRA(caCert *x509.Certificate, caKey crypto.PrivateKey, caFullchain []byte, caInfo cert.CAInfo, keyLength int, validity time.Duration, password string) (string, error) {
+	caDirPath, err := caInfoDirPath(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	dirPath := scepRADirPath(caDirPath)
+
+	err = os.MkdirAll(dirPath, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	notBefore := time.Now()
+	raCert, raKey, err := scepserver.CreateRA(caInfo, keyLength, nil, notBefore, notBefore.Add(validity), caCert, caKey)
+	if err != nil {
+		return "", err
+	}
+
+	err = recordIssuance(caInfo, raCert, "SCEP-RA", dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if oldRAs, err := utils.ReadCertificates(path.Join(dirPath, "cert.pem")); err == nil && len(oldRAs) != 0 {
+		err = recordSupersede(caInfo, oldRAs[0], raCert, "") // 旧的RA证书会被覆盖
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = saveCAInfo(caInfo)
+	if err != nil {
+		return "", err
+	}
+
+	raInfo, err := cert.NewCertInfo(path.Join(dirPath, "cert-info.json"), caInfo)
+	if err != nil {
+		return "", err
+	}
+	raInfo.SerialNumber = raCert.SerialNumber
+
+	err = saveCertificateAndKey(dirPath, raCert, raKey, password, caFullchain)
+	if err != nil {
+		return "", err
+	}
+
+	err = raInfo.SaveCertInfo()
+	if err != nil {
+		return "", err
+	}
+
+	return dirPath, nil
+}
+
+func CommandCreateSCEPRA(opt *flagparser.SCEPRAOption) error {
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	if opt.KeyLength < 2048 {
+		return fmt.Errorf("the key length of the RA must be at least 2048")
+	}
+
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	dirPath, err := createSCEPRA(caCert, caKey, caFullchain, caInfo, opt.KeyLength, validity, opt.Password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Success, save directory: ", dirPath)
+	return nil
+}
+
+func CommandCreateSCEPChallenge(opt *flagparser.SCEPChallengeCreateOption) error {
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	} else if opt.Uses < 0 {
+		return fmt.Errorf("not a valid uses: %d", opt.Uses)
+	}
+
+	store, ca, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	challenge, password, err := store.CreateChallenge(validity, opt.Uses, opt.Comment)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, challenge %s of %s (expires at %s)\n", challenge.ID, ca.String(), challenge.ExpiresAt.Format(time.DateTime))
+	fmt.Println("Challenge password (only shown once): ", password)
+	return nil
+}
+
+func CommandListSCEPChallenge(opt *flagparser.SCEPStoreOption) error {
+	store, _, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	challenges, err := store.ListChallenges()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Challenges: ", len(challenges))
+	if len(challenges) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " ID\tSTATUS\tUSES\tCREATED AT\tEXPIRES AT\tCOMMENT")
+
+	for _, c := range challenges {
+		uses := fmt.Sprintf("%d", c.Uses)
+		if c.MaxUses > 0 {
+			uses = fmt.Sprintf("%d/%d", c.Uses, c.MaxUses)
+		}
+		_, _ = fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Status(), uses, c.CreatedAt.Format(time.DateTime), c.ExpiresAt.Format(time.DateTime), c.Comment)
+	}
+
+	return w.Flush()
+}
+
+func CommandRevokeSCEPChallenge(opt *flagparser.SCEPIDOption) error {
+	if opt.ID == "" {
+		return fmt.Errorf("the id must be set")
+	}
+
+	store, _, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	err = store.RevokeChallenge(opt.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, challenge %s has been revoked\n", opt.ID)
+	return nil
+}
+
+func CommandListSCEPRequest(opt *flagparser.SCEPRequestListOption) error {
+	store, _, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	requests, err := store.ListRequests(opt.Status)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Requests: ", len(requests))
+	if len(requests) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, " ID\tTYPE\tSTATUS\tSUBJECT\tSERIAL NUMBER\tCREATED AT\tREASON")
+
+	for _, r := range requests {
+		_, _ = fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.MessageType, r.Status, r.Subject, r.CertificateSerial, r.CreatedAt.Format(time.DateTime), r.Reason)
+	}
+
+	return w.Flush()
+}
+
+func CommandApproveSCEPRequest(opt *flagparser.SCEPIDOption) error {
+	if opt.ID == "" {
+		return fmt.Errorf("the id must be set")
+	}
+
+	store, _, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	req, err := store.ApproveRequest(opt.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, request %s (%s) has been approved, the certificate will be issued when the client polls\n", req.ID, req.Subject)
+	return nil
+}
+
+func CommandRejectSCEPRequest(opt *flagparser.SCEPIDOption) error {
+	if opt.ID == "" {
+		return fmt.Errorf("the id must be set")
+	}
+
+	store, _, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	req, err := store.RejectRequest(opt.ID, opt.Reason)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Success, request %s (%s) has been rejected\n", req.ID, req.Subject)
+	return nil
+}
+
+func CommandServeSCEP(opt *flagparser.ServeSCEPOption) error {
+	validity := utils.ReadTimeDuration(opt.Validity)
+	if validity <= 0 {
+		return fmt.Errorf("not a valid validity: %s", opt.Validity)
+	}
+
+	extKeyUsage, err := parseAllowExtKeyUsageOption(opt.AllowExtKeyUsage.Value())
+	if err != nil {
+		return err
+	}
+
+	caCert, caKey, caFullchain, caInfo, err := parseIssuerOption(&opt.IssuerOption)
+	if err != nil {
+		return err
+	}
+
+	store, ca, err := openSCEPStore(opt.IssuerType, opt.Issuer)
+	if err != nil {
+		return err
+	}
+
+	raDirPath := scepRADirPath(ca.DirPath())
+	if !utils.IsExists(path.Join(raDirPath, "cert.pem")) {
+		return fmt.Errorf("the RA certificate of %s not found, create it with scep ra first", ca.String())
+	}
+
+	raCerts, err := utils.ReadCertificates(path.Join(raDirPath, "cert.pem"))
+	if err != nil {
+		return err
+	}
+
+	raKey, err := readPrivateKey(path.Join(raDirPath, "key.pem"), func() string {
+		return opt.RAPassword
+	})
+	if err != nil {
+		return err
+	}
+
+	online, err := newOnlineIssuer(caCert, caKey, caFullchain, caInfo, validity, opt.Policy.Value())
+	if err != nil {
+		return err
+	}
+	online.setExtKeyUsage(extKeyUsage)
+
+	issuer := &scepIssuer{
+		onlineIssuer: online,
+		ca:           ca,
+	}
+
+	caCerts, err := utils.ParseCertificates(caFullchain)
+	if err != nil {
+		return err
+	}
+
+	server, err := scepserver.NewServer(&scepserver.Config{
+		CACerts:        caCerts,
+		RA:             raCerts[0],
+		RAKey:          raKey,
+		Store:          store,
+		ManualApproval: opt.ManualApproval,
+		Issue:          issuer.issue,
+		VerifyRenewal:  issuer.verifyRenewal,
+		GetCertificate: issuer.getCertificate,
+		GetCRL:         issuer.getCRL,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              opt.Listen,
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Serve %s, store directory: %s, manual approval: %v\n", ca.String(), scepStoreDirPath(ca), opt.ManualApproval)
+	fmt.Printf("SCEP server listening on %s, url: http://%s/scep\n", opt.Listen, opt.Listen)
+
+	return httpServer.ListenAndServe()
+}
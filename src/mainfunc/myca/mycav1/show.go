@@ -1,10 +1,10 @@
 package mycav1
 
 import (
+	"crypto/x509"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/utils"
 	"os"
-	"path"
 )
 
 func PrintMenu() {
@@ -12,33 +12,11 @@ func PrintMenu() {
 }
 
 func showAllRCA() []string {
-	rca, err := utils.ReadDirOnlyDir(path.Join(home, "rca"))
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-	}
-
-	fmt.Println("总计: ", len(rca))
-
-	for i, v := range rca {
-		fmt.Printf(" %d. %s\n", i+1, v)
-	}
-
-	return rca
+	return showAllEntry("RCA")
 }
 
 func showAllICA() []string {
-	ica, err := utils.ReadDirOnlyDir(path.Join(home, "ica"))
-	if err != nil {
-		fmt.Printf("Error: %s\n", err.Error())
-	}
-
-	fmt.Println("总计: ", len(ica))
-
-	for i, v := range ica {
-		fmt.Printf(" %d. %s\n", i+1, v)
-	}
-
-	return ica
+	return showAllEntry("ICA")
 }
 
 func showFileOnPath(basePath string) []os.DirEntry {
@@ -70,3 +48,69 @@ func showFileOnPath(basePath string) []os.DirEntry {
 
 	return fileList
 }
+
+func showCertificateRequest(csr *x509.CertificateRequest) {
+	fmt.Println("Certificate Request:")
+	fmt.Printf("  Subject: %s\n", csr.Subject.String())
+	fmt.Printf("  Public Key Algorithm: %s\n", csr.PublicKeyAlgorithm.String())
+	fmt.Printf("  Signature Algorithm: %s\n", csr.SignatureAlgorithm.String())
+
+	for _, d := range csr.DNSNames {
+		fmt.Printf("  DNS: %s\n", d)
+	}
+
+	for _, ip := range csr.IPAddresses {
+		fmt.Printf("  IP: %s\n", ip.String())
+	}
+
+	for _, e := range csr.EmailAddresses {
+		fmt.Printf("  Email: %s\n", e)
+	}
+
+	for _, u := range csr.URIs {
+		fmt.Printf("  URI: %s\n", u.String())
+	}
+
+	keyUsage, ok, err := utils.ParseCSRKeyUsage(csr)
+	if err != nil {
+		fmt.Printf("  Key Usage: parser error (%s)\n", err.Error())
+	} else if ok {
+		for _, usage := range KeyUsageList {
+			if keyUsage&usage != 0 {
+				fmt.Printf("  Key Usage: %s\n", KeyUsageMap[usage])
+			}
+		}
+	}
+
+	extKeyUsage, unknownExtKeyUsage, ok, err := utils.ParseCSRExtKeyUsage(csr)
+	if err != nil {
+		fmt.Printf("  Ext Key Usage: parser error (%s)\n", err.Error())
+	} else if ok {
+		for _, usage := range extKeyUsage {
+			fmt.Printf("  Ext Key Usage: %s\n", ExtKeyUsageMap[usage])
+		}
+
+		for _, oid := range unknownExtKeyUsage {
+			fmt.Printf("  Ext Key Usage: %s (unknown, will be ignored)\n", oid.String())
+		}
+	}
+
+	for _, ext := range csr.Extensions {
+		fmt.Printf("  Extension: %s (critical: %v)\n", ext.Id.String(), ext.Critical)
+	}
+}
+
+func showAllPending() []string {
+	pending, err := utils.ReadDirOnlyDir(homePending)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	}
+
+	fmt.Println("总计: ", len(pending))
+
+	for i, v := range pending {
+		fmt.Printf(" %d. %s\n", i+1, v)
+	}
+
+	return pending
+}
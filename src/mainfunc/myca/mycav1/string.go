@@ -9,11 +9,37 @@ const menu = `Menu:
   6) Create User Certificate From RCA
   7) Create User Certificate From ICA
   8) Create User Certificate (Self Signed)
-  9) Exit`
+  9) Sign CSR From RCA
+  10) Sign CSR From ICA
+  11) Show All Pending CSR
+  12) Create CSR (For External CA)
+  13) Import Signed ICA
+  14) Revoke Certificate
+  15) Show Revoked Certificates
+  16) Generate CRL For RCA
+  17) Generate CRL For ICA
+  18) Create OCSP Signing Certificate For RCA
+  19) Create OCSP Signing Certificate For ICA
+  20) Audit Private Keys
+  21) Renew User Certificate
+  22) Show Issued Certificates
+  23) Show All User Certificates
+  24) Inspect Certificate, CSR, CRL or PFX File
+  25) Verify Certificate
+  26) Import Existing RCA or ICA
+  27) Exit`
+
+const keyMenu = `Private Key Menu:
+ 1) Generate a new key (default)
+ 2) Use the key from a file
+ 3) Use the key of an existing entry in MyCA (e.g. renew a CA and keep the Subject Key Identifier)`
 
 const cryptoMenu = `Crypto Menu:
  1) RSA 2048 (Good compatibility)
  2) RSA 4096 (Compatible and safe but more complex)
  3) ECDSA P256 (Safe and recommend)
  4) ECDSA P384 (Safe and recommend)
- 5) ECDSA P521 (Bad compatibility and not recommend)`
+ 5) ECDSA P521 (Bad compatibility and not recommend)
+ 6) Ed25519 (Safe and fast, but can not be saved as PFX and not supported by some old clients)
+ 7) RSA 3072 (Compatible and safe)
+ 8) RSA 8192 (Very slow, only for long-lived CA)`
@@ -7,3 +7,4 @@ var home string
 var homeRCA string
 var homeICA string
 var homeCert string
+var homePending string
@@ -0,0 +1,473 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package mycav1
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/crl"
+	"github.com/SongZihuan/MyCA/src/flagparser"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	verifyFormatText = "text"
+	verifyFormatJSON = "json"
+)
+
+// verifyPurpose 证书的用途，对应扩展密钥用途以及叶子证书必须包含的密钥用途之一
+type verifyPurpose struct {
+	ExtKeyUsage x509.ExtKeyUsage
+	KeyUsage    x509.KeyUsage // 为0时不检查
+}
+
+var verifyPurposeList = []string{"server", "client", "code", "email", "any"}
+
+var verifyPurposeMap = map[string]verifyPurpose{
+	"server": {x509.ExtKeyUsageServerAuth, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement},
+	"client": {x509.ExtKeyUsageClientAuth, x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement},
+	"code":   {x509.ExtKeyUsageCodeSigning, x509.KeyUsageDigitalSignature},
+	"email":  {x509.ExtKeyUsageEmailProtection, x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement},
+	"any":    {x509.ExtKeyUsageAny, 0},
+}
+
+const (
+	revocationStatusGood    = "good"
+	revocationStatusRevoked = "revoked"
+	revocationStatusUnknown = "unknown" // 签发CA不由MyCA管理
+)
+
+const (
+	crlStatusListed       = "listed"
+	crlStatusNotListed    = "not listed"
+	crlStatusNotPublished = "not published"
+	crlStatusInvalid      = "invalid" // CRL无法读取或签名错误
+)
+
+// verifyRevocation 证书在签发CA的吊销记录以及已发布CRL中的状态
+type verifyRevocation struct {
+	Issuer     string     `json:"issuer,omitempty"` // 签发CA，例如ICA/ICA-MyICA
+	Status     string     `json:"status"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	CRL        string     `json:"crl,omitempty"`
+	CRLExpired bool       `json:"crl_expired,omitempty"` // 已发布CRL的下次更新时间早于验证时间
+}
+
+// verifyChainEntry 证书链中的一个证书，第一个为被验证的证书，最后一个为根证书
+type verifyChainEntry struct {
+	Subject      string            `json:"subject"`
+	SerialNumber string            `json:"serial_number"`
+	Fingerprint  string            `json:"sha256_fingerprint"`
+	Source       string            `json:"source"` // MyCA中的CA（例如RCA/RCA-MyRootCA），或file（被验证的文件）、roots（-roots指定的文件）
+	NotBefore    time.Time         `json:"not_before"`
+	NotAfter     time.Time         `json:"not_after"`
+	Revocation   *verifyRevocation `json:"revocation,omitempty"` // 根证书为空
+}
+
+// verifyResult 验证结果，Errors为空时证书有效
+type verifyResult struct {
+	File     string              `json:"file"`
+	Subject  string              `json:"subject"`
+	Purpose  string              `json:"purpose"`
+	Time     time.Time           `json:"time"`
+	Hostname string              `json:"hostname,omitempty"`
+	Email    string              `json:"email,omitempty"`
+	Valid    bool                `json:"valid"`
+	Errors   []string            `json:"errors,omitempty"`
+	Chain    []*verifyChainEntry `json:"chain,omitempty"`
+}
+
+func (r *verifyResult) addError(format string, a ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, a...))
+}
+
+// verifyRequest 验证的参数
+type verifyRequest struct {
+	Purpose  string
+	Hostname string // 域名或IP地址
+	Email    string
+	Time     time.Time
+	Roots    []*x509.Certificate // home/rca以外的信任锚
+}
+
+// parseVerifyTime 解析验证时间，支持RFC 3339、"2006-01-02 15:04:05"和"2006-01-02"（本地时间），为空时返回当前时间
+func parseVerifyTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range []string{time.DateTime, time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("not a valid time: %s", s)
+}
+
+func parseVerifyPurpose(s string) (string, error) {
+	if s == "" {
+		return "server", nil
+	}
+
+	purpose := strings.ToLower(s)
+	if _, ok := verifyPurposeMap[purpose]; !ok {
+		return "", fmt.Errorf("unknown purpose: %s (supported: %s)", s, strings.Join(verifyPurposeList, ", "))
+	}
+
+	return purpose, nil
+}
+
+// checkRevocation 根据签发CA的吊销记录和已发布的CRL（crl.pem以及delta-crl.pem）检查证书的吊销状态
+func checkRevocation(c *x509.Certificate, issuer *localCertificate, at time.Time) *verifyRevocation {
+	if issuer == nil {
+		return &verifyRevocation{
+			Status: revocationStatusUnknown,
+		}
+	}
+
+	res := &verifyRevocation{
+		Issuer: fmt.Sprintf("%s/%s", issuer.Type, issuer.Name),
+		Status: revocationStatusGood,
+	}
+
+	db, err := revoke.GetRevocationDB(revocationDBPath(issuer.DirPath()))
+	if err != nil {
+		res.Status = revocationStatusUnknown
+	} else if r := db.Find(c.SerialNumber); r != nil {
+		revokedAt := r.RevokedAt
+		res.RevokedAt = &revokedAt
+		res.Reason = r.Reason.String()
+
+		if !r.RevokedAt.After(at) {
+			res.Status = revocationStatusRevoked
+		}
+	}
+
+	res.CRL, res.CRLExpired = checkPublishedCRL(c, issuer, at)
+	return res
+}
+
+func checkPublishedCRL(c *x509.Certificate, issuer *localCertificate, at time.Time) (string, bool) {
+	crlPath := path.Join(issuer.DirPath(), "crl.pem")
+	if !utils.IsExists(crlPath) {
+		return crlStatusNotPublished, false
+	}
+
+	status := crlStatusNotListed
+	expired := false
+
+	for _, p := range []string{crlPath, path.Join(issuer.DirPath(), "delta-crl.pem")} {
+		if p != crlPath && !utils.IsExists(p) {
+			continue
+		}
+
+		revocationList, err := crl.ReadCRL(p)
+		if err != nil || revocationList.CheckSignatureFrom(issuer.Cert) != nil {
+			return crlStatusInvalid, false
+		}
+
+		if !revocationList.NextUpdate.IsZero() && revocationList.NextUpdate.Before(at) {
+			expired = true
+		}
+
+		for _, entry := range revocationList.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(c.SerialNumber) != 0 {
+				continue
+			}
+
+			if entry.ReasonCode == int(revoke.ReasonRemoveFromCRL) {
+				status = crlStatusNotListed
+			} else {
+				status = crlStatusListed
+			}
+		}
+	}
+
+	return status, expired
+}
+
+// verifyCertificates 验证证书链，certs[0]为被验证的证书，其余证书作为中间证书
+// 根证书为home/rca中的全部RCA（以及req.Roots），中间证书为home/ica中的全部ICA
+func verifyCertificates(certs []*x509.Certificate, req *verifyRequest) *verifyResult {
+	leaf := certs[0]
+	res := &verifyResult{
+		Subject:  leaf.Subject.String(),
+		Purpose:  req.Purpose,
+		Time:     req.Time,
+		Hostname: req.Hostname,
+		Email:    req.Email,
+	}
+
+	purpose := verifyPurposeMap[req.Purpose]
+
+	locals := make(map[string]*localCertificate, 10)
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+
+	for _, ca := range loadAllLocalCertificate("RCA", "ICA") {
+		locals[revoke.Fingerprint(ca.Cert)] = ca
+		if ca.Type == "RCA" {
+			roots.AddCert(ca.Cert)
+		} else {
+			intermediates.AddCert(ca.Cert)
+		}
+	}
+
+	extraRoots := make(map[string]bool, len(req.Roots))
+	for _, c := range req.Roots {
+		extraRoots[revoke.Fingerprint(c)] = true
+		roots.AddCert(c)
+	}
+
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		DNSName:       req.Hostname,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   req.Time,
+		KeyUsages:     []x509.ExtKeyUsage{purpose.ExtKeyUsage},
+	})
+	if err != nil {
+		res.addError("%s", err.Error())
+		return res
+	}
+
+	chain := chains[0]
+	for i, c := range chain {
+		fingerprint := revoke.Fingerprint(c)
+		entry := &verifyChainEntry{
+			Subject:      c.Subject.String(),
+			SerialNumber: c.SerialNumber.Text(16),
+			Fingerprint:  fingerprint,
+			Source:       "file",
+			NotBefore:    c.NotBefore,
+			NotAfter:     c.NotAfter,
+		}
+
+		if ca, ok := locals[fingerprint]; ok {
+			entry.Source = fmt.Sprintf("%s/%s", ca.Type, ca.Name)
+		} else if extraRoots[fingerprint] {
+			entry.Source = "roots"
+		}
+
+		if i+1 < len(chain) {
+			entry.Revocation = checkRevocation(c, locals[revoke.Fingerprint(chain[i+1])], req.Time)
+			if entry.Revocation.Status == revocationStatusRevoked {
+				res.addError("certificate %s (serial number: %s) has been revoked at %s (%s)", entry.Subject, entry.SerialNumber, entry.Revocation.RevokedAt.Format(time.RFC3339), entry.Revocation.Reason)
+			}
+		}
+
+		if i > 0 && c.KeyUsage != 0 && c.KeyUsage&x509.KeyUsageCertSign == 0 {
+			res.addError("the key usage of CA certificate %s does not include KeyUsageCertSign", entry.Subject)
+		}
+
+		res.Chain = append(res.Chain, entry)
+	}
+
+	if purpose.KeyUsage != 0 && leaf.KeyUsage != 0 && leaf.KeyUsage&purpose.KeyUsage == 0 {
+		res.addError("the key usage of the certificate (%s) is not valid for the purpose %s", strings.Join(inspectKeyUsage(leaf.KeyUsage), ", "), req.Purpose)
+	}
+
+	if req.Email != "" {
+		matched := false
+		for _, email := range leaf.EmailAddresses {
+			if strings.EqualFold(email, req.Email) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			res.addError("the certificate is not valid for email %s", req.Email)
+		}
+	}
+
+	return res
+}
+
+// verifyFile 读取文件（与inspect支持的格式相同）中的证书并验证
+func verifyFile(filePath string, password string, passwordFunc func() string, req *verifyRequest) (*verifyResult, error) {
+	file, err := inspectFile(filePath, password, passwordFunc)
+	if err != nil {
+		return nil, err
+	} else if len(file.certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", filePath)
+	}
+
+	res := verifyCertificates(file.certs, req)
+	res.File = filePath
+	res.Valid = len(res.Errors) == 0
+
+	return res, nil
+}
+
+func (r *verifyRevocation) String() string {
+	var res string
+
+	switch {
+	case r.Status == revocationStatusUnknown && r.Issuer == "":
+		return "unknown (the issuer is not managed by MyCA)"
+	case r.Status == revocationStatusRevoked:
+		res = fmt.Sprintf("revoked at %s (%s)", r.RevokedAt.Local().Format(time.DateTime), r.Reason)
+	case r.RevokedAt != nil:
+		res = fmt.Sprintf("good (revoked later at %s)", r.RevokedAt.Local().Format(time.DateTime))
+	default:
+		res = r.Status
+	}
+
+	if r.CRLExpired {
+		res += fmt.Sprintf(", CRL: %s (expired)", r.CRL)
+	} else {
+		res += fmt.Sprintf(", CRL: %s", r.CRL)
+	}
+
+	return res
+}
+
+func printVerifyResult(res *verifyResult) {
+	fmt.Printf("File: %s\n", res.File)
+	fmt.Printf("Certificate: %s\n", res.Subject)
+	fmt.Printf("Purpose: %s\n", res.Purpose)
+	fmt.Printf("Time: %s\n", res.Time.Local().Format(time.DateTime))
+	if res.Hostname != "" {
+		fmt.Printf("Hostname: %s\n", res.Hostname)
+	}
+	if res.Email != "" {
+		fmt.Printf("Email: %s\n", res.Email)
+	}
+
+	if len(res.Chain) != 0 {
+		fmt.Println("Chain:")
+		for i, entry := range res.Chain {
+			fmt.Printf("  %d. %s [%s]\n", i, entry.Subject, entry.Source)
+			fmt.Printf("     Serial Number: %s, Not After: %s\n", entry.SerialNumber, entry.NotAfter.Local().Format(time.DateTime))
+			if entry.Revocation != nil {
+				fmt.Printf("     Revocation: %s\n", entry.Revocation.String())
+				if entry.Revocation.Status == revocationStatusRevoked && entry.Revocation.CRL == crlStatusNotListed {
+					fmt.Printf("     Warning: the published CRL of %s is out of date\n", entry.Revocation.Issuer)
+				}
+			} else {
+				fmt.Println("     Trust Anchor")
+			}
+		}
+	}
+
+	if res.Valid {
+		fmt.Println("Result: OK")
+		return
+	}
+
+	fmt.Println("Result: FAILED")
+	for _, e := range res.Errors {
+		fmt.Printf("  %s\n", e)
+	}
+}
+
+func VerifyCertificate() {
+	fmt.Printf("File path (certificate, chain, PFX or SPX): ")
+	filePath := ReadString()
+	if filePath == "" {
+		fmt.Println("Error: file path is empty")
+		return
+	}
+
+	fmt.Printf("Purpose (%s) [default: server]: ", strings.Join(verifyPurposeList, "/"))
+	purpose, err := parseVerifyPurpose(ReadString())
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	fmt.Printf("Hostname or IP address (leave empty to skip): ")
+	hostname := ReadString()
+
+	fmt.Printf("Email address (leave empty to skip): ")
+	email := ReadString()
+
+	res, err := verifyFile(filePath, "", func() string {
+		fmt.Printf("Enter the password of the file (leave empty to skip): ")
+		return ReadPassword()
+	}, &verifyRequest{
+		Purpose:  purpose,
+		Hostname: hostname,
+		Email:    email,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	printVerifyResult(res)
+}
+
+func CommandVerify(opt *flagparser.VerifyOption) error {
+	if opt.File == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	purpose, err := parseVerifyPurpose(opt.Purpose)
+	if err != nil {
+		return err
+	}
+
+	at, err := parseVerifyTime(opt.Time)
+	if err != nil {
+		return err
+	}
+
+	req := &verifyRequest{
+		Purpose:  purpose,
+		Hostname: opt.Hostname,
+		Email:    opt.Email,
+		Time:     at,
+	}
+
+	if opt.Roots != "" {
+		req.Roots, err = utils.ReadCertificates(opt.Roots)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := verifyFile(opt.File, opt.Password, nil, req)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(opt.Format) {
+	case verifyFormatText:
+		printVerifyResult(res)
+	case verifyFormatJSON:
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unknown format: %s", opt.Format)
+	}
+
+	if !res.Valid {
+		return fmt.Errorf("verification failed")
+	}
+
+	return nil
+}
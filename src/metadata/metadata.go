@@ -0,0 +1,265 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package metadata 定义RCA、ICA、证书和CSR信息文件的JSON格式，并提供从旧版本gob信息文件迁移的工具
+//
+// 每个信息文件都是一个JSON对象，包含 kind（文件类型）和 version（格式版本）两个字段，
+// 序列号和CRL编号以小写十六进制字符串保存，签名算法以名称保存（例如 SHA256-RSA），时间使用RFC 3339格式。
+// 上级CA不再被复制到下级的信息文件中，而是通过 Reference 引用。
+package metadata
+
+import (
+	"crypto/x509"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Version 当前信息文件的格式版本，格式发生不兼容的修改时递增
+const Version = 1
+
+const (
+	KindRCA      = "rca-info"
+	KindICA      = "ica-info"
+	KindCert     = "cert-info"      // 由RCA或ICA签发的证书
+	KindSelfCert = "self-cert-info" // 自签名证书（与KindCert使用同一个文件名）
+	KindCSR      = "csr-info"
+
+	KindIssuanceIndex = "issuance-index" // CA的签发索引（issued-db.json）
+	KindRevocationDB  = "revocation-db"  // CA的吊销数据库（revoke-db.json）
+
+	KindACMEAccount       = "acme-account"       // ACME服务的账户
+	KindACMEOrder         = "acme-order"         // ACME服务的订单
+	KindACMEAuthorization = "acme-authorization" // ACME服务的授权（包含其挑战）
+
+	KindSCEPChallenge = "scep-challenge" // SCEP服务的挑战密码
+	KindSCEPRequest   = "scep-request"   // SCEP服务的签发请求（用于人工审批和轮询）
+
+	KindCMPSecret = "cmp-secret" // CMP服务的共享密钥（PBM）
+
+	KindAPIToken = "api-token" // REST API的访问令牌
+)
+
+const (
+	ReferenceRCA      = "RCA"
+	ReferenceICA      = "ICA"
+	ReferenceExternal = "EXTERNAL" // 不由MyCA管理的外部CA（例如公共CA或企业根CA）
+)
+
+// Header 每个信息文件都包含的字段
+type Header struct {
+	Kind    string `json:"kind"`
+	Version int    `json:"version"`
+}
+
+func NewHeader(kind string) Header {
+	return Header{
+		Kind:    kind,
+		Version: Version,
+	}
+}
+
+// Reference 对上级CA的引用
+type Reference struct {
+	Type string `json:"type"`           // RCA、ICA或EXTERNAL
+	Name string `json:"name,omitempty"` // RCA或ICA在home目录中的目录名，即 rca/<name> 或 ica/<name>
+
+	IssuingCertificateURL []string `json:"issuing_certificate_url,omitempty"` // 外部CA的证书地址
+}
+
+// NewReference 根据上级CA信息文件的路径（home/rca/<name>/rca-info.json）生成引用
+func NewReference(refType string, infoFilePath string) *Reference {
+	ref := &Reference{
+		Type: refType,
+	}
+
+	if infoFilePath != "" {
+		ref.Name = path.Base(path.Dir(infoFilePath))
+	}
+
+	return ref
+}
+
+func (r *Reference) String() string {
+	if r == nil {
+		return ""
+	} else if r.Name == "" {
+		return r.Type
+	}
+	return fmt.Sprintf("%s/%s", r.Type, r.Name)
+}
+
+// Read 读取JSON信息文件，并检查文件的类型和格式版本
+func Read(filepath string, kind string, v any) error {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return err
+	}
+
+	var header Header
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return fmt.Errorf("parse %s failed: %s", filepath, err.Error())
+	}
+
+	if header.Kind != kind {
+		return fmt.Errorf("%s is a %s file, not %s", filepath, header.Kind, kind)
+	} else if header.Version <= 0 || header.Version > Version {
+		return fmt.Errorf("%s uses the unsupported schema version %d (supported: %d), it may be created by a newer MyCA", filepath, header.Version, Version)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return fmt.Errorf("parse %s failed: %s", filepath, err.Error())
+	}
+
+	return nil
+}
+
+// ReadKind 读取信息文件的类型，用于同一文件名可能保存不同类型的信息时（cert-info.json）
+func ReadKind(filepath string) (string, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return "", err
+	}
+
+	var header Header
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return "", fmt.Errorf("parse %s failed: %s", filepath, err.Error())
+	}
+
+	return header.Kind, nil
+}
+
+// Write 保存JSON信息文件，先写入临时文件再重命名，避免写入中断导致文件损坏
+func Write(filepath string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	// 临时文件名随机，多个进程同时保存同一文件时不会互相覆盖临时文件
+	tmpFile, err := os.CreateTemp(path.Dir(filepath), fmt.Sprintf(".%s.*.tmp", path.Base(filepath)))
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	err = os.Rename(tmpPath, filepath)
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// LegacyPath 返回JSON信息文件对应的旧版本gob信息文件的路径（rca-info.json -> rca-info.gob）
+func LegacyPath(filepath string) string {
+	return strings.TrimSuffix(filepath, ".json") + ".gob"
+}
+
+// NeedMigrate 判断JSON信息文件不存在而旧版本的gob信息文件存在
+func NeedMigrate(filepath string) bool {
+	return !utils.IsExists(filepath) && utils.IsExists(LegacyPath(filepath))
+}
+
+// ReadLegacy 读取旧版本的gob信息文件
+// 信息类型需以指针形式注册（名称与旧版本保持一致），使得解码后的值能满足接口（方法接收者为指针）
+func ReadLegacy(legacyPath string, v any) error {
+	file, err := os.Open(legacyPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	err = gob.NewDecoder(file).Decode(v)
+	if err != nil {
+		return fmt.Errorf("read the legacy file %s failed: %s", legacyPath, err.Error())
+	}
+
+	return nil
+}
+
+// RemoveLegacy 迁移完成（JSON信息文件已保存）后删除旧版本的gob信息文件
+func RemoveLegacy(legacyPath string) error {
+	err := os.Remove(legacyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// FormatBigInt 将序列号、CRL编号等格式化为小写十六进制字符串，nil为空字符串
+func FormatBigInt(n *big.Int) string {
+	if n == nil {
+		return ""
+	}
+	return n.Text(16)
+}
+
+// ParseBigInt 解析 FormatBigInt 的结果，空字符串为nil
+func ParseBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		return nil, fmt.Errorf("not a valid hex number: %s", s)
+	}
+
+	return n, nil
+}
+
+// FormatSignatureAlgorithm 返回签名算法的名称，x509.UnknownSignatureAlgorithm（由私钥决定）为空字符串
+func FormatSignatureAlgorithm(alg x509.SignatureAlgorithm) string {
+	if alg == x509.UnknownSignatureAlgorithm {
+		return ""
+	}
+	return alg.String()
+}
+
+func ParseSignatureAlgorithm(s string) (x509.SignatureAlgorithm, error) {
+	return utils.ParseSignatureAlgorithm(s)
+}
+
+// FormatTime 零值时间返回nil，使得JSON中省略该字段
+func FormatTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	t = t.UTC()
+	return &t
+}
+
+func ParseTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
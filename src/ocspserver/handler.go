@@ -0,0 +1,101 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ocspserver
+
+import (
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const maxRequestSize = 64 * 1024
+
+// ServeHTTP 处理 RFC 6960 附录A 定义的GET和POST请求
+func (r *Responder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var reqDER []byte
+	var err error
+
+	switch req.Method {
+	case http.MethodGet:
+		// GET {url}/{url-encoding of base-64 encoding of the DER encoding of the OCSPRequest}
+		// 响应器地址可以带有路径（例如/ocsp），请求为路径的最后一段
+		reqDER, err = decodeGetRequest(req.URL.EscapedPath())
+		if err != nil {
+			writeResponse(w, errorResponse(MalformedRequest), MalformedRequest, false, 0)
+			return
+		}
+	case http.MethodPost:
+		reqDER, err = io.ReadAll(io.LimitReader(req.Body, maxRequestSize+1))
+		if err != nil {
+			writeResponse(w, errorResponse(InternalError), InternalError, false, 0)
+			return
+		} else if len(reqDER) > maxRequestSize {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	res, status := r.Respond(reqDER)
+	writeResponse(w, res, status, req.Method == http.MethodGet, int(r.NextUpdate.Seconds()))
+}
+
+// decodeGetRequest 从请求路径中解码OCSP请求
+// 优先使用路径的最后一段；部分客户端不会将base64中的'/'编码为%2F，此时依次尝试包含更多段的后缀，
+// 直到解码结果为完整的DER编码
+func decodeGetRequest(escapedPath string) ([]byte, error) {
+	segments := strings.Split(strings.TrimPrefix(escapedPath, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		s, err := url.PathUnescape(strings.Join(segments[i:], "/"))
+		if err != nil || s == "" {
+			continue
+		}
+
+		res, err := decodeBase64(s)
+		if err != nil {
+			continue
+		}
+
+		var v asn1.RawValue
+		if rest, err := asn1.Unmarshal(res, &v); err == nil && len(rest) == 0 {
+			return res, nil
+		}
+	}
+
+	return nil, fmt.Errorf("not a valid base64 request")
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	// 部分客户端会省略base64的填充
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		res, err := encoding.DecodeString(s)
+		if err == nil {
+			return res, nil
+		}
+	}
+
+	return nil, fmt.Errorf("not a valid base64 string")
+}
+
+func writeResponse(w http.ResponseWriter, res []byte, status ResponseStatus, cacheable bool, maxAge int) {
+	w.Header().Set("Content-Type", "application/ocsp-response")
+
+	// RFC 5019 建议GET请求的成功响应可以被缓存（包含nonce的响应也只会被同一请求命中）
+	if cacheable && status == Successful && maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d, public, no-transform, must-revalidate", maxAge))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	w.WriteHeader(http.StatusOK) // OCSP错误也通过OCSPResponseStatus返回
+	_, _ = w.Write(res)
+}
@@ -0,0 +1,247 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package ocspserver 实现 RFC 6960 OCSP 应答服务，证书状态来自CA的吊销数据库
+package ocspserver
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/revoke"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// Issuer 由OCSP服务应答的CA
+type Issuer struct {
+	CA               *x509.Certificate
+	Signer           *x509.Certificate // 签名OCSP响应的证书，可以是CA本身或者CA签发的OCSP签名证书
+	Key              crypto.Signer
+	RevocationDBPath string
+	IsIssued         func(serialNumber *big.Int) bool // 为nil时未吊销的证书均视为有效
+}
+
+// NewIssuer 创建应答的CA，signer为nil时使用CA证书及其私钥签名
+func NewIssuer(ca *x509.Certificate, signer *x509.Certificate, key crypto.PrivateKey, revocationDBPath string, isIssued func(serialNumber *big.Int) bool) (*Issuer, error) {
+	if signer == nil {
+		signer = ca
+	}
+
+	keySigner, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the private key can not be used to sign")
+	}
+
+	err := utils.CheckKeyPair(signer, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(signer.Raw, ca.Raw) {
+		err = signer.CheckSignatureFrom(ca)
+		if err != nil {
+			return nil, fmt.Errorf("the OCSP signing certificate is not issued by the CA: %s", err.Error())
+		}
+
+		if !slices.Contains(signer.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+			return nil, fmt.Errorf("the OCSP signing certificate does not have the OCSP signing ext key usage")
+		}
+
+		if time.Now().After(signer.NotAfter) {
+			return nil, fmt.Errorf("the OCSP signing certificate has expired")
+		}
+	}
+
+	return &Issuer{
+		CA:               ca,
+		Signer:           signer,
+		Key:              keySigner,
+		RevocationDBPath: revocationDBPath,
+		IsIssued:         isIssued,
+	}, nil
+}
+
+// match 判断CertID是否指向该CA
+func (issuer *Issuer) match(id *certID) bool {
+	var hash crypto.Hash
+	for h, oid := range hashOIDMap {
+		if oid.Equal(id.HashAlgorithm.Algorithm) {
+			hash = h
+			break
+		}
+	}
+
+	if hash == 0 || !hash.Available() {
+		return false
+	}
+
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.CA.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return false
+	}
+
+	h := hash.New()
+	h.Write(issuer.CA.RawSubject)
+	if !bytes.Equal(h.Sum(nil), id.NameHash) {
+		return false
+	}
+
+	h.Reset()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	return bytes.Equal(h.Sum(nil), id.IssuerKeyHash)
+}
+
+type Responder struct {
+	Issuers    []*Issuer
+	NextUpdate time.Duration
+}
+
+func NewResponder(nextUpdate time.Duration, issuers ...*Issuer) *Responder {
+	return &Responder{
+		Issuers:    issuers,
+		NextUpdate: nextUpdate,
+	}
+}
+
+// Respond 处理DER编码的OCSP请求，返回DER编码的OCSP响应（出错时返回对应状态的错误响应）
+func (r *Responder) Respond(reqDER []byte) ([]byte, ResponseStatus) {
+	var req ocspRequest
+	rest, err := asn1.Unmarshal(reqDER, &req)
+	if err != nil || len(rest) != 0 || len(req.TBSRequest.RequestList) == 0 {
+		return errorResponse(MalformedRequest), MalformedRequest
+	}
+
+	var nonce *pkix.Extension
+	for _, ext := range req.TBSRequest.Extensions {
+		if !ext.Id.Equal(OIDPKIXOCSPNonce) {
+			continue
+		}
+
+		var value []byte
+		rest, err := asn1.Unmarshal(ext.Value, &value)
+		if err != nil || len(rest) != 0 || len(value) == 0 || len(value) > 32 { // RFC 8954 限制nonce为1至32字节
+			return errorResponse(MalformedRequest), MalformedRequest
+		}
+
+		nonce = &pkix.Extension{Id: OIDPKIXOCSPNonce, Value: ext.Value}
+	}
+
+	var issuer *Issuer
+	for _, i := range r.Issuers {
+		if i.match(&req.TBSRequest.RequestList[0].CertID) {
+			issuer = i
+			break
+		}
+	}
+
+	if issuer == nil {
+		return errorResponse(Unauthorized), Unauthorized
+	}
+
+	db, err := revoke.GetRevocationDB(issuer.RevocationDBPath)
+	if err != nil {
+		return errorResponse(InternalError), InternalError
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	responses := make([]singleResponse, 0, len(req.TBSRequest.RequestList))
+	for _, single := range req.TBSRequest.RequestList {
+		resp := singleResponse{
+			CertID:     single.CertID,
+			ThisUpdate: now,
+			NextUpdate: now.Add(r.NextUpdate),
+		}
+
+		if !issuer.match(&single.CertID) {
+			resp.Unknown = true // 一个响应只能由一个签名者签名，其他CA的证书状态视为未知
+		} else if revoked := db.Find(single.CertID.SerialNumber); revoked != nil {
+			resp.Revoked = revokedInfo{
+				RevocationTime: revoked.RevokedAt.UTC().Truncate(time.Second),
+				Reason:         asn1.Enumerated(revoked.Reason),
+			}
+		} else if issuer.IsIssued != nil && !issuer.IsIssued(single.CertID.SerialNumber) {
+			resp.Unknown = true
+		} else {
+			resp.Good = true
+		}
+
+		responses = append(responses, resp)
+	}
+
+	tbs := responseData{
+		ResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        1, // byName
+			IsCompound: true,
+			Bytes:      issuer.Signer.RawSubject,
+		},
+		ProducedAt: now,
+		Responses:  responses,
+	}
+
+	if nonce != nil {
+		tbs.Extensions = []pkix.Extension{*nonce}
+	}
+
+	res, err := issuer.sign(&tbs)
+	if err != nil {
+		return errorResponse(InternalError), InternalError
+	}
+
+	return res, Successful
+}
+
+func (issuer *Issuer) sign(tbs *responseData) ([]byte, error) {
+	tbsDER, err := asn1.Marshal(*tbs)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, signatureAlgorithm, err := utils.SignatureAlgorithmForKey(issuer.Key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := utils.SignData(issuer.Key, hash, tbsDER)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := basicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+	}
+
+	if !bytes.Equal(issuer.Signer.Raw, issuer.CA.Raw) {
+		basic.Certificates = []asn1.RawValue{{FullBytes: issuer.Signer.Raw}}
+	}
+
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status: asn1.Enumerated(Successful),
+		Response: responseBytes{
+			ResponseType: OIDPKIXOCSPBasic,
+			Response:     basicDER,
+		},
+	})
+}
+
+func errorResponse(status ResponseStatus) []byte {
+	return []byte{0x30, 0x03, 0x0A, 0x01, byte(status)}
+}
@@ -0,0 +1,84 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package ocspserver
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"time"
+)
+
+// CreateSigner 使用CA签发委派的OCSP签名证书，使得CA私钥无需在线
+func CreateSigner(caInfo cert.CAInfo, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey) (*x509.Certificate, crypto.PrivateKey, error) {
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+
+	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+	}
+
+	serialNumber, err := caInfo.NewCertSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+	}
+
+	noCheck, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagNull})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subject := pkix.Name{
+		Country:            ca.Subject.Country,
+		Organization:       ca.Subject.Organization,
+		OrganizationalUnit: ca.Subject.OrganizationalUnit,
+		CommonName:         fmt.Sprintf("%s OCSP Signer", ca.Subject.CommonName),
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: caInfo.GetSignatureAlgorithm(),
+		Subject:            subject,
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+
+		SubjectKeyId:   []byte(ski),
+		AuthorityKeyId: ca.SubjectKeyId,
+
+		IssuingCertificateURL: caInfo.GetIssuingCertificateURL(),
+
+		// RFC 6960 4.2.2.2.1 客户端无需检查OCSP签名证书的吊销状态，因此证书有效期应尽量短
+		ExtraExtensions: []pkix.Extension{{Id: OIDPKIXOCSPNoCheck, Value: noCheck}},
+	}
+
+	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return signer, privKey, nil
+}
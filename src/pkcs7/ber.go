@@ -0,0 +1,190 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pkcs7
+
+import (
+	"fmt"
+)
+
+const maxBERDepth = 64
+
+// berToDER 将BER编码转换为encoding/asn1可以解析的编码：不定长编码转换为定长编码，分段的OCTET STRING合并为一个
+// 部分客户端（例如一些SCEP客户端）发送的PKCS#7使用BER编码，DER编码的数据不会被修改
+func berToDER(data []byte) ([]byte, error) {
+	res, rest, err := convertBER(data, 0)
+	if err != nil {
+		return nil, err
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after ASN.1 element")
+	}
+	return res, nil
+}
+
+func convertBER(data []byte, depth int) ([]byte, []byte, error) {
+	if depth > maxBERDepth {
+		return nil, nil, fmt.Errorf("ASN.1 nesting too deep")
+	} else if len(data) < 2 {
+		return nil, nil, fmt.Errorf("ASN.1 element truncated")
+	}
+
+	offset := 1
+	if data[0]&0x1f == 0x1f {
+		for {
+			if offset >= len(data) {
+				return nil, nil, fmt.Errorf("ASN.1 tag truncated")
+			}
+			b := data[offset]
+			offset++
+			if b&0x80 == 0 {
+				break
+			}
+		}
+	}
+
+	tag := data[:offset]
+	constructed := data[0]&0x20 != 0
+
+	if offset >= len(data) {
+		return nil, nil, fmt.Errorf("ASN.1 length truncated")
+	}
+	l := data[offset]
+	offset++
+
+	var content []byte
+	var rest []byte
+
+	if l == 0x80 {
+		// 不定长编码，内容为若干元素，以 00 00 结束
+		if !constructed {
+			return nil, nil, fmt.Errorf("indefinite length of primitive ASN.1 element")
+		}
+
+		rest = data[offset:]
+		for {
+			if len(rest) >= 2 && rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+
+			child, r, err := convertBER(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			content = append(content, child...)
+			rest = r
+		}
+	} else {
+		length := int(l)
+		if l&0x80 != 0 {
+			n := int(l & 0x7f)
+			if n > 4 || offset+n > len(data) {
+				return nil, nil, fmt.Errorf("not a valid ASN.1 length")
+			}
+
+			length = 0
+			for i := 0; i < n; i++ {
+				length = length<<8 | int(data[offset+i])
+			}
+			offset += n
+		}
+
+		if length < 0 || offset+length > len(data) {
+			return nil, nil, fmt.Errorf("ASN.1 element truncated")
+		}
+
+		content = data[offset : offset+length]
+		rest = data[offset+length:]
+
+		if constructed {
+			var converted []byte
+			for r := content; len(r) != 0; {
+				child, rr, err := convertBER(r, depth+1)
+				if err != nil {
+					return nil, nil, err
+				}
+				converted = append(converted, child...)
+				r = rr
+			}
+			content = converted
+		}
+	}
+
+	// 分段的OCTET STRING（构造类型）合并为基本类型
+	if len(tag) == 1 && tag[0] == 0x24 {
+		merged, err := mergeOctetStrings(content)
+		if err != nil {
+			return nil, nil, err
+		}
+		return encodeElement([]byte{0x04}, merged), rest, nil
+	}
+
+	return encodeElement(tag, content), rest, nil
+}
+
+// mergeOctetStrings 合并若干个（已转换为DER的）OCTET STRING的内容
+func mergeOctetStrings(data []byte) ([]byte, error) {
+	var res []byte
+	for len(data) != 0 {
+		if data[0] != 0x04 {
+			return nil, fmt.Errorf("not a valid constructed OCTET STRING")
+		}
+
+		content, rest, err := elementContent(data)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, content...)
+		data = rest
+	}
+	return res, nil
+}
+
+// elementContent 返回DER元素（单字节标签）的内容和剩余数据
+func elementContent(data []byte) ([]byte, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, fmt.Errorf("ASN.1 element truncated")
+	}
+
+	offset := 2
+	length := int(data[1])
+	if data[1]&0x80 != 0 {
+		n := int(data[1] & 0x7f)
+		if n > 4 || offset+n > len(data) {
+			return nil, nil, fmt.Errorf("not a valid ASN.1 length")
+		}
+
+		length = 0
+		for i := 0; i < n; i++ {
+			length = length<<8 | int(data[offset+i])
+		}
+		offset += n
+	}
+
+	if length < 0 || offset+length > len(data) {
+		return nil, nil, fmt.Errorf("ASN.1 element truncated")
+	}
+
+	return data[offset : offset+length], data[offset+length:], nil
+}
+
+func encodeElement(tag []byte, content []byte) []byte {
+	res := make([]byte, 0, len(tag)+5+len(content))
+	res = append(res, tag...)
+
+	length := len(content)
+	switch {
+	case length < 0x80:
+		res = append(res, byte(length))
+	default:
+		var b []byte
+		for l := length; l > 0; l >>= 8 {
+			b = append([]byte{byte(l)}, b...)
+		}
+		res = append(res, 0x80|byte(len(b)))
+		res = append(res, b...)
+	}
+
+	return append(res, content...)
+}
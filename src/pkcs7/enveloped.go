@@ -0,0 +1,257 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+)
+
+// EncryptionAlgorithm EnvelopedData 的内容加密算法（均为CBC模式）
+type EncryptionAlgorithm int
+
+const (
+	EncryptionAES128 EncryptionAlgorithm = iota
+	EncryptionAES192
+	EncryptionAES256
+	EncryptionDES3
+	EncryptionDES // 仅用于兼容旧设备
+)
+
+type contentCipher struct {
+	name      string
+	oid       asn1.ObjectIdentifier
+	keySize   int
+	newCipher func(key []byte) (cipher.Block, error)
+}
+
+var contentCiphers = map[EncryptionAlgorithm]contentCipher{
+	EncryptionAES128: {"AES-128-CBC", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}, 16, aes.NewCipher},
+	EncryptionAES192: {"AES-192-CBC", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}, 24, aes.NewCipher},
+	EncryptionAES256: {"AES-256-CBC", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}, 32, aes.NewCipher},
+	EncryptionDES3:   {"DES-EDE3-CBC", asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}, 24, des.NewTripleDESCipher},
+	EncryptionDES:    {"DES-CBC", asn1.ObjectIdentifier{1, 3, 14, 3, 2, 7}, 8, des.NewCipher},
+}
+
+func (alg EncryptionAlgorithm) String() string {
+	if c, ok := contentCiphers[alg]; ok {
+		return c.name
+	}
+	return "unknown"
+}
+
+// envelopedData RFC 5652 6.1，不支持 originatorInfo 和 unprotectedAttrs
+type envelopedData struct {
+	Version              int
+	RecipientInfos       []asn1.RawValue `asn1:"set"`
+	EncryptedContentInfo encryptedContentInfo
+}
+
+// keyTransRecipientInfo RFC 5652 6.2.1，RID为 IssuerAndSerialNumber 或 [0] SubjectKeyIdentifier
+type keyTransRecipientInfo struct {
+	Version                int
+	RID                    asn1.RawValue
+	KeyEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedKey           []byte
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// Decrypt 使用接收者的证书和RSA私钥解密 EnvelopedData（可以是BER编码），返回内容和内容加密算法
+func Decrypt(data []byte, cert *x509.Certificate, key crypto.PrivateKey) ([]byte, EncryptionAlgorithm, error) {
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, 0, fmt.Errorf("only RSA private key can decrypt the enveloped data")
+	}
+
+	der, err := parseContentInfo(data, OIDEnvelopedData)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ed envelopedData
+	_, err = asn1.Unmarshal(der, &ed)
+	if err != nil {
+		return nil, 0, fmt.Errorf("parse PKCS#7 enveloped data failed: %s", err.Error())
+	}
+
+	var recipient *keyTransRecipientInfo
+	for _, raw := range ed.RecipientInfos {
+		var ri keyTransRecipientInfo
+		_, err := asn1.Unmarshal(raw.FullBytes, &ri)
+		if err != nil {
+			continue // 只支持密钥传输（KeyTransRecipientInfo）
+		}
+
+		if findCertificate(ri.RID, []*x509.Certificate{cert}) != nil {
+			recipient = &ri
+			break
+		}
+	}
+
+	if recipient == nil {
+		return nil, 0, fmt.Errorf("the enveloped data is not encrypted for the certificate %s", cert.Subject.String())
+	} else if !recipient.KeyEncryptionAlgorithm.Algorithm.Equal(oidRSAEncryption) {
+		return nil, 0, fmt.Errorf("unsupported key encryption algorithm: %s", recipient.KeyEncryptionAlgorithm.Algorithm.String())
+	}
+
+	eci := ed.EncryptedContentInfo
+
+	var alg EncryptionAlgorithm
+	var cc *contentCipher
+	for a, c := range contentCiphers {
+		if eci.ContentEncryptionAlgorithm.Algorithm.Equal(c.oid) {
+			alg = a
+			cc = &c
+			break
+		}
+	}
+	if cc == nil {
+		return nil, 0, fmt.Errorf("unsupported content encryption algorithm: %s", eci.ContentEncryptionAlgorithm.Algorithm.String())
+	}
+
+	var iv []byte
+	_, err = asn1.Unmarshal(eci.ContentEncryptionAlgorithm.Parameters.FullBytes, &iv)
+	if err != nil {
+		return nil, 0, fmt.Errorf("not a valid IV: %s", err.Error())
+	}
+
+	ciphertext := eci.EncryptedContent.Bytes
+	if eci.EncryptedContent.IsCompound {
+		ciphertext, err = mergeOctetStrings(ciphertext)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// 使用随机密钥代替解密失败的结果，避免泄露RSA填充是否正确（RFC 3218）
+	cek := make([]byte, cc.keySize)
+	_, err = io.ReadFull(utils.Rander(), cek)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = rsa.DecryptPKCS1v15SessionKey(utils.Rander(), rsaKey, recipient.EncryptedKey, cek)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	block, err := cc.newCipher(cek)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(iv) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, 0, fmt.Errorf("decrypt the enveloped data failed")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, 0, fmt.Errorf("decrypt the enveloped data failed")
+	}
+
+	return plaintext[:len(plaintext)-padding], alg, nil
+}
+
+// Encrypt 使用接收者证书的RSA公钥生成 EnvelopedData
+func Encrypt(content []byte, recipients []*x509.Certificate, alg EncryptionAlgorithm) ([]byte, error) {
+	cc, ok := contentCiphers[alg]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content encryption algorithm")
+	} else if len(recipients) == 0 {
+		return nil, fmt.Errorf("no recipient")
+	}
+
+	cek := make([]byte, cc.keySize)
+	_, err := io.ReadFull(utils.Rander(), cek)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := cc.newCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, block.BlockSize())
+	_, err = io.ReadFull(utils.Rander(), iv)
+	if err != nil {
+		return nil, err
+	}
+
+	padding := block.BlockSize() - len(content)%block.BlockSize()
+	plaintext := append(append([]byte{}, content...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+
+	ed := envelopedData{
+		Version: 0,
+		EncryptedContentInfo: encryptedContentInfo{
+			ContentType: OIDData,
+			ContentEncryptionAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  cc.oid,
+				Parameters: asn1.RawValue{Tag: asn1.TagOctetString, Bytes: iv},
+			},
+			EncryptedContent: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: ciphertext},
+		},
+	}
+
+	for _, c := range recipients {
+		pub, ok := c.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("the public key of the recipient %s is not a RSA key", c.Subject.String())
+		}
+
+		encryptedKey, err := rsa.EncryptPKCS1v15(utils.Rander(), pub, cek)
+		if err != nil {
+			return nil, err
+		}
+
+		rid, err := asn1.Marshal(issuerAndSerialNumber{
+			Issuer:       asn1.RawValue{FullBytes: c.RawIssuer},
+			SerialNumber: c.SerialNumber,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ri, err := asn1.Marshal(keyTransRecipientInfo{
+			Version:                0,
+			RID:                    asn1.RawValue{FullBytes: rid},
+			KeyEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue},
+			EncryptedKey:           encryptedKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ed.RecipientInfos = append(ed.RecipientInfos, asn1.RawValue{FullBytes: ri})
+	}
+
+	der, err := asn1.Marshal(ed)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalContentInfo(OIDEnvelopedData, der)
+}
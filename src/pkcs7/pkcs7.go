@@ -0,0 +1,133 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package pkcs7 实现注册协议（EST、SCEP等）需要的 PKCS#7 / CMS（RFC 5652）结构：
+// 只包含证书或CRL的 SignedData、带签名属性的 SignedData 以及使用RSA密钥传输的 EnvelopedData
+package pkcs7
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+)
+
+var (
+	OIDData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	OIDSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	OIDEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+
+	OIDAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	OIDAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	OIDAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+)
+
+// contentInfo RFC 5652 3
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// rawSet 保存 [n] IMPLICIT SET 的完整编码（CertificateSet、RevocationInfoChoices和SignedAttributes）
+type rawSet struct {
+	Raw asn1.RawContent
+}
+
+// newRawSet 将若干个DER编码的元素组成rawSet，编码时使用字段的标签
+func newRawSet(elements [][]byte) (rawSet, error) {
+	var b []byte
+	for _, e := range elements {
+		b = append(b, e...)
+	}
+
+	raw, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b})
+	if err != nil {
+		return rawSet{}, err
+	}
+
+	return rawSet{Raw: raw}, nil
+}
+
+// content 返回集合的内容（若干个元素的编码）
+func (s rawSet) content() ([]byte, error) {
+	if len(s.Raw) == 0 {
+		return nil, nil
+	}
+
+	var v asn1.RawValue
+	_, err := asn1.Unmarshal(s.Raw, &v)
+	if err != nil {
+		return nil, err
+	}
+	return v.Bytes, nil
+}
+
+// marshalContentInfo 使用ContentInfo包装内容
+func marshalContentInfo(contentType asn1.ObjectIdentifier, content []byte) ([]byte, error) {
+	return asn1.Marshal(contentInfo{
+		ContentType: contentType,
+		Content:     asn1.RawValue{FullBytes: encodeElement([]byte{0xa0}, content)},
+	})
+}
+
+// parseContentInfo 解析ContentInfo（可以是BER编码），返回内容的DER编码
+func parseContentInfo(data []byte, contentType asn1.ObjectIdentifier) ([]byte, error) {
+	der, err := berToDER(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 content info failed: %s", err.Error())
+	}
+
+	var ci contentInfo
+	rest, err := asn1.Unmarshal(der, &ci)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 content info failed: %s", err.Error())
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("trailing data after PKCS#7 content info")
+	} else if !ci.ContentType.Equal(contentType) {
+		return nil, fmt.Errorf("unexpected PKCS#7 content type: %s", ci.ContentType.String())
+	}
+
+	// encoding/asn1 不会解开 RawValue 的显式标签，Bytes为 [0] 中的内容
+	return ci.Content.Bytes, nil
+}
+
+// MarshalCertsOnly 生成只包含证书的 SignedData（certs-only，RFC 5652 5.2中没有签名者的退化形式）
+func MarshalCertsOnly(certs []*x509.Certificate) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate")
+	}
+	return MarshalDegenerate(certs, nil)
+}
+
+// MarshalDegenerate 生成没有签名者、只包含证书和CRL（DER编码）的 SignedData
+func MarshalDegenerate(certs []*x509.Certificate, crls [][]byte) ([]byte, error) {
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []asn1.RawValue{},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: OIDData,
+		},
+		SignerInfos: []asn1.RawValue{},
+	}
+
+	err := sd.setCertificates(certs, crls)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd.marshal()
+}
+
+// ParseCertificates 读取 SignedData 中的全部证书（不验证签名）
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	sd, err := ParseSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	return sd.Certificates, nil
+}
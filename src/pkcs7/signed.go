@@ -0,0 +1,494 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"slices"
+	"time"
+)
+
+var (
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+var hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+var ecdsaSignatureOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   oidECDSAWithSHA1,
+	crypto.SHA256: oidECDSAWithSHA256,
+	crypto.SHA384: oidECDSAWithSHA384,
+	crypto.SHA512: oidECDSAWithSHA512,
+}
+
+// ErrAttributeNotFound 签名者没有该签名属性
+var ErrAttributeNotFound = errors.New("attribute not found")
+
+// signedData RFC 5652 5.1
+type signedData struct {
+	Version          int
+	DigestAlgorithms []asn1.RawValue `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     rawSet          `asn1:"optional,tag:0"`
+	CRLs             rawSet          `asn1:"optional,tag:1"`
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+// signerInfo RFC 5652 5.3，SID为 IssuerAndSerialNumber 或 [0] SubjectKeyIdentifier
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        rawSet `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      rawSet `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue // SET OF AttributeValue
+}
+
+// Attribute 签名属性，Value 为 encoding/asn1 可以编码的值（例如string编码为PrintableString，[]byte编码为OCTET STRING）
+type Attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value any
+}
+
+// Signer SignedData 的签名者
+type Signer struct {
+	Certificate *x509.Certificate // 签名者的证书（在 SignedData 的证书中查找），未找到时为nil
+	Hash        crypto.Hash       // 摘要算法
+
+	signedAttrs []byte // 使用SET标签编码的签名属性，为空时签名直接针对内容
+	attributes  []attribute
+	signature   []byte
+}
+
+// SignedData 解析后的 SignedData
+type SignedData struct {
+	ContentType  asn1.ObjectIdentifier
+	Content      []byte // 封装的内容，不包含内容时为nil
+	Certificates []*x509.Certificate
+	CRLs         []*x509.RevocationList
+	Signers      []*Signer
+}
+
+func (sd *signedData) setCertificates(certs []*x509.Certificate, crls [][]byte) error {
+	if len(certs) != 0 {
+		raws := make([][]byte, 0, len(certs))
+		for _, c := range certs {
+			raws = append(raws, c.Raw)
+		}
+
+		set, err := newRawSet(raws)
+		if err != nil {
+			return err
+		}
+		sd.Certificates = set
+	}
+
+	if len(crls) != 0 {
+		set, err := newRawSet(crls)
+		if err != nil {
+			return err
+		}
+		sd.CRLs = set
+	}
+
+	return nil
+}
+
+func (sd *signedData) marshal() ([]byte, error) {
+	der, err := asn1.Marshal(*sd)
+	if err != nil {
+		return nil, err
+	}
+	return marshalContentInfo(OIDSignedData, der)
+}
+
+// splitElements 将若干个DER元素的编码拆分为单个元素
+func splitElements(data []byte) ([][]byte, error) {
+	var res [][]byte
+	for len(data) != 0 {
+		var v asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v.FullBytes)
+		data = rest
+	}
+	return res, nil
+}
+
+// ParseSignedData 解析 SignedData（可以是BER编码），不验证签名
+func ParseSignedData(data []byte) (*SignedData, error) {
+	der, err := parseContentInfo(data, OIDSignedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var sd signedData
+	_, err = asn1.Unmarshal(der, &sd)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 signed data failed: %s", err.Error())
+	}
+
+	res := &SignedData{
+		ContentType: sd.EncapContentInfo.EContentType,
+	}
+
+	// encoding/asn1 不会解开 RawValue 的显式标签，Bytes为 [0] 中的 OCTET STRING
+	if eContent := sd.EncapContentInfo.EContent.Bytes; len(eContent) != 0 {
+		if eContent[0] != asn1.TagOctetString {
+			return nil, fmt.Errorf("parse PKCS#7 signed data failed: the encapsulated content is not an OCTET STRING")
+		}
+
+		content, _, err := elementContent(eContent)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS#7 signed data failed: %s", err.Error())
+		}
+		res.Content = append([]byte{}, content...)
+	}
+
+	certsDER, err := sd.Certificates.content()
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 certificates failed: %s", err.Error())
+	}
+
+	certElements, err := splitElements(certsDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 certificates failed: %s", err.Error())
+	}
+
+	for _, e := range certElements {
+		if e[0] != 0x30 {
+			continue // 只支持X.509证书，忽略属性证书等其他类型
+		}
+
+		c, err := x509.ParseCertificate(e)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS#7 certificates failed: %s", err.Error())
+		}
+		res.Certificates = append(res.Certificates, c)
+	}
+
+	crlsDER, err := sd.CRLs.content()
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 CRLs failed: %s", err.Error())
+	}
+
+	crlElements, err := splitElements(crlsDER)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 CRLs failed: %s", err.Error())
+	}
+
+	for _, e := range crlElements {
+		if e[0] != 0x30 {
+			continue
+		}
+
+		crl, err := x509.ParseRevocationList(e)
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS#7 CRLs failed: %s", err.Error())
+		}
+		res.CRLs = append(res.CRLs, crl)
+	}
+
+	for _, raw := range sd.SignerInfos {
+		signer, err := parseSigner(raw.FullBytes, res.Certificates)
+		if err != nil {
+			return nil, err
+		}
+		res.Signers = append(res.Signers, signer)
+	}
+
+	return res, nil
+}
+
+func parseSigner(der []byte, certs []*x509.Certificate) (*Signer, error) {
+	var si signerInfo
+	_, err := asn1.Unmarshal(der, &si)
+	if err != nil {
+		return nil, fmt.Errorf("parse PKCS#7 signer info failed: %s", err.Error())
+	}
+
+	res := &Signer{
+		signature: si.Signature,
+	}
+
+	for h, oid := range hashOIDs {
+		if si.DigestAlgorithm.Algorithm.Equal(oid) {
+			res.Hash = h
+			break
+		}
+	}
+	if res.Hash == 0 {
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", si.DigestAlgorithm.Algorithm.String())
+	}
+
+	res.Certificate = findCertificate(si.SID, certs)
+
+	if len(si.SignedAttrs.Raw) != 0 {
+		// 签名针对以SET标签（而不是[0]）编码的签名属性
+		res.signedAttrs = append([]byte{0x31}, si.SignedAttrs.Raw[1:]...)
+
+		_, err = asn1.UnmarshalWithParams(res.signedAttrs, &res.attributes, "set")
+		if err != nil {
+			return nil, fmt.Errorf("parse PKCS#7 signed attributes failed: %s", err.Error())
+		}
+	}
+
+	return res, nil
+}
+
+// findCertificate 根据SID（IssuerAndSerialNumber或[0] SubjectKeyIdentifier）查找证书
+func findCertificate(sid asn1.RawValue, certs []*x509.Certificate) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if len(c.SubjectKeyId) != 0 && bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c
+			}
+		}
+		return nil
+	}
+
+	var ias issuerAndSerialNumber
+	_, err := asn1.Unmarshal(sid.FullBytes, &ias)
+	if err != nil {
+		return nil
+	}
+
+	for _, c := range certs {
+		if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// Attribute 读取签名属性的（第一个）值
+func (s *Signer) Attribute(oid asn1.ObjectIdentifier, v any) error {
+	for _, attr := range s.attributes {
+		if !attr.Type.Equal(oid) {
+			continue
+		}
+
+		_, err := asn1.Unmarshal(attr.Values.Bytes, v)
+		if err != nil {
+			return fmt.Errorf("parse attribute %s failed: %s", oid.String(), err.Error())
+		}
+		return nil
+	}
+	return ErrAttributeNotFound
+}
+
+// Verify 验证全部签名者的签名，不验证签名者证书的证书链
+func (sd *SignedData) Verify() error {
+	if len(sd.Signers) == 0 {
+		return fmt.Errorf("no signer")
+	}
+
+	for _, s := range sd.Signers {
+		err := s.verify(sd.ContentType, sd.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Signer) verify(contentType asn1.ObjectIdentifier, content []byte) error {
+	if s.Certificate == nil {
+		return fmt.Errorf("the certificate of the signer is not found")
+	}
+
+	signed := content
+	if len(s.signedAttrs) != 0 {
+		var digest []byte
+		err := s.Attribute(OIDAttributeMessageDigest, &digest)
+		if err != nil {
+			return err
+		}
+
+		h := s.Hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return fmt.Errorf("the message digest does not match the content")
+		}
+
+		var attrContentType asn1.ObjectIdentifier
+		err = s.Attribute(OIDAttributeContentType, &attrContentType)
+		if err == nil && !attrContentType.Equal(contentType) {
+			return fmt.Errorf("the content type attribute does not match the content")
+		}
+
+		signed = s.signedAttrs
+	}
+
+	h := s.Hash.New()
+	h.Write(signed)
+	hashed := h.Sum(nil)
+
+	switch pub := s.Certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		err := rsa.VerifyPKCS1v15(pub, s.Hash, hashed, s.signature)
+		if err != nil {
+			return fmt.Errorf("signature verification failed: %s", err.Error())
+		}
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, hashed, s.signature) {
+			return fmt.Errorf("signature verification failed")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type of the signer")
+	}
+
+	return nil
+}
+
+func marshalAttribute(attr Attribute) ([]byte, error) {
+	value, err := asn1.Marshal(attr.Value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal attribute %s failed: %s", attr.Type.String(), err.Error())
+	}
+
+	return asn1.Marshal(attribute{
+		Type:   attr.Type,
+		Values: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+	})
+}
+
+// Sign 使用RSA或ECDSA私钥生成带签名属性（contentType、messageDigest、signingTime以及attrs）的 SignedData
+// content为nil时不包含内容（messageDigest为空内容的摘要），签名者证书和certs包含在 SignedData 的证书中
+func Sign(content []byte, cert *x509.Certificate, key crypto.PrivateKey, hash crypto.Hash, attrs []Attribute, certs []*x509.Certificate) ([]byte, error) {
+	digestOID, ok := hashOIDs[hash]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm")
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type")
+	}
+
+	var sigAlg pkix.AlgorithmIdentifier
+	switch signer.Public().(type) {
+	case *rsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption, Parameters: asn1.NullRawValue}
+	case *ecdsa.PublicKey:
+		sigAlg = pkix.AlgorithmIdentifier{Algorithm: ecdsaSignatureOIDs[hash]}
+	default:
+		return nil, fmt.Errorf("unsupported private key type, only RSA and ECDSA are supported")
+	}
+
+	h := hash.New()
+	h.Write(content)
+
+	all := append([]Attribute{
+		{Type: OIDAttributeContentType, Value: OIDData},
+		{Type: OIDAttributeMessageDigest, Value: h.Sum(nil)},
+		{Type: OIDAttributeSigningTime, Value: time.Now().UTC()},
+	}, attrs...)
+
+	encodedAttrs := make([][]byte, 0, len(all))
+	for _, attr := range all {
+		encoded, err := marshalAttribute(attr)
+		if err != nil {
+			return nil, err
+		}
+		encodedAttrs = append(encodedAttrs, encoded)
+	}
+
+	// DER要求SET OF的元素按编码排序
+	slices.SortFunc(encodedAttrs, bytes.Compare)
+
+	signedAttrs, err := newRawSet(encodedAttrs)
+	if err != nil {
+		return nil, err
+	}
+
+	h = hash.New()
+	h.Write(signedAttrs.Raw)
+
+	signature, err := signer.Sign(utils.Rander(), h.Sum(nil), hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+		SerialNumber: cert.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digestAlg := pkix.AlgorithmIdentifier{Algorithm: digestOID, Parameters: asn1.NullRawValue}
+
+	si, err := asn1.Marshal(signerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    digestAlg,
+		SignedAttrs:        signedAttrs,
+		SignatureAlgorithm: sigAlg,
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digestAlgDER, err := asn1.Marshal(digestAlg)
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []asn1.RawValue{{FullBytes: digestAlgDER}},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: OIDData,
+		},
+		SignerInfos: []asn1.RawValue{{FullBytes: si}},
+	}
+
+	if content != nil {
+		sd.EncapContentInfo.EContent = asn1.RawValue{FullBytes: encodeElement([]byte{0xa0}, encodeElement([]byte{0x04}, content))}
+	}
+
+	err = sd.setCertificates(append([]*x509.Certificate{cert}, certs...), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return sd.marshal()
+}
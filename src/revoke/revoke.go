@@ -0,0 +1,287 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package revoke 保存CA的吊销记录，每个CA（RCA或ICA）在其目录下拥有独立的吊销数据库
+package revoke
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Reason RFC 5280 5.3.1 定义的吊销原因
+type Reason int
+
+const (
+	ReasonUnspecified          Reason = 0
+	ReasonKeyCompromise        Reason = 1
+	ReasonCACompromise         Reason = 2
+	ReasonAffiliationChanged   Reason = 3
+	ReasonSuperseded           Reason = 4
+	ReasonCessationOfOperation Reason = 5
+	ReasonCertificateHold      Reason = 6
+	ReasonRemoveFromCRL        Reason = 8 // 仅用于增量CRL，不能作为吊销原因
+	ReasonPrivilegeWithdrawn   Reason = 9
+	ReasonAACompromise         Reason = 10
+)
+
+var ReasonList = []Reason{
+	ReasonUnspecified,
+	ReasonKeyCompromise,
+	ReasonCACompromise,
+	ReasonAffiliationChanged,
+	ReasonSuperseded,
+	ReasonCessationOfOperation,
+	ReasonCertificateHold,
+	ReasonPrivilegeWithdrawn,
+	ReasonAACompromise,
+}
+
+var ReasonMap = map[Reason]string{
+	ReasonUnspecified:          "unspecified",
+	ReasonKeyCompromise:        "keyCompromise",
+	ReasonCACompromise:         "cACompromise",
+	ReasonAffiliationChanged:   "affiliationChanged",
+	ReasonSuperseded:           "superseded",
+	ReasonCessationOfOperation: "cessationOfOperation",
+	ReasonCertificateHold:      "certificateHold",
+	ReasonRemoveFromCRL:        "removeFromCRL",
+	ReasonPrivilegeWithdrawn:   "privilegeWithdrawn",
+	ReasonAACompromise:         "aACompromise",
+}
+
+func (r Reason) String() string {
+	name, ok := ReasonMap[r]
+	if !ok {
+		return fmt.Sprintf("unknown(%d)", int(r))
+	}
+	return name
+}
+
+// ParseReason 解析吊销原因，支持名称（不区分大小写）或数字
+func ParseReason(s string) (Reason, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return ReasonUnspecified, nil
+	}
+
+	for _, r := range ReasonList {
+		if strings.EqualFold(s, r.String()) || s == fmt.Sprintf("%d", int(r)) {
+			return r, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown revocation reason: %s", s)
+}
+
+// RevokedCert 一条吊销记录
+type RevokedCert struct {
+	SerialNumber *big.Int
+	RevokedAt    time.Time
+	Reason       Reason
+	Subject      string
+	Fingerprint  string // 证书的SHA-256指纹（未知证书时为空）
+	NotAfter     time.Time
+}
+
+// DBFileName 吊销数据库保存在CA目录下的文件名
+const DBFileName = "revoke-db.json"
+
+type RevocationDB struct {
+	Revoked []*RevokedCert
+
+	FilePath string `gob:"-"`
+}
+
+func init() {
+	// 旧版本的吊销数据库（revoke-db.gob）迁移时需要解码
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/revoke.RevocationDB", &RevocationDB{})
+}
+
+// revocationDBFile 吊销数据库（revoke-db.json）的格式
+type revocationDBFile struct {
+	metadata.Header
+	Revoked []*revokedCertFile `json:"revoked"`
+}
+
+type revokedCertFile struct {
+	SerialNumber string     `json:"serial_number"`
+	RevokedAt    time.Time  `json:"revoked_at"`
+	Reason       string     `json:"reason"`
+	Subject      string     `json:"subject,omitempty"`
+	Fingerprint  string     `json:"sha256_fingerprint,omitempty"`
+	NotAfter     *time.Time `json:"not_after,omitempty"`
+}
+
+func NewRevocationDB(filepath string) (*RevocationDB, error) {
+	db := &RevocationDB{
+		Revoked:  make([]*RevokedCert, 0, 10),
+		FilePath: filepath,
+	}
+
+	return db, nil
+}
+
+// GetRevocationDB 读取吊销数据库，文件不存在时返回空数据库（旧版本创建的CA没有该文件），旧版本的gob数据库在读取时迁移
+func GetRevocationDB(filepath string) (*RevocationDB, error) {
+	if metadata.NeedMigrate(filepath) {
+		return migrateRevocationDB(filepath)
+	} else if !utils.IsExists(filepath) {
+		return NewRevocationDB(filepath)
+	}
+
+	var f revocationDBFile
+	err := metadata.Read(filepath, metadata.KindRevocationDB, &f)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &RevocationDB{
+		Revoked:  make([]*RevokedCert, 0, len(f.Revoked)),
+		FilePath: filepath,
+	}
+
+	for _, r := range f.Revoked {
+		serialNumber, err := metadata.ParseBigInt(r.SerialNumber)
+		if err != nil {
+			return nil, err
+		} else if serialNumber == nil {
+			return nil, fmt.Errorf("%s: serial number is missing", filepath)
+		}
+
+		reason, err := ParseReason(r.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", filepath, err.Error())
+		}
+
+		res.Revoked = append(res.Revoked, &RevokedCert{
+			SerialNumber: serialNumber,
+			RevokedAt:    r.RevokedAt,
+			Reason:       reason,
+			Subject:      r.Subject,
+			Fingerprint:  r.Fingerprint,
+			NotAfter:     metadata.ParseTime(r.NotAfter),
+		})
+	}
+
+	return res, nil
+}
+
+// migrateRevocationDB 读取旧版本的revoke-db.gob，保存为revoke-db.json后删除旧文件
+func migrateRevocationDB(filepath string) (*RevocationDB, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var res RevocationDB
+	err := metadata.ReadLegacy(legacyPath, &res)
+	if err != nil {
+		return nil, err
+	}
+	res.FilePath = filepath
+
+	err = res.SaveRevocationDB()
+	if err != nil {
+		return nil, err
+	}
+
+	err = metadata.RemoveLegacy(legacyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+func (db *RevocationDB) SaveRevocationDB() error {
+	f := &revocationDBFile{
+		Header:  metadata.NewHeader(metadata.KindRevocationDB),
+		Revoked: make([]*revokedCertFile, 0, len(db.Revoked)),
+	}
+
+	for _, r := range db.Revoked {
+		f.Revoked = append(f.Revoked, &revokedCertFile{
+			SerialNumber: metadata.FormatBigInt(r.SerialNumber),
+			RevokedAt:    r.RevokedAt.UTC(),
+			Reason:       r.Reason.String(),
+			Subject:      r.Subject,
+			Fingerprint:  r.Fingerprint,
+			NotAfter:     metadata.FormatTime(r.NotAfter),
+		})
+	}
+
+	return metadata.Write(db.FilePath, f)
+}
+
+// Find 根据序列号查找吊销记录，未吊销时返回nil
+func (db *RevocationDB) Find(serialNumber *big.Int) *RevokedCert {
+	for _, r := range db.Revoked {
+		if r.SerialNumber.Cmp(serialNumber) == 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+func (db *RevocationDB) IsRevoked(serialNumber *big.Int) bool {
+	return db.Find(serialNumber) != nil
+}
+
+// Revoke 吊销证书，同一证书不能被吊销两次
+func (db *RevocationDB) Revoke(cert *x509.Certificate, reason Reason, revokedAt time.Time) (*RevokedCert, error) {
+	res, err := db.RevokeSerialNumber(cert.SerialNumber, reason, revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Subject = cert.Subject.String()
+	res.Fingerprint = Fingerprint(cert)
+	res.NotAfter = cert.NotAfter
+
+	return res, nil
+}
+
+// RevokeSerialNumber 根据序列号吊销证书（适用于证书文件已不存在的情况）
+func (db *RevocationDB) RevokeSerialNumber(serialNumber *big.Int, reason Reason, revokedAt time.Time) (*RevokedCert, error) {
+	if serialNumber == nil || serialNumber.Sign() <= 0 {
+		return nil, fmt.Errorf("not a valid serial number")
+	}
+
+	if _, ok := ReasonMap[reason]; !ok || reason == ReasonRemoveFromCRL {
+		return nil, fmt.Errorf("not a valid revocation reason: %s", reason.String())
+	}
+
+	if r := db.Find(serialNumber); r != nil {
+		return nil, fmt.Errorf("the certificate (serial number: %s) has been revoked at %s", serialNumber.Text(16), r.RevokedAt.Format(time.RFC3339))
+	}
+
+	res := &RevokedCert{
+		SerialNumber: new(big.Int).Set(serialNumber),
+		RevokedAt:    revokedAt.UTC(),
+		Reason:       reason,
+	}
+
+	db.Revoked = append(db.Revoked, res)
+
+	return res, nil
+}
+
+// Fingerprint 计算证书的SHA-256指纹（小写十六进制）
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint 规范化用户输入的指纹（去除冒号和空白，转为小写）
+func NormalizeFingerprint(s string) string {
+	s = strings.ReplaceAll(s, ":", "")
+	s = strings.ReplaceAll(s, " ", "")
+	return strings.ToLower(strings.TrimSpace(s))
+}
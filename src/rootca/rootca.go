@@ -2,17 +2,18 @@ package rootca
 
 import (
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/rsa"
 	"crypto/x509"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"github.com/SongZihuan/MyCA/src/global"
+	"github.com/SongZihuan/MyCA/src/issuance"
+	"github.com/SongZihuan/MyCA/src/metadata"
 	"github.com/SongZihuan/MyCA/src/utils"
+	"io/fs"
 	"math/big"
-	"os"
+	"path"
+	"sync"
 	"time"
 )
 
@@ -21,17 +22,26 @@ type RCAInfo struct {
 	OCSPServer            []string
 	IssuingCertificateURL []string
 	CRLDistributionPoints []string
+	CRLNumber             *big.Int                   // 最近一次签发的CRL的编号（包括增量CRL）
+	BaseCRLNumber         *big.Int                   // 最近一次签发的完整CRL的编号，增量CRL以此为基础
+	BaseCRLAt             time.Time                  // 最近一次签发的完整CRL的thisUpdate
+	RandSource            string                     // 生成私钥时使用的随机源，旧版本（使用math/rand）创建的CA为空
+	SignatureAlgorithm    x509.SignatureAlgorithm    // 签发证书和CRL默认使用的签名算法，为0时由私钥决定
+	SerialMode            string                     // 证书序列号的分配模式，为空时（旧版本创建的CA）使用顺序模式
+	NameConstraints       *utils.NameConstraints     // RCA证书的名称约束，为nil时表示没有约束
+	Policies              []*utils.CertificatePolicy // 可供签发证书时选择的命名证书策略
 
 	FilePath string `gob:"-"`
 }
 
 func init() {
-	gob.Register(RCAInfo{})
+	// 旧版本的ICA和证书信息文件（gob）中保存了RCA信息的副本，迁移时需要解码
+	gob.RegisterName("github.com/SongZihuan/MyCA/src/rootca.RCAInfo", &RCAInfo{})
 }
 
 func NewRCAInfo(filepath string, ocsp []string, issuerURL []string, crlURL []string) (*RCAInfo, error) {
 	randMax := new(big.Int).Lsh(big.NewInt(1), uint(40))
-	randSerialNumber, err := rand.Int(rand.Reader, randMax)
+	randSerialNumber, err := utils.RandInt(randMax)
 	if err != nil {
 		return nil, fmt.Errorf("error generating random number: %s", err.Error())
 	}
@@ -41,59 +51,156 @@ func NewRCAInfo(filepath string, ocsp []string, issuerURL []string, crlURL []str
 		OCSPServer:            ocsp,
 		IssuingCertificateURL: issuerURL,
 		CRLDistributionPoints: crlURL,
+		RandSource:            utils.RandSource(),
 		FilePath:              filepath,
 	}
 
 	return info, nil
 }
 
+// rcaInfoFile RCA信息文件（rca-info.json）的格式
+type rcaInfoFile struct {
+	metadata.Header
+	SerialNumber          string     `json:"serial_number"`
+	SerialMode            string     `json:"serial_mode,omitempty"`
+	OCSPServer            []string   `json:"ocsp_server,omitempty"`
+	IssuingCertificateURL []string   `json:"issuing_certificate_url,omitempty"`
+	CRLDistributionPoints []string   `json:"crl_distribution_points,omitempty"`
+	CRLNumber             string     `json:"crl_number,omitempty"`
+	BaseCRLNumber         string     `json:"base_crl_number,omitempty"`
+	BaseCRLAt             *time.Time `json:"base_crl_at,omitempty"`
+	RandSource            string     `json:"rand_source,omitempty"`
+	SignatureAlgorithm    string     `json:"signature_algorithm,omitempty"`
+
+	NameConstraints *utils.NameConstraints     `json:"name_constraints,omitempty"`
+	Policies        []*utils.CertificatePolicy `json:"policies,omitempty"`
+}
+
 func GetRCAInfo(filepath string) (*RCAInfo, error) {
-	file, err := os.Open(filepath)
+	if metadata.NeedMigrate(filepath) {
+		return migrateRCAInfo(filepath)
+	}
+
+	var f rcaInfoFile
+	err := metadata.Read(filepath, metadata.KindRCA, &f)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	var res RCAInfo
-	decoder := gob.NewDecoder(file)
-	err = decoder.Decode(&res)
+	res := &RCAInfo{
+		OCSPServer:            f.OCSPServer,
+		IssuingCertificateURL: f.IssuingCertificateURL,
+		CRLDistributionPoints: f.CRLDistributionPoints,
+		BaseCRLAt:             metadata.ParseTime(f.BaseCRLAt),
+		RandSource:            f.RandSource,
+		SerialMode:            f.SerialMode,
+		NameConstraints:       f.NameConstraints,
+		Policies:              f.Policies,
+		FilePath:              filepath,
+	}
+
+	res.SerialNumber, err = metadata.ParseBigInt(f.SerialNumber)
 	if err != nil {
 		return nil, err
+	} else if res.SerialNumber == nil {
+		return nil, fmt.Errorf("%s: serial number is missing", filepath)
 	}
 
-	res.FilePath = filepath
+	res.CRLNumber, err = metadata.ParseBigInt(f.CRLNumber)
+	if err != nil {
+		return nil, err
+	}
 
-	return &res, nil
+	res.BaseCRLNumber, err = metadata.ParseBigInt(f.BaseCRLNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	res.SignatureAlgorithm, err = metadata.ParseSignatureAlgorithm(f.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
 }
 
-func (info *RCAInfo) SaveRCAInfo() error {
-	file, err := os.OpenFile(info.FilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+// migrateRCAInfo 读取旧版本的rca-info.gob，保存为rca-info.json后删除旧文件
+func migrateRCAInfo(filepath string) (*RCAInfo, error) {
+	legacyPath := metadata.LegacyPath(filepath)
+
+	var res RCAInfo
+	err := metadata.ReadLegacy(legacyPath, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	res.FilePath = filepath
+
+	err = res.SaveRCAInfo()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		_ = file.Close()
-	}()
 
-	encoder := gob.NewEncoder(file)
-	err = encoder.Encode(*info) // 不需要以指针形式出现
+	err = metadata.RemoveLegacy(legacyPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return &res, nil
+}
+
+func (info *RCAInfo) SaveRCAInfo() error {
+	return metadata.Write(info.FilePath, &rcaInfoFile{
+		Header:                metadata.NewHeader(metadata.KindRCA),
+		SerialNumber:          metadata.FormatBigInt(info.SerialNumber),
+		SerialMode:            info.SerialMode,
+		OCSPServer:            info.OCSPServer,
+		IssuingCertificateURL: info.IssuingCertificateURL,
+		CRLDistributionPoints: info.CRLDistributionPoints,
+		CRLNumber:             metadata.FormatBigInt(info.CRLNumber),
+		BaseCRLNumber:         metadata.FormatBigInt(info.BaseCRLNumber),
+		BaseCRLAt:             metadata.FormatTime(info.BaseCRLAt),
+		RandSource:            info.RandSource,
+		SignatureAlgorithm:    metadata.FormatSignatureAlgorithm(info.SignatureAlgorithm),
+		NameConstraints:       info.NameConstraints,
+		Policies:              info.Policies,
+	})
 }
 
+// serialNumberLock 保护分配序列号时对信息文件的读取和写入
+var serialNumberLock sync.Mutex
+
+// NewCertSerialNumber 分配新的证书序列号，序列号不与签发索引中的记录重复，并在签发证书前保存到信息文件中，避免下次签发时重复使用。
+// 长期运行的服务（EST、ACME等）持有的信息可能已过期（例如CRL编号已被命令行更新），因此重新读取信息文件并只更新其中的序列号
 func (info *RCAInfo) NewCertSerialNumber() (*big.Int, error) {
-	randMax := new(big.Int).Lsh(big.NewInt(1), uint(40))
-	addSerialNumber, err := rand.Int(rand.Reader, randMax)
+	serialNumberLock.Lock()
+	defer serialNumberLock.Unlock()
+
+	saved, err := GetRCAInfo(info.FilePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		saved = info // 正在创建的CA，信息文件尚未保存
+	} else if err != nil {
+		return nil, err
+	}
+
+	lastSerialNumber := info.SerialNumber
+	if saved.SerialNumber.Cmp(lastSerialNumber) > 0 {
+		lastSerialNumber = saved.SerialNumber
+	}
+
+	serialNumber, err := issuance.NewSerialNumber(path.Dir(info.FilePath), info.SerialMode, lastSerialNumber)
 	if err != nil {
-		return nil, fmt.Errorf("error generating random number: %s", err.Error())
+		return nil, err
+	}
+
+	saved.SerialNumber = new(big.Int).Set(serialNumber)
+	err = saved.SaveRCAInfo()
+	if err != nil {
+		return nil, fmt.Errorf("save the serial number failed: %s", err.Error())
 	}
 
-	return info.SerialNumber.Add(info.SerialNumber, addSerialNumber), nil
+	info.SerialNumber = new(big.Int).Set(serialNumber)
+	return serialNumber, nil
 }
 
 func (info *RCAInfo) GetIssuingCertificateURL() []string {
@@ -108,15 +215,45 @@ func (info *RCAInfo) GetCRLDistributionPoints() []string {
 	return info.CRLDistributionPoints
 }
 
-// CreateRCA 创建根CA证书
-func CreateRCA(infoFilePath string, cryptoType utils.CryptoType, keyLength int, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, maxPathLen int, ocsp []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time) (*x509.Certificate, crypto.PrivateKey, *RCAInfo, error) {
-	var privKey crypto.PrivateKey
-	var pubKey crypto.PublicKey
+func (info *RCAInfo) GetSignatureAlgorithm() x509.SignatureAlgorithm {
+	return info.SignatureAlgorithm
+}
+
+// Reference 返回下级ICA和证书的信息文件中对该RCA的引用
+func (info *RCAInfo) Reference() *metadata.Reference {
+	return metadata.NewReference(metadata.ReferenceRCA, info.FilePath)
+}
+
+// NewCRLNumber 返回新的CRL编号，CRL编号单调递增（旧版本创建的CA从1开始）
+func (info *RCAInfo) NewCRLNumber() *big.Int {
+	if info.CRLNumber == nil {
+		info.CRLNumber = big.NewInt(0)
+	}
+
+	info.CRLNumber = new(big.Int).Add(info.CRLNumber, big.NewInt(1))
+	return new(big.Int).Set(info.CRLNumber)
+}
+
+func (info *RCAInfo) GetBaseCRL() (*big.Int, time.Time) {
+	return info.BaseCRLNumber, info.BaseCRLAt
+}
+
+func (info *RCAInfo) SetBaseCRL(number *big.Int, thisUpdate time.Time) {
+	info.BaseCRLNumber = new(big.Int).Set(number)
+	info.BaseCRLAt = thisUpdate
+}
 
+// CreateRCA 创建根CA证书
+func CreateRCA(infoFilePath string, cryptoType utils.CryptoType, keyLength int, key crypto.PrivateKey, subject *global.CertSubject, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, maxPathLen int, nameConstraints *utils.NameConstraints, policies *utils.PolicySettings, ocsp []string, selfURL []string, crlURL []string, notBefore time.Time, notAfter time.Time, signatureAlgorithm x509.SignatureAlgorithm) (*x509.Certificate, crypto.PrivateKey, *RCAInfo, error) {
 	info, err := NewRCAInfo(infoFilePath, ocsp, selfURL, crlURL)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	info.SignatureAlgorithm = signatureAlgorithm
+
+	if !nameConstraints.IsEmpty() {
+		info.NameConstraints = nameConstraints
+	}
 
 	err = subject.SetCNIfEmpty() // 兜底，确保CN被设置
 	if err != nil {
@@ -129,41 +266,16 @@ func CreateRCA(infoFilePath string, cryptoType utils.CryptoType, keyLength int,
 		extKeyUsage = utils.CopySlice(extKeyUsage)
 	}
 
-	switch cryptoType {
-	case utils.CryptoTypeRsa:
-		if keyLength != 2048 && keyLength != 4096 {
-			return nil, nil, nil, fmt.Errorf("unsupported RSA key length: %d", keyLength)
-		}
-
-		priv, err := rsa.GenerateKey(utils.Rander(), keyLength)
-		if err != nil {
-			return nil, nil, nil, err
-		}
-
-		privKey = priv
-		pubKey = &priv.PublicKey
-	case utils.CryptoTypeEcc:
-		fallthrough
-	case utils.CryptoTypeEcdsa:
-		var curve elliptic.Curve
-		switch keyLength {
-		case 256:
-			curve = elliptic.P256()
-		case 384:
-			curve = elliptic.P384()
-		case 521:
-			curve = elliptic.P521()
-		default:
-			return nil, nil, nil, fmt.Errorf("unsupported ECC key length: %d", keyLength)
-		}
-		priv, err := ecdsa.GenerateKey(curve, utils.Rander())
-		if err != nil {
-			return nil, nil, nil, err
-		}
-		privKey = priv
-		pubKey = &priv.PublicKey
-	default:
-		return nil, nil, nil, fmt.Errorf("unsupported crypto type: %s", cryptoType)
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, cryptoType, keyLength)
+	if err != nil {
+		return nil, nil, nil, err
+	} else if key != nil {
+		info.RandSource = utils.RandSourceExternal
+	}
+
+	err = utils.CheckSignatureAlgorithm(signatureAlgorithm, pubKey)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	if notBefore.Equal(time.Time{}) {
@@ -185,10 +297,11 @@ func CreateRCA(infoFilePath string, cryptoType utils.CryptoType, keyLength int,
 	}
 
 	template := &x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject:      subject.ToPkixName(),
-		NotBefore:    notBefore,
-		NotAfter:     notAfter,
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: info.SignatureAlgorithm,
+		Subject:            subject.ToPkixName(),
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
 
 		KeyUsage:    keyUsage,
 		ExtKeyUsage: extKeyUsage, // 允许全部扩展用途
@@ -206,6 +319,16 @@ func CreateRCA(infoFilePath string, cryptoType utils.CryptoType, keyLength int,
 		CRLDistributionPoints: info.CRLDistributionPoints,
 	}
 
+	err = info.NameConstraints.Apply(template)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = policies.Apply(template)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	derBytes, err := x509.CreateCertificate(utils.Rander(), template, template, pubKey, privKey)
 	if err != nil {
 		return nil, nil, nil, err
@@ -0,0 +1,251 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package scepserver
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/pkcs7"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"math/big"
+)
+
+// RFC 8894 3.2.1 SCEP签名属性
+var (
+	oidMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidPKIStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+
+	oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+)
+
+// 消息类型（RFC 8894 3.2.1.2），以十进制字符串编码
+const (
+	MessageTypeCertRep    = "3"
+	MessageTypeRenewalReq = "17"
+	MessageTypePKCSReq    = "19"
+	MessageTypeCertPoll   = "20"
+	MessageTypeGetCert    = "21"
+	MessageTypeGetCRL     = "22"
+)
+
+var messageTypeNames = map[string]string{
+	MessageTypeCertRep:    "CertRep",
+	MessageTypeRenewalReq: "RenewalReq",
+	MessageTypePKCSReq:    "PKCSReq",
+	MessageTypeCertPoll:   "CertPoll",
+	MessageTypeGetCert:    "GetCert",
+	MessageTypeGetCRL:     "GetCRL",
+}
+
+// 响应状态（RFC 8894 3.2.1.3）
+const (
+	pkiStatusSuccess = "0"
+	pkiStatusFailure = "2"
+	pkiStatusPending = "3"
+)
+
+// 失败原因（RFC 8894 3.2.1.4）
+const (
+	failBadAlg          = "0"
+	failBadMessageCheck = "1"
+	failBadRequest      = "2"
+	failBadTime         = "3"
+	failBadCertID       = "4"
+)
+
+// pkiMessage 解析后的请求
+type pkiMessage struct {
+	MessageType   string
+	TransactionID string
+	SenderNonce   []byte
+	Signer        *x509.Certificate // 签名者证书，PKCSReq时通常为客户端的临时自签名证书
+	Hash          crypto.Hash
+	Content       []byte                    // 解密后的 messageData
+	Encryption    pkcs7.EncryptionAlgorithm // 请求使用的内容加密算法，响应使用相同的算法
+
+	Verified bool // 签名是否验证通过，未通过时只能返回 badMessageCheck
+}
+
+// issuerAndSerialNumber GetCert 和 GetCRL 的 messageData
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// parsePKIMessage 解析请求的签名属性并解密内容，签名验证失败时返回 Verified 为false的消息
+func parsePKIMessage(data []byte, ra *x509.Certificate, raKey crypto.PrivateKey) (*pkiMessage, error) {
+	sd, err := pkcs7.ParseSignedData(data)
+	if err != nil {
+		return nil, err
+	} else if len(sd.Signers) != 1 {
+		return nil, fmt.Errorf("the pkiMessage must have exactly one signer")
+	}
+
+	signer := sd.Signers[0]
+	if signer.Certificate == nil {
+		return nil, fmt.Errorf("the certificate of the signer is not found")
+	}
+
+	msg := &pkiMessage{
+		Signer: signer.Certificate,
+		Hash:   signer.Hash,
+	}
+
+	err = signer.Attribute(oidMessageType, &msg.MessageType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = signer.Attribute(oidTransactionID, &msg.TransactionID)
+	if err != nil {
+		return nil, err
+	} else if msg.TransactionID == "" {
+		return nil, fmt.Errorf("the transactionID is empty")
+	}
+
+	err = signer.Attribute(oidSenderNonce, &msg.SenderNonce)
+	if err != nil && !errors.Is(err, pkcs7.ErrAttributeNotFound) {
+		return nil, err
+	}
+
+	if sd.Verify() != nil {
+		return msg, nil
+	}
+	msg.Verified = true
+
+	if len(sd.Content) != 0 {
+		msg.Content, msg.Encryption, err = pkcs7.Decrypt(sd.Content, ra, raKey)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt the pkiMessage failed: %s", err.Error())
+		}
+	}
+
+	return msg, nil
+}
+
+// challengePassword 读取CSR中的挑战密码属性（RFC 2985 5.4.1），不存在时返回空字符串
+func challengePassword(csr *x509.CertificateRequest) (string, error) {
+	var tbs struct {
+		Version       int
+		Subject       asn1.RawValue
+		PublicKeyInfo asn1.RawValue
+		Attributes    []asn1.RawValue `asn1:"tag:0"`
+	}
+
+	_, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &tbs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, raw := range tbs.Attributes {
+		var attr struct {
+			Type   asn1.ObjectIdentifier
+			Values []asn1.RawValue `asn1:"set"`
+		}
+
+		_, err := asn1.Unmarshal(raw.FullBytes, &attr)
+		if err != nil || !attr.Type.Equal(oidChallengePassword) || len(attr.Values) == 0 {
+			continue
+		}
+
+		var password string
+		_, err = asn1.Unmarshal(attr.Values[0].FullBytes, &password)
+		if err != nil {
+			return "", fmt.Errorf("not a valid challenge password: %s", err.Error())
+		}
+		return password, nil
+	}
+
+	return "", nil
+}
+
+// certRep 响应（CertRep）
+type certRep struct {
+	Status   string
+	FailInfo string
+	Certs    []*x509.Certificate // 成功时返回的证书
+	CRL      []byte              // GetCRL成功时返回的CRL（DER）
+}
+
+func success(certs ...*x509.Certificate) *certRep {
+	return &certRep{Status: pkiStatusSuccess, Certs: certs}
+}
+
+func pending() *certRep {
+	return &certRep{Status: pkiStatusPending}
+}
+
+func failure(failInfo string) *certRep {
+	return &certRep{Status: pkiStatusFailure, FailInfo: failInfo}
+}
+
+// marshalCertRep 生成使用RA密钥签名的CertRep，成功时内容为加密给请求签名者的退化SignedData
+func marshalCertRep(req *pkiMessage, rep *certRep, ra *x509.Certificate, raKey crypto.PrivateKey) ([]byte, error) {
+	senderNonce := make([]byte, 16)
+	_, err := io.ReadFull(utils.Rander(), senderNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := []pkcs7.Attribute{
+		{Type: oidTransactionID, Value: req.TransactionID},
+		{Type: oidMessageType, Value: MessageTypeCertRep},
+		{Type: oidPKIStatus, Value: rep.Status},
+		{Type: oidSenderNonce, Value: senderNonce},
+	}
+
+	if len(req.SenderNonce) != 0 {
+		attrs = append(attrs, pkcs7.Attribute{Type: oidRecipientNonce, Value: req.SenderNonce})
+	}
+
+	if rep.Status == pkiStatusFailure {
+		attrs = append(attrs, pkcs7.Attribute{Type: oidFailInfo, Value: rep.FailInfo})
+	}
+
+	var content []byte
+	if rep.Status == pkiStatusSuccess {
+		var crls [][]byte
+		if rep.CRL != nil {
+			crls = [][]byte{rep.CRL}
+		}
+
+		degenerate, err := pkcs7.MarshalDegenerate(rep.Certs, crls)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err = pkcs7.Encrypt(degenerate, []*x509.Certificate{req.Signer}, req.Encryption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hash := req.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+
+	return pkcs7.Sign(content, ra, raKey, hash, attrs, nil)
+}
+
+// parseIssuerAndSerialNumber 读取 GetCert 和 GetCRL 的 messageData
+func parseIssuerAndSerialNumber(data []byte) (*issuerAndSerialNumber, error) {
+	var ias issuerAndSerialNumber
+	_, err := asn1.Unmarshal(data, &ias)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid IssuerAndSerialNumber: %s", err.Error())
+	} else if ias.SerialNumber == nil {
+		return nil, fmt.Errorf("not a valid IssuerAndSerialNumber: no serial number")
+	}
+	return &ias, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package scepserver
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/cert"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"time"
+)
+
+// CreateRA 签发SCEP的RA（Registration Authority）证书，客户端使用其公钥加密请求，服务使用其私钥签名响应，
+// SCEP只支持RSA密钥传输，因此RA密钥必须是RSA密钥
+func CreateRA(caInfo cert.CAInfo, keyLength int, key crypto.PrivateKey, notBefore time.Time, notAfter time.Time, ca *x509.Certificate, caKey crypto.PrivateKey) (*x509.Certificate, crypto.PrivateKey, error) {
+	if key != nil {
+		if _, ok := key.(*rsa.PrivateKey); !ok {
+			return nil, nil, fmt.Errorf("the private key of the RA must be a RSA key")
+		}
+	}
+
+	privKey, pubKey, err := utils.GenerateKeyIfNil(key, utils.CryptoTypeRsa, keyLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+
+	ski, err := utils.CalculateSubjectKeyIdentifier(pubKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get subject key indentifier failed: %s", err.Error())
+	}
+
+	serialNumber, err := caInfo.NewCertSerialNumber()
+	if err != nil {
+		return nil, nil, fmt.Errorf("get new serial number failed: %s", err.Error())
+	}
+
+	subject := pkix.Name{
+		Country:            ca.Subject.Country,
+		Organization:       ca.Subject.Organization,
+		OrganizationalUnit: ca.Subject.OrganizationalUnit,
+		CommonName:         fmt.Sprintf("%s SCEP RA", ca.Subject.CommonName),
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:       serialNumber,
+		SignatureAlgorithm: caInfo.GetSignatureAlgorithm(),
+		Subject:            subject,
+		NotBefore:          notBefore,
+		NotAfter:           notAfter,
+
+		// RFC 8894 2.1.1 RA证书同时用于签名响应和解密请求
+		KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+
+		SubjectKeyId:   []byte(ski),
+		AuthorityKeyId: ca.SubjectKeyId,
+
+		IssuingCertificateURL: caInfo.GetIssuingCertificateURL(),
+		OCSPServer:            caInfo.GetOCSPServer(),
+		CRLDistributionPoints: caInfo.GetCRLDistributionPoints(),
+	}
+
+	derBytes, err := x509.CreateCertificate(utils.Rander(), template, ca, pubKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ra, err := x509.ParseCertificate(derBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ra, privKey, nil
+}
@@ -0,0 +1,497 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package scepserver 实现 RFC 8894 SCEP（Simple Certificate Enrolment Protocol）服务，
+// 请求使用RA证书加密、响应使用RA私钥签名，客户端通过挑战密码认证，没有挑战密码的请求可以等待人工审批
+package scepserver
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/pkcs7"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxRequestSize = 64 * 1024
+
+const (
+	contentTypeCACaps   = "text/plain"
+	contentTypeCACert   = "application/x-x509-ca-ra-cert"
+	contentTypePKIReply = "application/x-pki-message"
+)
+
+// caCaps RFC 8894 3.5.2
+var caCaps = []string{"POSTPKIOperation", "Renewal", "SHA-1", "SHA-256", "SHA-512", "AES", "DES3", "SCEPStandard"}
+
+// IssueRequest 签发请求，调用时CSR的签名和客户端的认证（挑战密码、人工审批或续期证书）已检查
+type IssueRequest struct {
+	CSR           *x509.CertificateRequest
+	TransactionID string
+	Old           *x509.Certificate // RenewalReq 时为被续期的证书（即请求的签名者证书）
+}
+
+// IssueFunc 签发证书，返回的错误会记录在请求中并向客户端返回 badRequest
+type IssueFunc func(req *IssueRequest) (*x509.Certificate, error)
+
+// Config SCEP服务的配置
+type Config struct {
+	CACerts        []*x509.Certificate // GetCACert 返回的CA证书（签发CA在前）
+	RA             *x509.Certificate   // RA证书，由签发CA签发
+	RAKey          crypto.PrivateKey   // RA的RSA私钥
+	Store          *Store
+	ManualApproval bool // 没有挑战密码的请求等待人工审批，为false时拒绝这些请求
+	Issue          IssueFunc
+
+	VerifyRenewal  func(old *x509.Certificate) error                      // 验证被续期的证书由签发CA签发、未过期且未被吊销
+	GetCertificate func(serialNumber *big.Int) (*x509.Certificate, error) // 按序列号查找签发CA签发的证书，不存在时返回nil
+	GetCRL         func() ([]byte, error)                                 // 返回签发CA的CRL（DER）
+}
+
+// Server SCEP服务，实现 http.Handler，请求的路径不影响处理（客户端通常使用 /scep 或 /cgi-bin/pkiclient.exe）
+type Server struct {
+	lock           sync.Mutex
+	ca             *x509.Certificate
+	cacert         []byte
+	ra             *x509.Certificate
+	raKey          crypto.PrivateKey
+	store          *Store
+	manualApproval bool
+	issue          IssueFunc
+	verifyRenewal  func(old *x509.Certificate) error
+	getCertificate func(serialNumber *big.Int) (*x509.Certificate, error)
+	getCRL         func() ([]byte, error)
+}
+
+func NewServer(config *Config) (*Server, error) {
+	if len(config.CACerts) == 0 || config.RA == nil || config.RAKey == nil || config.Store == nil || config.Issue == nil {
+		return nil, fmt.Errorf("the CA certificates, RA, store and issue function must be set")
+	} else if config.VerifyRenewal == nil || config.GetCertificate == nil || config.GetCRL == nil {
+		return nil, fmt.Errorf("the renewal verification, certificate and CRL functions must be set")
+	}
+
+	err := utils.CheckKeyPair(config.RA, config.RAKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.RA.CheckSignatureFrom(config.CACerts[0])
+	if err != nil {
+		return nil, fmt.Errorf("the RA certificate is not issued by the CA: %s", err.Error())
+	} else if time.Now().After(config.RA.NotAfter) {
+		return nil, fmt.Errorf("the RA certificate has expired")
+	}
+
+	cacert, err := pkcs7.MarshalCertsOnly(append(append([]*x509.Certificate{}, config.CACerts...), config.RA))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		ca:             config.CACerts[0],
+		cacert:         cacert,
+		ra:             config.RA,
+		raKey:          config.RAKey,
+		store:          config.Store,
+		manualApproval: config.ManualApproval,
+		issue:          config.Issue,
+		verifyRenewal:  config.VerifyRenewal,
+		getCertificate: config.GetCertificate,
+		getCRL:         config.GetCRL,
+	}, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	operation := r.URL.Query().Get("operation")
+
+	switch {
+	case operation == "GetCACaps" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", contentTypeCACaps)
+		_, _ = w.Write([]byte(strings.Join(caCaps, "\n") + "\n"))
+	case operation == "GetCACert" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", contentTypeCACert)
+		_, _ = w.Write(s.cacert)
+	case operation == "PKIOperation" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		s.pkiOperation(w, r)
+	case operation == "":
+		http.Error(w, "the operation must be set", http.StatusBadRequest)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported operation: %s %s", r.Method, operation), http.StatusBadRequest)
+	}
+}
+
+// readPKIMessage 读取 PKIOperation 的请求，GET时为base64编码的message参数，POST时为DER编码的请求体
+func readPKIMessage(r *http.Request) ([]byte, error) {
+	if r.Method == http.MethodGet {
+		// 未正确URL编码的base64中的“+”会被解析为空格
+		message := strings.ReplaceAll(r.URL.Query().Get("message"), " ", "+")
+		if message == "" {
+			return nil, fmt.Errorf("the message must be set")
+		}
+		return decodeBase64(message)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > maxRequestSize {
+		return nil, fmt.Errorf("the request is too large")
+	} else if len(data) == 0 {
+		return nil, fmt.Errorf("the request is empty")
+	}
+
+	if data[0] != 0x30 {
+		// 部分客户端POST时也使用base64编码
+		return decodeBase64(string(data))
+	}
+	return data, nil
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == '\t' || r == ' ' {
+			return -1
+		}
+		return r
+	}, s)
+
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, fmt.Errorf("not a valid base64 message: %s", err.Error())
+		}
+	}
+	return data, nil
+}
+
+func (s *Server) pkiOperation(w http.ResponseWriter, r *http.Request) {
+	data, err := readPKIMessage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := parsePKIMessage(data, s.ra, s.raKey)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("not a valid pkiMessage: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	rep, err := s.handle(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res, err := marshalCertRep(msg, rep, s.ra, s.raKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypePKIReply)
+	_, _ = w.Write(res)
+}
+
+// handle 处理请求，客户端的错误以失败的CertRep返回，服务端的错误返回error
+func (s *Server) handle(msg *pkiMessage) (*certRep, error) {
+	if !msg.Verified {
+		return failure(failBadMessageCheck), nil
+	} else if msg.Signer.PublicKeyAlgorithm != x509.RSA {
+		return failure(failBadAlg), nil // 成功的响应只能加密给签名者的RSA公钥
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch msg.MessageType {
+	case MessageTypePKCSReq, MessageTypeRenewalReq:
+		return s.enroll(msg)
+	case MessageTypeCertPoll:
+		return s.poll(msg)
+	case MessageTypeGetCert:
+		return s.getCert(msg)
+	case MessageTypeGetCRL:
+		return s.getCRLRep(msg)
+	default:
+		return failure(failBadRequest), nil
+	}
+}
+
+func (s *Server) enroll(msg *pkiMessage) (*certRep, error) {
+	csr, err := x509.ParseCertificateRequest(msg.Content)
+	if err != nil {
+		return failure(failBadRequest), nil
+	} else if csr.CheckSignature() != nil {
+		return failure(failBadMessageCheck), nil
+	}
+
+	// RFC 8894 3.2.1 PKCSReq的签名者证书（通常为临时自签名证书）必须使用CSR中的公钥
+	if msg.MessageType == MessageTypePKCSReq {
+		pub, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !pub.Equal(msg.Signer.PublicKey) {
+			return failure(failBadMessageCheck), nil
+		}
+	}
+
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+
+	id := requestID(msg.TransactionID)
+
+	// 客户端可以使用相同的transactionID重新发送请求（RFC 8894 3.3.1）
+	req, err := s.store.getRequest(id)
+	if err == nil {
+		block, _ := pem.Decode([]byte(req.CSR))
+		if block == nil || !bytes.Equal(block.Bytes, csr.Raw) {
+			return failure(failBadRequest), nil
+		}
+		return s.resume(req, csr)
+	} else if !errors.Is(err, errNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	req = &Request{
+		ID:            id,
+		TransactionID: msg.TransactionID,
+		MessageType:   messageTypeNames[msg.MessageType],
+		Subject:       csr.Subject.String(),
+		CSR:           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr.Raw})),
+		CreatedAt:     now,
+	}
+
+	if msg.MessageType == MessageTypeRenewalReq {
+		err = s.verifyRenewal(msg.Signer)
+		if err != nil {
+			return s.reject(req, fmt.Sprintf("the certificate to renew is not valid: %s", err.Error()), failBadMessageCheck)
+		}
+
+		err = checkRenewal(csr, msg.Signer)
+		if err != nil {
+			return s.reject(req, err.Error(), failBadRequest)
+		}
+		return s.issueRequest(req, csr, msg.Signer, nil)
+	}
+
+	password, err := challengePassword(csr)
+	if err != nil {
+		return s.reject(req, err.Error(), failBadRequest)
+	}
+
+	if password == "" {
+		if !s.manualApproval {
+			return s.reject(req, "no challenge password", failBadRequest)
+		}
+
+		req.Status = RequestPending
+		err = s.store.saveRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		return pending(), nil
+	}
+
+	challenge, err := s.store.findChallenge(password)
+	if errors.Is(err, errInvalidChallenge) {
+		return s.reject(req, err.Error(), failBadRequest)
+	} else if err != nil {
+		return nil, err
+	}
+
+	req.ChallengeID = challenge.ID
+	return s.issueRequest(req, csr, nil, challenge)
+}
+
+// checkRenewal RenewalReq的CSR的主题和SAN必须与被续期的证书（签名者证书）相同，避免使用任一有效证书续期得到其他名称的证书
+func checkRenewal(csr *x509.CertificateRequest, old *x509.Certificate) error {
+	if csr.Subject.String() != old.Subject.String() {
+		return fmt.Errorf("the subject of the certificate request (%s) is not the same as the certificate to be renewed (%s)", csr.Subject.String(), old.Subject.String())
+	}
+
+	csrIPs := make([]string, 0, len(csr.IPAddresses))
+	for _, ip := range csr.IPAddresses {
+		csrIPs = append(csrIPs, ip.String())
+	}
+	oldIPs := make([]string, 0, len(old.IPAddresses))
+	for _, ip := range old.IPAddresses {
+		oldIPs = append(oldIPs, ip.String())
+	}
+
+	csrURIs := make([]string, 0, len(csr.URIs))
+	for _, u := range csr.URIs {
+		csrURIs = append(csrURIs, u.String())
+	}
+	oldURIs := make([]string, 0, len(old.URIs))
+	for _, u := range old.URIs {
+		oldURIs = append(oldURIs, u.String())
+	}
+
+	if !sameSet(csr.DNSNames, old.DNSNames) || !sameSet(csrIPs, oldIPs) || !sameSet(csr.EmailAddresses, old.EmailAddresses) || !sameSet(csrURIs, oldURIs) {
+		return fmt.Errorf("the subject alternative names of the certificate request are not the same as the certificate to be renewed")
+	}
+
+	return nil
+}
+
+// sameSet 忽略大小写、顺序和重复项比较两组名称
+func sameSet(a []string, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	for i := range a {
+		a[i] = strings.ToLower(a[i])
+	}
+	for i := range b {
+		b[i] = strings.ToLower(b[i])
+	}
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// poll 处理 CertPoll（RFC 8894 3.3.3），请求必须使用与CSR相同的密钥签名
+func (s *Server) poll(msg *pkiMessage) (*certRep, error) {
+	s.store.lock.Lock()
+	defer s.store.lock.Unlock()
+
+	req, err := s.store.getRequest(requestID(msg.TransactionID))
+	if errors.Is(err, errNotFound) {
+		return failure(failBadCertID), nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	csr, err := parseCSRPEM(req.CSR)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(msg.Signer.PublicKey) {
+		return failure(failBadMessageCheck), nil
+	}
+
+	return s.resume(req, csr)
+}
+
+// resume 根据已保存请求的状态响应（调用者需持有store的锁）
+func (s *Server) resume(req *Request, csr *x509.CertificateRequest) (*certRep, error) {
+	switch req.Status {
+	case RequestPending:
+		return pending(), nil
+	case RequestApproved:
+		return s.issueRequest(req, csr, nil, nil)
+	case RequestIssued:
+		serialNumber, ok := new(big.Int).SetString(req.CertificateSerial, 16)
+		if !ok {
+			return nil, fmt.Errorf("not a valid serial number in request %s: %s", req.ID, req.CertificateSerial)
+		}
+
+		userCert, err := s.getCertificate(serialNumber)
+		if err != nil {
+			return nil, err
+		} else if userCert == nil {
+			return failure(failBadCertID), nil
+		}
+		return success(userCert), nil
+	default:
+		return failure(failBadRequest), nil
+	}
+}
+
+// issueRequest 签发证书并更新请求，challenge不为nil时签发成功后增加其使用次数（调用者需持有store的锁）
+func (s *Server) issueRequest(req *Request, csr *x509.CertificateRequest, old *x509.Certificate, challenge *Challenge) (*certRep, error) {
+	userCert, err := s.issue(&IssueRequest{
+		CSR:           csr,
+		TransactionID: req.TransactionID,
+		Old:           old,
+	})
+	if err != nil {
+		return s.reject(req, err.Error(), failBadRequest)
+	}
+
+	if challenge != nil {
+		err = s.store.useChallenge(challenge)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req.Status = RequestIssued
+	req.Reason = ""
+	req.CertificateSerial = userCert.SerialNumber.Text(16)
+	err = s.store.saveRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return success(userCert), nil
+}
+
+// reject 拒绝请求并记录原因（调用者需持有store的锁）
+func (s *Server) reject(req *Request, reason string, failInfo string) (*certRep, error) {
+	req.Status = RequestRejected
+	req.Reason = reason
+
+	err := s.store.saveRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return failure(failInfo), nil
+}
+
+func (s *Server) getCert(msg *pkiMessage) (*certRep, error) {
+	ias, err := parseIssuerAndSerialNumber(msg.Content)
+	if err != nil {
+		return failure(failBadRequest), nil
+	} else if !bytes.Equal(ias.Issuer.FullBytes, s.ca.RawSubject) {
+		return failure(failBadCertID), nil
+	}
+
+	userCert, err := s.getCertificate(ias.SerialNumber)
+	if err != nil {
+		return nil, err
+	} else if userCert == nil {
+		return failure(failBadCertID), nil
+	}
+
+	return success(userCert), nil
+}
+
+func (s *Server) getCRLRep(msg *pkiMessage) (*certRep, error) {
+	ias, err := parseIssuerAndSerialNumber(msg.Content)
+	if err != nil {
+		return failure(failBadRequest), nil
+	} else if !bytes.Equal(ias.Issuer.FullBytes, s.ca.RawSubject) {
+		return failure(failBadCertID), nil
+	}
+
+	crl, err := s.getCRL()
+	if err != nil {
+		return nil, err
+	}
+
+	return &certRep{Status: pkiStatusSuccess, CRL: crl}, nil
+}
+
+func parseCSRPEM(data string) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("not a valid CSR")
+	}
+	return x509.ParseCertificateRequest(block.Bytes)
+}
@@ -0,0 +1,364 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package scepserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/SongZihuan/MyCA/src/metadata"
+	"github.com/SongZihuan/MyCA/src/utils"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 挑战密码的状态（根据字段计算，不保存在文件中）
+const (
+	ChallengeActive  = "active"
+	ChallengeUsed    = "used"
+	ChallengeExpired = "expired"
+	ChallengeRevoked = "revoked"
+)
+
+// 签发请求的状态
+const (
+	RequestPending  = "pending"  // 等待人工审批
+	RequestApproved = "approved" // 已审批，客户端下一次轮询时签发
+	RequestRejected = "rejected"
+	RequestIssued   = "issued"
+)
+
+// Challenge 挑战密码，保存在 challenges/<id>.json，只保存密码的SHA-256摘要
+type Challenge struct {
+	metadata.Header
+
+	ID        string     `json:"id"`
+	Hash      string     `json:"hash"`
+	Comment   string     `json:"comment,omitempty"`
+	MaxUses   int        `json:"max_uses"` // 0表示不限制次数
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (c *Challenge) Status() string {
+	switch {
+	case c.RevokedAt != nil:
+		return ChallengeRevoked
+	case time.Now().After(c.ExpiresAt):
+		return ChallengeExpired
+	case c.MaxUses > 0 && c.Uses >= c.MaxUses:
+		return ChallengeUsed
+	default:
+		return ChallengeActive
+	}
+}
+
+// Request 签发请求（PKCSReq），保存在 requests/<id>.json，ID由transactionID计算
+type Request struct {
+	metadata.Header
+
+	ID                string    `json:"id"`
+	TransactionID     string    `json:"transaction_id"`
+	MessageType       string    `json:"message_type"` // PKCSReq 或 RenewalReq
+	Status            string    `json:"status"`
+	Subject           string    `json:"subject"`
+	CSR               string    `json:"csr"` // PEM
+	ChallengeID       string    `json:"challenge_id,omitempty"`
+	CertificateSerial string    `json:"certificate_serial,omitempty"` // 小写十六进制
+	Reason            string    `json:"reason,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+var (
+	errNotFound         = errors.New("not found")
+	errInvalidChallenge = errors.New("the challenge password is not valid")
+)
+
+// Store 将挑战密码和签发请求保存在目录中（通常为 home/scep/<rca|ica>/<name>），
+// 服务运行期间也可以由命令行创建挑战密码和审批请求，因此每次都从文件中读取
+type Store struct {
+	lock sync.Mutex
+	dir  string
+}
+
+func NewStore(dir string) (*Store, error) {
+	for _, sub := range []string{"challenges", "requests"} {
+		err := os.MkdirAll(path.Join(dir, sub), 0600)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+func newID(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(utils.Rander(), b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isValidID(id string, n int) bool {
+	if len(id) != n*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil && strings.ToLower(id) == id
+}
+
+func hashChallenge(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Store) challengePath(id string) string {
+	return path.Join(s.dir, "challenges", id+".json")
+}
+
+func (s *Store) requestPath(id string) string {
+	return path.Join(s.dir, "requests", id+".json")
+}
+
+// requestID 由transactionID计算请求ID（transactionID由客户端生成，不能直接作为文件名）
+func requestID(transactionID string) string {
+	sum := sha256.Sum256([]byte(transactionID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// CreateChallenge 生成新的挑战密码，返回的密码只在此时可见
+func (s *Store) CreateChallenge(validity time.Duration, maxUses int, comment string) (*Challenge, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id, err := newID(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	password, err := newID(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	challenge := &Challenge{
+		Header:    metadata.NewHeader(metadata.KindSCEPChallenge),
+		ID:        id,
+		Hash:      hashChallenge(password),
+		Comment:   comment,
+		MaxUses:   maxUses,
+		CreatedAt: now,
+		ExpiresAt: now.Add(validity),
+	}
+
+	err = metadata.Write(s.challengePath(id), challenge)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return challenge, password, nil
+}
+
+func (s *Store) getChallenge(id string) (*Challenge, error) {
+	if !isValidID(id, 8) {
+		return nil, errNotFound
+	}
+
+	var challenge Challenge
+	err := metadata.Read(s.challengePath(id), metadata.KindSCEPChallenge, &challenge)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (s *Store) listChallenges() ([]*Challenge, error) {
+	entries, err := os.ReadDir(path.Join(s.dir, "challenges"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Challenge, 0, len(entries))
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !isValidID(id, 8) {
+			continue
+		}
+
+		challenge, err := s.getChallenge(id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, challenge)
+	}
+
+	slices.SortFunc(res, func(a, b *Challenge) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// ListChallenges 返回全部挑战密码（按创建时间排序）
+func (s *Store) ListChallenges() ([]*Challenge, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.listChallenges()
+}
+
+// RevokeChallenge 吊销挑战密码，已签发的证书不受影响
+func (s *Store) RevokeChallenge(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	challenge, err := s.getChallenge(id)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("challenge %s not found", id)
+	} else if err != nil {
+		return err
+	} else if challenge.RevokedAt != nil {
+		return fmt.Errorf("challenge %s has been revoked", id)
+	}
+
+	now := time.Now()
+	challenge.RevokedAt = &now
+	return metadata.Write(s.challengePath(id), challenge)
+}
+
+// findChallenge 查找与密码匹配的可用挑战密码，不增加使用次数（调用者需持有锁）
+func (s *Store) findChallenge(password string) (*Challenge, error) {
+	challenges, err := s.listChallenges()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := hashChallenge(password)
+	for _, c := range challenges {
+		if subtle.ConstantTimeCompare([]byte(c.Hash), []byte(hash)) != 1 {
+			continue
+		}
+
+		if c.Status() != ChallengeActive {
+			return nil, errInvalidChallenge
+		}
+		return c, nil
+	}
+
+	return nil, errInvalidChallenge
+}
+
+// useChallenge 增加挑战密码的使用次数（调用者需持有锁）
+func (s *Store) useChallenge(c *Challenge) error {
+	c.Uses++
+	return metadata.Write(s.challengePath(c.ID), c)
+}
+
+func (s *Store) getRequest(id string) (*Request, error) {
+	if !isValidID(id, 16) {
+		return nil, errNotFound
+	}
+
+	var req Request
+	err := metadata.Read(s.requestPath(id), metadata.KindSCEPRequest, &req)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (s *Store) saveRequest(req *Request) error {
+	req.Header = metadata.NewHeader(metadata.KindSCEPRequest)
+	req.UpdatedAt = time.Now()
+	return metadata.Write(s.requestPath(req.ID), req)
+}
+
+// ListRequests 返回全部签发请求（按创建时间排序），status不为空时只返回该状态的请求
+func (s *Store) ListRequests(status string) ([]*Request, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	entries, err := os.ReadDir(path.Join(s.dir, "requests"))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Request, 0, len(entries))
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok || !isValidID(id, 16) {
+			continue
+		}
+
+		req, err := s.getRequest(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if status == "" || req.Status == status {
+			res = append(res, req)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b *Request) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return res, nil
+}
+
+// decideRequest 审批或拒绝等待中的请求
+func (s *Store) decideRequest(id string, status string, reason string) (*Request, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	req, err := s.getRequest(id)
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("request %s not found", id)
+	} else if err != nil {
+		return nil, err
+	} else if req.Status != RequestPending {
+		return nil, fmt.Errorf("request %s is %s, only the pending requests can be approved or rejected", id, req.Status)
+	}
+
+	req.Status = status
+	req.Reason = reason
+
+	err = s.saveRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// ApproveRequest 审批请求，证书在客户端下一次轮询（CertPoll）时签发
+func (s *Store) ApproveRequest(id string) (*Request, error) {
+	return s.decideRequest(id, RequestApproved, "")
+}
+
+func (s *Store) RejectRequest(id string, reason string) (*Request, error) {
+	return s.decideRequest(id, RequestRejected, reason)
+}
@@ -0,0 +1,301 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// NameConstraints CA证书的名称约束（RFC 5280 4.2.1.10）
+// DNS和URI约束为域名，以“.”开头时仅匹配其子域名，否则匹配该域名及其子域名
+// 邮箱约束为完整邮箱地址（仅匹配该邮箱）或域名（规则同DNS约束）
+// IP约束为CIDR格式的网段
+type NameConstraints struct {
+	PermittedDNSDomains     []string `json:"permitted_dns_domains,omitempty"`
+	ExcludedDNSDomains      []string `json:"excluded_dns_domains,omitempty"`
+	PermittedIPRanges       []string `json:"permitted_ip_ranges,omitempty"`
+	ExcludedIPRanges        []string `json:"excluded_ip_ranges,omitempty"`
+	PermittedEmailAddresses []string `json:"permitted_email_addresses,omitempty"`
+	ExcludedEmailAddresses  []string `json:"excluded_email_addresses,omitempty"`
+	PermittedURIDomains     []string `json:"permitted_uri_domains,omitempty"`
+	ExcludedURIDomains      []string `json:"excluded_uri_domains,omitempty"`
+}
+
+// Check 检查名称约束的格式，并将域名统一为小写、网段统一为标准形式
+func (nc *NameConstraints) Check() error {
+	var err error
+
+	for _, lst := range []*[]string{&nc.PermittedDNSDomains, &nc.ExcludedDNSDomains, &nc.PermittedURIDomains, &nc.ExcludedURIDomains} {
+		*lst, err = normalizeConstraintList(*lst, normalizeDomainConstraint)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, lst := range []*[]string{&nc.PermittedEmailAddresses, &nc.ExcludedEmailAddresses} {
+		*lst, err = normalizeConstraintList(*lst, normalizeEmailConstraint)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, lst := range []*[]string{&nc.PermittedIPRanges, &nc.ExcludedIPRanges} {
+		*lst, err = normalizeConstraintList(*lst, normalizeIPConstraint)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewNameConstraintsFromCertificate 读取CA证书中的名称约束，证书没有名称约束时返回nil
+func NewNameConstraintsFromCertificate(cert *x509.Certificate) *NameConstraints {
+	nc := &NameConstraints{
+		PermittedDNSDomains:     CopySlice(cert.PermittedDNSDomains),
+		ExcludedDNSDomains:      CopySlice(cert.ExcludedDNSDomains),
+		PermittedIPRanges:       formatIPRanges(cert.PermittedIPRanges),
+		ExcludedIPRanges:        formatIPRanges(cert.ExcludedIPRanges),
+		PermittedEmailAddresses: CopySlice(cert.PermittedEmailAddresses),
+		ExcludedEmailAddresses:  CopySlice(cert.ExcludedEmailAddresses),
+		PermittedURIDomains:     CopySlice(cert.PermittedURIDomains),
+		ExcludedURIDomains:      CopySlice(cert.ExcludedURIDomains),
+	}
+
+	if nc.IsEmpty() {
+		return nil
+	}
+
+	return nc
+}
+
+// IsEmpty 判断是否未设置任何约束
+func (nc *NameConstraints) IsEmpty() bool {
+	return nc == nil || (len(nc.PermittedDNSDomains) == 0 && len(nc.ExcludedDNSDomains) == 0 &&
+		len(nc.PermittedIPRanges) == 0 && len(nc.ExcludedIPRanges) == 0 &&
+		len(nc.PermittedEmailAddresses) == 0 && len(nc.ExcludedEmailAddresses) == 0 &&
+		len(nc.PermittedURIDomains) == 0 && len(nc.ExcludedURIDomains) == 0)
+}
+
+// Apply 将名称约束写入CA证书模板，扩展标记为关键扩展
+func (nc *NameConstraints) Apply(template *x509.Certificate) error {
+	if nc.IsEmpty() {
+		return nil
+	}
+
+	permittedIPRanges, err := parseIPRanges(nc.PermittedIPRanges)
+	if err != nil {
+		return err
+	}
+
+	excludedIPRanges, err := parseIPRanges(nc.ExcludedIPRanges)
+	if err != nil {
+		return err
+	}
+
+	template.PermittedDNSDomainsCritical = true
+	template.PermittedDNSDomains = CopySlice(nc.PermittedDNSDomains)
+	template.ExcludedDNSDomains = CopySlice(nc.ExcludedDNSDomains)
+	template.PermittedIPRanges = permittedIPRanges
+	template.ExcludedIPRanges = excludedIPRanges
+	template.PermittedEmailAddresses = CopySlice(nc.PermittedEmailAddresses)
+	template.ExcludedEmailAddresses = CopySlice(nc.ExcludedEmailAddresses)
+	template.PermittedURIDomains = CopySlice(nc.PermittedURIDomains)
+	template.ExcludedURIDomains = CopySlice(nc.ExcludedURIDomains)
+
+	return nil
+}
+
+// CheckNameConstraints 检查证书的备用名称是否满足证书链（签发者及其上级CA）中每个CA证书的名称约束
+func CheckNameConstraints(chain []*x509.Certificate, domains []string, ips []net.IP, emails []string, uris []*url.URL) error {
+	for _, ca := range chain {
+		if ca == nil || !ca.IsCA {
+			continue
+		}
+
+		for _, domain := range domains {
+			if !checkConstraint(domain, ca.PermittedDNSDomains, ca.ExcludedDNSDomains, matchDomainConstraint) {
+				return fmt.Errorf("DNS name %s is not permitted by the name constraints of %s", domain, ca.Subject.CommonName)
+			}
+		}
+
+		for _, ip := range ips {
+			if !checkIPConstraint(ip, ca.PermittedIPRanges, ca.ExcludedIPRanges) {
+				return fmt.Errorf("IP address %s is not permitted by the name constraints of %s", ip.String(), ca.Subject.CommonName)
+			}
+		}
+
+		for _, email := range emails {
+			if !checkConstraint(email, ca.PermittedEmailAddresses, ca.ExcludedEmailAddresses, matchEmailConstraint) {
+				return fmt.Errorf("email address %s is not permitted by the name constraints of %s", email, ca.Subject.CommonName)
+			}
+		}
+
+		for _, u := range uris {
+			if !checkConstraint(u.Hostname(), ca.PermittedURIDomains, ca.ExcludedURIDomains, matchDomainConstraint) {
+				return fmt.Errorf("URI %s is not permitted by the name constraints of %s", u.String(), ca.Subject.CommonName)
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkConstraint(name string, permitted []string, excluded []string, match func(name string, constraint string) bool) bool {
+	for _, constraint := range excluded {
+		if match(name, constraint) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+
+	for _, constraint := range permitted {
+		if match(name, constraint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkIPConstraint(ip net.IP, permitted []*net.IPNet, excluded []*net.IPNet) bool {
+	for _, ipNet := range excluded {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+
+	for _, ipNet := range permitted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchDomainConstraint 通配符域名（*.example.com）按其父域名下的子域名处理
+func matchDomainConstraint(domain string, constraint string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	constraint = strings.ToLower(constraint)
+
+	if constraint == "" {
+		return true
+	}
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(domain, constraint)
+	}
+
+	return domain == constraint || strings.HasSuffix(domain, "."+constraint)
+}
+
+func matchEmailConstraint(email string, constraint string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(email, constraint)
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	host := strings.ToLower(email[at+1:])
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+
+	return host == constraint
+}
+
+func normalizeConstraintList(lst []string, normalize func(string) (string, error)) ([]string, error) {
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	res := make([]string, 0, len(lst))
+	for _, s := range lst {
+		n, err := normalize(strings.TrimSpace(s))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+
+	return res, nil
+}
+
+func normalizeDomainConstraint(domain string) (string, error) {
+	domain = strings.ToLower(domain)
+	if !IsValidDomain(strings.TrimPrefix(domain, ".")) {
+		return "", fmt.Errorf("%s is not a valid domain constraint", domain)
+	}
+	return domain, nil
+}
+
+func normalizeEmailConstraint(email string) (string, error) {
+	if strings.Contains(email, "@") {
+		if !IsValidEmail(email) {
+			return "", fmt.Errorf("%s is not a valid email constraint", email)
+		}
+		return email, nil
+	}
+
+	email = strings.ToLower(email)
+	if !IsValidDomain(strings.TrimPrefix(email, ".")) {
+		return "", fmt.Errorf("%s is not a valid email constraint", email)
+	}
+	return email, nil
+}
+
+func normalizeIPConstraint(ipRange string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(ipRange)
+	if err != nil {
+		return "", fmt.Errorf("%s is not a valid IP range (CIDR)", ipRange)
+	}
+	return ipNet.String(), nil
+}
+
+func parseIPRanges(lst []string) ([]*net.IPNet, error) {
+	if len(lst) == 0 {
+		return nil, nil
+	}
+
+	res := make([]*net.IPNet, 0, len(lst))
+	for _, s := range lst {
+		_, ipNet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid IP range (CIDR)", s)
+		}
+		res = append(res, ipNet)
+	}
+
+	return res, nil
+}
+
+func formatIPRanges(lst []*net.IPNet) []string {
+	if len(lst) == 0 {
+		return nil
+	}
+
+	res := make([]string, 0, len(lst))
+	for _, ipNet := range lst {
+		res = append(res, ipNet.String())
+	}
+
+	return res
+}
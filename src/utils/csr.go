@@ -0,0 +1,218 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+const (
+	PemTypeCertificateRequest    = "CERTIFICATE REQUEST"
+	PemTypeNewCertificateRequest = "NEW CERTIFICATE REQUEST"
+)
+
+var (
+	OIDExtensionKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 15}
+	OIDExtensionExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// ExtKeyUsageOIDMap 扩展用途与OID的对应关系（与 crypto/x509 保持一致）
+var ExtKeyUsageOIDMap = map[x509.ExtKeyUsage]asn1.ObjectIdentifier{
+	x509.ExtKeyUsageAny:                            {2, 5, 29, 37, 0},
+	x509.ExtKeyUsageServerAuth:                     {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	x509.ExtKeyUsageClientAuth:                     {1, 3, 6, 1, 5, 5, 7, 3, 2},
+	x509.ExtKeyUsageCodeSigning:                    {1, 3, 6, 1, 5, 5, 7, 3, 3},
+	x509.ExtKeyUsageEmailProtection:                {1, 3, 6, 1, 5, 5, 7, 3, 4},
+	x509.ExtKeyUsageIPSECEndSystem:                 {1, 3, 6, 1, 5, 5, 7, 3, 5},
+	x509.ExtKeyUsageIPSECTunnel:                    {1, 3, 6, 1, 5, 5, 7, 3, 6},
+	x509.ExtKeyUsageIPSECUser:                      {1, 3, 6, 1, 5, 5, 7, 3, 7},
+	x509.ExtKeyUsageTimeStamping:                   {1, 3, 6, 1, 5, 5, 7, 3, 8},
+	x509.ExtKeyUsageOCSPSigning:                    {1, 3, 6, 1, 5, 5, 7, 3, 9},
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     {1, 3, 6, 1, 4, 1, 311, 10, 3, 3},
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      {2, 16, 840, 1, 113730, 4, 1},
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: {1, 3, 6, 1, 4, 1, 311, 2, 1, 22},
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     {1, 3, 6, 1, 4, 1, 311, 61, 1, 1},
+}
+
+// ReadCertificateRequest 读取PEM或DER格式的证书签名请求（PKCS#10），并校验其自签名
+func ReadCertificateRequest(filePath string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCertificateRequest(data)
+}
+
+func ParseCertificateRequest(data []byte) (*x509.CertificateRequest, error) {
+	derData := data
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != PemTypeCertificateRequest && block.Type != PemTypeNewCertificateRequest {
+			return nil, fmt.Errorf("pem type of csr error: %s", block.Type)
+		}
+		derData = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(derData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("csr signature check failed: %s", err.Error())
+	}
+
+	return csr, nil
+}
+
+// ParseCSRKeyUsage 解析证书签名请求中申请的密钥用途，第二个返回值表示是否申请了密钥用途
+func ParseCSRKeyUsage(csr *x509.CertificateRequest) (x509.KeyUsage, bool, error) {
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(OIDExtensionKeyUsage) {
+			continue
+		}
+
+		var bits asn1.BitString
+		rest, err := asn1.Unmarshal(ext.Value, &bits)
+		if err != nil {
+			return 0, false, err
+		} else if len(rest) != 0 {
+			return 0, false, fmt.Errorf("trailing data after key usage")
+		}
+
+		var usage int
+		for i := 0; i < 9; i++ {
+			if bits.At(i) != 0 {
+				usage |= 1 << uint(i)
+			}
+		}
+
+		return x509.KeyUsage(usage), true, nil
+	}
+
+	return 0, false, nil
+}
+
+// ParseCSRExtKeyUsage 解析证书签名请求中申请的扩展用途，无法识别的OID将在第二个返回值中返回
+func ParseCSRExtKeyUsage(csr *x509.CertificateRequest) ([]x509.ExtKeyUsage, []asn1.ObjectIdentifier, bool, error) {
+	for _, ext := range csr.Extensions {
+		if !ext.Id.Equal(OIDExtensionExtKeyUsage) {
+			continue
+		}
+
+		var oids []asn1.ObjectIdentifier
+		rest, err := asn1.Unmarshal(ext.Value, &oids)
+		if err != nil {
+			return nil, nil, false, err
+		} else if len(rest) != 0 {
+			return nil, nil, false, fmt.Errorf("trailing data after ext key usage")
+		}
+
+		extKeyUsage := make([]x509.ExtKeyUsage, 0, len(oids))
+		unknown := make([]asn1.ObjectIdentifier, 0, 0)
+
+	OIDCycle:
+		for _, oid := range oids {
+			for usage, usageOID := range ExtKeyUsageOIDMap {
+				if oid.Equal(usageOID) {
+					extKeyUsage = append(extKeyUsage, usage)
+					continue OIDCycle
+				}
+			}
+			unknown = append(unknown, oid)
+		}
+
+		return extKeyUsage, unknown, true, nil
+	}
+
+	return nil, nil, false, nil
+}
+
+var OIDExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+
+func MarshalKeyUsageExtension(keyUsage x509.KeyUsage) (pkix.Extension, error) {
+	var a [2]byte
+	a[0] = reverseBitsInAByte(byte(keyUsage))
+	a[1] = reverseBitsInAByte(byte(keyUsage >> 8))
+
+	l := 1
+	if a[1] != 0 {
+		l = 2
+	}
+
+	bitString := a[:l]
+	value, err := asn1.Marshal(asn1.BitString{Bytes: bitString, BitLength: asn1BitLength(bitString)})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: OIDExtensionKeyUsage, Critical: true, Value: value}, nil
+}
+
+func MarshalExtKeyUsageExtension(extKeyUsage []x509.ExtKeyUsage) (pkix.Extension, error) {
+	oids := make([]asn1.ObjectIdentifier, 0, len(extKeyUsage))
+	for _, usage := range extKeyUsage {
+		oid, ok := ExtKeyUsageOIDMap[usage]
+		if !ok {
+			return pkix.Extension{}, fmt.Errorf("unknown ext key usage: %d", usage)
+		}
+		oids = append(oids, oid)
+	}
+
+	value, err := asn1.Marshal(oids)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: OIDExtensionExtKeyUsage, Value: value}, nil
+}
+
+func MarshalBasicConstraintsExtension(isCA bool, maxPathLen int) (pkix.Extension, error) {
+	type basicConstraints struct {
+		IsCA       bool `asn1:"optional"`
+		MaxPathLen int  `asn1:"optional,default:-1"`
+	}
+
+	if !isCA || maxPathLen < 0 {
+		maxPathLen = -1
+	}
+
+	value, err := asn1.Marshal(basicConstraints{IsCA: isCA, MaxPathLen: maxPathLen})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{Id: OIDExtensionBasicConstraints, Critical: true, Value: value}, nil
+}
+
+func reverseBitsInAByte(in byte) byte {
+	b1 := in>>4 | in<<4
+	b2 := b1>>2&0x33 | b1<<2&0xcc
+	b3 := b2>>1&0x55 | b2<<1&0xaa
+	return b3
+}
+
+func asn1BitLength(bitString []byte) int {
+	bitLen := len(bitString) * 8
+
+	for i := range bitString {
+		b := bitString[len(bitString)-i-1]
+
+		for bit := uint(0); bit < 8; bit++ {
+			if (b>>bit)&1 == 1 {
+				return bitLen
+			}
+			bitLen--
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,41 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"os"
+)
+
+// FileLock 基于锁文件的排他锁，用于在多个MyCA进程（例如同时运行的服务和命令行）之间同步对同一文件的读-改-写
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile 获取锁文件的排他锁（锁文件不存在时创建），阻塞直到获得锁
+func LockFile(lockPath string) (*FileLock, error) {
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileLock{file: file}, nil
+}
+
+// Unlock 释放锁，锁文件保留以供下次使用
+func (l *FileLock) Unlock() error {
+	err := unlockFile(l.file)
+	if err != nil {
+		_ = l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
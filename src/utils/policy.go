@@ -0,0 +1,452 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	OIDExtensionCertificatePolicies = asn1.ObjectIdentifier{2, 5, 29, 32}
+	OIDPolicyQualifierCPS           = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 1}
+	OIDPolicyQualifierUserNotice    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 2, 2}
+)
+
+// PolicyInformation 证书策略扩展（RFC 5280 4.2.1.4）中的一条策略
+type PolicyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	CPS        []string // CPS（证书实践声明）的地址
+	UserNotice []string // 用户通知的显式文本
+}
+
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers []policyQualifierInfo `asn1:"optional"`
+}
+
+type policyQualifierInfo struct {
+	PolicyQualifierId asn1.ObjectIdentifier
+	Qualifier         asn1.RawValue
+}
+
+// ParseCertificatePolicies 解析证书策略扩展的值，包括CPS地址和用户通知（crypto/x509只解析策略OID）
+func ParseCertificatePolicies(value []byte) ([]*PolicyInformation, error) {
+	var policies []policyInformation
+	rest, err := asn1.Unmarshal(value, &policies)
+	if err != nil {
+		return nil, fmt.Errorf("parse certificate policies failed: %s", err.Error())
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("parse certificate policies failed: trailing data")
+	}
+
+	res := make([]*PolicyInformation, 0, len(policies))
+	for _, p := range policies {
+		info := &PolicyInformation{
+			Policy: p.Policy,
+		}
+
+		for _, q := range p.Qualifiers {
+			switch {
+			case q.PolicyQualifierId.Equal(OIDPolicyQualifierCPS):
+				var cps string
+				if _, err := asn1.Unmarshal(q.Qualifier.FullBytes, &cps); err == nil {
+					info.CPS = append(info.CPS, cps)
+				}
+			case q.PolicyQualifierId.Equal(OIDPolicyQualifierUserNotice):
+				if text := parseUserNoticeText(q.Qualifier.FullBytes); text != "" {
+					info.UserNotice = append(info.UserNotice, text)
+				}
+			}
+		}
+
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// parseUserNoticeText 返回用户通知中的显式文本，noticeRef（可选的SEQUENCE）被忽略
+func parseUserNoticeText(data []byte) string {
+	var notice asn1.RawValue
+	_, err := asn1.Unmarshal(data, &notice)
+	if err != nil || notice.Tag != asn1.TagSequence {
+		return ""
+	}
+
+	rest := notice.Bytes
+	for len(rest) > 0 {
+		var elem asn1.RawValue
+		rest, err = asn1.Unmarshal(rest, &elem)
+		if err != nil {
+			return ""
+		} else if elem.Tag == asn1.TagSequence {
+			continue
+		}
+
+		var text string
+		if _, err := asn1.Unmarshal(elem.FullBytes, &text); err == nil {
+			return text
+		}
+	}
+
+	return ""
+}
+
+var (
+	OIDExtensionPolicyMappings    = asn1.ObjectIdentifier{2, 5, 29, 33}
+	OIDExtensionPolicyConstraints = asn1.ObjectIdentifier{2, 5, 29, 36}
+	OIDExtensionInhibitAnyPolicy  = asn1.ObjectIdentifier{2, 5, 29, 54}
+	OIDAnyPolicy                  = asn1.ObjectIdentifier{2, 5, 29, 32, 0}
+)
+
+// AnyPolicyName anyPolicy（2.5.29.32.0）的名称，CA证书可使用该名称表示接受任意策略
+const AnyPolicyName = "any"
+
+// CertificatePolicy CA信息中保存的命名证书策略，签发证书时按名称选择
+type CertificatePolicy struct {
+	Name       string   `json:"name"`
+	OID        string   `json:"oid"`
+	CPS        []string `json:"cps,omitempty"`
+	UserNotice string   `json:"user_notice,omitempty"`
+}
+
+// PolicyMapping 策略映射，IssuerDomainPolicy为上级CA中的策略，SubjectDomainPolicy为本CA中与之等价的策略
+type PolicyMapping struct {
+	IssuerDomainPolicy  string `json:"issuer_domain_policy"`
+	SubjectDomainPolicy string `json:"subject_domain_policy"`
+}
+
+// PolicySettings CA证书中与策略有关的扩展，约束的值小于0时表示不设置
+type PolicySettings struct {
+	Policies              []*CertificatePolicy
+	Mappings              []*PolicyMapping
+	RequireExplicitPolicy int
+	InhibitPolicyMapping  int
+	InhibitAnyPolicy      int
+}
+
+// ParseCertificatePolicyDef 解析命名策略的定义，格式为 name=OID[;cps=URL][;notice=TEXT]，cps可以出现多次
+func ParseCertificatePolicyDef(def string) (*CertificatePolicy, error) {
+	parts := strings.Split(def, ";")
+
+	name, oid, ok := strings.Cut(parts[0], "=")
+	if !ok {
+		return nil, fmt.Errorf("not a valid policy definition (name=OID[;cps=URL][;notice=TEXT]): %s", def)
+	}
+
+	res := &CertificatePolicy{
+		Name: strings.TrimSpace(name),
+		OID:  strings.TrimSpace(oid),
+	}
+
+	for _, p := range parts[1:] {
+		key, value, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("not a valid policy qualifier: %s", p)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "cps":
+			res.CPS = append(res.CPS, strings.TrimSpace(value))
+		case "notice":
+			res.UserNotice = strings.TrimSpace(value)
+		default:
+			return nil, fmt.Errorf("unknown policy qualifier: %s", key)
+		}
+	}
+
+	err := res.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Check 检查命名策略的名称、OID和限定符
+func (p *CertificatePolicy) Check() error {
+	if !IsValidPolicyName(p.Name) {
+		return fmt.Errorf("not a valid policy name: %s", p.Name)
+	} else if p.Name == AnyPolicyName {
+		return fmt.Errorf("the policy name %s is reserved for anyPolicy", AnyPolicyName)
+	}
+
+	oid, err := ParseObjectIdentifier(p.OID)
+	if err != nil {
+		return err
+	} else if oid.Equal(OIDAnyPolicy) {
+		return fmt.Errorf("use the policy name %s for anyPolicy", AnyPolicyName)
+	}
+
+	for _, cps := range p.CPS {
+		u, err := url.Parse(cps)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("not a valid HTTP/HTTPS CPS URL: %s", cps)
+		}
+	}
+
+	if len([]rune(p.UserNotice)) > 200 {
+		return fmt.Errorf("the user notice is too long (at most 200 characters)")
+	}
+
+	return nil
+}
+
+func (p *CertificatePolicy) String() string {
+	res := p.Name + "=" + p.OID
+	for _, cps := range p.CPS {
+		res += ";cps=" + cps
+	}
+	if p.UserNotice != "" {
+		res += ";notice=" + p.UserNotice
+	}
+	return res
+}
+
+func IsValidPolicyName(name string) bool {
+	pattern := `^[a-zA-Z0-9][a-zA-Z0-9\-._]*$`
+	matched, _ := regexp.MatchString(pattern, name)
+	return matched
+}
+
+// ParseObjectIdentifier 解析点分十进制格式的OID
+func ParseObjectIdentifier(s string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("not a valid OID: %s", s)
+	}
+
+	res := make(asn1.ObjectIdentifier, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("not a valid OID: %s", s)
+		}
+		res = append(res, n)
+	}
+
+	if res[0] > 2 || (res[0] < 2 && res[1] >= 40) {
+		return nil, fmt.Errorf("not a valid OID: %s", s)
+	}
+
+	return res, nil
+}
+
+// FindCertificatePolicy 按名称在命名策略中查找，名称为any时返回anyPolicy
+func FindCertificatePolicy(policies []*CertificatePolicy, name string) (*CertificatePolicy, error) {
+	if name == AnyPolicyName {
+		return &CertificatePolicy{Name: AnyPolicyName, OID: OIDAnyPolicy.String()}, nil
+	}
+
+	for _, p := range policies {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("certificate policy %s is not defined", name)
+}
+
+// MergeCertificatePolicies 合并命名策略，同名的策略使用后者
+func MergeCertificatePolicies(lists ...[]*CertificatePolicy) []*CertificatePolicy {
+	res := make([]*CertificatePolicy, 0, 10)
+
+	for _, lst := range lists {
+	NextPolicy:
+		for _, p := range lst {
+			for i, r := range res {
+				if r.Name == p.Name {
+					res[i] = p
+					continue NextPolicy
+				}
+			}
+			res = append(res, p)
+		}
+	}
+
+	return res
+}
+
+// ApplyCertificatePolicies 将证书策略扩展（包括CPS地址和用户通知）写入证书模板
+func ApplyCertificatePolicies(template *x509.Certificate, policies []*CertificatePolicy) error {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	value, err := marshalCertificatePolicies(policies)
+	if err != nil {
+		return err
+	}
+
+	template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+		Id:    OIDExtensionCertificatePolicies,
+		Value: value,
+	})
+
+	return nil
+}
+
+// Apply 将证书策略、策略映射、策略约束和inhibitAnyPolicy扩展写入CA证书模板
+func (s *PolicySettings) Apply(template *x509.Certificate) error {
+	if s == nil {
+		return nil
+	}
+
+	err := ApplyCertificatePolicies(template, s.Policies)
+	if err != nil {
+		return err
+	}
+
+	if len(s.Mappings) != 0 {
+		value, err := marshalPolicyMappings(s.Mappings)
+		if err != nil {
+			return err
+		}
+
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:       OIDExtensionPolicyMappings,
+			Critical: true,
+			Value:    value,
+		})
+	}
+
+	if s.RequireExplicitPolicy >= 0 || s.InhibitPolicyMapping >= 0 {
+		value, err := marshalPolicyConstraints(s.RequireExplicitPolicy, s.InhibitPolicyMapping)
+		if err != nil {
+			return err
+		}
+
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:       OIDExtensionPolicyConstraints,
+			Critical: true,
+			Value:    value,
+		})
+	}
+
+	if s.InhibitAnyPolicy >= 0 {
+		value, err := asn1.Marshal(s.InhibitAnyPolicy)
+		if err != nil {
+			return err
+		}
+
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:       OIDExtensionInhibitAnyPolicy,
+			Critical: true,
+			Value:    value,
+		})
+	}
+
+	return nil
+}
+
+type userNotice struct {
+	ExplicitText string `asn1:"utf8"`
+}
+
+func marshalCertificatePolicies(policies []*CertificatePolicy) ([]byte, error) {
+	lst := make([]policyInformation, 0, len(policies))
+
+	for _, p := range policies {
+		oid, err := ParseObjectIdentifier(p.OID)
+		if err != nil {
+			return nil, err
+		}
+
+		info := policyInformation{
+			Policy: oid,
+		}
+
+		for _, cps := range p.CPS {
+			qualifier, err := asn1.MarshalWithParams(cps, "ia5")
+			if err != nil {
+				return nil, err
+			}
+			info.Qualifiers = append(info.Qualifiers, policyQualifierInfo{
+				PolicyQualifierId: OIDPolicyQualifierCPS,
+				Qualifier:         asn1.RawValue{FullBytes: qualifier},
+			})
+		}
+
+		if p.UserNotice != "" {
+			qualifier, err := asn1.Marshal(userNotice{ExplicitText: p.UserNotice})
+			if err != nil {
+				return nil, err
+			}
+			info.Qualifiers = append(info.Qualifiers, policyQualifierInfo{
+				PolicyQualifierId: OIDPolicyQualifierUserNotice,
+				Qualifier:         asn1.RawValue{FullBytes: qualifier},
+			})
+		}
+
+		lst = append(lst, info)
+	}
+
+	return asn1.Marshal(lst)
+}
+
+type policyMapping struct {
+	IssuerDomainPolicy  asn1.ObjectIdentifier
+	SubjectDomainPolicy asn1.ObjectIdentifier
+}
+
+func marshalPolicyMappings(mappings []*PolicyMapping) ([]byte, error) {
+	lst := make([]policyMapping, 0, len(mappings))
+
+	for _, m := range mappings {
+		issuerDomainPolicy, err := ParseObjectIdentifier(m.IssuerDomainPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		subjectDomainPolicy, err := ParseObjectIdentifier(m.SubjectDomainPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		if issuerDomainPolicy.Equal(OIDAnyPolicy) || subjectDomainPolicy.Equal(OIDAnyPolicy) {
+			return nil, fmt.Errorf("anyPolicy can not be mapped")
+		}
+
+		lst = append(lst, policyMapping{
+			IssuerDomainPolicy:  issuerDomainPolicy,
+			SubjectDomainPolicy: subjectDomainPolicy,
+		})
+	}
+
+	return asn1.Marshal(lst)
+}
+
+// marshalPolicyConstraints 编码策略约束扩展，两个字段均为可选的隐式标记整数（[0]和[1]）
+func marshalPolicyConstraints(requireExplicitPolicy int, inhibitPolicyMapping int) ([]byte, error) {
+	fields := make([]asn1.RawValue, 0, 2)
+
+	for tag, n := range []int{requireExplicitPolicy, inhibitPolicyMapping} {
+		if n < 0 {
+			continue
+		}
+
+		der, err := asn1.Marshal(n)
+		if err != nil {
+			return nil, err
+		}
+
+		var integer asn1.RawValue
+		_, err = asn1.Unmarshal(der, &integer)
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, Bytes: integer.Bytes})
+	}
+
+	return asn1.Marshal(fields)
+}
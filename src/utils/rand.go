@@ -1,22 +1,57 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"io"
+	"math/big"
+	"sync"
 )
 
-var _rander *rand.Rand = nil
+const (
+	RandSourceCryptoRand = "crypto/rand"
+	RandSourceCustom     = "custom"
+	RandSourceExternal   = "external"  // 私钥不是由MyCA生成的（例如从文件导入）
+	RandSourceMathRand   = "math/rand" // 私钥由v1.0.0及更早的版本生成（这些版本的信息文件中没有记录随机源）
+)
+
+var _randerLock sync.RWMutex
+var _rander io.Reader = rand.Reader
 
-func init() {
-	_rander = rand.New(rand.NewSource(time.Now().UnixNano()))
+// Rander 返回全部密码学操作（生成密钥、签名、生成序列号等）使用的随机源，默认为 crypto/rand
+func Rander() io.Reader {
+	_randerLock.RLock()
+	defer _randerLock.RUnlock()
+
+	return _rander
 }
 
-func Rander() *rand.Rand {
-	if _rander == nil {
-		panic("nil rander")
+// SetRander 替换随机源（例如在测试中使用确定的随机源），为nil时恢复为 crypto/rand
+func SetRander(r io.Reader) {
+	_randerLock.Lock()
+	defer _randerLock.Unlock()
+
+	if r == nil {
+		r = rand.Reader
 	}
 
-	return _rander
+	_rander = r
+}
+
+// RandSource 返回当前随机源的名称，记录在CA和证书信息中，用于审计由不安全随机源生成的密钥
+func RandSource() string {
+	_randerLock.RLock()
+	defer _randerLock.RUnlock()
+
+	if _rander == rand.Reader {
+		return RandSourceCryptoRand
+	}
+
+	return RandSourceCustom
+}
+
+// RandInt 返回[0, max)范围内的随机数
+func RandInt(max *big.Int) (*big.Int, error) {
+	return rand.Int(Rander(), max)
 }
 
 func RandIntn(n int) int {
@@ -24,5 +59,10 @@ func RandIntn(n int) int {
 		return 0
 	}
 
-	return _rander.Intn(n)
+	res, err := RandInt(big.NewInt(int64(n)))
+	if err != nil {
+		panic(err) // crypto/rand 读取失败意味着系统随机源不可用
+	}
+
+	return int(res.Int64())
 }
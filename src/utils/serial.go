@@ -0,0 +1,94 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	SerialModeSequential = "sequential" // 在上一个序列号的基础上增加一个随机值（默认）
+	SerialModeRandom     = "random"     // 完全随机的128位序列号（公共CA的做法）
+)
+
+// MaxSerialNumberOctets RFC 5280 4.1.2.2 规定序列号不能超过20个字节
+const MaxSerialNumberOctets = 20
+
+// ParseSerialMode 解析序列号的分配模式，为空时使用顺序模式
+func ParseSerialMode(mode string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", SerialModeSequential:
+		return SerialModeSequential, nil
+	case SerialModeRandom:
+		return SerialModeRandom, nil
+	default:
+		return "", fmt.Errorf("unknown serial number mode: %s", mode)
+	}
+}
+
+// CheckSerialNumber 检查序列号是否为正数，且DER编码后不超过20个字节
+func CheckSerialNumber(serialNumber *big.Int) error {
+	if serialNumber == nil || serialNumber.Sign() <= 0 {
+		return fmt.Errorf("the serial number must be positive")
+	}
+
+	// DER编码的INTEGER为补码，最高位为1时需要额外的0字节
+	if serialNumber.BitLen()/8+1 > MaxSerialNumberOctets {
+		return fmt.Errorf("the serial number is longer than %d octets", MaxSerialNumberOctets)
+	}
+
+	return nil
+}
+
+// NextSerialNumber 根据分配模式生成新的序列号，current为上一次分配的序列号（旧版本创建的CA以此为基础递增）
+func NextSerialNumber(mode string, current *big.Int) (*big.Int, error) {
+	var res *big.Int
+
+	switch mode {
+	case SerialModeRandom:
+		randMax := new(big.Int).Lsh(big.NewInt(1), uint(128))
+		for res == nil || res.Sign() == 0 {
+			n, err := RandInt(randMax)
+			if err != nil {
+				return nil, fmt.Errorf("error generating random number: %s", err.Error())
+			}
+			res = n
+		}
+	case "", SerialModeSequential:
+		randMax := new(big.Int).Lsh(big.NewInt(1), uint(40))
+		addSerialNumber, err := RandInt(randMax)
+		if err != nil {
+			return nil, fmt.Errorf("error generating random number: %s", err.Error())
+		}
+
+		res = new(big.Int).Add(addSerialNumber, big.NewInt(1)) // 至少增加1，保证序列号单调递增
+		if current != nil {
+			res.Add(res, current)
+		}
+	default:
+		return nil, fmt.Errorf("unknown serial number mode: %s", mode)
+	}
+
+	err := CheckSerialNumber(res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// SeedSerialNumber 返回导入的CA的初始序列号（2^64以上的随机值）
+// 导入前由其他工具（例如OpenSSL）签发的证书通常使用从1开始的顺序序列号，顺序模式在此基础上递增不会与之重复
+func SeedSerialNumber() (*big.Int, error) {
+	randMax := new(big.Int).Lsh(big.NewInt(1), uint(64))
+	n, err := RandInt(randMax)
+	if err != nil {
+		return nil, fmt.Errorf("error generating random number: %s", err.Error())
+	}
+
+	return n.Add(n, randMax), nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2025 MyCA Authors. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+var (
+	OIDSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	OIDSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	OIDSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	OIDSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	OIDSignatureEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// SignatureAlgorithmForKey 返回公钥对应的签名哈希以及签名算法标识，用于需要手动构建的ASN.1结构（例如OCSP响应）
+func SignatureAlgorithmForKey(pub crypto.PublicKey) (crypto.Hash, pkix.AlgorithmIdentifier, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return crypto.SHA256, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureSHA256WithRSA, Parameters: asn1.NullRawValue}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return crypto.SHA256, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureECDSAWithSHA256}, nil
+		case elliptic.P384():
+			return crypto.SHA384, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureECDSAWithSHA384}, nil
+		case elliptic.P521():
+			return crypto.SHA512, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureECDSAWithSHA512}, nil
+		default:
+			return 0, pkix.AlgorithmIdentifier{}, fmt.Errorf("unsupported ECC curve")
+		}
+	case ed25519.PublicKey:
+		// RFC 8410 Ed25519对原始数据签名，不使用哈希，且参数必须省略
+		return 0, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureEd25519}, nil
+	default:
+		return 0, pkix.AlgorithmIdentifier{}, fmt.Errorf("unsupported public key type: %T", pub)
+	}
+}
+
+// SignData 使用指定的哈希算法对数据签名
+func SignData(key crypto.Signer, hash crypto.Hash, data []byte) ([]byte, error) {
+	if hash == 0 {
+		return key.Sign(Rander(), data, crypto.Hash(0))
+	}
+
+	h := hash.New()
+	h.Write(data)
+	return key.Sign(Rander(), h.Sum(nil), hash)
+}
+
+// SignatureAlgorithmList MyCA支持的签发证书、CSR和CRL的签名算法
+var SignatureAlgorithmList = []x509.SignatureAlgorithm{
+	x509.SHA256WithRSA,
+	x509.SHA384WithRSA,
+	x509.SHA512WithRSA,
+	x509.SHA256WithRSAPSS,
+	x509.SHA384WithRSAPSS,
+	x509.SHA512WithRSAPSS,
+	x509.ECDSAWithSHA256,
+	x509.ECDSAWithSHA384,
+	x509.ECDSAWithSHA512,
+	x509.PureEd25519,
+}
+
+func normalizeSignatureAlgorithmName(name string) string {
+	name = strings.ToUpper(name)
+	for _, s := range []string{"-", "_", " ", "WITH"} {
+		name = strings.ReplaceAll(name, s, "")
+	}
+	return strings.TrimPrefix(name, "PURE")
+}
+
+// ParseSignatureAlgorithm 解析签名算法名称，支持 SHA256WithRSAPSS 和 SHA256-RSAPSS 等写法（不区分大小写），空字符串表示由私钥决定（x509.UnknownSignatureAlgorithm）
+func ParseSignatureAlgorithm(name string) (x509.SignatureAlgorithm, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ToLower(name) == "default" {
+		return x509.UnknownSignatureAlgorithm, nil
+	}
+
+	n := normalizeSignatureAlgorithmName(name)
+	for _, alg := range SignatureAlgorithmList {
+		if normalizeSignatureAlgorithmName(alg.String()) == n {
+			return alg, nil
+		}
+	}
+
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported signature algorithm: %s", name)
+}
+
+// SignatureAlgorithmListForCryptoType 返回该类型的私钥可以使用的签名算法
+func SignatureAlgorithmListForCryptoType(cryptoType CryptoType) []x509.SignatureAlgorithm {
+	switch cryptoType {
+	case CryptoTypeRsa:
+		return SignatureAlgorithmList[0:6]
+	case CryptoTypeEcc:
+		fallthrough
+	case CryptoTypeEcdsa:
+		return SignatureAlgorithmList[6:9]
+	case CryptoTypeEd25519:
+		return SignatureAlgorithmList[9:]
+	default:
+		return nil
+	}
+}
+
+// CheckSignatureAlgorithm 检查签名算法是否可用于该公钥对应的私钥，x509.UnknownSignatureAlgorithm 表示由私钥决定，总是可用
+func CheckSignatureAlgorithm(alg x509.SignatureAlgorithm, pub crypto.PublicKey) error {
+	if alg == x509.UnknownSignatureAlgorithm {
+		return nil
+	}
+
+	var cryptoType CryptoType
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		cryptoType = CryptoTypeRsa
+	case *ecdsa.PublicKey:
+		cryptoType = CryptoTypeEcdsa
+	case ed25519.PublicKey:
+		cryptoType = CryptoTypeEd25519
+	default:
+		return fmt.Errorf("unsupported public key type: %T", pub)
+	}
+
+	for _, a := range SignatureAlgorithmListForCryptoType(cryptoType) {
+		if a == alg {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the signature algorithm %s can not be used with %s key", alg.String(), cryptoType)
+}
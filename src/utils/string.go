@@ -15,7 +15,7 @@ func RandStr(length int) string {
 
 	var result []byte
 	for i := 0; i < length; i++ {
-		result = append(result, bytes[Rander().Intn(len(bytes))])
+		result = append(result, bytes[RandIntn(len(bytes))])
 	}
 
 	return string(result)
@@ -3,15 +3,16 @@ package utils
 import (
 	"crypto"
 	"crypto/ecdsa"
-	"crypto/rand"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/x509"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"github.com/youmark/pkcs8"
-	"log"
 	"os"
 	"regexp"
 	"software.sslmate.com/src/go-pkcs12"
@@ -21,17 +22,139 @@ import (
 type CryptoType string
 
 const (
-	CryptoTypeRsa   CryptoType = "RSA"
-	CryptoTypeEcdsa CryptoType = "ECDSA"
-	CryptoTypeEcc   CryptoType = "ECC"
+	CryptoTypeRsa     CryptoType = "RSA"
+	CryptoTypeEcdsa   CryptoType = "ECDSA"
+	CryptoTypeEcc     CryptoType = "ECC"
+	CryptoTypeEd25519 CryptoType = "ED25519"
 )
 
+// ErrPFXUnsupportedKey 私钥无法保存为PFX（PKCS#12）文件
+var ErrPFXUnsupportedKey = errors.New("PFX (PKCS#12) can not carry Ed25519 private key, most PKCS#12 implementations (e.g. Windows and Java) do not support it")
+
 const (
 	PemTypePrivateKeyNotPassword  = "PRIVATE KEY"
 	PemTypePrivateKeyWithPassword = "ENCRYPTED " + PemTypePrivateKeyNotPassword
 	PemTypeCertificate            = "CERTIFICATE"
 )
 
+// GenerateKey 根据加密算法和密钥长度生成私钥
+func GenerateKey(cryptoType CryptoType, keyLength int) (crypto.PrivateKey, crypto.PublicKey, error) {
+	switch cryptoType {
+	case CryptoTypeRsa:
+		if keyLength != 2048 && keyLength != 3072 && keyLength != 4096 && keyLength != 8192 {
+			return nil, nil, fmt.Errorf("unsupported RSA key length: %d", keyLength)
+		}
+
+		priv, err := rsa.GenerateKey(Rander(), keyLength)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return priv, &priv.PublicKey, nil
+	case CryptoTypeEcc:
+		fallthrough
+	case CryptoTypeEcdsa:
+		var curve elliptic.Curve
+		switch keyLength {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("unsupported ECC key length: %d", keyLength)
+		}
+
+		priv, err := ecdsa.GenerateKey(curve, Rander())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return priv, &priv.PublicKey, nil
+	case CryptoTypeEd25519:
+		if keyLength != 0 && keyLength != 256 {
+			return nil, nil, fmt.Errorf("unsupported Ed25519 key length: %d", keyLength)
+		}
+
+		pub, priv, err := ed25519.GenerateKey(Rander())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return priv, pub, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported crypto type: %s", cryptoType)
+	}
+}
+
+// GenerateKeyIfNil 私钥为nil时根据加密算法和密钥长度生成新的私钥，否则检查并使用已有的私钥（例如续期时保持SubjectKeyId不变）
+func GenerateKeyIfNil(key crypto.PrivateKey, cryptoType CryptoType, keyLength int) (crypto.PrivateKey, crypto.PublicKey, error) {
+	if key == nil {
+		return GenerateKey(cryptoType, keyLength)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown private key type")
+	}
+
+	err := CheckPublicKey(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, signer.Public(), nil
+}
+
+// CheckPublicKey 检查公钥的类型以及强度是否受支持
+func CheckPublicKey(pubKey crypto.PublicKey) error {
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return fmt.Errorf("RSA key length is too short: %d", key.N.BitLen())
+		}
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() && key.Curve != elliptic.P384() && key.Curve != elliptic.P521() {
+			return fmt.Errorf("unsupported ECC curve: %s", key.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		// pass
+	default:
+		return fmt.Errorf("unsupported public key type: %T", pubKey)
+	}
+
+	return nil
+}
+
+// GetCryptoType 返回私钥的加密算法以及密钥长度
+func GetCryptoType(key crypto.PrivateKey) (CryptoType, int, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return CryptoTypeRsa, k.N.BitLen(), nil
+	case *ecdsa.PrivateKey:
+		return CryptoTypeEcdsa, k.Curve.Params().BitSize, nil
+	case ed25519.PrivateKey:
+		return CryptoTypeEd25519, 256, nil
+	default:
+		return "", 0, fmt.Errorf("unknown crypto type")
+	}
+}
+
+// GetPublicKeyCryptoType 返回公钥的加密算法以及密钥长度
+func GetPublicKeyCryptoType(pubKey crypto.PublicKey) (CryptoType, int, error) {
+	switch k := pubKey.(type) {
+	case *rsa.PublicKey:
+		return CryptoTypeRsa, k.N.BitLen(), nil
+	case *ecdsa.PublicKey:
+		return CryptoTypeEcdsa, k.Curve.Params().BitSize, nil
+	case ed25519.PublicKey:
+		return CryptoTypeEd25519, 256, nil
+	default:
+		return "", 0, fmt.Errorf("unknown crypto type")
+	}
+}
+
 func SaveCertificate(cert *x509.Certificate, caFullchain []byte, cert1SavePath, cert2SavePath, fullchain1SavePath, fullchain2SavePath string) error {
 	// 将证书转换为 PEM 格式
 	certPEM := pem.EncodeToMemory(&pem.Block{
@@ -144,6 +267,10 @@ func SaveSPX(key crypto.PrivateKey, priPasswordStr string, cert *x509.Certificat
 func SavePFX(key crypto.PrivateKey, priPasswordStr string, cert *x509.Certificate, caFullchain []byte, savePath string) error {
 	priPasswordStr = strings.TrimSpace(priPasswordStr)
 
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		return ErrPFXUnsupportedKey
+	}
+
 	if len(priPasswordStr) != 0 && !isValidPassword(priPasswordStr) {
 		return fmt.Errorf("password is invalid")
 	}
@@ -165,9 +292,9 @@ func SavePFX(key crypto.PrivateKey, priPasswordStr string, cert *x509.Certificat
 		chainCerts = append(chainCerts, cert)
 	}
 
-	pfxData, err := pkcs12.LegacyRC2.WithRand(rand.Reader).Encode(key, cert, chainCerts, priPasswordStr)
+	pfxData, err := pkcs12.LegacyRC2.WithRand(Rander()).Encode(key, cert, chainCerts, priPasswordStr)
 	if err != nil {
-		log.Fatalf("Failed to create PKCS #12 data: %v", err)
+		return fmt.Errorf("failed to create PKCS #12 data: %s", err.Error())
 	}
 
 	err = os.WriteFile(savePath, pfxData, 0600)
@@ -211,14 +338,89 @@ func ParserPrivateKey(derData []byte, pwVargs ...string) (key crypto.PrivateKey,
 		return nil, "", err
 	}
 
-	switch key.(type) {
-	case *rsa.PrivateKey:
-		return key, CryptoTypeRsa, nil
-	case *ecdsa.PrivateKey:
-		return key, CryptoTypeEcdsa, nil
+	keyType, _, err = GetCryptoType(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, keyType, nil
+}
+
+const (
+	PemTypeRSAPrivateKey = "RSA PRIVATE KEY" // PKCS#1
+	PemTypeECPrivateKey  = "EC PRIVATE KEY"  // SEC1
+)
+
+// ParseAnyPrivateKey 解析PEM或DER格式的私钥：PKCS#8（可加密）、PKCS#1（RSA）以及SEC1（ECDSA，包括旧式的PEM加密）
+// PEM文件中可以包含证书等其他块，使用第一个私钥块；私钥加密时调用passwordFunc读取密码
+func ParseAnyPrivateKey(data []byte, passwordFunc func() string) (crypto.PrivateKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		for rest := data; block != nil; block, rest = pem.Decode(rest) {
+			if strings.HasSuffix(block.Type, PemTypePrivateKeyNotPassword) {
+				return parsePrivateKeyPemBlock(block, passwordFunc)
+			}
+		}
+		return nil, fmt.Errorf("no private key found")
+	}
+
+	if key, _, err := ParserPrivateKey(data); err == nil {
+		return key, nil
+	} else if key, err := x509.ParsePKCS1PrivateKey(data); err == nil {
+		return checkPrivateKey(key)
+	} else if key, err := x509.ParseECPrivateKey(data); err == nil {
+		return checkPrivateKey(key)
+	}
+
+	key, _, err := ParserPrivateKey(data, passwordFunc()) // DER格式的加密PKCS#8私钥
+	if err != nil {
+		return nil, fmt.Errorf("not a PKCS#8, PKCS#1 or SEC1 private key (%s)", err.Error())
+	}
+
+	return key, nil
+}
+
+func parsePrivateKeyPemBlock(block *pem.Block, passwordFunc func() string) (crypto.PrivateKey, error) {
+	switch block.Type {
+	case PemTypePrivateKeyNotPassword:
+		key, _, err := ParserPrivateKey(block.Bytes)
+		return key, err
+	case PemTypePrivateKeyWithPassword:
+		key, _, err := ParserPrivateKey(block.Bytes, passwordFunc())
+		return key, err
+	case PemTypeRSAPrivateKey, PemTypeECPrivateKey:
+		der := block.Bytes
+		if x509.IsEncryptedPEMBlock(block) { // OpenSSL使用旧式的PEM加密（RFC 1423）保存PKCS#1和SEC1私钥
+			var err error
+			der, err = x509.DecryptPEMBlock(block, []byte(strings.TrimSpace(passwordFunc())))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var key crypto.PrivateKey
+		var err error
+		if block.Type == PemTypeRSAPrivateKey {
+			key, err = x509.ParsePKCS1PrivateKey(der)
+		} else {
+			key, err = x509.ParseECPrivateKey(der)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return checkPrivateKey(key)
 	default:
-		return nil, "", fmt.Errorf("unknown crypto type")
+		return nil, fmt.Errorf("unknown private key type: %s", block.Type)
+	}
+}
+
+// checkPrivateKey 检查私钥的算法和长度是否被MyCA支持
+func checkPrivateKey(key crypto.PrivateKey) (crypto.PrivateKey, error) {
+	_, _, err := GetCryptoType(key)
+	if err != nil {
+		return nil, err
 	}
+	return key, nil
 }
 
 func isValidPassword(password string) bool {
@@ -241,3 +443,56 @@ func CalculateSubjectKeyIdentifier(publicKey crypto.PublicKey) (string, error) {
 
 	return skiHex, nil
 }
+
+// CheckKeyPair 检查私钥与证书的公钥是否匹配
+func CheckKeyPair(cert *x509.Certificate, key crypto.PrivateKey) error {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("unknown private key type")
+	}
+
+	pubKey, ok := signer.Public().(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+	if !ok || !pubKey.Equal(cert.PublicKey) {
+		return fmt.Errorf("the private key does not match the certificate")
+	}
+
+	return nil
+}
+
+// ReadCertificates 读取PEM（可包含多个证书）或DER格式的证书
+func ReadCertificates(filePath string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCertificates(data)
+}
+
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	block, rest := pem.Decode(data)
+	if block == nil {
+		return x509.ParseCertificates(data)
+	}
+
+	res := make([]*x509.Certificate, 0, 3)
+	for block != nil {
+		if block.Type == PemTypeCertificate {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, cert)
+		}
+
+		block, rest = pem.Decode(rest)
+	}
+
+	if len(res) == 0 {
+		return nil, fmt.Errorf("not certificate found")
+	}
+
+	return res, nil
+}